@@ -0,0 +1,8 @@
+//go:build !lite
+
+package cmd
+
+// liteBuild is true when built with -tags lite. Features with heavy
+// dependencies (interactive UIs, rich report renderers, enrichment lookups)
+// live in files tagged !lite so lite binaries do not link them.
+const liteBuild = false
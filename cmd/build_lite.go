@@ -0,0 +1,5 @@
+//go:build lite
+
+package cmd
+
+const liteBuild = true
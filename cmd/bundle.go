@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"dnsdoc/internal/bundle"
+	"dnsdoc/internal/capture"
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	bundleOut      string
+	bundlePcap     string
+	bundlePcapPort uint16
+)
+
+// bundleManifest describes a bundle: what was run, where, and its outcome.
+type bundleManifest struct {
+	Created     time.Time     `json:"created"`
+	Command     []string      `json:"command"`
+	ExitCode    int           `json:"exit_code"`
+	Took        time.Duration `json:"took_ns"`
+	Environment bundleEnv     `json:"environment"`
+	Files       []string      `json:"files"`
+	Notes       []string      `json:"notes,omitempty"`
+}
+
+type bundleEnv struct {
+	Dnsdoc    string                    `json:"dnsdoc"`
+	Go        string                    `json:"go"`
+	OS        string                    `json:"os"`
+	Arch      string                    `json:"arch"`
+	Lite      bool                      `json:"lite_build"`
+	CGO       bool                      `json:"cgo"`
+	Hostname  string                    `json:"hostname,omitempty"`
+	Resolvers []dnsprobe.SystemResolver `json:"system_resolvers,omitempty"`
+}
+
+// bundleResultsEnv names the file a bundled command writes its JSON results
+// to, next to its text report; see saveBundleResults.
+const bundleResultsEnv = envPrefix + "BUNDLE_RESULTS"
+
+const (
+	bundleManifestFile = "manifest.json"
+	bundleReportFile   = "report.txt"
+	bundleStderrFile   = "stderr.txt"
+	bundleResultsFile  = "results.json"
+	bundlePcapFile     = "capture.pcap"
+)
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle [flags] -- <command> [args...]",
+	Short: "Run a dnsdoc command and pack its report, JSON results (for commands with --output), environment details and optionally a pcap of the DNS traffic into one .tgz for support tickets; open it again with dnsdoc view.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sub, _, err := rootCmd.Find(args)
+		if err != nil || sub == rootCmd {
+			return fmt.Errorf("unknown command %q", args[0])
+		}
+		if sub == cmd || sub.Name() == "view" {
+			return fmt.Errorf("%s cannot be bundled", sub.Name())
+		}
+		if bundleOut == "" {
+			bundleOut = "dnsdoc-" + sub.Name() + "-" + time.Now().Format("20060102-150405") + ".tgz"
+		}
+		self, err := os.Executable()
+		if err != nil {
+			return err
+		}
+
+		// Global flags given to bundle apply to the bundled command too;
+		// --lite keeps colors out of the report.
+		childArgs := slices.Clone(args)
+		cmd.InheritedFlags().Visit(func(f *pflag.Flag) {
+			if f.Name != "lite" {
+				childArgs = append(childArgs, "--"+f.Name+"="+f.Value.String())
+			}
+		})
+		childArgs = append(childArgs, "--lite")
+
+		man := bundleManifest{Created: time.Now(), Command: append([]string{"dnsdoc"}, args...), Environment: bundleEnvironment()}
+
+		var pcap bytes.Buffer
+		var stopCapture func() error
+		if bundlePcap != "" {
+			if stopCapture, err = startBundleCapture(bundlePcap, bundlePcapPort, &pcap); err != nil {
+				return err
+			}
+		}
+
+		// Commands with --output write the JSON results of the same run to
+		// a file alongside their text report.
+		var env []string
+		var resultsPath string
+		if sub.Flags().Lookup("output") != nil && !slices.ContainsFunc(args, func(a string) bool { return a == "--output" || len(a) > 9 && a[:9] == "--output=" }) {
+			f, err := os.CreateTemp("", "dnsdoc-results-*.json")
+			if err != nil {
+				return err
+			}
+			f.Close()
+			resultsPath = f.Name()
+			defer os.Remove(resultsPath)
+			env = append(env, bundleResultsEnv+"="+resultsPath)
+		}
+
+		start := time.Now()
+		var report, stderr bytes.Buffer
+		man.ExitCode, err = runBundled(self, childArgs, env, io.MultiWriter(os.Stdout, &report), io.MultiWriter(os.Stderr, &stderr))
+		man.Took = time.Since(start)
+		if err != nil {
+			return err
+		}
+
+		files := []bundle.File{{Name: bundleReportFile, Data: report.Bytes()}}
+		if stderr.Len() > 0 {
+			files = append(files, bundle.File{Name: bundleStderrFile, Data: stderr.Bytes()})
+		}
+		if resultsPath != "" {
+			results, err := os.ReadFile(resultsPath)
+			switch {
+			case err != nil:
+				return err
+			case len(results) > 0:
+				files = append(files, bundle.File{Name: bundleResultsFile, Data: results})
+			default:
+				man.Notes = append(man.Notes, "no "+bundleResultsFile+": the command ended before writing its results")
+			}
+		}
+
+		if stopCapture != nil {
+			if err := stopCapture(); err != nil {
+				man.Notes = append(man.Notes, "capture: "+err.Error())
+			}
+			files = append(files, bundle.File{Name: bundlePcapFile, Data: pcap.Bytes()})
+		}
+
+		man.Files = []string{bundleManifestFile}
+		for _, f := range files {
+			man.Files = append(man.Files, f.Name)
+		}
+		manifest, err := json.MarshalIndent(man, "", "  ")
+		if err != nil {
+			return err
+		}
+		files = append([]bundle.File{{Name: bundleManifestFile, Data: manifest}}, files...)
+		if err := bundle.Write(bundleOut, files); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "\nbundle written to %s (%d files); open it with: dnsdoc view %s\n", bundleOut, len(files), bundleOut)
+		if man.ExitCode != 0 {
+			return exitError{code: man.ExitCode, err: fmt.Errorf("%s exited with status %d", sub.Name(), man.ExitCode)}
+		}
+		return nil
+	},
+}
+
+func init() {
+	bundleCmd.Flags().StringVarP(&bundleOut, "out", "o", "", "Archive to write (default dnsdoc-<command>-<time>.tgz).")
+	bundleCmd.Flags().StringVar(&bundlePcap, "pcap", "", "Also capture the DNS traffic on this interface during the run into capture.pcap (Linux, needs root or CAP_NET_RAW).")
+	bundleCmd.Flags().Uint16Var(&bundlePcapPort, "pcap-port", 53, "Port whose UDP and TCP traffic --pcap captures.")
+}
+
+// runBundled runs dnsdoc with args and env added to the environment and
+// returns its exit status; err is only set when it could not be run at all.
+func runBundled(self string, args, env []string, stdout, stderr io.Writer) (int, error) {
+	c := exec.Command(self, args...)
+	c.Env = append(os.Environ(), env...)
+	c.Stdout, c.Stderr = stdout, stderr
+	err := c.Run()
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return ee.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// bundlingResults reports whether dnsdoc bundle asked for the JSON results
+// of this run.
+func bundlingResults() bool {
+	return os.Getenv(bundleResultsEnv) != ""
+}
+
+// saveBundleResults writes v, the --output json form of this run's report,
+// where dnsdoc bundle asked for it; outside a bundle it does nothing.
+func saveBundleResults(v any) error {
+	path := os.Getenv(bundleResultsEnv)
+	if path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+func startBundleCapture(iface string, port uint16, w io.Writer) (stop func() error, err error) {
+	src, err := capture.Open(iface, capture.PortFilter(port))
+	if err != nil {
+		return nil, err
+	}
+	pw, err := capture.NewPcapWriter(w)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	var werr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			at, frame, err := src.ReadFrame()
+			if err != nil {
+				return
+			}
+			if err := pw.WriteFrame(at, frame); err != nil {
+				werr = err
+				return
+			}
+		}
+	}()
+	return func() error {
+		// Let the last replies arrive.
+		time.Sleep(200 * time.Millisecond)
+		src.Close()
+		wg.Wait()
+		return werr
+	}, nil
+}
+
+func bundleEnvironment() bundleEnv {
+	env := bundleEnv{Dnsdoc: "unknown", Go: runtime.Version(), OS: runtime.GOOS, Arch: runtime.GOARCH, Lite: liteBuild, CGO: cgoEnabled}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		env.Dnsdoc = bi.Main.Version
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" {
+				env.Dnsdoc += " (" + s.Value + ")"
+			}
+		}
+	}
+	env.Hostname, _ = os.Hostname()
+	env.Resolvers, _ = dnsprobe.SystemResolvers()
+	return env
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	caaServer   string
+	caaCA       string
+	caaWildcard bool
+)
+
+var caaCmd = &cobra.Command{
+	Use:   "caa <domain>",
+	Short: "Find the CAA records that govern certificate issuance for a domain, walking up the name tree as a CA does, validate their syntax and show which CAs may issue.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var serverArgs []string
+		if caaServer != "" {
+			serverArgs = []string{caaServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+
+		p, err := dnsprobe.LookupCAA(cmd.Context(), server, args[0], baseOptions())
+		if err != nil {
+			return err
+		}
+
+		au := newAurora()
+		fmt.Printf("\n=== caa: %s via %s ===\n", p.Domain, server)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "name\tresult")
+		for i, l := range p.Levels {
+			res := "no CAA records"
+			if len(l.Records) > 0 {
+				res = fmt.Sprintf("%d record(s), relevant", len(l.Records))
+			}
+			if l.Alias != "" {
+				res += " (via CNAME " + l.Alias + ")"
+			}
+			if i == p.Relevant {
+				res = au.Bold(res).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\n", l.Name, res)
+		}
+		_ = w.Flush()
+
+		var invalid int
+		if set := p.Set(); len(set) > 0 {
+			fmt.Printf("\nRecords:\n")
+			w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "flags\ttag\tvalue\tstatus")
+			for _, r := range set {
+				unknown, err := dnsprobe.CheckCAA(r)
+				status := au.Green("ok").String()
+				switch {
+				case err != nil:
+					invalid++
+					status = au.Red(err.Error()).String()
+				case unknown && r.Flag&128 != 0:
+					invalid++
+					status = au.Red("unknown critical tag: CAs refuse to issue").String()
+				case unknown:
+					status = au.Yellow("unknown tag, ignored").String()
+				}
+				fmt.Fprintf(w, "%d\t%s\t%q\t%s\n", r.Flag, r.Tag, r.Value, status)
+			}
+			_ = w.Flush()
+		}
+
+		issue, restricted := p.Issuers(false)
+		wild, wildRestricted := p.Issuers(true)
+		fmt.Printf("\nissue:\t%s\n", caaIssuers(issue, restricted))
+		fmt.Printf("issuewild:\t%s\n", caaIssuers(wild, wildRestricted))
+
+		if invalid > 0 {
+			return fmt.Errorf("%d invalid CAA record(s)", invalid)
+		}
+		if caaCA != "" {
+			issuers, restricted := issue, restricted
+			if caaWildcard {
+				issuers, restricted = wild, wildRestricted
+			}
+			ca := strings.ToLower(strings.TrimSuffix(caaCA, "."))
+			if restricted && !slices.Contains(issuers, ca) {
+				fmt.Printf("\n%s:\t%s\n", ca, au.Red("may not issue"))
+				return exitError{code: exitMismatch, err: fmt.Errorf("CAA does not allow %s to issue for %s", ca, p.Domain)}
+			}
+			fmt.Printf("\n%s:\t%s\n", ca, au.Green("may issue"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	caaCmd.Flags().StringVar(&caaServer, "server", "", "DNS server to resolve the records with (default: system resolver).")
+	caaCmd.Flags().StringVar(&caaCA, "ca", "", "Check whether this CA (by its CAA domain, e.g. letsencrypt.org) may issue; exits with status 2 if not.")
+	caaCmd.Flags().BoolVar(&caaWildcard, "wildcard", false, "With --ca, check issuance of a wildcard certificate (issuewild).")
+}
+
+func caaIssuers(issuers []string, restricted bool) string {
+	switch {
+	case !restricted:
+		return "any CA"
+	case len(issuers) == 0:
+		return "no CA"
+	}
+	return strings.Join(issuers, ", ")
+}
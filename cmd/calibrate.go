@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	calibrateSamples int
+	calibrateGateway bool
+	calibrateSave    bool
+)
+
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate [dns-server]",
+	Short: "Measure this machine's timing noise floor against an in-process loopback server, the LAN gateway and optionally a given (e.g. mock) server, and save it so latency reports can mark differences below it as noise.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if calibrateSamples < 10 {
+			return fmt.Errorf("--samples must be >= 10")
+		}
+		ctx := cmd.Context()
+		// The loopback server is reached directly, over the same protocol
+		// as the other targets.
+		opts := baseOptions()
+		opts.TCP = opts.TCP || opts.Proxy != ""
+		opts.Proxy, opts.Family = "", 0
+		lo, err := dnsprobe.StartLoopbackServer(opts.TCP)
+		if err != nil {
+			return err
+		}
+		defer lo.Close()
+		cals := []dnsprobe.Calibration{dnsprobe.Calibrate(ctx, dnsprobe.LoopbackTarget, lo.Addr, opts, calibrateSamples)}
+
+		opts = baseOptions()
+		if calibrateGateway {
+			if gw, err := dnsprobe.DefaultGateway(); err != nil {
+				fmt.Fprintf(os.Stderr, "gateway: %v\n", err)
+			} else {
+				cals = append(cals, dnsprobe.Calibrate(ctx, "gateway", net.JoinHostPort(gw.String(), "53"), opts, calibrateSamples))
+			}
+		}
+		if len(args) == 1 {
+			cals = append(cals, dnsprobe.Calibrate(ctx, "server", args[0], opts, calibrateSamples))
+		}
+
+		au := newAurora()
+		for _, c := range cals {
+			fmt.Printf("\n=== noise floor: %s (%s) ===\n", c.Target, c.Server)
+			if len(c.Phases) == 0 {
+				fmt.Printf("%s no replies; skipped\n", au.Yellow("warning:"))
+				continue
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "phase\tp50\tp95\tjitter")
+			for _, p := range c.Phases {
+				fmt.Fprintf(w, "%s\t%s\t%s\t±%s\n", p.Phase, p.P50, p.P95, p.Jitter)
+			}
+			_ = w.Flush()
+			fmt.Printf("samples:\t%d (%d failed)\n", c.Samples, c.Failed)
+		}
+		fmt.Println("\njitter is p95-p50 over identical queries: differences smaller than it are measurement noise, not the resolver.")
+
+		if !calibrateSave {
+			return nil
+		}
+		path, err := calibrationPath()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(cals, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("saved to %s; latency reports now mark differences within the loopback jitter as noise\n", path)
+		return nil
+	},
+}
+
+func init() {
+	calibrateCmd.Flags().IntVar(&calibrateSamples, "samples", 200, "Identical queries sent to every target.")
+	calibrateCmd.Flags().BoolVar(&calibrateGateway, "gateway", true, "Also measure DNS on the default gateway (Linux), which most home and office routers forward.")
+	calibrateCmd.Flags().BoolVar(&calibrateSave, "save", true, "Save the result for later reports (<user config dir>/dnsdoc/calibration.json).")
+}
+
+func calibrationPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dnsdoc", "calibration.json"), nil
+}
+
+// loadNoiseFloor returns the saved loopback calibration, or nil when
+// calibrate has not been run.
+func loadNoiseFloor() *dnsprobe.Calibration {
+	path, err := calibrationPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "note: ignoring calibration: %v\n", err)
+		}
+		return nil
+	}
+	var cals []dnsprobe.Calibration
+	if err := json.Unmarshal(data, &cals); err != nil {
+		fmt.Fprintf(os.Stderr, "note: ignoring calibration %s: %v\n", path, err)
+		return nil
+	}
+	for _, c := range cals {
+		if c.Target == dnsprobe.LoopbackTarget && len(c.Phases) > 0 {
+			return &c
+		}
+	}
+	return nil
+}
+
+// noiseFloorNote describes c for the foot of a report.
+func noiseFloorNote(c *dnsprobe.Calibration) string {
+	return fmt.Sprintf("noise floor:\t±%s total, ±%s rtt (loopback, calibrated %s); smaller differences are measurement noise",
+		c.Floor("total"), c.Floor("rtt(approx)"), c.At.Format(time.DateOnly))
+}
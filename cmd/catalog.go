@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	catalogConsumers   string
+	catalogConcurrency int
+)
+
+var catalogCmd = &cobra.Command{
+	Use:   "catalog <catalog-zone> <primary>",
+	Short: "Transfer a catalog zone (RFC 9432) from its primary by AXFR, list its member zones and their properties, and with --consumers check that every secondary serves every member at the primary's serial.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var consumers []string
+		for _, s := range strings.Split(catalogConsumers, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				consumers = append(consumers, s)
+			}
+		}
+
+		c, err := dnsprobe.InspectCatalog(cmd.Context(), args[1], args[0], consumers, baseOptions(), catalogConcurrency)
+		if err != nil {
+			return err
+		}
+
+		au := newAurora()
+		fmt.Printf("\n=== catalog: %s from %s ===\n", c.Zone, c.Server)
+		fmt.Printf("serial:\t%d\nversion:\t%s\nmembers:\t%d\n\n", c.Serial, c.Version, len(c.Members))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		header := "zone\tid\tgroup\tcoo"
+		if len(consumers) > 0 {
+			header += "\tserial\tconsumers"
+		}
+		fmt.Fprintln(w, header)
+		var unserved int
+		for _, m := range c.Members {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s", m.Zone, m.ID, orDash(strings.Join(m.Groups, ", ")), orDash(m.COO))
+			if len(m.Serving) > 0 {
+				serial := "-"
+				if p := m.Serving[0]; p.HasSerial {
+					serial = fmt.Sprint(p.Serial)
+				}
+				var bad []string
+				for i, s := range m.Serving[1:] {
+					switch {
+					case s.Err != nil:
+						bad = append(bad, fmt.Sprintf("%s: %v", s.Server, s.Err))
+					case m.Behind(i + 1):
+						bad = append(bad, fmt.Sprintf("%s: behind at %d", s.Server, s.Serial))
+					}
+				}
+				status := au.Green(fmt.Sprintf("%d/%d current", len(consumers)-len(bad), len(consumers))).String()
+				if len(bad) > 0 {
+					unserved++
+					status = au.Red(strings.Join(bad, "; ")).String()
+				}
+				fmt.Fprintf(w, "\t%s\t%s", serial, status)
+			}
+			fmt.Fprintln(w)
+		}
+		_ = w.Flush()
+
+		var ext bool
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, m := range c.Members {
+			for _, e := range m.Ext {
+				if !ext {
+					fmt.Printf("\nCustom properties:\n")
+					fmt.Fprintln(w, "zone\tproperty")
+					ext = true
+				}
+				fmt.Fprintf(w, "%s\t%s\n", m.Zone, e)
+			}
+		}
+		_ = w.Flush()
+		for _, p := range c.Problems {
+			fmt.Printf("%s %s\n", au.Red("problem:"), p)
+		}
+
+		if unserved > 0 {
+			fmt.Printf("%s %d member zone(s) missing or behind on a consumer; check that it has transferred the catalog\n", au.Red("mismatch:"), unserved)
+		}
+		switch {
+		case len(c.Problems) > 0:
+			return fmt.Errorf("%d problem(s) in catalog %s", len(c.Problems), c.Zone)
+		case unserved > 0:
+			return exitError{code: exitMismatch, err: fmt.Errorf("%d member zone(s) of %s not served everywhere", unserved, c.Zone)}
+		case len(consumers) > 0:
+			fmt.Printf("%s every consumer serves every member zone\n", au.Green("in sync:"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	catalogCmd.Flags().StringVar(&catalogConsumers, "consumers", "", "CSV of secondaries consuming the catalog, asked for each member zone's SOA.")
+	catalogCmd.Flags().IntVar(&catalogConcurrency, "concurrency", 16, "SOA queries to the primary and consumers in flight at once.")
+}
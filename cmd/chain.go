@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+)
+
+var (
+	chainDomains     string
+	chainDomainsFile string
+	chainType        string
+	chainMaxHops     int
+	chainLong        int
+)
+
+var chainCmd = &cobra.Command{
+	Use:   "chain [dns-server]",
+	Short: "Follow CNAME chains one alias at a time against the same resolver and show every hop with its latency and TTL, flagging long or looping chains.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		domains := dataSet.Domains
+		switch {
+		case chainDomainsFile != "":
+			domains, err = readDomainsFile(chainDomainsFile)
+		case strings.TrimSpace(chainDomains) != "":
+			domains, err = parseDomains(chainDomains)
+		}
+		if err != nil {
+			return err
+		}
+		qtype, ok := dns.StringToType[strings.ToUpper(chainType)]
+		if !ok {
+			return fmt.Errorf("unknown record type %q", chainType)
+		}
+		if chainMaxHops < 1 {
+			return fmt.Errorf("--max-hops must be >= 1")
+		}
+		opts := baseOptions()
+		opts.Type = qtype
+
+		ctx := cmd.Context()
+		au := newAurora()
+		var loops, long int
+		for i, name := range domains {
+			c := dnsprobe.FollowChain(ctx, server, name, opts, chainMaxHops)
+			if len(c.Hops) == 0 || dnsprobe.CutShort(ctx, c.Hops[len(c.Hops)-1].Err) {
+				fmt.Println()
+				printSkipped(ctx, au, "name", domains[i:])
+				domains = domains[:i]
+				break
+			}
+			fmt.Printf("\n=== %s via %s ===\n", name, server)
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "hop\tname\trecord\tttl\trtt")
+			for i, h := range c.Hops {
+				switch {
+				case h.Err != nil:
+					fmt.Fprintf(w, "%d\t%s\t%s\t-\t%s\n", i+1, h.Name, au.Red(fmt.Sprintf("error: %v", h.Err)), h.RTT)
+				case h.Target != "":
+					fmt.Fprintf(w, "%d\t%s\tCNAME %s\t%d\t%s\n", i+1, h.Name, h.Target, h.TTL, h.RTT)
+				case len(h.Values) > 0:
+					fmt.Fprintf(w, "%d\t%s\t%s %s\t%d\t%s\n", i+1, h.Name, dns.TypeToString[qtype], strings.Join(h.Values, ","), h.TTL, h.RTT)
+				default:
+					fmt.Fprintf(w, "%d\t%s\t%s (no %s)\t-\t%s\n", i+1, h.Name, h.RCode, dns.TypeToString[qtype], h.RTT)
+				}
+			}
+			_ = w.Flush()
+
+			fmt.Printf("chain:\t%d alias(es), %d queries, %s in total", c.Aliases(), len(c.Hops), c.Took())
+			if len(c.Hops[len(c.Hops)-1].Values) > 0 {
+				fmt.Printf(", cacheable for %ds", c.TTL())
+			}
+			fmt.Println()
+			switch {
+			case c.Loop:
+				loops++
+				fmt.Printf("%s %s points back to an earlier name; resolvers will fail this lookup\n", au.Red("loop:"), c.Hops[len(c.Hops)-1].Name)
+			case c.Truncated:
+				long++
+				fmt.Printf("%s stopped after %d hops without reaching the end (see --max-hops); many resolvers give up on chains this long\n", au.Red("too long:"), chainMaxHops)
+			case c.Aliases() > chainLong:
+				long++
+				fmt.Printf("%s more than %d aliases; every hop is another lookup on a cold cache\n", au.Yellow("long chain:"), chainLong)
+			}
+		}
+		if len(domains) > 1 && loops+long > 0 {
+			fmt.Printf("\nflagged:\t%d looping, %d long of %d chains\n", loops, long, len(domains))
+		}
+		return nil
+	},
+}
+
+func init() {
+	chainCmd.Flags().StringVar(&chainDomains, "domains", "", "CSV of names to follow (overrides the built-in popular set).")
+	chainCmd.Flags().StringVar(&chainDomainsFile, "domains-file", "", "File with one name per line (# comments allowed).")
+	chainCmd.Flags().StringVar(&chainType, "type", "A", "Record type the chain should end in.")
+	chainCmd.Flags().IntVar(&chainMaxHops, "max-hops", 16, "Give up after this many queries per name and flag the chain as too long.")
+	chainCmd.Flags().IntVar(&chainLong, "long", 3, "Flag chains with more than this many aliases as long.")
+}
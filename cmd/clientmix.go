@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	clientmixMix           string
+	clientmixRate          float64
+	clientmixDuration      time.Duration
+	clientmixDomains       string
+	clientmixSearchDomains string
+)
+
+var clientmixCmd = &cobra.Command{
+	Use:   "clientmix [dns-server]",
+	Short: "Load a resolver the way a population of Windows, macOS, Android and iOS clients would: their A/AAAA parallelism, retry timers and search list handling, with per-platform results.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		if clientmixRate <= 0 || clientmixDuration <= 0 {
+			return fmt.Errorf("--rate and --duration must be > 0")
+		}
+		if err := guard(guardedRun{mode: "clientmix", servers: []string{server}, rate: clientmixRate, duration: clientmixDuration}); err != nil {
+			return err
+		}
+		mix, err := dnsprobe.ParseClientMix(clientmixMix)
+		if err != nil {
+			return err
+		}
+		domains, err := parseDomains(clientmixDomains)
+		if err != nil {
+			return err
+		}
+		search, _ := dnsprobe.SystemSearchConfig()
+		if clientmixSearchDomains != "" {
+			search.Search = nil
+			for _, d := range strings.Split(clientmixSearchDomains, ",") {
+				if d = strings.TrimSpace(d); d != "" {
+					search.Search = append(search.Search, d)
+				}
+			}
+		}
+		if search.Ndots < 1 {
+			search.Ndots = 1
+		}
+
+		var shares []string
+		for _, s := range mix {
+			shares = append(shares, fmt.Sprintf("%s=%d", s.Profile.Name, s.Weight))
+		}
+		fmt.Printf("clientmix: server=%s rate=%g lookups/s duration=%s mix=%s search=%s\n",
+			server, clientmixRate, clientmixDuration, strings.Join(shares, ","), orDash(strings.Join(search.Search, ",")))
+
+		ctx := cmd.Context()
+		stats := dnsprobe.RunClientMix(ctx, dnsprobe.ClientMixConfig{
+			Server:   server,
+			Domains:  domains,
+			Search:   search,
+			Options:  baseOptions(),
+			Mix:      mix,
+			Rate:     clientmixRate,
+			Duration: clientmixDuration,
+		})
+
+		au := newAurora()
+		fmt.Printf("\n=== client mix: %s ===\n", server)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "client\tlookups\tanswered\tnegative\tfailed\tqueries/lookup\tretries\tp50\tp95\tmax")
+		for _, st := range stats {
+			failed := fmt.Sprint(st.Failed)
+			if st.Failed > 0 {
+				failed = au.Red(failed).String()
+			}
+			perLookup := "-"
+			if st.Lookups > 0 {
+				perLookup = fmt.Sprintf("%.2f", float64(st.Queries)/float64(st.Lookups))
+			}
+			lat := "-\t-\t-"
+			if st.Answered+st.Negative > 0 {
+				lat = fmt.Sprintf("%s\t%s\t%s", st.P50.Round(time.Microsecond), st.P95.Round(time.Microsecond), st.Max.Round(time.Microsecond))
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t%d\t%s\n", st.Profile, st.Lookups, st.Answered, st.Negative, failed, perLookup, st.Retries, lat)
+		}
+		_ = w.Flush()
+		fmt.Println("\nqueries/lookup counts every packet a lookup sent (both families, search candidates and retries); retries are the resends after a timeout.")
+		if ctx.Err() != nil {
+			fmt.Printf("%s the results above cover the run until then; lookups cut short are left out\n", au.Yellow(stopReason(ctx)+":"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	clientmixCmd.Flags().StringVar(&clientmixMix, "mix", "windows=40,android=30,ios=20,macos=10", "Client platforms and their relative weights (windows, macos, android, ios).")
+	clientmixCmd.Flags().Float64Var(&clientmixRate, "rate", 20, "Lookups started per second across all clients.")
+	clientmixCmd.Flags().DurationVar(&clientmixDuration, "duration", 30*time.Second, "How long to generate lookups.")
+	clientmixCmd.Flags().StringVar(&clientmixDomains, "domains", "", "CSV of names clients look up (overrides the default set); unqualified names exercise the search list.")
+	clientmixCmd.Flags().StringVar(&clientmixSearchDomains, "search-domains", "", "CSV search list clients apply instead of the system one.")
+}
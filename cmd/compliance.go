@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+)
+
+var (
+	complianceName   string
+	complianceOutput string
+)
+
+// complianceJSON is one --output json result. Code and Remediation are set
+// for every result that is not strict.
+type complianceJSON struct {
+	Check       string                `json:"check"`
+	Desc        string                `json:"desc"`
+	Expected    []string              `json:"expected"`
+	RCode       string                `json:"rcode,omitempty"`
+	Answers     int                   `json:"answers"`
+	RTT         time.Duration         `json:"rtt_ns"`
+	Verdict     string                `json:"verdict"`
+	Error       string                `json:"error,omitempty"`
+	Code        string                `json:"code,omitempty"`
+	Remediation *dnsprobe.Remediation `json:"remediation,omitempty"`
+}
+
+// complianceReportJSON is the --output json report. Skipped lists the
+// checks a run ended early did not get to.
+type complianceReportJSON struct {
+	Server  string           `json:"server"`
+	Name    string           `json:"name"`
+	Results []complianceJSON `json:"results"`
+	Skipped []string         `json:"skipped,omitempty"`
+}
+
+var complianceCmd = &cobra.Command{
+	Use:   "compliance [dns-server]",
+	Short: "Send unusual opcodes (STATUS/NOTIFY/UPDATE/...) and rare qtypes and report how strictly the resolver rejects them.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(complianceOutput); err != nil {
+			return err
+		}
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		results := dnsprobe.RunCompliance(ctx, server, complianceName, baseOptions(), dnsprobe.ComplianceChecks)
+		results, skipped := splitSkipped(ctx, results, func(r dnsprobe.ComplianceResult) (string, error) { return r.Check.Name, r.Err })
+		if complianceOutput == outputJSON {
+			return printJSON(newComplianceJSON(server, results, skipped))
+		}
+		au := newAurora()
+		printComplianceReport(au, server, results)
+		printSkipped(ctx, au, "check", skipped)
+		return saveBundleResults(newComplianceJSON(server, results, skipped))
+	},
+}
+
+func init() {
+	complianceCmd.Flags().StringVar(&complianceName, "name", "example.com", "Query name used by the checks.")
+	complianceCmd.Flags().StringVar(&complianceOutput, "output", outputText, "Report format: text, or json with stable finding codes and structured remediation for each non-strict result.")
+}
+
+func newComplianceJSON(server string, results []dnsprobe.ComplianceResult, skipped []string) complianceReportJSON {
+	out := complianceReportJSON{Server: server, Name: complianceName, Skipped: skipped}
+	for _, r := range results {
+		j := complianceJSON{
+			Check:       r.Check.Name,
+			Desc:        r.Check.Desc,
+			Expected:    rcodeNames(r.Check.Expect),
+			RCode:       r.RCode,
+			Answers:     r.Answers,
+			RTT:         r.RTT,
+			Verdict:     string(r.Verdict),
+			Code:        r.Code(),
+			Remediation: r.Remediation(complianceName),
+		}
+		if r.Err != nil {
+			j.Error = r.Err.Error()
+		}
+		out.Results = append(out.Results, j)
+	}
+	return out
+}
+
+func printComplianceReport(au *aurora.Aurora, server string, results []dnsprobe.ComplianceResult) {
+	fmt.Printf("\n=== compliance: %s ===\n", server)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "check\texpected\tgot\trtt\tverdict\tnotes")
+
+	counts := map[dnsprobe.ComplianceVerdict]int{}
+	for _, r := range results {
+		counts[r.Verdict]++
+
+		got, notes := complianceGot(r)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Check.Name, expectedRCodes(r.Check.Expect), got, r.RTT, colorVerdict(au, r.Verdict), notes)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\nsummary:\tstrict=%d permissive=%d silent=%d error=%d\n",
+		counts[dnsprobe.VerdictStrict], counts[dnsprobe.VerdictPermissive], counts[dnsprobe.VerdictSilent], counts[dnsprobe.VerdictError])
+	if counts[dnsprobe.VerdictSilent] > 0 {
+		fmt.Printf("silent checks got no reply at all; a middlebox on the path may be dropping or choking on that traffic.\n")
+	}
+}
+
+// complianceGot describes what the server answered, and notes the check or
+// the error.
+func complianceGot(r dnsprobe.ComplianceResult) (got, notes string) {
+	switch {
+	case r.Err != nil:
+		return "-", r.Err.Error()
+	case r.Answers > 0:
+		return fmt.Sprintf("%s (%d answers)", r.RCode, r.Answers), r.Check.Desc
+	}
+	return r.RCode, r.Check.Desc
+}
+
+func expectedRCodes(rcodes []int) string {
+	return strings.Join(rcodeNames(rcodes), "/")
+}
+
+func rcodeNames(rcodes []int) []string {
+	names := make([]string, 0, len(rcodes))
+	for _, rc := range rcodes {
+		names = append(names, dns.RcodeToString[rc])
+	}
+	return names
+}
+
+func colorVerdict(au *aurora.Aurora, v dnsprobe.ComplianceVerdict) string {
+	switch v {
+	case dnsprobe.VerdictStrict:
+		return fmt.Sprint(au.Green(v))
+	case dnsprobe.VerdictPermissive:
+		return fmt.Sprint(au.Yellow(v))
+	default:
+		return fmt.Sprint(au.Red(v))
+	}
+}
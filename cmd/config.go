@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix starts the environment variable of every flag: --qname-case is
+// DNSDOC_QNAME_CASE.
+const envPrefix = "DNSDOC_"
+
+// exclusiveGroupAnnotation is where cobra's MarkFlagsMutuallyExclusive
+// records a flag's groups, each as its flag names separated by spaces.
+const exclusiveGroupAnnotation = "cobra_annotation_mutually_exclusive"
+
+var rootConfig string
+
+// configServer is the default dns-server for commands run without one, from
+// DNSDOC_SERVER or the config file's "server".
+var configServer string
+
+// fileConfig is the config file: flag names to values, applied to every
+// command that has the flag, and per-command sections keyed by command path
+// ("latency", "profile record") that take precedence over them. "server" is
+// also the default dns-server argument, and "limits" the caps of the
+// aggressive modes (see scanLimits).
+type fileConfig struct {
+	Flags    map[string]any
+	Commands map[string]map[string]any
+	Limits   scanLimits
+}
+
+func (c *fileConfig) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Flags = map[string]any{}
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "commands":
+			err = json.Unmarshal(v, &c.Commands)
+		case "limits":
+			dec := json.NewDecoder(bytes.NewReader(v))
+			dec.DisallowUnknownFields()
+			err = dec.Decode(&c.Limits)
+		default:
+			var val any
+			err = json.Unmarshal(v, &val)
+			c.Flags[k] = val
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dnsdoc", "config.json"), nil
+}
+
+// loadFileConfig reads path, or the default config file when path is empty;
+// only a missing default file is not an error.
+func loadFileConfig(path string) (fileConfig, error) {
+	explicit := path != ""
+	if !explicit {
+		p, err := defaultConfigPath()
+		if err != nil {
+			return fileConfig{}, nil
+		}
+		path = p
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return fileConfig{}, nil
+		}
+		return fileConfig{}, fmt.Errorf("config: %w", err)
+	}
+	var c fileConfig
+	if err := json.Unmarshal(data, &c); err != nil {
+		return fileConfig{}, fmt.Errorf("config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// applyConfig fills every flag of cmd the command line left unset, first
+// from its DNSDOC_* environment variable and then from the config file, so
+// flags win over the environment, the environment over the file and the
+// file over built-in defaults. Flags it sets stay unchanged as far as
+// cobra and the commands can tell, and it skips a flag when another of its
+// mutually exclusive group was set by a layer above.
+func applyConfig(cmd *cobra.Command) error {
+	path := rootConfig
+	if !cmd.Flags().Changed("config") {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	file, err := loadFileConfig(path)
+	if err != nil {
+		return err
+	}
+	section := strings.TrimSpace(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()))
+	own := file.Commands[section]
+	for name := range own {
+		if name != "server" && cmd.Flags().Lookup(name) == nil {
+			return fmt.Errorf("config: %q has no --%s flag", section, name)
+		}
+	}
+
+	// set holds the flags given so far, on the command line or by a layer
+	// already applied.
+	set := map[string]bool{}
+	cmd.Flags().Visit(func(f *pflag.Flag) { set[f.Name] = true })
+	var errs []error
+	layer := func(lookup func(f *pflag.Flag) (string, any, bool)) {
+		applied := map[string]bool{}
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			if set[f.Name] || f.Name == "config" || f.Name == "help" {
+				return
+			}
+			src, v, ok := lookup(f)
+			if !ok {
+				return
+			}
+			for _, other := range exclusiveWith(cmd, f) {
+				switch {
+				case set[other]:
+					return
+				case applied[other]:
+					errs = append(errs, fmt.Errorf("%s: cannot be combined with --%s, which is also set there", src, other))
+					return
+				}
+			}
+			if err := setConfigValue(f, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", src, err))
+			}
+			applied[f.Name] = true
+		})
+		for name := range applied {
+			set[name] = true
+		}
+	}
+	layer(func(f *pflag.Flag) (string, any, bool) {
+		env := envName(f.Name)
+		v, ok := os.LookupEnv(env)
+		return env, v, ok
+	})
+	layer(func(f *pflag.Flag) (string, any, bool) {
+		v, ok := own[f.Name]
+		if !ok {
+			v, ok = file.Flags[f.Name]
+		}
+		return "config " + f.Name, v, ok
+	})
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	configLimits = file.Limits
+	configServer = os.Getenv(envPrefix + "SERVER")
+	for _, vals := range []map[string]any{own, file.Flags} {
+		if s, ok := vals["server"].(string); ok && configServer == "" {
+			configServer = s
+		}
+	}
+	return nil
+}
+
+// exclusiveWith lists the flags of cmd that share a mutually exclusive
+// group with f.
+func exclusiveWith(cmd *cobra.Command, f *pflag.Flag) []string {
+	var out []string
+	for _, group := range f.Annotations[exclusiveGroupAnnotation] {
+		for _, name := range strings.Fields(group) {
+			if name != f.Name && cmd.Flags().Lookup(name) != nil {
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
+
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// setConfigValue sets f from a JSON value; lists become the comma-separated
+// form the CSV and slice flags take.
+func setConfigValue(f *pflag.Flag, v any) error {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, p := range v {
+			parts[i] = fmt.Sprint(p)
+		}
+		return f.Value.Set(strings.Join(parts, ","))
+	case float64:
+		return f.Value.Set(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		return f.Value.Set(fmt.Sprint(v))
+	}
+}
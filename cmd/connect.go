@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	connectServer          string
+	connectResolutionDelay time.Duration
+	connectAttemptDelay    time.Duration
+	connectTimeout         time.Duration
+)
+
+var connectCmd = &cobra.Command{
+	Use:   "connect <host> <port>",
+	Short: "Simulate a Happy Eyeballs v2 (RFC 8305) connection and report which address won and how much of the setup time was DNS.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host, port := args[0], args[1]
+
+		var serverArgs []string
+		if connectServer != "" {
+			serverArgs = []string{connectServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+
+		cfg := dnsprobe.HappyEyeballsConfig{
+			ResolutionDelay: connectResolutionDelay,
+			AttemptDelay:    connectAttemptDelay,
+			ConnectTimeout:  connectTimeout,
+		}
+		res := dnsprobe.HappyEyeballs(cmd.Context(), server, host, port, baseOptions(), cfg)
+		printHappyEyeballs(host, port, server, res)
+		if res.Err != nil {
+			return res.Err
+		}
+		return nil
+	},
+}
+
+func init() {
+	connectCmd.Flags().StringVar(&connectServer, "server", "", "DNS server used for the A/AAAA lookups (default: system resolver).")
+	connectCmd.Flags().DurationVar(&connectResolutionDelay, "resolution-delay", dnsprobe.DefaultHappyEyeballsConfig.ResolutionDelay, "How long to wait for AAAA after A answers first.")
+	connectCmd.Flags().DurationVar(&connectAttemptDelay, "attempt-delay", dnsprobe.DefaultHappyEyeballsConfig.AttemptDelay, "Delay between staggered connection attempts.")
+	connectCmd.Flags().DurationVar(&connectTimeout, "connect-timeout", dnsprobe.DefaultHappyEyeballsConfig.ConnectTimeout, "Timeout for each connection attempt.")
+}
+
+func printHappyEyeballs(host, port, server string, res dnsprobe.HappyEyeballsResult) {
+	fmt.Printf("\n=== connect %s port %s (Happy Eyeballs v2) ===\n", host, port)
+	fmt.Printf("dns server:\t%s\n", server)
+
+	fmt.Printf("\nDNS (times from start):\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "query\tanswered at\tresult")
+	for _, q := range res.Queries {
+		result := strings.Join(q.Addrs, ",")
+		if q.Err != nil {
+			result = q.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", q.Type, q.At, orDash(result))
+	}
+	_ = w.Flush()
+
+	if len(res.Attempts) > 0 {
+		fmt.Printf("\nConnection attempts:\n")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "#\taddress\tfamily\tstarted\tended\tresult")
+		for i, a := range res.Attempts {
+			result := "cancelled"
+			switch {
+			case a.Won:
+				result = fmt.Sprintf("connected in %s (winner)", a.End-a.Start)
+			case a.Err != nil:
+				result = a.Err.Error()
+			}
+			ended := "-"
+			if a.End > 0 {
+				ended = a.End.String()
+			}
+			fmt.Fprintf(w, "%d\t%s\tIPv%d\t%s\t%s\t%s\n", i+1, a.Addr, a.Family, a.Start, ended, result)
+		}
+		_ = w.Flush()
+	}
+
+	fmt.Println()
+	if res.Err != nil {
+		fmt.Printf("result:\tfailed after %s: %v\n", res.Total, res.Err)
+		return
+	}
+	win := res.Attempts[res.Winner]
+	fmt.Printf("winner:\t%s (IPv%d)\n", win.Addr, win.Family)
+	fmt.Printf("total:\t%s\n", res.Total)
+	fmt.Printf("dns contribution:\t%s (%.0f%% of connection setup)\n", res.FirstAttempt, 100*float64(res.FirstAttempt)/float64(res.Total))
+}
@@ -0,0 +1,326 @@
+//go:build !lite
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dashboardInterval time.Duration
+	dashboardDomains  string
+)
+
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard [dns-server...]",
+	Short: "Full-screen live view of several resolvers: latency sparklines, success rates and last answers. Resolvers and domains can be added or removed while it runs.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if dashboardInterval <= 0 {
+			return fmt.Errorf("--interval must be > 0")
+		}
+		servers := args
+		if len(servers) == 0 {
+			s, err := serverFromArgs(nil)
+			if err != nil {
+				return err
+			}
+			servers = []string{s}
+		}
+		domains, err := parseDomains(dashboardDomains)
+		if err != nil {
+			return err
+		}
+
+		m := &dashModel{
+			au:       newAurora(),
+			opts:     baseOptions(),
+			interval: dashboardInterval,
+			domains:  domains,
+		}
+		for _, s := range servers {
+			m.addResolver(s)
+		}
+		_, err = tea.NewProgram(m, tea.WithAltScreen()).Run()
+		return err
+	},
+}
+
+// The dashboard pulls in bubbletea, so it only exists in full builds.
+func init() {
+	dashboardCmd.Flags().DurationVar(&dashboardInterval, "interval", time.Second, "Time between probe rounds.")
+	dashboardCmd.Flags().StringVar(&dashboardDomains, "domains", "google.com", "CSV of domains to cycle through.")
+	rootCmd.AddCommand(dashboardCmd)
+}
+
+type dashResolver struct {
+	server  string
+	samples []time.Duration // newest last; -1 marks a failed probe
+	ok      int
+	fail    int
+	latency dnsprobe.Distribution
+	last    string
+	busy    bool
+}
+
+func (r *dashResolver) record(res dnsprobe.Result, err error) {
+	r.busy = false
+	sample := res.Timings.Total
+	if err != nil {
+		r.fail++
+		sample = -1
+		r.last = err.Error()
+	} else {
+		r.ok++
+		r.latency.Add(res.Timings.Total)
+		vals := make([]string, 0, len(res.Answers))
+		for _, a := range res.Answers {
+			vals = append(vals, a.Value)
+		}
+		r.last = fmt.Sprintf("%s %s %s", res.QName, res.RCode, strings.Join(vals, ","))
+	}
+	r.samples = append(r.samples, sample)
+	if len(r.samples) > sparkWidth {
+		r.samples = r.samples[len(r.samples)-sparkWidth:]
+	}
+}
+
+type dashInput int
+
+const (
+	inputNone dashInput = iota
+	inputResolver
+	inputDomain
+)
+
+type dashModel struct {
+	au        *aurora.Aurora
+	opts      dnsprobe.Options
+	interval  time.Duration
+	resolvers []*dashResolver
+	domains   []string
+	round     int
+	selRes    int
+	selDomain int
+	input     dashInput
+	buf       string
+	status    string
+	width     int
+}
+
+type dashTick struct{}
+
+type dashResult struct {
+	r   *dashResolver
+	res dnsprobe.Result
+	err error
+}
+
+func (m *dashModel) addResolver(server string) {
+	m.resolvers = append(m.resolvers, &dashResolver{server: dnsprobe.NormalizeServer(server)})
+}
+
+func (m *dashModel) Init() tea.Cmd {
+	return m.probeRound()
+}
+
+// probeRound queries every idle resolver for the next domain in turn and
+// schedules the following round.
+func (m *dashModel) probeRound() tea.Cmd {
+	cmds := []tea.Cmd{tea.Tick(m.interval, func(time.Time) tea.Msg { return dashTick{} })}
+	if len(m.domains) == 0 {
+		return tea.Batch(cmds...)
+	}
+	name := m.domains[m.round%len(m.domains)]
+	m.round++
+	for _, r := range m.resolvers {
+		if r.busy {
+			continue
+		}
+		r.busy = true
+		opts := m.opts
+		cmds = append(cmds, func() tea.Msg {
+			res, err := dnsprobe.Probe(context.Background(), r.server, name, opts)
+			return dashResult{r: r, res: res, err: err}
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m *dashModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	case dashTick:
+		return m, m.probeRound()
+	case dashResult:
+		msg.r.record(msg.res, msg.err)
+	case tea.KeyMsg:
+		if m.input != inputNone {
+			return m, m.handleInput(msg)
+		}
+		return m, m.handleKey(msg)
+	}
+	return m, nil
+}
+
+func (m *dashModel) handleKey(k tea.KeyMsg) tea.Cmd {
+	switch k.String() {
+	case "q", "ctrl+c":
+		return tea.Quit
+	case "up", "k":
+		if m.selRes > 0 {
+			m.selRes--
+		}
+	case "down", "j":
+		if m.selRes < len(m.resolvers)-1 {
+			m.selRes++
+		}
+	case "tab":
+		if len(m.domains) > 0 {
+			m.selDomain = (m.selDomain + 1) % len(m.domains)
+		}
+	case "r":
+		m.input, m.buf = inputResolver, ""
+	case "n":
+		m.input, m.buf = inputDomain, ""
+	case "x":
+		if len(m.resolvers) > 0 {
+			m.status = "removed resolver " + m.resolvers[m.selRes].server
+			m.resolvers = append(m.resolvers[:m.selRes], m.resolvers[m.selRes+1:]...)
+			if m.selRes >= len(m.resolvers) && m.selRes > 0 {
+				m.selRes--
+			}
+		}
+	case "d":
+		if len(m.domains) > 0 {
+			m.status = "removed domain " + m.domains[m.selDomain]
+			m.domains = append(m.domains[:m.selDomain], m.domains[m.selDomain+1:]...)
+			if m.selDomain >= len(m.domains) && m.selDomain > 0 {
+				m.selDomain--
+			}
+		}
+	}
+	return nil
+}
+
+func (m *dashModel) handleInput(k tea.KeyMsg) tea.Cmd {
+	switch k.Type {
+	case tea.KeyEsc:
+		m.input = inputNone
+	case tea.KeyBackspace:
+		if m.buf != "" {
+			m.buf = m.buf[:len(m.buf)-1]
+		}
+	case tea.KeyEnter:
+		v := strings.TrimSpace(m.buf)
+		switch {
+		case v == "":
+		case m.input == inputResolver:
+			m.addResolver(v)
+			m.status = "added resolver " + v
+		default:
+			m.domains = append(m.domains, v)
+			m.status = "added domain " + v
+		}
+		m.input = inputNone
+	case tea.KeyRunes:
+		m.buf += string(k.Runes)
+	case tea.KeyCtrlC:
+		return tea.Quit
+	}
+	return nil
+}
+
+func (m *dashModel) View() string {
+	au := m.au
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s  every %s\n", au.Bold("dnsdoc dashboard"), m.interval)
+	b.WriteString("domains: ")
+	for i, d := range m.domains {
+		if i > 0 {
+			b.WriteString("  ")
+		}
+		if i == m.selDomain {
+			b.WriteString(au.Reverse(d).String())
+		} else {
+			b.WriteString(d)
+		}
+	}
+	if len(m.domains) == 0 {
+		b.WriteString(au.Yellow("(none; press n to add one)").String())
+	}
+	b.WriteString("\n\n")
+
+	fmt.Fprintf(&b, "  %-24s %10s %10s %7s  %-*s  %s\n", "resolver", "last", "p50", "ok%", sparkWidth, "latency", "last answer")
+	for i, r := range m.resolvers {
+		cursor := "  "
+		if i == m.selRes {
+			cursor = au.Cyan("> ").String()
+		}
+		last := "-"
+		if n := len(r.samples); n > 0 {
+			if r.samples[n-1] < 0 {
+				last = "fail"
+			} else {
+				last = r.samples[n-1].Round(time.Microsecond).String()
+			}
+		}
+		p50 := "-"
+		if r.latency.Count() > 0 {
+			p50 = r.latency.Quantile(0.5).Round(time.Microsecond).String()
+		}
+		rate := fmt.Sprintf("%7s", "-")
+		if total := r.ok + r.fail; total > 0 {
+			pct := float64(r.ok) / float64(total) * 100
+			rate = fmt.Sprintf("%7.1f", pct)
+			switch {
+			case pct < 90:
+				rate = au.Red(rate).String()
+			case pct < 100:
+				rate = au.Yellow(rate).String()
+			}
+		}
+		spark := sparkline(au, r.samples)
+		pad := sparkWidth - len(r.samples)
+		fmt.Fprintf(&b, "%s%-24s %10s %10s %s  %s%s  %s\n", cursor, r.server, last, p50, rate, spark, strings.Repeat(" ", pad), m.clip(r.last))
+	}
+	if len(m.resolvers) == 0 {
+		b.WriteString(au.Yellow("  (no resolvers; press r to add one)\n").String())
+	}
+
+	b.WriteString("\n")
+	switch m.input {
+	case inputResolver:
+		fmt.Fprintf(&b, "add resolver: %s█  (enter to add, esc to cancel)\n", m.buf)
+	case inputDomain:
+		fmt.Fprintf(&b, "add domain: %s█  (enter to add, esc to cancel)\n", m.buf)
+	default:
+		if m.status != "" {
+			b.WriteString(m.status + "\n")
+		}
+		b.WriteString(au.Faint("↑/↓ select resolver  r add resolver  x remove resolver  tab select domain  n add domain  d remove domain  q quit").String())
+	}
+	return b.String()
+}
+
+// clip shortens the last-answer column to the terminal width.
+func (m *dashModel) clip(s string) string {
+	room := m.width - (2 + 24 + 1 + 10 + 1 + 10 + 1 + 7 + 2 + sparkWidth + 2)
+	if m.width == 0 || len(s) <= room {
+		return s
+	}
+	if room < 4 {
+		return ""
+	}
+	return s[:room-1] + "…"
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/data"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	dataUpdateDir     string
+	dataUpdateServer  string
+	dataUpdateDomains int
+)
+
+// dataSet is the data set --data-dir selects, loaded before every command.
+var dataSet *data.Set
+
+var dataCmd = &cobra.Command{
+	Use:   "data",
+	Short: "Show the data set in use (public resolver presets, popular domains, root hints, root trust anchors): where it was loaded from and each file's version.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		au := newAurora()
+		fmt.Printf("\n=== data: %s ===\n", dataSet.Location())
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "file\tversion\tentries\tsha256\tsource")
+		for _, name := range data.Files {
+			e, _ := dataSet.Manifest.Entry(name)
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", name, e.Version, dataSet.Count(name), e.SHA256[:12], e.Source)
+		}
+		_ = w.Flush()
+
+		fmt.Printf("\nResolver presets:\n")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, r := range dataSet.Resolvers {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.Host, strings.Join(r.Addrs, ", "))
+		}
+		_ = w.Flush()
+
+		fmt.Printf("\nRoot trust anchors:\n")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		now := time.Now()
+		for _, a := range dataSet.TrustAnchors {
+			status := au.Green("valid").String()
+			switch {
+			case now.Before(a.ValidFrom):
+				status = au.Yellow("valid from " + a.ValidFrom.Format(time.DateOnly)).String()
+			case !a.Valid(now):
+				status = "retired " + a.ValidUntil.Format(time.DateOnly)
+			}
+			fmt.Fprintf(w, "%d\talgorithm %d\t%s\n", a.DS.KeyTag, a.DS.Algorithm, status)
+		}
+		_ = w.Flush()
+		return nil
+	},
+}
+
+var dataUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Fetch the data files from their upstream sources (InterNIC root hints, IANA trust anchors, the Tranco top sites list, DNS lookups of the resolver presets) and write them as a new set, keeping the current copy of any file that cannot be fetched.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := dataUpdateDir
+		if dir == "" {
+			d, err := data.DefaultDir()
+			if err != nil {
+				return err
+			}
+			dir = d
+		}
+		if dataUpdateDomains < 1 {
+			return fmt.Errorf("--domains must be >= 1")
+		}
+		var serverArgs []string
+		if dataUpdateServer != "" {
+			serverArgs = []string{dataUpdateServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+
+		results, err := data.Update(cmd.Context(), dataSet, data.UpdateConfig{Dir: dir, Server: server, Domains: dataUpdateDomains, Options: baseOptions()})
+		if err != nil {
+			return err
+		}
+
+		au := newAurora()
+		fmt.Printf("\n=== data update: %s ===\n", dir)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "file\tversion\tstatus")
+		var kept int
+		for _, r := range results {
+			status := au.Green("updated").String()
+			switch {
+			case r.Err != nil:
+				kept++
+				status = au.Yellow(fmt.Sprintf("kept: %v", r.Err)).String()
+			case !r.Changed:
+				status = "unchanged"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, r.Version, status)
+		}
+		_ = w.Flush()
+		if kept > 0 {
+			return fmt.Errorf("%d of %d file(s) could not be fetched and were kept from %s", kept, len(results), dataSet.Location())
+		}
+		return nil
+	},
+}
+
+func init() {
+	dataUpdateCmd.Flags().StringVar(&dataUpdateDir, "dir", "", "Write the set to this directory instead of <user config dir>/dnsdoc/data; use it later with --data-dir. The directory is replaced as a whole, so it must be new, empty or hold an earlier set.")
+	dataUpdateCmd.Flags().StringVar(&dataUpdateServer, "server", "", "DNS server used to look up the resolver presets (default: system resolver).")
+	dataUpdateCmd.Flags().IntVar(&dataUpdateDomains, "domains", 50, "How many of the most popular sites to keep.")
+	dataCmd.AddCommand(dataUpdateCmd)
+}
+
+// loadData loads the data set --data-dir selects. The data commands fall
+// back to the built-in set when it is broken, so it can be inspected and
+// replaced.
+func loadData(cmd *cobra.Command) error {
+	s, err := data.Load(rootDataDir)
+	if err != nil {
+		if cmd != dataCmd && cmd.Parent() != dataCmd {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "warning: %v; using the built-in data\n", err)
+		if s, err = data.Load(data.Embedded); err != nil {
+			return err
+		}
+	}
+	dataSet = s
+	return nil
+}
+
+// rootHints maps the root server names of the data set to their addresses.
+func rootHints() map[string][]string {
+	hints := map[string][]string{}
+	for _, r := range dataSet.RootHints {
+		hints[r.Name] = r.Addrs
+	}
+	return hints
+}
+
+// presetAddr is the first address of r in the family --ipv4 or --ipv6
+// selects.
+func presetAddr(r data.Resolver) string {
+	for _, a := range r.Addrs {
+		v4 := net.ParseIP(a).To4() != nil
+		if rootIPv6 && v4 || rootIPv4 && !v4 {
+			continue
+		}
+		return a
+	}
+	return r.Addrs[0]
+}
+
+// presetServers splits a CSV of servers, replacing preset names with the
+// preset's address and "public" with every preset's.
+func presetServers(csv string) []string {
+	var out []string
+	for _, s := range strings.Split(csv, ",") {
+		s = strings.TrimSpace(s)
+		switch r, ok := dataSet.Resolver(s); {
+		case s == "":
+		case s == "public":
+			for _, r := range dataSet.Resolvers {
+				out = append(out, presetAddr(r))
+			}
+		case ok:
+			out = append(out, presetAddr(r))
+		default:
+			out = append(out, s)
+		}
+	}
+	return out
+}
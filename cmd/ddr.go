@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var ddrProbe int
+
+var ddrCmd = &cobra.Command{
+	Use:   "ddr [dns-server]",
+	Short: "Discover the encrypted endpoints (DoT, DoH, DoQ) a resolver designates through DDR (RFC 9462), verify that clients may upgrade to them, and with --probe compare their latency with plain DNS.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		server = dnsprobe.NormalizeServer(server)
+
+		ctx := cmd.Context()
+		opts := baseOptions()
+		designated, err := dnsprobe.DiscoverDesignated(ctx, server, opts)
+		if err != nil {
+			return err
+		}
+
+		au := newAurora()
+		fmt.Printf("\n=== ddr: %s ===\n", server)
+		if len(designated) == 0 {
+			fmt.Printf("%s %s has no SVCB records at %s; clients cannot discover an encrypted endpoint for it\n", au.Yellow("none:"), server, dnsprobe.DDRName)
+			return nil
+		}
+
+		var base *dnsprobe.Distribution
+		if ddrProbe > 0 {
+			if base, err = dnsprobe.ProbeDesignated(ctx, dnsprobe.DesignatedResolver{Protocol: "do53", Addrs: []string{server}}, ddrProbe, opts); err != nil {
+				return fmt.Errorf("plain DNS baseline: %w", err)
+			}
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		header := "priority\tprotocol\ttarget\tendpoint\talpn"
+		if base != nil {
+			header += "\tp50\tvs do53"
+		}
+		fmt.Fprintln(w, header+"\tverified")
+		unverified, checkable := 0, len(designated)
+		for _, d := range designated {
+			endpoint := strings.Join(d.Addrs, ", ")
+			if d.Protocol == "doh" {
+				endpoint = d.URL()
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s", d.Priority, d.Protocol, d.Target, orDash(endpoint), strings.Join(d.ALPN, ","))
+			switch {
+			case base == nil:
+			case d.Protocol == "doq":
+				fmt.Fprintf(w, "\t-\t-")
+			default:
+				dist, err := dnsprobe.ProbeDesignated(ctx, d, ddrProbe, opts)
+				if err != nil {
+					fmt.Fprintf(w, "\t%s\t-", au.Red(fmt.Sprintf("error: %v", err)))
+				} else {
+					p50 := dist.Quantile(0.5)
+					fmt.Fprintf(w, "\t%s\t%+.1fms", p50, float64(p50-base.Quantile(0.5))/1e6)
+				}
+			}
+			status := au.Green("yes").String()
+			switch {
+			case d.Protocol == "doq":
+				checkable--
+				status = au.Yellow("not checked: DNS over QUIC is not supported").String()
+			case !d.Verified:
+				unverified++
+				status = au.Red(d.VerifyErr.Error()).String()
+			}
+			fmt.Fprintf(w, "\t%s\n", status)
+		}
+		_ = w.Flush()
+		if base != nil {
+			fmt.Printf("plain DNS p50:\t%s over %d queries\n", base.Quantile(0.5), base.Count())
+		}
+
+		if checkable > 0 && unverified == checkable {
+			host, _, _ := net.SplitHostPort(server)
+			fmt.Printf("%s no designation verifies, so clients will not upgrade to encrypted DNS; the certificate must list %s as an IP address\n", au.Red("unverified:"), host)
+			return exitError{code: exitMismatch, err: fmt.Errorf("no verifiable DDR designation for %s", server)}
+		}
+		return nil
+	},
+}
+
+func init() {
+	ddrCmd.Flags().IntVar(&ddrProbe, "probe", 0, "Send N queries to each designated endpoint and to the resolver over plain DNS and compare their latency (0 skips it).")
+}
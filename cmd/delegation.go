@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"dnsdoc/internal/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var delegationServer string
+
+var delegationCmd = &cobra.Command{
+	Use:   "delegation <zone>",
+	Short: "Audit a zone's delegation: parent vs child NS sets, missing or incorrect glue, lame delegations and unreachable nameservers, with the latency of every check and a summary verdict.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var serverArgs []string
+		if delegationServer != "" {
+			serverArgs = []string{delegationServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+
+		findings, err := doctor.Delegation(cmd.Context(), server, args[0], baseOptions())
+		if err != nil {
+			return err
+		}
+
+		if err := printFindings("delegation: "+args[0], findings); err != nil {
+			return exitError{code: exitMismatch, err: err}
+		}
+		return nil
+	},
+}
+
+func init() {
+	delegationCmd.Flags().StringVar(&delegationServer, "server", "", "DNS server used to find the parent zone and resolve nameserver names (default: system resolver).")
+}
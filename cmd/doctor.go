@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+	"dnsdoc/internal/doctor"
+
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/spf13/cobra"
+)
+
+var (
+	doctorDomain    string
+	doctorSigned    string
+	doctorBogus     string
+	doctorHTTPS     string
+	doctorNoDedupe  bool
+	doctorOutput    string
+	doctorNoRDAP    bool
+	doctorSigWindow time.Duration
+)
+
+// doctorJSON is the --output json report.
+type doctorJSON struct {
+	Server   string           `json:"server"`
+	Findings []doctor.Finding `json:"findings"`
+	Pass     int              `json:"pass"`
+	Warn     int              `json:"warn"`
+	Fail     int              `json:"fail"`
+	Took     time.Duration    `json:"took_ns"`
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [dns-server]",
+	Short: "One-shot health report for a resolver: UDP/TCP reachability, EDNS, DNSSEC validation and signature expiry, large responses, negative caching, NXDOMAIN hijacking, DNS64 (NAT64 prefix), HTTPS records and the test domain's registration (expiry, lock, parking), with remediation hints.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutput(doctorOutput); err != nil {
+			return err
+		}
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+
+		cfg := doctor.DefaultConfig
+		cfg.Server = server
+		cfg.Options = baseOptions()
+		cfg.Domain = doctorDomain
+		cfg.Signed = doctorSigned
+		cfg.Large = doctorSigned
+		cfg.Bogus = doctorBogus
+		cfg.HTTPSName = doctorHTTPS
+		cfg.SigWindow = doctorSigWindow
+		cfg.Registration = !doctorNoRDAP
+		if !doctorNoDedupe {
+			cfg.Cache = dnsprobe.NewQueryCache()
+		}
+
+		if doctorOutput == outputJSON {
+			start := time.Now()
+			findings := doctor.Run(cmd.Context(), cfg, nil)
+			rep := newDoctorJSON(server, findings, time.Since(start))
+			if err := printJSON(rep); err != nil {
+				return err
+			}
+			if rep.Fail > 0 {
+				return fmt.Errorf("%d check(s) failed", rep.Fail)
+			}
+			return nil
+		}
+
+		au := newAurora()
+		fmt.Printf("\n=== doctor: %s ===\n", server)
+		start := time.Now()
+		findings := doctor.Run(cmd.Context(), cfg, func(f doctor.Finding) {
+			fmt.Printf("%-20s %s  %s\n", f.Check, colorStatus(au, f.Status), f.Took.Round(time.Microsecond))
+		})
+		took := time.Since(start)
+		err = printDoctorReport(au, findings, took)
+		if cfg.Cache != nil {
+			fmt.Printf("queries:\t%d sent, %d answered from the in-run cache (--no-dedupe to disable)\n", cfg.Cache.Misses, cfg.Cache.Hits)
+		}
+		if serr := saveBundleResults(newDoctorJSON(server, findings, took)); serr != nil {
+			return serr
+		}
+		return err
+	},
+}
+
+func newDoctorJSON(server string, findings []doctor.Finding, took time.Duration) doctorJSON {
+	rep := doctorJSON{Server: server, Findings: findings, Took: took}
+	for _, f := range findings {
+		switch f.Status {
+		case doctor.Pass:
+			rep.Pass++
+		case doctor.Warn:
+			rep.Warn++
+		default:
+			rep.Fail++
+		}
+	}
+	return rep
+}
+
+func init() {
+	doctorCmd.Flags().StringVar(&doctorDomain, "domain", doctor.DefaultConfig.Domain, "Ordinary domain that should resolve; negative caching is tested under it.")
+	doctorCmd.Flags().StringVar(&doctorSigned, "signed", doctor.DefaultConfig.Signed, "DNSSEC-signed zone used for the validation and large-response checks.")
+	doctorCmd.Flags().BoolVar(&doctorNoDedupe, "no-dedupe", false, "Send every query even if an identical one was already sent in this run (strict measurement).")
+	doctorCmd.Flags().BoolVar(&doctorNoRDAP, "no-rdap", false, "Skip the registration check, which looks --domain up in RDAP (HTTPS) for expiry, transfer lock and parked name servers.")
+	doctorCmd.Flags().StringVar(&doctorOutput, "output", outputText, "Report format: text, or json with stable finding codes and structured remediation for each warning and failure.")
+	doctorCmd.Flags().DurationVar(&doctorSigWindow, "sig-window", doctor.DefaultConfig.SigWindow, "Warn when a signature of --signed's DNSKEY, SOA or NS set expires within this long.")
+	doctorCmd.Flags().StringVar(&doctorHTTPS, "https-name", doctor.DefaultConfig.HTTPSName, "Name publishing an HTTPS record, used to test that the resolver handles the type.")
+	doctorCmd.Flags().StringVar(&doctorBogus, "bogus", doctor.DefaultConfig.Bogus, "Zone with deliberately broken DNSSEC that a validating resolver must reject.")
+}
+
+// printDoctorReport prints the findings table and hints; wall is how long the
+// whole run took, to set against the summed check times.
+func printDoctorReport(au *aurora.Aurora, findings []doctor.Finding, wall time.Duration) error {
+	fmt.Printf("\nReport:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "check\tstatus\ttime\tdetail")
+	counts := map[doctor.Status]int{}
+	var sum time.Duration
+	for _, f := range findings {
+		counts[f.Status]++
+		sum += f.Took
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Check, colorStatus(au, f.Status), f.Took.Round(time.Microsecond), f.Detail)
+	}
+	_ = w.Flush()
+
+	if counts[doctor.Warn]+counts[doctor.Fail] > 0 {
+		fmt.Printf("\nWhat to do:\n")
+		for _, f := range findings {
+			if f.Status != doctor.Pass && f.Hint != "" {
+				fmt.Printf("  - %s [%s]: %s\n", f.Check, f.Code, f.Hint)
+			}
+		}
+	}
+
+	fmt.Printf("\nsummary:\tpass=%d warn=%d fail=%d\n", counts[doctor.Pass], counts[doctor.Warn], counts[doctor.Fail])
+	fmt.Printf("time:\t\t%s (checks ran in parallel; %s if run one after another)\n", wall.Round(time.Microsecond), sum.Round(time.Microsecond))
+	if n := counts[doctor.Fail]; n > 0 {
+		return fmt.Errorf("%d check(s) failed", n)
+	}
+	return nil
+}
+
+func colorStatus(au *aurora.Aurora, s doctor.Status) string {
+	switch s {
+	case doctor.Pass:
+		return fmt.Sprint(au.Green(s))
+	case doctor.Warn:
+		return fmt.Sprint(au.Yellow(s))
+	default:
+		return fmt.Sprint(au.Red(s))
+	}
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"dnsdoc/internal/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var dsServer string
+
+var dsCmd = &cobra.Command{
+	Use:   "ds <zone>",
+	Short: "Verify that a zone's DS records at the parent match its DNSKEYs by key tag, algorithm and digest, reporting orphaned DS records, a DS set with an unsigned zone and signed zones without a DS, and check its CDS/CDNSKEY records (RFC 8078) against the live keys and the parent.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var serverArgs []string
+		if dsServer != "" {
+			serverArgs = []string{dsServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+
+		findings, err := doctor.DS(cmd.Context(), server, args[0], baseOptions())
+		if err != nil {
+			return err
+		}
+		return printFindings("ds: "+args[0], findings)
+	},
+}
+
+func init() {
+	dsCmd.Flags().StringVar(&dsServer, "server", "", "DNS server used to find the parent zone and the zone's nameservers (default: system resolver).")
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	ecsSubnet    string
+	ecsZone      string
+	ecsScopeName string
+)
+
+var ecsCmd = &cobra.Command{
+	Use:   "ecs [dns-server]",
+	Short: "Find out whether a resolver forwards EDNS Client Subnet to authoritatives, what it does with a subnet the client supplies, and the scope it reports back.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		_, subnet, err := net.ParseCIDR(ecsSubnet)
+		if err != nil {
+			return fmt.Errorf("--subnet: %w", err)
+		}
+
+		rep, err := dnsprobe.DetectECS(cmd.Context(), server, ecsZone, ecsScopeName, subnet, baseOptions())
+		if err != nil {
+			return err
+		}
+		au := newAurora()
+
+		fmt.Printf("\n=== client subnet (ECS): %s ===\n", server)
+		fmt.Printf("resolver egress:\t%s\n", orDash(firstOf(rep.Egress)))
+		fmt.Printf("plain query:\tauthoritative saw %s\n", orDash(rep.Forwarded))
+		fmt.Printf("query with ECS %s:\tauthoritative saw %s\n", rep.Sent, orDash(rep.Relayed))
+		if rep.Echoed {
+			fmt.Printf("reply for %s:\tECS %s/%d, scope /%d\n", ecsScopeName, subnet.IP, rep.SourcePrefix, rep.Scope)
+		} else {
+			fmt.Printf("reply for %s:\tno ECS option\n", ecsScopeName)
+		}
+
+		fmt.Println()
+		switch {
+		case rep.Forwarded == "" && rep.Relayed == "":
+			fmt.Printf("%s the resolver sends no client subnet upstream; CDNs locate clients by the resolver's address\n", au.Green("private:"))
+		case rep.Forwarded != "":
+			fmt.Printf("%s the resolver adds %s, derived from your address, to queries to authoritatives\n", au.Yellow("forwards ECS:"), rep.Forwarded)
+		default:
+			fmt.Printf("%s the resolver sends a client subnet upstream only when the client supplies one\n", au.Yellow("relays ECS:"))
+		}
+		switch {
+		case rep.Relayed == "":
+			fmt.Printf("client-supplied subnets are dropped\n")
+		case rep.Relayed == rep.Sent:
+			fmt.Printf("client-supplied subnets are passed through unchanged\n")
+		default:
+			fmt.Printf("client-supplied subnets are rewritten (sent %s, authoritative saw %s)\n", rep.Sent, rep.Relayed)
+		}
+		switch {
+		case !rep.Echoed:
+			fmt.Printf("the resolver does not report an ECS scope to clients\n")
+		case rep.Scope == 0:
+			fmt.Printf("effective scope /0: the answer for %s is shared by all clients\n", ecsScopeName)
+		default:
+			fmt.Printf("effective scope /%d: the answer for %s is cached per /%d of client addresses\n", rep.Scope, ecsScopeName, rep.Scope)
+		}
+		return nil
+	},
+}
+
+func init() {
+	ecsCmd.Flags().StringVar(&ecsSubnet, "subnet", "198.51.100.0/24", "Client subnet to send; use your own network's prefix for realistic scope results.")
+	ecsCmd.Flags().StringVar(&ecsZone, "zone", dnsprobe.ECSZone, "TXT reflector that reports the client subnet it receives (Google or Akamai whoami.ds.akahelp.net format).")
+	ecsCmd.Flags().StringVar(&ecsScopeName, "scope-name", "www.google.com", "Name served by an ECS-aware authoritative, resolved with the subnet to read the scope.")
+}
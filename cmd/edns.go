@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	ednsDomains string
+	ednsRounds  int
+	ednsSubnet  string
+)
+
+var ednsCmd = &cobra.Command{
+	Use:   "edns [dns-server]",
+	Short: "A/B experiment: benchmark the same server with and without each EDNS feature (cookies, ECS, padding, DO) and compare latency and response size.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		if ednsRounds < 1 {
+			return fmt.Errorf("--rounds must be >= 1")
+		}
+		_, subnet, err := net.ParseCIDR(ednsSubnet)
+		if err != nil {
+			return fmt.Errorf("--ecs-subnet: %w", err)
+		}
+		domains, err := parseDomains(ednsDomains)
+		if err != nil {
+			return err
+		}
+
+		variants := dnsprobe.EDNSVariants(subnet)
+		ctx := cmd.Context()
+		results, rounds := dnsprobe.RunEDNSVariants(ctx, server, domains, baseOptions(), ednsRounds, variants)
+
+		au := newAurora()
+		fmt.Printf("\n=== EDNS option A/B: %s (%d rounds x %d domains, deltas vs baseline) ===\n", server, ednsRounds, len(domains))
+		var base dnsprobe.EDNSVariantResult
+		for _, r := range results {
+			if r.Variant.Name == "baseline" {
+				base = r
+			}
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "variant\tok\tfail\tp50\tΔp50\tavg size\tΔsize\trcodes\tnotes")
+		for _, r := range results {
+			dLat, dSize := "-", "-"
+			if r.Variant.Name != "baseline" && r.OK > 0 && base.OK > 0 {
+				d := r.P50 - base.P50
+				dLat = signedDuration(d)
+				switch {
+				case d > base.P50/10:
+					dLat = au.Red(dLat).String()
+				case d < -base.P50/10:
+					dLat = au.Green(dLat).String()
+				}
+				dSize = fmt.Sprintf("%+dB", r.AvgSize-base.AvgSize)
+			}
+			fail := fmt.Sprint(r.Fail)
+			if r.Fail > 0 {
+				fail = au.Red(fail).String()
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%dB\t%s\t%s\t%s\n", r.Variant.Name, r.OK, fail, r.P50, dLat, r.AvgSize, dSize, formatRCodes(r.RCodes), r.Variant.Desc)
+		}
+		_ = w.Flush()
+
+		var broken []string
+		for _, r := range results {
+			if r.Fail > 0 && r.OK == 0 {
+				broken = append(broken, r.Variant.Name)
+			}
+		}
+		if len(broken) > 0 {
+			fmt.Printf("\nno answers at all with: %s (the server or a middlebox drops these queries)\n", strings.Join(broken, ", "))
+		}
+		if ctx.Err() != nil {
+			fmt.Printf("\n%s %d of %d rounds (%s); the last one ran only in part\n", au.Yellow("skipped:"), ednsRounds-rounds, ednsRounds, stopReason(ctx))
+		}
+		return nil
+	},
+}
+
+func init() {
+	ednsCmd.Flags().StringVar(&ednsDomains, "domains", "", "CSV of domains to query (overrides the default set).")
+	ednsCmd.Flags().IntVar(&ednsRounds, "rounds", 10, "Queries per domain per variant.")
+	ednsCmd.Flags().StringVar(&ednsSubnet, "ecs-subnet", "192.0.2.0/24", "Client subnet sent by the +ecs variant; use your own network's prefix for realistic results.")
+}
+
+func signedDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + (-d).String()
+	}
+	return "+" + d.String()
+}
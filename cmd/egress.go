@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	egressPaths   string
+	egressTLSName string
+	egressDoHPath string
+)
+
+var egressCmd = &cobra.Command{
+	Use:   "egress [target]",
+	Short: "Try DNS to one target over 53/UDP, 53/TCP, DoT (853), DoH (443) and alternative ports, and report which are reachable from this network, revealing egress filtering that forces a transport. The target defaults to 1.1.1.1.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rootProxy != "" {
+			return fmt.Errorf("--proxy would hide the network being checked; egress connects directly")
+		}
+		host := "1.1.1.1"
+		if len(args) == 1 {
+			host = args[0]
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		}
+		paths, err := dnsprobe.ParseEgressPaths(egressPaths)
+		if err != nil {
+			return err
+		}
+
+		results := dnsprobe.CheckEgress(cmd.Context(), host, paths, baseOptions(), egressTLSName, egressDoHPath)
+
+		au := newAurora()
+		fmt.Printf("\n=== DNS egress to %s ===\n", host)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "path\trcode\trtt\tdetail\tverdict")
+		var open, blocked []string
+		for _, r := range results {
+			rcode, rtt, detail := "-", "-", "-"
+			if r.Err != nil {
+				detail = r.Err.Error()
+			} else {
+				rcode, rtt = r.RCode, r.RTT.String()
+			}
+			verdict := r.Verdict
+			switch r.Verdict {
+			case dnsprobe.EgressOpen:
+				open = append(open, r.Path.String())
+				verdict = au.Green(verdict).String()
+			case dnsprobe.EgressFiltered:
+				blocked = append(blocked, r.Path.String())
+				verdict = au.Red(verdict).String()
+			default:
+				verdict = au.Yellow(verdict).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Path, rcode, rtt, detail, verdict)
+		}
+		_ = w.Flush()
+
+		fmt.Println()
+		switch {
+		case len(open) == 0:
+			fmt.Printf("%s no path reached %s; DNS to it is blocked from here, or it is down\n", au.Red("no egress:"), host)
+		case len(blocked) > 0:
+			fmt.Printf("%s %s got no reply while %s answered; this network likely drops those transports or ports\n", au.Yellow("filtered:"), strings.Join(blocked, ", "), strings.Join(open, ", "))
+		default:
+			fmt.Printf("%s %s answered and nothing was silently dropped\n", au.Green("open:"), strings.Join(open, ", "))
+		}
+		fmt.Println("filtered paths got no reply at all, the usual sign of a firewall dropping them; refused ones were rejected, often because the target does not listen there.")
+		return nil
+	},
+}
+
+func init() {
+	var defaults []string
+	for _, p := range dnsprobe.DefaultEgressPaths {
+		defaults = append(defaults, p.String())
+	}
+	egressCmd.Flags().StringVar(&egressPaths, "paths", strings.Join(defaults, ","), "CSV of transport/port pairs to try; transports are udp, tcp, dot and doh.")
+	egressCmd.Flags().StringVar(&egressTLSName, "tls-name", "", "Name DoT and DoH verify the certificate against and DoH puts in its URL (default: the target).")
+	egressCmd.Flags().StringVar(&egressDoHPath, "doh-path", "/dns-query", "URL path of the DoH endpoint.")
+}
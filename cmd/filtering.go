@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	filteringTargetsFile string
+	filteringBlockIPs    []string
+	filteringReference   string
+)
+
+var filteringCmd = &cobra.Command{
+	Use:   "filtering [dns-server]",
+	Short: "Check whether a resolver filters known-blocked categories (malware, phishing, adult test domains) and how: NXDOMAIN, 0.0.0.0 or a block-page IP.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+
+		cfg := dnsprobe.FilterConfig{
+			Targets:   dnsprobe.DefaultFilterTargets,
+			BlockIPs:  filteringBlockIPs,
+			Reference: filteringReference,
+		}
+		if filteringTargetsFile != "" {
+			cfg.Targets, err = dnsprobe.ReadFilterTargets(filteringTargetsFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		ctx := cmd.Context()
+		results := dnsprobe.CheckFiltering(ctx, server, cfg, baseOptions())
+		results, skipped := splitSkipped(ctx, results, func(r dnsprobe.FilterResult) (string, error) { return r.Target.Domain, r.Err })
+
+		au := newAurora()
+		fmt.Printf("\n=== filtering: %s ===\n", server)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "category\tdomain\trcode\tanswers\textended error\tverdict")
+		type tally struct{ blocked, total int }
+		var order []string
+		cats := map[string]*tally{}
+		controlBlocked := false
+		for _, r := range results {
+			c := r.Target.Category
+			if cats[c] == nil {
+				cats[c] = &tally{}
+				order = append(order, c)
+			}
+			cats[c].total++
+
+			verdict := string(r.Verdict)
+			switch {
+			case r.Err != nil:
+				verdict = au.Red(fmt.Sprintf("error: %v", r.Err)).String()
+			case r.Verdict.IsBlocked():
+				cats[c].blocked++
+				if c == "control" {
+					controlBlocked = true
+					verdict = au.Red(verdict).String()
+				} else {
+					verdict = au.Green(verdict).String()
+				}
+			case c != "control":
+				verdict = au.Yellow(verdict).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c, r.Target.Domain, orDash(r.RCode), orDash(strings.Join(r.Addrs, ",")), orDash(r.EDE), verdict)
+		}
+		_ = w.Flush()
+
+		fmt.Printf("\nsummary:\n")
+		for _, c := range order {
+			if c == "control" {
+				continue
+			}
+			fmt.Printf("  %s:\t%d/%d blocked\n", c, cats[c].blocked, cats[c].total)
+		}
+		if controlBlocked {
+			fmt.Printf("%s a control domain was blocked, so the resolver may be failing rather than filtering\n", au.Red("warning:"))
+		}
+		printSkipped(ctx, au, "domain", skipped)
+		if filteringReference == "" {
+			fmt.Printf("tip: pass --reference <unfiltered resolver> to catch block pages that are not 0.0.0.0, or --block-ip to name them\n")
+		}
+		return nil
+	},
+}
+
+func init() {
+	filteringCmd.Flags().StringVar(&filteringTargetsFile, "targets-file", "", "File of \"category domain\" lines to test instead of the built-in list (category \"control\" must resolve).")
+	filteringCmd.Flags().StringSliceVar(&filteringBlockIPs, "block-ip", nil, "Addresses of the resolver's block page (repeatable or CSV).")
+	filteringCmd.Flags().StringVar(&filteringReference, "reference", "", "Unfiltered resolver to compare answers with, to detect rewrites to unknown block pages.")
+}
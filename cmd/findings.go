@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/doctor"
+)
+
+// printFindings prints findings under title as a table, then the hints of
+// those that did not pass, the counts and the verdict. It returns an error
+// when any check failed.
+func printFindings(title string, findings []doctor.Finding) error {
+	au := newAurora()
+	fmt.Printf("\n=== %s ===\n", title)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "check\tstatus\ttime\tdetail")
+	counts := map[doctor.Status]int{}
+	for _, f := range findings {
+		counts[f.Status]++
+		took := "-"
+		if f.Took > 0 {
+			took = f.Took.Round(time.Microsecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Check, colorStatus(au, f.Status), took, f.Detail)
+	}
+	_ = w.Flush()
+
+	if counts[doctor.Warn]+counts[doctor.Fail] > 0 {
+		fmt.Printf("\nWhat to do:\n")
+		for _, f := range findings {
+			if f.Status != doctor.Pass && f.Hint != "" {
+				fmt.Printf("  - %s [%s]: %s\n", f.Check, f.Code, f.Hint)
+			}
+		}
+	}
+
+	verdict := doctor.Verdict(findings)
+	switch verdict {
+	case "healthy":
+		verdict = au.Green(verdict).String()
+	case "degraded":
+		verdict = au.Yellow(verdict).String()
+	default:
+		verdict = au.Red(verdict).String()
+	}
+	fmt.Printf("\nsummary:\tpass=%d warn=%d fail=%d\n", counts[doctor.Pass], counts[doctor.Warn], counts[doctor.Fail])
+	fmt.Printf("verdict:\t%s\n", verdict)
+	if n := counts[doctor.Fail]; n > 0 {
+		return fmt.Errorf("%d check(s) failed", n)
+	}
+	return nil
+}
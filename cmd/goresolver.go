@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	goresolverDomains string
+	goresolverRounds  int
+)
+
+var goresolverCmd = &cobra.Command{
+	Use:   "goresolver [dns-server]",
+	Short: "Compare Go's net.Resolver (pure Go and default/cgo paths) with direct wire probes to tell resolver slowness from stdlib overhead.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		domains, err := parseDomains(goresolverDomains)
+		if err != nil {
+			return err
+		}
+		if goresolverRounds < 1 {
+			return fmt.Errorf("--rounds must be >= 1")
+		}
+
+		ctx := cmd.Context()
+		opts := baseOptions()
+
+		paths := []string{"wire A", "wire A+AAAA", "go resolver", "go default"}
+		dists := make([]dnsprobe.Distribution, len(paths))
+		fails := make([]int, len(paths))
+		lastAddrs := map[string][]string{}
+
+		record := func(i int, d time.Duration, addrs []string, err error) {
+			if dnsprobe.CutShort(ctx, err) {
+				return
+			}
+			if err != nil {
+				fails[i]++
+				return
+			}
+			dists[i].Add(d)
+			lastAddrs[paths[i]] = addrs
+		}
+
+		round := 0
+		for ; round < goresolverRounds && ctx.Err() == nil; round++ {
+			for _, name := range domains {
+				r, err := dnsprobe.Probe(ctx, server, name, opts)
+				record(0, r.Timings.Total, r.Addrs(), err)
+
+				for i, l := range []dnsprobe.Lookup{
+					dnsprobe.LookupWireDual(ctx, server, name, opts),
+					dnsprobe.LookupGo(ctx, server, name, opts),
+					dnsprobe.LookupDefault(ctx, name, opts.Timeout),
+				} {
+					record(i+1, l.Duration, l.Addrs, l.Err)
+				}
+			}
+		}
+
+		fmt.Printf("\n=== Go resolver vs wire probes: %s (%d rounds x %d domains) ===\n", server, goresolverRounds, len(domains))
+		fmt.Printf("GODEBUG:\t%s\n", orDash(os.Getenv("GODEBUG")))
+		fmt.Printf("cgo:\t%t\n\n", cgoEnabled)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "path\tok\tfail\tp50\tmean\tmax\tlast addrs\tnotes")
+		notes := []string{
+			"single A query",
+			"A and AAAA in parallel (what a host lookup costs on the wire)",
+			"net.Resolver{PreferGo: true} dialing " + server,
+			"net.Resolver{} via system config (not pinned to " + server + ")",
+		}
+		for i, p := range paths {
+			d := &dists[i]
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n", p, d.Count(), fails[i], d.Quantile(0.5), d.Mean(), d.Max(),
+				orDash(strings.Join(lastAddrs[p], ",")), notes[i])
+		}
+		_ = w.Flush()
+
+		if dists[1].Count() > 0 && dists[2].Count() > 0 {
+			fmt.Printf("\nstdlib overhead (go resolver p50 - wire A+AAAA p50):\t%s\n", dists[2].Quantile(0.5)-dists[1].Quantile(0.5))
+		}
+		if dists[1].Count() > 0 && dists[3].Count() > 0 {
+			fmt.Printf("system path overhead (go default p50 - wire A+AAAA p50):\t%s\n", dists[3].Quantile(0.5)-dists[1].Quantile(0.5))
+		}
+		fmt.Printf("\nset GODEBUG=netdns=go or GODEBUG=netdns=cgo to force the path used by \"go default\".\n")
+		if ctx.Err() != nil {
+			fmt.Printf("\n%s %d of %d rounds (%s); the last one ran only in part\n", newAurora().Yellow("skipped:"), goresolverRounds-round, goresolverRounds, stopReason(ctx))
+		}
+		return nil
+	},
+}
+
+func init() {
+	goresolverCmd.Flags().StringVar(&goresolverDomains, "domains", "", "CSV of domains to test (overrides the default set).")
+	goresolverCmd.Flags().IntVar(&goresolverRounds, "rounds", 5, "How many times to resolve every domain through each path.")
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	rootAllowlist string
+	rootYes       bool
+)
+
+// scanLimits are the "limits" of the config file: caps on the load, scan
+// and enumeration modes that their own flags cannot raise, and the allowlist
+// of networks and domains they may target without confirmation. With
+// RequireAllowlist, nothing else may be targeted at all. They guard against
+// mistakes, not against the user: --config or DNSDOC_CONFIG can select a
+// file without them.
+type scanLimits struct {
+	MaxRate          float64 `json:"max_rate"`
+	MaxConcurrency   int     `json:"max_concurrency"`
+	MaxDuration      string  `json:"max_duration"`
+	Allowlist        string  `json:"allowlist"`
+	RequireAllowlist bool    `json:"require_allowlist"`
+}
+
+// configLimits holds the limits of the config file loaded for this run.
+var configLimits scanLimits
+
+// guardedRun describes what an aggressive mode is about to do: the servers
+// receiving its queries, the domains it enumerates and how hard it pushes.
+// Zero values mean the mode has no such knob.
+type guardedRun struct {
+	mode        string
+	servers     []string
+	domains     []string
+	rate        float64
+	concurrency int
+	duration    time.Duration
+}
+
+// guard enforces the configured caps and, for targets outside the
+// allowlist, asks for confirmation: --yes, or an answer on the terminal.
+func guard(r guardedRun) error {
+	lim := configLimits
+	if lim.MaxRate > 0 && r.rate > lim.MaxRate {
+		return fmt.Errorf("%s: rate %g/s exceeds the configured max_rate %g/s", r.mode, r.rate, lim.MaxRate)
+	}
+	if lim.MaxConcurrency > 0 && r.concurrency > lim.MaxConcurrency {
+		return fmt.Errorf("%s: concurrency %d exceeds the configured max_concurrency %d", r.mode, r.concurrency, lim.MaxConcurrency)
+	}
+	if lim.MaxDuration != "" && r.duration > 0 {
+		max, err := time.ParseDuration(lim.MaxDuration)
+		if err != nil {
+			return fmt.Errorf("config limits: max_duration: %w", err)
+		}
+		if r.duration > max {
+			return fmt.Errorf("%s: duration %s exceeds the configured max_duration %s", r.mode, r.duration, max)
+		}
+	}
+
+	path := rootAllowlist
+	if lim.Allowlist != "" {
+		path = lim.Allowlist
+	}
+	var allow allowlist
+	if path != "" {
+		var err error
+		if allow, err = readAllowlist(path); err != nil {
+			return err
+		}
+	} else if lim.RequireAllowlist {
+		return fmt.Errorf("config limits: require_allowlist is set but no allowlist is configured")
+	}
+	var outside []string
+	for _, s := range r.servers {
+		if !allow.server(s) {
+			outside = append(outside, s)
+		}
+	}
+	for _, d := range r.domains {
+		if !allow.domain(d) {
+			outside = append(outside, d)
+		}
+	}
+	if len(outside) == 0 {
+		return nil
+	}
+
+	what := strings.Join(outside, ", ")
+	unconfirmed := fmt.Errorf("%s: %s not in an allowlist; pass --yes to confirm you are authorized to test it, or list it with --allowlist", r.mode, what)
+	switch {
+	case lim.RequireAllowlist:
+		return fmt.Errorf("%s: %s not in the allowlist %s, and the config requires it", r.mode, what, path)
+	case rootYes:
+		fmt.Fprintf(os.Stderr, "%s: targeting %s, outside any allowlist (confirmed with --yes)\n", r.mode, what)
+		return nil
+	case !stdinIsTerminal():
+		return unconfirmed
+	}
+	fmt.Fprintf(os.Stderr, "%s will send %s to %s, which is not in an allowlist.\nOnly test systems you own or are authorized to test. Continue? [y/N] ", r.mode, r.load(), what)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		fmt.Fprintln(os.Stderr)
+		return unconfirmed
+	}
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+		return fmt.Errorf("%s: not confirmed", r.mode)
+	}
+	return nil
+}
+
+// load describes the traffic of r for the confirmation prompt.
+func (r guardedRun) load() string {
+	var parts []string
+	if r.rate > 0 {
+		parts = append(parts, fmt.Sprintf("%g queries/s", r.rate))
+	}
+	if r.concurrency > 0 {
+		parts = append(parts, fmt.Sprintf("%d queries at once", r.concurrency))
+	}
+	load := strings.Join(parts, ", ")
+	if load == "" {
+		load = "queries"
+	}
+	if r.duration > 0 {
+		load += " for " + r.duration.String()
+	}
+	return load
+}
+
+// allowlist holds networks (CIDR or single addresses) and domains, which
+// also cover their subdomains.
+type allowlist struct {
+	prefixes []netip.Prefix
+	domains  []string
+}
+
+func readAllowlist(path string) (allowlist, error) {
+	var a allowlist
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return a, fmt.Errorf("allowlist: %w", err)
+	}
+	for _, l := range strings.Split(string(data), "\n") {
+		if l = strings.TrimSpace(l); l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		if p, err := netip.ParsePrefix(l); err == nil {
+			a.prefixes = append(a.prefixes, p.Masked())
+		} else if ip, err := netip.ParseAddr(l); err == nil {
+			a.prefixes = append(a.prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+		} else {
+			a.domains = append(a.domains, dns.Fqdn(strings.ToLower(strings.TrimPrefix(l, "*."))))
+		}
+	}
+	return a, nil
+}
+
+// server reports whether a host, host:port or DoH URL is allowed.
+func (a allowlist) server(s string) bool {
+	host := strings.TrimPrefix(s, "https://")
+	host, _, _ = strings.Cut(host, "/")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if ip, err := netip.ParseAddr(host); err == nil {
+		for _, p := range a.prefixes {
+			if p.Contains(ip.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+	return a.domain(host)
+}
+
+func (a allowlist) domain(name string) bool {
+	name = dns.Fqdn(strings.ToLower(name))
+	for _, d := range a.domains {
+		if dns.IsSubDomain(d, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// stdinIsTerminal reports whether a confirmation can be asked for.
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
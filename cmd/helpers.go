@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+)
+
+func serverFromArgs(args []string) (string, error) {
+	var server string
+	if len(args) == 1 {
+		server = args[0]
+	} else if configServer != "" {
+		server = configServer
+	} else {
+		s, err := dnsprobe.SystemDefaultDNSServer()
+		if err != nil {
+			return "", fmt.Errorf("no dns-server arg and failed to detect system default resolver: %w", err)
+		}
+		server = s
+	}
+	if r, ok := dataSet.Resolver(server); ok {
+		server = presetAddr(r)
+	}
+
+	if !dnsprobe.IsResolvedStub(server) {
+		return server, nil
+	}
+
+	ups, err := dnsprobe.ResolvedUpstreams()
+	if rootUpstream {
+		if err != nil {
+			return "", fmt.Errorf("--upstream: failed to read systemd-resolved upstream servers: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "using systemd-resolved upstream %s instead of stub %s\n", ups[0].Server, server)
+		return ups[0].Server, nil
+	}
+	if err == nil {
+		names := make([]string, 0, len(ups))
+		for _, u := range ups {
+			names = append(names, u.Server)
+		}
+		fmt.Fprintf(os.Stderr, "note: %s is the systemd-resolved stub; upstream servers: %s (pass --upstream to probe them directly)\n",
+			server, strings.Join(names, ", "))
+	}
+	return server, nil
+}
+
+func parseDomains(csv string) ([]string, error) {
+	if strings.TrimSpace(csv) == "" {
+		random128, err := dnsprobe.RandomDomain128WithCOM()
+		if err != nil {
+			return nil, err
+		}
+		return qualifyNames([]string{
+			"google.com",
+			"earentir.dev",
+			random128,
+		}), nil
+	}
+
+	var domains []string
+	for _, d := range strings.Split(csv, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		domains = append(domains, d)
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("--domains provided but no valid domains found after parsing")
+	}
+	return qualifyNames(domains), nil
+}
+
+func readDomainsFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var domains []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("%s: no domains found", path)
+	}
+	return qualifyNames(domains), nil
+}
+
+// qualifyNames appends the trailing dot to every name when --trailing-dot is set.
+func qualifyNames(names []string) []string {
+	if !rootDot {
+		return names
+	}
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = dns.Fqdn(n)
+	}
+	return out
+}
+
+// loadExpectations collects --expect assertions from flags and an optional file.
+func loadExpectations(specs []string, file string) (*dnsprobe.Expectations, error) {
+	exp := &dnsprobe.Expectations{}
+	for _, s := range specs {
+		if err := exp.Add(s); err != nil {
+			return nil, err
+		}
+	}
+	if file != "" {
+		if err := exp.AddFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return exp, nil
+}
+
+// checkExpectation prints a line when r does not match its expectation and
+// reports whether it was a mismatch. label prefixes the line in compare output.
+func checkExpectation(exp *dnsprobe.Expectations, label string, r dnsprobe.Result, err error) bool {
+	if exp.Len() == 0 || err != nil {
+		return false
+	}
+	au := newAurora()
+	if m := exp.Check(r); m != nil {
+		fmt.Printf("%sexpect:\t%s\n", label, au.Red("MISMATCH "+m.String()))
+		return true
+	}
+	return false
+}
+
+func mismatchError(n int) error {
+	if n == 0 {
+		return nil
+	}
+	return exitError{code: exitMismatch, err: fmt.Errorf("%d answer(s) did not match --expect", n)}
+}
+
+// Report formats for commands with --output.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+func checkOutput(format string) error {
+	if format != outputText && format != outputJSON {
+		return fmt.Errorf("invalid --output %q (want %s or %s)", format, outputText, outputJSON)
+	}
+	return nil
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// stderrIsTerminal reports whether progress written to stderr is seen live.
+func stderrIsTerminal() bool {
+	fi, err := os.Stderr.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// newPool returns the pool --pool asks for, nil without one. The pool
+// keeps plain DNS-over-TCP connections only, so it refuses a DoH server
+// rather than leave it unpooled behind the user's back.
+func newPool(n int, idle time.Duration, servers ...string) (*dnsprobe.Pool, error) {
+	switch {
+	case n == 0:
+		return nil, nil
+	case n < 0:
+		return nil, fmt.Errorf("--pool must be >= 0")
+	case !rootTCP && rootProxy == "":
+		return nil, fmt.Errorf("--pool needs --tcp or --proxy; UDP has no connections to keep")
+	case slices.ContainsFunc(servers, dnsprobe.IsDoHURL):
+		return nil, fmt.Errorf("--pool only keeps plain DNS-over-TCP connections; DoT and DoH are not pooled")
+	}
+	return dnsprobe.NewPool(n, idle), nil
+}
+
+func printPoolStats(w io.Writer, p *dnsprobe.Pool) {
+	st := p.Stats()
+	fmt.Fprintf(w, "\nconnection pool:\t%d opened, %d queries on reused connections, %d stale connections dropped\n", st.Dials, st.Reuses, st.Discarded)
+}
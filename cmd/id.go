@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var idCmd = &cobra.Command{
+	Use:   "id [dns-server]",
+	Short: "Fingerprint a resolver: collect what version.bind, hostname.bind, id.server (CHAOS TXT) and NSID reveal about its software and which anycast instance answered.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+
+		items := dnsprobe.Identify(cmd.Context(), server, baseOptions())
+
+		fmt.Printf("\n=== identity: %s ===\n", server)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "query\trcode\tvalue")
+		var software, instance []string
+		for _, it := range items {
+			switch {
+			case it.Err != nil:
+				fmt.Fprintf(w, "%s\t-\t%v\n", it.Source, it.Err)
+				continue
+			case it.Value == "":
+				fmt.Fprintf(w, "%s\t%s\t(not disclosed)\n", it.Source, it.RCode)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", it.Source, it.RCode, it.Value)
+			if it.Source == "CH TXT version.bind" || it.Source == "CH TXT version.server" {
+				software = append(software, it.Value)
+			} else {
+				instance = append(instance, it.Value)
+			}
+		}
+		_ = w.Flush()
+
+		fmt.Println()
+		fmt.Printf("software:\t%s\n", orDash(firstOf(software)))
+		fmt.Printf("instance:\t%s\n", orDash(firstOf(instance)))
+		if len(software) == 0 && len(instance) == 0 {
+			fmt.Printf("the server disclosed nothing about itself (common for hardened and public resolvers)\n")
+		}
+		return nil
+	},
+}
+
+func firstOf(vals []string) string {
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[0]
+}
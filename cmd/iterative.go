@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+)
+
+// runIterative is latency --iterative: every domain resolved from the root
+// hints, with the time per delegation level, next to the resolver's answer
+// time for the same name.
+func runIterative(ctx context.Context, server string, domains []string, opts dnsprobe.Options) error {
+	au := newAurora()
+	hints := rootHints()
+	var failed int
+	for i, d := range domains {
+		if ctx.Err() != nil {
+			fmt.Println()
+			printSkipped(ctx, au, "domain", domains[i:])
+			domains = domains[:i]
+			break
+		}
+		it, err := dnsprobe.ResolveIterative(ctx, d, opts.Type, hints, opts)
+		fmt.Printf("\n=== iterative: %s %s ===\n", it.Name, dns.TypeToString[it.Type])
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "level\tserver\taddress\tqname\trtt\toutcome")
+		for _, s := range it.Steps {
+			outcome := s.Outcome
+			if s.Err != nil {
+				outcome = au.Red(fmt.Sprintf("error: %v", s.Err)).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Level, s.Server, s.Addr, s.QName, s.RTT, outcome)
+		}
+		_ = w.Flush()
+		if err != nil {
+			failed++
+			fmt.Printf("%s %v\n", au.Red("failed:"), err)
+			continue
+		}
+
+		var queries time.Duration
+		for _, l := range it.Levels() {
+			queries += l.Time
+		}
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "by level\ttime\tshare\tqueries")
+		for _, l := range it.Levels() {
+			fmt.Fprintf(w, "%s\t%s\t%.0f%%\t%d\n", l.Level, l.Time, float64(l.Time)/float64(max(queries, 1))*100, l.Queries)
+		}
+		_ = w.Flush()
+
+		var values []string
+		for _, rr := range it.Answer {
+			if rr.Header().Rrtype == it.Type {
+				values = append(values, strings.TrimPrefix(rr.String(), rr.Header().String()))
+			}
+		}
+		fmt.Printf("\nresult:\t%s %s\n", it.RCode, orDash(strings.Join(values, ", ")))
+		fmt.Printf("iterative:\t%s (%s in %d queries)\n", it.Took, queries, len(it.Steps))
+		r, err := dnsprobe.Probe(ctx, server, d, opts)
+		if err != nil {
+			fmt.Printf("resolver %s:\t%s\n", server, au.Red(fmt.Sprintf("error: %v", err)))
+			continue
+		}
+		fmt.Printf("resolver %s:\t%s (%s)\n", server, r.Timings.Total, r.RCode)
+	}
+	fmt.Printf("\nthe resolver may answer from its cache; when it is slower than the iterative resolution, the delay is in the resolver rather than the authoritative servers\n")
+	if failed == len(domains) {
+		return fmt.Errorf("no domain could be resolved iteratively")
+	}
+	return nil
+}
@@ -2,23 +2,61 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
 	"text/tabwriter"
 	"time"
 
 	"dnsdoc/internal/dnsprobe"
+	"dnsdoc/internal/history"
+	"dnsdoc/internal/latency"
 
 	"github.com/logrusorgru/aurora/v4"
+	"github.com/miekg/dns"
 	"github.com/spf13/cobra"
 )
 
 var (
-	latencyBench   bool
-	latencyBrute   int
-	latencyDomains string
-	latencyCompare string
+	latencyBench    bool
+	latencyBrute    int
+	latencyDomains  string
+	latencyFile     string
+	latencyCompare  string
+	latencyFamily   bool
+	latencyStub     bool
+	latencyUncached bool
+	latencyOutliers float64
+	latencyTrim     float64
+	latencyRetries  int
+	latencyDuration time.Duration
+	latencyBucket   time.Duration
+	latencyPool     int
+	latencyPoolIdle time.Duration
+	latencyHistory  string
+	latencySections bool
+	latencyAttrib   bool
+	latencyType     string
+	latencyIter     bool
+	latencyOutput   string
+
+	// latencyNoise is the saved calibration, if any; differences within its
+	// jitter are shown as ties.
+	latencyNoise *dnsprobe.Calibration
+
+	latencyExpect     []string
+	latencyExpectFile string
+
+	latencySearch        bool
+	latencySearchDomains string
+	latencyNdots         int
+	latencySearchConfig  dnsprobe.SearchConfig
 )
 
 var latencyCmd = &cobra.Command{
@@ -26,123 +64,740 @@ var latencyCmd = &cobra.Command{
 	Short: "Measure detailed DNS request timings (serial) and caching behavior (bench/brute). Optionally compare two resolvers.",
 	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var server string
-		if len(args) == 1 {
-			server = args[0]
-		} else {
-			s, err := dnsprobe.SystemDefaultDNSServer()
-			if err != nil {
-				return fmt.Errorf("no dns-server arg and failed to detect system default resolver: %w", err)
+		if err := checkOutput(latencyOutput); err != nil {
+			return err
+		}
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+
+		// Ctrl-C ends the run early; benchmarks cut short are still
+		// reported, with the scoreboard so far.
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		opts := baseOptions()
+		qtype, ok := dns.StringToType[strings.ToUpper(latencyType)]
+		if !ok {
+			return fmt.Errorf("unknown record type %q", latencyType)
+		}
+		opts.Type = qtype
+		if latencyRetries < 0 {
+			return fmt.Errorf("--retries must be >= 0")
+		}
+		if latencyDuration < 0 || latencyBucket < 0 {
+			return fmt.Errorf("--duration and --bucket must be >= 0")
+		}
+		if latencyOutliers < 0 {
+			return fmt.Errorf("--outliers must be >= 0")
+		}
+		if latencyTrim < 0 || latencyTrim >= 50 {
+			return fmt.Errorf("--trim must be from 0 to under 50 (percent)")
+		}
+		if latencyDuration > 0 && !latencyBench && latencyBrute == 0 {
+			return fmt.Errorf("--duration needs --bench or --brute")
+		}
+		if latencyDuration > 0 && latencyBucket == 0 {
+			latencyBucket = time.Second
+			if latencyDuration > 5*time.Minute {
+				latencyBucket = time.Minute
 			}
-			server = s
+		}
+		latencyNoise = loadNoiseFloor()
+		if opts.Pool, err = newPool(latencyPool, latencyPoolIdle, server, latencyCompare); err != nil {
+			return err
+		}
+		if opts.Pool != nil {
+			defer opts.Pool.Close()
 		}
 
-		ctx := context.Background()
-		timeout := 3 * time.Second
+		exp, err := loadExpectations(latencyExpect, latencyExpectFile)
+		if err != nil {
+			return err
+		}
 
 		var domains []string
-		if strings.TrimSpace(latencyDomains) != "" {
-			for _, d := range strings.Split(latencyDomains, ",") {
-				d = strings.TrimSpace(d)
-				if d == "" {
-					continue
+		switch {
+		case latencyFile != "":
+			domains, err = readDomainsFile(latencyFile)
+		case latencyDomains == "" && exp.Len() > 0:
+			domains = qualifyNames(exp.Domains())
+		default:
+			domains, err = parseDomains(latencyDomains)
+		}
+		if err != nil {
+			return err
+		}
+
+		if latencyOutput == outputJSON && (latencyIter || latencyStub) {
+			return fmt.Errorf("--output json cannot be combined with --iterative or --stub")
+		}
+		if latencyIter {
+			if latencyCompare != "" || latencyFamily || latencyBrute > 0 || latencyBench || latencySearch {
+				return fmt.Errorf("--iterative cannot be combined with --compare, --family-compare, --bench, --brute or --search")
+			}
+			return runIterative(ctx, server, domains, opts)
+		}
+
+		if latencySearch {
+			cfg, err := dnsprobe.SystemSearchConfig()
+			if err != nil && latencySearchDomains == "" {
+				return fmt.Errorf("--search: failed to read system search list: %w", err)
+			}
+			if latencySearchDomains != "" {
+				cfg.Search = nil
+				for _, d := range strings.Split(latencySearchDomains, ",") {
+					if d = strings.TrimSpace(d); d != "" {
+						cfg.Search = append(cfg.Search, d)
+					}
 				}
-				domains = append(domains, d)
 			}
-			if len(domains) == 0 {
-				return fmt.Errorf("--domains provided but no valid domains found after parsing")
+			if latencyNdots >= 0 {
+				cfg.Ndots = latencyNdots
 			}
-		} else {
-			random128, err := dnsprobe.RandomDomain128WithCOM()
+			latencySearchConfig = cfg
+		}
+
+		serverB := strings.TrimSpace(latencyCompare)
+		window, isBaseline := strings.CutPrefix(serverB, "baseline:")
+		if isBaseline {
+			serverB = ""
+		}
+		if latencyBrute > 0 || latencyBench && latencyDuration > 0 {
+			targets := []string{server}
+			if serverB != "" {
+				targets = append(targets, serverB)
+			}
+			// Every benchmark of every name on every server runs for
+			// --duration in turn.
+			runs := len(domains) * len(targets)
+			if latencyFamily {
+				runs *= 2
+			}
+			if latencyBench && latencyBrute > 0 {
+				runs *= 2
+			}
+			mode := "latency --brute"
+			if latencyBrute == 0 {
+				mode = "latency --bench"
+			}
+			if err := guard(guardedRun{mode: mode, servers: targets, concurrency: latencyBrute, duration: latencyDuration * time.Duration(runs)}); err != nil {
+				return err
+			}
+		}
+		run := latency.Runner{
+			A:        latency.Target{Label: server, Server: server, Opts: opts},
+			B:        latency.Target{Label: serverB, Server: serverB, Opts: opts},
+			Names:    domains,
+			Brute:    latencyBrute,
+			Uncached: latencyUncached,
+			Retries:  latencyRetries,
+			Robust:   latencyOutliers > 0 || latencyTrim > 0,
+			Duration: latencyDuration,
+			Bucket:   latencyBucket,
+			Stub:     latencyStub,
+		}
+		if isBaseline {
+			if latencyFamily {
+				return fmt.Errorf("--family-compare and --compare cannot be combined")
+			}
+			if run.Baseline, run.B.Label, err = loadLatencyBaseline(server, opts, window); err != nil {
+				return err
+			}
+		}
+		if latencyFamily {
+			if serverB != "" {
+				return fmt.Errorf("--family-compare and --compare cannot be combined")
+			}
+			v4, v6, err := familyPair(ctx, server)
 			if err != nil {
 				return err
 			}
-			domains = []string{
-				"google.com",
-				"earentir.dev",
-				random128,
+			run.A.Server, run.B.Server = v4, v6
+			run.A.Opts.Family, run.B.Opts.Family = 4, 6
+			run.A.Label, run.B.Label = "IPv4 "+v4, "IPv6 "+v6
+		}
+		if latencyBench {
+			run.Serial = 10
+		}
+		if latencySearch {
+			run.Search = &latencySearchConfig
+		}
+
+		rep := &latencyReporter{au: newAurora(), exp: exp, run: &run, jsonOnly: latencyOutput == outputJSON}
+		if rep.jsonOnly || bundlingResults() {
+			rep.json = &latencyReportJSON{Server: run.A.Label, Compare: run.B.Label}
+		}
+		if run.Serial > 0 || run.Brute > 0 {
+			rep.progressA, rep.progressB = newBenchProgress(run.A.Label), newBenchProgress(run.B.Label)
+			run.A.Opts.Hooks, run.B.Opts.Hooks = rep.progressA.hooks(), rep.progressB.hooks()
+		}
+		run.Reporter = rep
+		if err := run.Run(ctx); err != nil {
+			if ctx.Err() == nil {
+				return err
+			}
+			if rep.json != nil {
+				rep.json.Skipped = run.Skipped
+				rep.json.Interrupted = true
+			}
+			if rep.jsonOnly {
+				if err := printJSON(rep.json); err != nil {
+					return err
+				}
+				return interruptExit(ctx, mismatchError(rep.mismatches))
+			}
+			rep.progressA.reset()
+			rep.progressB.reset()
+			fmt.Printf("\n%s the results above cover the run until then\n", rep.au.Yellow(stopReason(ctx)+":"))
+			printSkipped(ctx, rep.au, "domain", run.Skipped)
+		}
+		if rep.jsonOnly {
+			if err := printJSON(rep.json); err != nil {
+				return err
+			}
+			return interruptExit(ctx, mismatchError(rep.mismatches))
+		}
+		if run.B.Server != "" || run.Baseline != nil {
+			rep.score.print(rep.au, run.A.Label, run.B.Label)
+		}
+		if slowerB, ok := rep.score.consistent(); ok && latencyAttrib && run.Baseline == nil && ctx.Err() == nil {
+			printAttribution(ctx, &run, slowerB)
+		}
+		if opts.Pool != nil {
+			printPoolStats(os.Stdout, opts.Pool)
+		}
+		if rep.json != nil {
+			if err := saveBundleResults(rep.json); err != nil {
+				return err
 			}
 		}
+		return interruptExit(ctx, mismatchError(rep.mismatches))
+	},
+}
 
-		au := aurora.New(aurora.WithColors(true))
+func init() {
+	latencyCmd.Flags().StringVar(&latencyDomains, "domains", "", "CSV of domains to test (overrides the default set). Example: --domains google.com,example.org")
+	latencyCmd.Flags().StringVar(&latencyFile, "domains-file", "", "File with one domain per line (# comments allowed); overrides --domains.")
+	latencyCmd.Flags().StringVar(&latencyCompare, "compare", "", "Compare against another DNS server (host or host:port), or against this server's own stored history with baseline:last-day, baseline:last-week, baseline:last-month or baseline:<N>d. Example: --compare 9.9.9.9")
+	latencyCmd.Flags().StringVar(&latencyHistory, "history", "", "History file for --compare baseline:..., as written by dnsdoc profile record. Defaults to <user config dir>/dnsdoc/history.jsonl.")
+	latencyCmd.Flags().BoolVar(&latencyFamily, "family-compare", false, "Probe the same resolver over IPv4 and IPv6 side by side. The server must be a hostname (e.g. dns.google) or an \"IPv4,IPv6\" address pair.")
+	latencyCmd.Flags().BoolVar(&latencyAttrib, "attribute", false, "When --compare finds one resolver consistently slower, measure both again to explain why: network round trip, cache hits and upstream egress address.")
+	latencyCmd.Flags().StringVar(&latencyType, "type", "A", "Record type to query. SVCB and HTTPS answers are shown with their SvcParams (alpn, port, address hints, ECH) decoded.")
+	latencyCmd.Flags().BoolVar(&latencyIter, "iterative", false, "Resolve each domain without a recursive resolver, from the root hints through the TLD to the authoritative servers, and break the time down by delegation level; the resolver's own time is shown next to it, to tell resolver slowness from authoritative slowness.")
+	latencyCmd.Flags().BoolVar(&latencySections, "all-sections", false, "Also print the authority and additional records of each reply (the SOA of NXDOMAIN/NODATA answers, glue, EDNS options), not just their counts.")
+	latencyCmd.Flags().BoolVar(&latencyStub, "stub", false, "Also resolve each domain through the OS stub resolver (getaddrinfo) and show the overhead it adds over wire probes.")
+	latencyCmd.Flags().BoolVar(&latencySearch, "search", false, "Apply the host's search list and ndots to unqualified names, like applications do, and show the name actually queried.")
+	latencyCmd.Flags().StringVar(&latencySearchDomains, "search-domains", "", "CSV search list to use with --search instead of the system one.")
+	latencyCmd.Flags().IntVar(&latencyNdots, "ndots", -1, "ndots to use with --search instead of the system value.")
+	latencyCmd.Flags().StringArrayVar(&latencyExpect, "expect", nil, "Assert that a domain only returns these answers, e.g. --expect example.com=93.184.216.34 (repeatable; comma-separate several values). Mismatches exit with status 2. Without --domains the asserted domains are probed.")
+	latencyCmd.Flags().StringVar(&latencyExpectFile, "expect-file", "", "File of --expect assertions, one domain=value[,value...] per line (# comments allowed).")
+	latencyCmd.Flags().BoolVar(&latencyBench, "bench", false, "Repeat serially 10 times after the first request and print averages (caching check).")
+	latencyCmd.Flags().IntVar(&latencyBrute, "brute", 0, "Run N requests concurrently per domain and print averages (default disabled; typical N=250).")
+	latencyCmd.Flags().IntVar(&latencyPool, "pool", 0, "With --tcp or --proxy, keep up to N connections per server open and reuse them across queries instead of connecting for each one (0 disables).")
+	latencyCmd.Flags().DurationVar(&latencyPoolIdle, "pool-idle", 30*time.Second, "Close pooled connections idle for longer than this.")
+	latencyCmd.Flags().IntVar(&latencyRetries, "retries", 0, "Resend a --bench/--brute request that timed out up to N times, like a stub resolver, and report loss and retransmissions apart from failures (0 disables).")
+	latencyCmd.Flags().DurationVar(&latencyDuration, "duration", 0, "With --bench or --brute, keep benchmarking each domain for this long instead of a fixed count (--brute repeats its round of N), and print results per --bucket to show latency drift or throttling setting in. The duration applies per domain, per benchmark and, with --compare, per server in turn, so the run takes that many times longer.")
+	latencyCmd.Flags().DurationVar(&latencyBucket, "bucket", 0, "With --duration, the width of each time slice (default 1s, or 1m for durations over 5m).")
+	latencyCmd.Flags().Float64Var(&latencyOutliers, "outliers", 0, "With --bench or --brute, set aside replies slower than this many interquartile ranges above the third quartile, e.g. 3 (Tukey's far-out fence), list them separately and leave them out of the averages (0 disables).")
+	latencyCmd.Flags().Float64Var(&latencyTrim, "trim", 0, "With --bench or --brute, average over the middle replies only, dropping this percentage of the fastest and as many of the slowest, e.g. 10, so a single retransmission does not skew averages and comparisons (0 disables).")
+	latencyCmd.Flags().StringVar(&latencyOutput, "output", outputText, "Report format: text, or json of every probe and benchmark, which dnsdoc view can render again later.")
+	latencyCmd.Flags().BoolVar(&latencyUncached, "uncached", false, "Send every --bench/--brute request to a unique random subdomain so it misses the resolver's cache, measuring recursion instead of cache hits. Most such names are NXDOMAIN; resolvers with aggressive NSEC caching (RFC 8198) may still answer signed zones from cache.")
+}
 
-		for _, name := range domains {
-			if strings.TrimSpace(latencyCompare) == "" {
-				r, err := dnsprobe.ProbeA(ctx, server, name, timeout)
-				if err != nil {
-					printErrorBlock(server, name, err)
-				} else {
-					printResultBlock(r)
-				}
+// loadLatencyBaseline reads the history of server over the window named by
+// --compare baseline:<window> and returns it with a label for column B.
+func loadLatencyBaseline(server string, opts dnsprobe.Options, window string) (*latency.Baseline, string, error) {
+	var span time.Duration
+	switch window {
+	case "last-day":
+		span = 24 * time.Hour
+	case "last-week":
+		span = 7 * 24 * time.Hour
+	case "last-month":
+		span = 30 * 24 * time.Hour
+	default:
+		days, err := strconv.Atoi(strings.TrimSuffix(window, "d"))
+		if err != nil || !strings.HasSuffix(window, "d") || days < 1 {
+			return nil, "", fmt.Errorf("invalid baseline %q (want last-day, last-week, last-month or <N>d)", window)
+		}
+		span = time.Duration(days) * 24 * time.Hour
+	}
 
-				if latencyBench {
-					bench := dnsprobe.BenchmarkSerial(ctx, server, name, timeout, 10)
-					printBenchmarkBlock("bench (serial x10)", bench)
-				}
+	path, err := historyPath(latencyHistory)
+	if err != nil {
+		return nil, "", err
+	}
+	server = dnsprobe.NormalizeServer(server)
+	qtype := dns.TypeToString[opts.Type]
+	if opts.Type == 0 {
+		qtype = "A"
+	}
+	since := time.Now().Add(-span)
+	recs, err := history.Load(path, func(r history.Record) bool {
+		return r.Server == server && r.QType == qtype && r.Time.After(since)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(recs) == 0 {
+		return nil, "", fmt.Errorf("no %s history for %s in %s (%s); run `dnsdoc profile record` first", qtype, server, path, window)
+	}
+	return latency.NewBaseline(recs), fmt.Sprintf("%s baseline (%s, %d stored probes)", server, window, len(recs)), nil
+}
 
-				if latencyBrute > 0 {
-					br := dnsprobe.BenchmarkConcurrent(ctx, server, name, timeout, latencyBrute)
-					printBenchmarkBlock(fmt.Sprintf("brute (concurrent x%d)", latencyBrute), br)
-				}
-				continue
+// familyPair returns the IPv4 and IPv6 addresses (host:port) of one resolver,
+// either from an explicit "v4,v6" pair or by resolving a hostname both ways.
+func familyPair(ctx context.Context, server string) (string, string, error) {
+	var v4, v6 string
+	if a, b, ok := strings.Cut(server, ","); ok {
+		for _, s := range []string{a, b} {
+			s = dnsprobe.NormalizeServer(strings.TrimSpace(s))
+			host, _, _ := net.SplitHostPort(s)
+			ip := net.ParseIP(host)
+			switch {
+			case ip == nil:
+				return "", "", fmt.Errorf("--family-compare: %s is not an IP address", s)
+			case ip.To4() != nil:
+				v4 = s
+			default:
+				v6 = s
 			}
+		}
+		if v4 == "" || v6 == "" {
+			return "", "", fmt.Errorf("--family-compare: need one IPv4 and one IPv6 address, got %s", server)
+		}
+		return v4, v6, nil
+	}
 
-			rA, errA := dnsprobe.ProbeA(ctx, server, name, timeout)
-			rB, errB := dnsprobe.ProbeA(ctx, latencyCompare, name, timeout)
+	host, port, _ := net.SplitHostPort(dnsprobe.NormalizeServer(server))
+	if net.ParseIP(host) != nil {
+		return "", "", fmt.Errorf("--family-compare: %s is a literal address; pass a hostname (e.g. dns.google) or an \"IPv4,IPv6\" pair", server)
+	}
+	ips4, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return "", "", fmt.Errorf("--family-compare: no IPv4 address for %s: %w", host, err)
+	}
+	ips6, err := net.DefaultResolver.LookupIP(ctx, "ip6", host)
+	if err != nil {
+		return "", "", fmt.Errorf("--family-compare: no IPv6 address for %s: %w", host, err)
+	}
+	return net.JoinHostPort(ips4[0].String(), port), net.JoinHostPort(ips6[0].String(), port), nil
+}
 
-			fmt.Printf("\n=== %s (compare) ===\n", name)
-			fmt.Printf("A:\t%s\n", server)
-			fmt.Printf("B:\t%s\n", latencyCompare)
+// latencyReportJSON is the --output json report. Compare is the label of
+// the second server or baseline, if any.
+type latencyReportJSON struct {
+	Server      string             `json:"server"`
+	Compare     string             `json:"compare,omitempty"`
+	Probes      []latencyProbeJSON `json:"probes"`
+	Benchmarks  []latencyBenchJSON `json:"benchmarks,omitempty"`
+	Interrupted bool               `json:"interrupted,omitempty"`
+	Skipped     []string           `json:"skipped,omitempty"`
+}
 
-			if errA != nil || errB != nil {
-				if errA != nil {
-					fmt.Printf("\nA error:\t%v\n", errA)
-				}
-				if errB != nil {
-					fmt.Printf("B error:\t%v\n", errB)
-				}
-			} else {
-				printCompareTimingsTable(au, rA, rB)
+type latencyProbeJSON struct {
+	Name     string           `json:"name"`
+	Server   string           `json:"server"`
+	RCode    string           `json:"rcode,omitempty"`
+	Answers  []string         `json:"answers,omitempty"`
+	Timings  dnsprobe.Timings `json:"timings"`
+	Error    string           `json:"error,omitempty"`
+	Mismatch string           `json:"mismatch,omitempty"`
+}
+
+type latencyBenchJSON struct {
+	Name      string         `json:"name"`
+	Server    string         `json:"server"`
+	Benchmark string         `json:"benchmark"`
+	Attempts  int            `json:"attempts"`
+	Success   int            `json:"success"`
+	Fail      int            `json:"fail"`
+	Lost      int            `json:"lost"`
+	Avg       time.Duration  `json:"avg_ns"`
+	Failures  map[string]int `json:"failures,omitempty"`
+}
+
+// latencyReporter prints a latency run as it goes, checks --expect and keeps
+// the compare scoreboard. With json set it also collects the JSON report,
+// and with jsonOnly it only does that.
+type latencyReporter struct {
+	au         *aurora.Aurora
+	exp        *dnsprobe.Expectations
+	run        *latency.Runner
+	score      compareScoreboard
+	mismatches int
+	// progressA and progressB show benchmarks of A and B as they run.
+	progressA, progressB *benchProgress
+	json                 *latencyReportJSON
+	jsonOnly             bool
+	// name is the name of the last probe, which the benchmarks after it
+	// measure.
+	name string
+}
+
+func (p *latencyReporter) Probe(a, b *latency.Outcome) {
+	p.name = a.Name
+	if p.json != nil {
+		p.addProbeJSON(p.run.A.Label, a, true)
+		if b != nil {
+			p.addProbeJSON(p.run.B.Label, b, p.run.Baseline == nil)
+		}
+	}
+	if p.jsonOnly {
+		return
+	}
+	if p.run.Search != nil {
+		printSearchAttempts(p.run.A.Server, a.Name, a.Search)
+		if b != nil && p.run.Baseline == nil {
+			printSearchAttempts(p.run.B.Server, b.Name, b.Search)
+		}
+	}
+	if b == nil {
+		if a.Err != nil {
+			printErrorBlock(a.Result, a.Err)
+		} else {
+			printResultBlock(a.Result)
+		}
+		if checkExpectation(p.exp, "", a.Result, a.Err) {
+			p.mismatches++
+		}
+		return
+	}
+
+	fmt.Printf("\n=== %s (compare) ===\n", a.Name)
+	fmt.Printf("A:\t%s\n", p.run.A.Label)
+	fmt.Printf("B:\t%s\n", p.run.B.Label)
+	if a.Err != nil || b.Err != nil {
+		if a.Err != nil {
+			fmt.Printf("\nA error:\t%v\n", a.Err)
+		}
+		if b.Err != nil {
+			fmt.Printf("B error:\t%v\n", b.Err)
+		}
+	} else {
+		printCompareTimingsTable(p.au, a.Result, b.Result)
+	}
+	if !errors.Is(b.Err, latency.ErrNoBaseline) {
+		p.score.add(a.Result, a.Err, b.Result, b.Err)
+	}
+	if checkExpectation(p.exp, "A ", a.Result, a.Err) {
+		p.mismatches++
+	}
+	if p.run.Baseline == nil && checkExpectation(p.exp, "B ", b.Result, b.Err) {
+		p.mismatches++
+	}
+}
+
+func (p *latencyReporter) Stub(name string, wire, stub dnsprobe.Lookup) {
+	printStubBlock(p.run.A.Server, wire, stub)
+}
+
+func (p *latencyReporter) Benchmark(a, b *latency.Bench) {
+	p.progressA.reset()
+	p.progressB.reset()
+	if p.json != nil {
+		p.addBenchJSON(p.run.A.Label, a)
+		if b != nil {
+			p.addBenchJSON(p.run.B.Label, b)
+		}
+	}
+	if p.jsonOnly {
+		return
+	}
+	resA, robustA := robustBench(a.Result)
+	if b == nil {
+		printBenchmarkBlock(a.Label(), resA)
+		printRobustNote(p.au, "", a.Result, robustA)
+		printBenchFailures(p.au, "", a.Result.Failures, nil)
+		printBenchBuckets(p.au, a.Label(), p.run.Bucket, a.Result.Buckets)
+		if a.Concurrent {
+			printConsistency(p.au, "", a.Result.Consistency)
+		}
+		return
+	}
+	resB, robustB := robustBench(b.Result)
+	printCompareBenchmarkTimingsTable(p.au, a.Label(), resA, resB)
+	printRobustNote(p.au, "A ", a.Result, robustA)
+	printRobustNote(p.au, "B ", b.Result, robustB)
+	printBenchFailures(p.au, a.Label()+" ", a.Result.Failures, b.Result.Failures)
+	printBenchBuckets(p.au, "A "+a.Label(), p.run.Bucket, a.Result.Buckets)
+	printBenchBuckets(p.au, "B "+b.Label(), p.run.Bucket, b.Result.Buckets)
+	if a.Concurrent {
+		printConsistency(p.au, "A ", a.Result.Consistency)
+		printConsistency(p.au, "B ", b.Result.Consistency)
+	}
+}
+
+// addProbeJSON adds o to the report, checking it against --expect when
+// check is set. Mismatches count towards the exit status only in a JSON
+// report; the text report counts its own.
+func (p *latencyReporter) addProbeJSON(server string, o *latency.Outcome, check bool) {
+	j := latencyProbeJSON{Name: o.Name, Server: server, RCode: o.Result.RCode, Timings: o.Result.Timings}
+	for _, a := range o.Result.Answers {
+		j.Answers = append(j.Answers, a.Type+" "+a.Value)
+	}
+	switch {
+	case o.Err != nil:
+		j.Error = o.Err.Error()
+	case check && p.exp.Len() > 0:
+		if m := p.exp.Check(o.Result); m != nil {
+			j.Mismatch = m.String()
+			if p.jsonOnly {
+				p.mismatches++
 			}
+		}
+	}
+	p.json.Probes = append(p.json.Probes, j)
+}
+
+func (p *latencyReporter) addBenchJSON(server string, b *latency.Bench) {
+	res, _ := robustBench(b.Result)
+	p.json.Benchmarks = append(p.json.Benchmarks, latencyBenchJSON{
+		Name:      p.name,
+		Server:    server,
+		Benchmark: b.Label(),
+		Attempts:  res.Attempts,
+		Success:   res.Success,
+		Fail:      res.Fail,
+		Lost:      res.Lost,
+		Avg:       res.Avg.Total,
+		Failures:  res.Failures,
+	})
+}
 
-			if latencyBench {
-				benchA := dnsprobe.BenchmarkSerial(ctx, server, name, timeout, 10)
-				benchB := dnsprobe.BenchmarkSerial(ctx, latencyCompare, name, timeout, 10)
-				printCompareBenchmarkTimingsTable(au, "bench (serial x10)", benchA, benchB)
+// robustBench returns b with its averages taken without outliers and
+// trimmed as --outliers and --trim ask, and how they were taken; the
+// RobustAvg is nil when neither is set.
+func robustBench(b dnsprobe.Benchmark) (dnsprobe.Benchmark, *dnsprobe.RobustAvg) {
+	if latencyOutliers == 0 && latencyTrim == 0 {
+		return b, nil
+	}
+	r := b.Robust(latencyOutliers, latencyTrim/100)
+	b.Avg = r.Avg
+	return b, &r
+}
+
+// printRobustNote says which replies the averages of b cover and lists the
+// outliers set aside.
+func printRobustNote(au *aurora.Aurora, label string, b dnsprobe.Benchmark, r *dnsprobe.RobustAvg) {
+	if r == nil {
+		return
+	}
+	fmt.Printf("%saverages:\tover %d of %d replies", label, r.Used, b.Success)
+	if r.Trimmed > 0 {
+		fmt.Printf(", %d trimmed (%g%% from each end)", r.Trimmed, latencyTrim)
+	}
+	fmt.Println()
+	if latencyOutliers == 0 {
+		return
+	}
+	switch {
+	case r.High == 0:
+		fmt.Printf("%soutliers:\ttoo few replies to look for any\n", label)
+		return
+	case len(r.Outliers) == 0:
+		fmt.Printf("%soutliers:\tnone slower than %g×IQR above the third quartile (%s)\n", label, latencyOutliers, r.High.Round(time.Microsecond))
+		return
+	}
+	values := make([]string, len(r.Outliers))
+	for i, d := range r.Outliers {
+		values[i] = d.Round(time.Microsecond).String()
+	}
+	if n := len(values); n > 10 {
+		values = append(append(values[:5:5], "..."), values[n-5:]...)
+	}
+	list := strings.Join(values, " ")
+	fmt.Printf("%soutliers:\t%s slower than %g×IQR above the third quartile (%s), set aside: %s\n", label,
+		au.Yellow(fmt.Sprint(len(r.Outliers))), latencyOutliers, r.High.Round(time.Microsecond), list)
+}
+
+// printBenchFailures breaks a benchmark's failures down by class, most
+// frequent first; with b set it sets A's counts against B's.
+func printBenchFailures(au *aurora.Aurora, label string, a, b map[string]int) {
+	if len(a)+len(b) == 0 {
+		return
+	}
+	var classes []string
+	for _, m := range []map[string]int{a, b} {
+		for c := range m {
+			if !slices.Contains(classes, c) {
+				classes = append(classes, c)
 			}
+		}
+	}
+	slices.SortFunc(classes, func(x, y string) int {
+		if d := a[y] + b[y] - a[x] - b[x]; d != 0 {
+			return d
+		}
+		return strings.Compare(x, y)
+	})
+	fmt.Printf("\n%sfailures:\n", label)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if b == nil {
+		fmt.Fprintln(w, "class\tcount\tmeaning")
+	} else {
+		fmt.Fprintln(w, "class\tA\tB\tmeaning")
+	}
+	for _, c := range classes {
+		counts := au.Red(fmt.Sprint(a[c])).String()
+		if b != nil {
+			counts = fmt.Sprintf("%d\t%d", a[c], b[c])
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c, counts, dnsprobe.FailureMeanings[c])
+	}
+	_ = w.Flush()
+}
+
+// printBenchBuckets prints a timed benchmark slice by slice, then how the
+// median moved from the first slice to the last and where failures set in
+// if the run began without any.
+func printBenchBuckets(au *aurora.Aurora, label string, width time.Duration, buckets []dnsprobe.BenchBucket) {
+	if len(buckets) == 0 {
+		return
+	}
+	fmt.Printf("\n%s over time (%s buckets):\n", label, width)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "offset\tqueries\tfail\tlost\tmean\tp50\tp90\tmax")
+	var answered []dnsprobe.BenchBucket
+	for _, b := range buckets {
+		fail := fmt.Sprint(b.Fail)
+		if b.Fail > 0 {
+			fail = au.Red(fmt.Sprintf("%d (%.0f%%)", b.Fail, b.FailRatio()*100)).String()
+		}
+		if b.Success > 0 {
+			answered = append(answered, b)
+		}
+		fmt.Fprintf(w, "+%s\t%d\t%s\t%d\t%s\t%s\t%s\t%s\n", b.Offset, b.Attempts, fail, b.Lost,
+			b.Mean.Round(time.Microsecond), b.P50.Round(time.Microsecond), b.P90.Round(time.Microsecond), b.Max.Round(time.Microsecond))
+	}
+	_ = w.Flush()
 
-			if latencyBrute > 0 {
-				brA := dnsprobe.BenchmarkConcurrent(ctx, server, name, timeout, latencyBrute)
-				brB := dnsprobe.BenchmarkConcurrent(ctx, latencyCompare, name, timeout, latencyBrute)
-				printCompareBenchmarkTimingsTable(au, fmt.Sprintf("brute (concurrent x%d)", latencyBrute), brA, brB)
+	if len(answered) >= 2 {
+		first, last := answered[0], answered[len(answered)-1]
+		line := fmt.Sprintf("p50 %s at +%s -> %s at +%s", first.P50.Round(time.Microsecond), first.Offset, last.P50.Round(time.Microsecond), last.Offset)
+		if first.P50 > 0 && last.P50 > first.P50*3/2 {
+			line = au.Yellow(fmt.Sprintf("%s (%.1fx)", line, float64(last.P50)/float64(first.P50))).String()
+		}
+		fmt.Printf("drift:\t%s\n", line)
+	}
+	if len(buckets) >= 2 {
+		var p50s []time.Duration
+		for _, b := range buckets[max(0, len(buckets)-2*sparkWidth):] {
+			if b.Success == 0 {
+				p50s = append(p50s, -1)
+			} else {
+				p50s = append(p50s, b.P50)
 			}
 		}
+		fmt.Printf("p50 trend:\t%s\n", sparkline(au, p50s))
+	}
+	for i, b := range buckets {
+		if b.Fail == 0 {
+			continue
+		}
+		if i == 0 {
+			break
+		}
+		var n, failed int
+		for _, r := range buckets[i:] {
+			n, failed = n+r.Attempts, failed+r.Fail
+		}
+		fmt.Printf("%s none failed before +%s, %.0f%% from then on; the server or something on the path may be rate limiting\n",
+			au.Yellow("failures:"), b.Offset, float64(failed)/float64(n)*100)
+		break
+	}
+}
 
-		return nil
-	},
+func printSearchAttempts(server, name string, attempts []dnsprobe.SearchAttempt) {
+	fmt.Printf("\nsearch %s via %s (ndots=%d, search=%s):\n", name, server, latencySearchConfig.Ndots, strings.Join(latencySearchConfig.Search, ","))
+	for i, a := range attempts {
+		status := a.RCode
+		if a.Err != nil {
+			status = a.Err.Error()
+		} else if a.RCode == "NOERROR" {
+			status = fmt.Sprintf("NOERROR (%d answers)", a.Answers)
+		}
+		marker := "  "
+		if i == len(attempts)-1 {
+			marker = "->"
+		}
+		fmt.Printf("  %s %s\t%s\n", marker, a.Name, status)
+	}
 }
 
-func init() {
-	latencyCmd.Flags().StringVar(&latencyDomains, "domains", "", "CSV of domains to test (overrides the default set). Example: --domains google.com,example.org")
-	latencyCmd.Flags().StringVar(&latencyCompare, "compare", "", "Compare against another DNS server (host or host:port). Example: --compare 9.9.9.9")
-	latencyCmd.Flags().BoolVar(&latencyBench, "bench", false, "Repeat serially 10 times after the first request and print averages (caching check).")
-	latencyCmd.Flags().IntVar(&latencyBrute, "brute", 0, "Run N requests concurrently per domain and print averages (default disabled; typical N=250).")
+func printStubBlock(server string, wire, stub dnsprobe.Lookup) {
+	fmt.Printf("\nSystem stub vs wire (A+AAAA):\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "path\tduration\tresult")
+	for _, l := range []dnsprobe.Lookup{wire, stub} {
+		res := strings.Join(l.Addrs, ",")
+		if l.Err != nil {
+			res = l.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", l.Path, l.Duration, orDash(res))
+	}
+	_ = w.Flush()
+
+	if wire.Err == nil && stub.Err == nil {
+		fmt.Printf("stub overhead:\t%s (nsswitch, local caches, search processing)\n", stub.Duration-wire.Duration)
+	}
+	if def, err := dnsprobe.SystemDefaultDNSServer(); err == nil && dnsprobe.NormalizeServer(def) != dnsprobe.NormalizeServer(server) {
+		fmt.Printf("note:\tthe stub uses the system resolver %s, not %s\n", def, server)
+	}
 }
 
-func printErrorBlock(server, name string, err error) {
-	fmt.Printf("\n=== %s ===\n", name)
-	fmt.Printf("server:\t%s\n", server)
+func printSection(name string, rrs []string) {
+	if len(rrs) == 0 {
+		return
+	}
+	fmt.Printf("  %s:\n", name)
+	for _, rr := range rrs {
+		fmt.Printf("    - %s\n", rr)
+	}
+}
+
+func printErrorBlock(r dnsprobe.Result, err error) {
+	fmt.Printf("\n=== %s ===\n", r.QName)
+	fmt.Printf("server:\t%s\n", r.Server)
 	fmt.Printf("error:\t%v\n", err)
+	if r.LocalAddr != "" {
+		fmt.Printf("local:\t%s\n", r.LocalAddr)
+		fmt.Printf("remote:\t%s\n", r.RemoteAddr)
+	}
+
+	fmt.Printf("\nTimings before failure (wall-clock):\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "phase\tduration")
+	fmt.Fprintf(w, "total\t%s\n", r.Timings.Total)
+	fmt.Fprintf(w, "pack\t%s\n", r.Timings.Pack)
+	fmt.Fprintf(w, "dial\t%s\n", r.Timings.Dial)
+	if r.Proxy != "" {
+		fmt.Fprintf(w, "proxy\t%s\n", r.Timings.Proxy)
+	}
+	fmt.Fprintf(w, "write\t%s\n", r.Timings.Write)
+	fmt.Fprintf(w, "read\t%s\n", r.Timings.Read)
+	fmt.Fprintf(w, "unpack\t%s\n", r.Timings.Unpack)
+	_ = w.Flush()
 }
 
 func printResultBlock(r dnsprobe.Result) {
 	fmt.Printf("\n=== %s ===\n", r.QName)
 	fmt.Printf("server:\t%s\n", r.Server)
 	fmt.Printf("network:\t%s\n", r.Network)
+	if r.Proxy != "" {
+		fmt.Printf("proxy:\t%s\n", r.Proxy)
+	}
 	fmt.Printf("local:\t%s\n", r.LocalAddr)
 	fmt.Printf("remote:\t%s\n", r.RemoteAddr)
 	fmt.Printf("timeout:\t%s\n", r.Timeout)
-	fmt.Printf("qtype:\tA\n")
+	fmt.Printf("qtype:\t%s\n", r.QType)
+	fmt.Printf("qname(wire):\t%s\n", r.WireQName)
+	if r.ResponseQName != "" && r.ResponseQName != r.WireQName {
+		fmt.Printf("note:\tresponse question %s differs from the query name (case not echoed exactly)\n", r.ResponseQName)
+	} else if rootDNS0x20 {
+		fmt.Printf("0x20:\tcase echoed exactly\n")
+	}
 
 	fmt.Printf("\nresponse:\n")
 	fmt.Printf("  rcode:\t%s\n", r.RCode)
@@ -155,21 +810,41 @@ func printResultBlock(r dnsprobe.Result) {
 	if len(r.Answers) > 0 {
 		fmt.Printf("  answers:\n")
 		for _, a := range r.Answers {
-			fmt.Printf("    - %s\tTTL=%d\n", a.Value, a.TTL)
+			fmt.Printf("    - %s\n", a.RR)
+			if b := a.Service; b != nil {
+				fmt.Printf("        %s mode, priority %d, target %s\n", b.Mode(), b.Priority, b.Target)
+				for _, p := range b.Params {
+					fmt.Printf("        %s:\t%s\n", p.Key, p.Value)
+				}
+			}
 		}
 	}
+	if latencySections {
+		printSection("authority", r.Authority)
+		printSection("additional", r.Additional)
+	}
 
 	fmt.Printf("\nTimings (wall-clock):\n")
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	fmt.Fprintln(w, "phase\tduration\tnotes")
 	fmt.Fprintf(w, "total\t%s\t-\n", r.Timings.Total)
-	fmt.Fprintf(w, "dial\t%s\tudp dial to server\n", r.Timings.Dial)
+	if r.Reused {
+		fmt.Fprintf(w, "dial\t%s\treused pooled %s connection\n", r.Timings.Dial, r.Network)
+	} else {
+		fmt.Fprintf(w, "dial\t%s\t%s dial to server\n", r.Timings.Dial, r.Network)
+	}
+	if r.Proxy != "" {
+		fmt.Fprintf(w, "proxy\t%s\tconnect to proxy (part of dial)\n", r.Timings.Proxy)
+	}
 	fmt.Fprintf(w, "pack\t%s\tdns message -> wire bytes\n", r.Timings.Pack)
 	fmt.Fprintf(w, "write\t%s\twrite query bytes\n", r.Timings.Write)
 	fmt.Fprintf(w, "read\t%s\tread response bytes\n", r.Timings.Read)
 	fmt.Fprintf(w, "unpack\t%s\twire bytes -> dns message\n", r.Timings.Unpack)
 	fmt.Fprintf(w, "rtt(approx)\t%s\twrite+read (useful for caching deltas)\n", r.Timings.RTTApprox)
 	_ = w.Flush()
+	if latencyNoise != nil {
+		fmt.Println(noiseFloorNote(latencyNoise))
+	}
 }
 
 func printBenchmarkBlock(label string, b dnsprobe.Benchmark) {
@@ -179,23 +854,99 @@ func printBenchmarkBlock(label string, b dnsprobe.Benchmark) {
 	fmt.Fprintf(w, "attempts\t%d\n", b.Attempts)
 	fmt.Fprintf(w, "success\t%d\n", b.Success)
 	fmt.Fprintf(w, "fail\t%d\n", b.Fail)
+	fmt.Fprintf(w, "lost\t%d (timed out on every try)\n", b.Lost)
+	fmt.Fprintf(w, "loss\t%s\n", lossString(b))
+	fmt.Fprintf(w, "rcodes\t%s\n", rcodeShares(b.RCodes))
 	fmt.Fprintf(w, "avg_total\t%s\n", b.Avg.Total)
 	fmt.Fprintf(w, "avg_dial\t%s\n", b.Avg.Dial)
+	if b.Avg.Proxy > 0 {
+		fmt.Fprintf(w, "avg_proxy\t%s\n", b.Avg.Proxy)
+	}
 	fmt.Fprintf(w, "avg_pack\t%s\n", b.Avg.Pack)
 	fmt.Fprintf(w, "avg_write\t%s\n", b.Avg.Write)
 	fmt.Fprintf(w, "avg_read\t%s\n", b.Avg.Read)
 	fmt.Fprintf(w, "avg_unpack\t%s\n", b.Avg.Unpack)
 	fmt.Fprintf(w, "avg_rtt(approx)\t%s\n", b.Avg.RTTApprox)
+	fmt.Fprintf(w, "cache_hits(est)\t%s\n", cacheEstimateString(b.Cache))
+	fmt.Fprintf(w, "ttls\t%s\n", benchTTLString(b.TTLs))
 	_ = w.Flush()
 }
 
+// ttlPatterns explains the patterns BenchTTLs reads TTLs as.
+var ttlPatterns = map[string]string{
+	dnsprobe.TTLFixed:    "never counted down; the resolver resets or rewrites TTLs, or answers authoritatively",
+	dnsprobe.TTLShared:   "counted down in step with the clock; one cache answered every query",
+	dnsprobe.TTLRefilled: "counted down in one cache, which fetched the record again as it expired",
+	dnsprobe.TTLSeveral:  "counted down on separate clocks; queries went to backends with caches of their own",
+}
+
+func benchTTLString(t dnsprobe.BenchTTLs) string {
+	if t.Samples == 0 {
+		return "-"
+	}
+	s := fmt.Sprintf("min %ds, max %ds, %d down, %d up over %s", t.Min, t.Max, t.Decrements, t.Rises, t.Span.Round(time.Millisecond))
+	switch t.Pattern {
+	case "":
+		return s + "; too few replies or too short a run to tell a pattern"
+	case dnsprobe.TTLSeveral:
+		return fmt.Sprintf("%s; %s: %d caches, %s", s, t.Pattern, t.Caches, ttlPatterns[t.Pattern])
+	}
+	return fmt.Sprintf("%s; %s: %s", s, t.Pattern, ttlPatterns[t.Pattern])
+}
+
+func cacheEstimateString(e dnsprobe.CacheEstimate) string {
+	if !e.Known() {
+		return "unknown (latencies did not split and TTLs did not count down)"
+	}
+	s := fmt.Sprintf("%d/%d (%.0f%%)", e.Hits, e.Hits+e.Misses, e.Ratio()*100)
+	var why []string
+	if e.Threshold > 0 {
+		why = append(why, "misses slower than "+e.Threshold.Round(time.Microsecond).String())
+	}
+	if e.TTLHits > 0 {
+		why = append(why, fmt.Sprintf("%d with a TTL counted down", e.TTLHits))
+	}
+	return s + "; " + strings.Join(why, ", ")
+}
+
+// printConsistency lists the distinct answer sets seen during a brute run and
+// flags anything that looks like spoofing.
+func printConsistency(au *aurora.Aurora, label string, c dnsprobe.Consistency) {
+	if c.Replies == 0 && len(c.Strays) == 0 {
+		return
+	}
+	fmt.Printf("\n%sconsistency (%d replies):\n", label, c.Replies)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "count\tanswer set")
+	for _, s := range c.AnswerSets {
+		fmt.Fprintf(w, "%d\t%s\n", s.Count, s)
+	}
+	_ = w.Flush()
+	if c.Replies >= 2 {
+		sent, echoed := dnsprobe.SpreadOf(c.SentIDs), dnsprobe.SpreadOf(c.EchoedIDs)
+		fmt.Printf("message IDs:\tsent ~%.1f bits (%s), echoed ~%.1f bits (%s)\n",
+			sent.Bits, colorPortRating(au, dnsprobe.PortRating(sent)), echoed.Bits, colorPortRating(au, dnsprobe.PortRating(echoed)))
+	}
+	anomalies := c.Anomalies()
+	if len(anomalies) == 0 {
+		fmt.Printf("%s all replies agreed, matched their query IDs and came from the server\n", au.Green("ok:"))
+		return
+	}
+	for _, a := range anomalies {
+		fmt.Printf("%s %s\n", au.Yellow("anomaly:"), a)
+	}
+}
+
 func printCompareTimingsTable(au *aurora.Aurora, a dnsprobe.Result, b dnsprobe.Result) {
 	fmt.Printf("\nTimings compare (lower is better):\n")
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	fmt.Fprintln(w, "phase\tA\tB\tnotes")
 
 	printCompareDurRow(au, w, "total", a.Timings.Total, b.Timings.Total, "-")
-	printCompareDurRow(au, w, "dial", a.Timings.Dial, b.Timings.Dial, "udp dial to server")
+	printCompareDurRow(au, w, "dial", a.Timings.Dial, b.Timings.Dial, "dial to server")
+	if a.Proxy != "" {
+		printCompareDurRow(au, w, "proxy", a.Timings.Proxy, b.Timings.Proxy, "connect to proxy (part of dial)")
+	}
 	printCompareDurRow(au, w, "pack", a.Timings.Pack, b.Timings.Pack, "dns message -> wire bytes")
 	printCompareDurRow(au, w, "write", a.Timings.Write, b.Timings.Write, "write query bytes")
 	printCompareDurRow(au, w, "read", a.Timings.Read, b.Timings.Read, "read response bytes")
@@ -203,6 +954,9 @@ func printCompareTimingsTable(au *aurora.Aurora, a dnsprobe.Result, b dnsprobe.R
 	printCompareDurRow(au, w, "rtt(approx)", a.Timings.RTTApprox, b.Timings.RTTApprox, "write+read")
 
 	_ = w.Flush()
+	if latencyNoise != nil {
+		fmt.Println(noiseFloorNote(latencyNoise))
+	}
 }
 
 func printCompareBenchmarkTimingsTable(au *aurora.Aurora, label string, a dnsprobe.Benchmark, b dnsprobe.Benchmark) {
@@ -211,18 +965,61 @@ func printCompareBenchmarkTimingsTable(au *aurora.Aurora, label string, a dnspro
 	fmt.Fprintln(w, "phase\tA\tB\tnotes")
 
 	printCompareDurRow(au, w, "avg_total", a.Avg.Total, b.Avg.Total, "-")
-	printCompareDurRow(au, w, "avg_dial", a.Avg.Dial, b.Avg.Dial, "udp dial to server")
+	printCompareDurRow(au, w, "avg_dial", a.Avg.Dial, b.Avg.Dial, "dial to server")
 	printCompareDurRow(au, w, "avg_pack", a.Avg.Pack, b.Avg.Pack, "dns message -> wire bytes")
 	printCompareDurRow(au, w, "avg_write", a.Avg.Write, b.Avg.Write, "write query bytes")
 	printCompareDurRow(au, w, "avg_read", a.Avg.Read, b.Avg.Read, "read response bytes")
 	printCompareDurRow(au, w, "avg_unpack", a.Avg.Unpack, b.Avg.Unpack, "wire bytes -> dns message")
 	printCompareDurRow(au, w, "avg_rtt(approx)", a.Avg.RTTApprox, b.Avg.RTTApprox, "write+read")
-
 	_ = w.Flush()
+
+	fmt.Printf("A cache hits (est):\t%s\n", cacheEstimateString(a.Cache))
+	fmt.Printf("B cache hits (est):\t%s\n", cacheEstimateString(b.Cache))
+	fmt.Printf("A ttls:\t%s\n", benchTTLString(a.TTLs))
+	fmt.Printf("B ttls:\t%s\n", benchTTLString(b.TTLs))
+	fmt.Printf("A loss:\t%s, %d lost\n", lossString(a), a.Lost)
+	fmt.Printf("B loss:\t%s, %d lost\n", lossString(b), b.Lost)
+	fmt.Printf("A rcodes:\t%s\n", rcodeShares(a.RCodes))
+	fmt.Printf("B rcodes:\t%s\n", rcodeShares(b.RCodes))
+}
+
+// rcodeShares is formatRCodes with each rcode's share of the replies.
+func rcodeShares(m map[string]int) string {
+	if len(m) == 0 {
+		return "-"
+	}
+	var n int
+	keys := make([]string, 0, len(m))
+	for k, v := range m {
+		keys = append(keys, k)
+		n += v
+	}
+	slices.Sort(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d (%.1f%%)", k, m[k], float64(m[k])/float64(n)*100))
+	}
+	return strings.Join(parts, " ")
+}
+
+// lossString is a benchmark's share of unanswered queries with its
+// retransmissions.
+func lossString(b dnsprobe.Benchmark) string {
+	return fmt.Sprintf("%.1f%% (%d of %d sent unanswered), %d retransmit(s), %d answered on retry", b.LossRatio()*100, b.Timeouts, b.Sent, b.Retransmits, b.Recovered)
 }
 
 func printCompareDurRow(au *aurora.Aurora, w *tabwriter.Writer, label string, a time.Duration, b time.Duration, notes string) {
 	aS, bS := colorPairLowerBetter(au, a, b)
+	if latencyNoise != nil {
+		if floor := latencyNoise.Floor(strings.TrimPrefix(label, "avg_")); floor > 0 && (a-b).Abs() <= floor {
+			aS, bS = au.Gray(12, a.String()).String(), au.Gray(12, b.String()).String()
+			if notes == "-" {
+				notes = "within noise floor"
+			} else {
+				notes += " (within noise floor)"
+			}
+		}
+	}
 	fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", label, aS, bS, notes)
 }
 
@@ -236,3 +1033,160 @@ func colorPairLowerBetter(au *aurora.Aurora, a time.Duration, b time.Duration) (
 	}
 	return fmt.Sprint(au.Red(a.String())), fmt.Sprint(au.Green(b.String()))
 }
+
+// scoreTieRatio is how close two durations must be (relative to the slower
+// one) to count as a tie on the scoreboard.
+const scoreTieRatio = 0.05
+
+var scorePhases = [...]string{"total", "dial", "pack", "write", "read", "unpack", "rtt(approx)"}
+
+func timingPhases(t dnsprobe.Timings) []time.Duration {
+	return []time.Duration{t.Total, t.Dial, t.Pack, t.Write, t.Read, t.Unpack, t.RTTApprox}
+}
+
+type winTally struct {
+	a, b, ties int
+}
+
+func (t *winTally) add(a, b time.Duration) {
+	hi := max(a, b)
+	switch {
+	case hi == 0 || float64(absDuration(a-b)) <= float64(hi)*scoreTieRatio:
+		t.ties++
+	case a < b:
+		t.a++
+	default:
+		t.b++
+	}
+}
+
+type compareScoreboard struct {
+	overall        winTally
+	phases         [len(scorePhases)]winTally
+	aFails, bFails int
+}
+
+func (s *compareScoreboard) add(a dnsprobe.Result, errA error, b dnsprobe.Result, errB error) {
+	switch {
+	case errA != nil && errB != nil:
+		s.aFails++
+		s.bFails++
+		return
+	case errA != nil:
+		s.aFails++
+		s.overall.b++
+		return
+	case errB != nil:
+		s.bFails++
+		s.overall.a++
+		return
+	}
+
+	s.overall.add(a.Timings.Total, b.Timings.Total)
+	pa, pb := timingPhases(a.Timings), timingPhases(b.Timings)
+	for i := range s.phases {
+		s.phases[i].add(pa[i], pb[i])
+	}
+}
+
+func (s *compareScoreboard) print(au *aurora.Aurora, serverA, serverB string) {
+	fmt.Printf("\n=== scoreboard ===\n")
+	fmt.Printf("A:\t%s\n", serverA)
+	fmt.Printf("B:\t%s\n", serverB)
+
+	verdict := fmt.Sprint(au.Gray(12, "tie"))
+	switch {
+	case s.overall.a > s.overall.b:
+		verdict = fmt.Sprint(au.Green("A faster"))
+	case s.overall.b > s.overall.a:
+		verdict = fmt.Sprint(au.Green("B faster"))
+	}
+	fmt.Printf("\nverdict:\t%s (A wins %d, B wins %d, ties %d; ties within %.0f%%)\n",
+		verdict, s.overall.a, s.overall.b, s.overall.ties, scoreTieRatio*100)
+	if s.aFails > 0 || s.bFails > 0 {
+		fmt.Printf("failures:\tA=%d B=%d (a failure counts as a win for the other side)\n", s.aFails, s.bFails)
+	}
+
+	fmt.Printf("\nBy phase:\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "phase\tA wins\tB wins\tties")
+	for i, name := range scorePhases {
+		t := s.phases[i]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", name, t.a, t.b, t.ties)
+	}
+	_ = w.Flush()
+}
+
+// consistent reports whether one side won at least two thirds of the
+// decided comparisons, and whether that side is A (so B is the slower one).
+func (s *compareScoreboard) consistent() (slowerB, ok bool) {
+	t := s.overall
+	win := max(t.a, t.b)
+	if win < 2 || win*3 < (t.a+t.b)*2 {
+		return false, false
+	}
+	return t.a > t.b, true
+}
+
+// printAttribution profiles both servers of a compare run and explains the
+// latency gap of the slower one.
+func printAttribution(ctx context.Context, run *latency.Runner, slowerB bool) {
+	fast, slow := run.A, run.B
+	if !slowerB {
+		fast, slow = slow, fast
+	}
+	pf := dnsprobe.ProfileResolver(ctx, fast.Server, run.Names, fast.Opts)
+	ps := dnsprobe.ProfileResolver(ctx, slow.Server, run.Names, slow.Opts)
+
+	fmt.Printf("\n=== why %s is slower ===\n", slow.Label)
+	for _, p := range []dnsprobe.ResolverProfile{pf, ps} {
+		if p.Err != nil {
+			fmt.Printf("%s:\t%v\n", p.Server, p.Err)
+			return
+		}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\t%s\t%s\n", fast.Label, slow.Label)
+	fmt.Fprintf(w, "network rtt\t%s\t%s\n", pf.NetworkRTT.Round(time.Microsecond), ps.NetworkRTT.Round(time.Microsecond))
+	fmt.Fprintf(w, "median lookup\t%s\t%s\n", pf.Median.Round(time.Microsecond), ps.Median.Round(time.Microsecond))
+	fmt.Fprintf(w, "beyond rtt\t%s\t%s\n", pf.Processing().Round(time.Microsecond), ps.Processing().Round(time.Microsecond))
+	fmt.Fprintf(w, "cache hits\t%s\t%s\n", cacheRatio(pf.Cache), cacheRatio(ps.Cache))
+	fmt.Fprintf(w, "egress\t%s\t%s\n", orDash(egressOf(pf)), orDash(egressOf(ps)))
+	_ = w.Flush()
+
+	gap := ps.Median - pf.Median
+	factors := dnsprobe.Attribute(pf, ps)
+	if gap <= 0 || len(factors) == 0 {
+		fmt.Printf("\nno single factor stands out; the gap did not reproduce or is within noise\n")
+		return
+	}
+	fmt.Printf("\nslower by %s (median), because:\n", gap.Round(time.Microsecond))
+	for _, f := range factors {
+		if f.Delta > 0 {
+			fmt.Printf("  - %s (+%s, ~%.0f%% of the gap): %s\n", f.Name, f.Delta.Round(time.Microsecond), min(float64(f.Delta)/float64(gap), 1)*100, f.Detail)
+		} else {
+			fmt.Printf("  - %s: %s\n", f.Name, f.Detail)
+		}
+	}
+}
+
+func cacheRatio(e dnsprobe.CacheEstimate) string {
+	if !e.Known() {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%% (%d/%d)", e.Ratio()*100, e.Hits, e.Hits+e.Misses)
+}
+
+func egressOf(p dnsprobe.ResolverProfile) string {
+	if p.EgressName == "" {
+		return p.Egress
+	}
+	return p.Egress + " " + p.EgressName
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	loadQPS         float64
+	loadBurst       int
+	loadDuration    time.Duration
+	loadInterval    time.Duration
+	loadMaxInFlight int
+	loadDomains     string
+	loadPool        int
+	loadPoolIdle    time.Duration
+)
+
+var loadCmd = &cobra.Command{
+	Use:   "load [dns-server]",
+	Short: "Generate sustained load at a target rate from a token bucket and report the rate achieved, latency percentiles over time and a breakdown of rcodes and errors. Unlike latency --brute, which fires one burst, it holds the rate for --duration; Ctrl-C stops early.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		if loadQPS <= 0 {
+			return fmt.Errorf("--qps must be > 0")
+		}
+		if loadDuration <= 0 || loadInterval <= 0 {
+			return fmt.Errorf("--duration and --interval must be > 0")
+		}
+		if loadMaxInFlight < 1 {
+			return fmt.Errorf("--max-inflight must be >= 1")
+		}
+		burst := loadBurst
+		if burst <= 0 {
+			burst = max(1, int(loadQPS/100))
+		}
+		if err := guard(guardedRun{mode: "load", servers: []string{server}, rate: loadQPS, concurrency: max(burst, loadMaxInFlight), duration: loadDuration}); err != nil {
+			return err
+		}
+		domains, err := parseDomains(loadDomains)
+		if err != nil {
+			return err
+		}
+		pool, err := newPool(loadPool, loadPoolIdle, server)
+		if err != nil {
+			return err
+		}
+		if pool != nil {
+			defer pool.Close()
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		cfg := dnsprobe.SoakConfig{
+			Server:      server,
+			Domains:     domains,
+			Options:     baseOptions(),
+			QPS:         loadQPS,
+			Bucket:      burst,
+			Duration:    loadDuration,
+			Interval:    loadInterval,
+			MaxInFlight: loadMaxInFlight,
+		}
+		cfg.Options.Pool = pool
+		progress := newBenchProgress(server)
+		cfg.Options.Hooks = progress.hooks()
+
+		fmt.Printf("load: server=%s qps=%g burst=%d max-inflight=%d duration=%s domains=%s\n\n",
+			server, loadQPS, burst, loadMaxInFlight, loadDuration, strings.Join(domains, ","))
+		fmt.Printf("%-12s %9s %8s %8s %7s %10s %10s %10s %10s\n", "time", "done/s", "answered", "skipped", "failed", "p50", "p90", "p99", "max")
+		total := dnsprobe.Soak(ctx, cfg, func(w dnsprobe.SoakSummary) {
+			progress.clear()
+			fmt.Printf("%-12s %9.1f %8d %8d %7d %10s %10s %10s %10s\n", w.End.Format("15:04:05.000"), w.QPS(), w.Success, w.Skipped, w.Lost+w.Errors,
+				w.P50.Round(time.Microsecond), w.P90.Round(time.Microsecond), w.P99.Round(time.Microsecond), w.Max.Round(time.Microsecond))
+		})
+
+		progress.clear()
+		if ctx.Err() != nil {
+			fmt.Printf("\nload ended after %s of %s: %s\n", total.End.Sub(total.Start).Round(time.Second), loadDuration, stopReason(ctx))
+		}
+		printLoadReport(server, total)
+		if pool != nil {
+			printPoolStats(os.Stdout, pool)
+		}
+		if total.Success == 0 {
+			return interruptExit(ctx, fmt.Errorf("no query was answered"))
+		}
+		return interruptExit(ctx, nil)
+	},
+}
+
+func init() {
+	loadCmd.Flags().Float64Var(&loadQPS, "qps", 100, "Target query rate (queries per second).")
+	loadCmd.Flags().IntVar(&loadBurst, "burst", 0, "Token bucket size: how many queries may go out back to back to catch up after a stall (default: 10ms worth of --qps).")
+	loadCmd.Flags().DurationVar(&loadDuration, "duration", time.Minute, "How long to hold the rate.")
+	loadCmd.Flags().DurationVar(&loadInterval, "interval", time.Second, "How often to print the rate and latency of the last window.")
+	loadCmd.Flags().IntVar(&loadMaxInFlight, "max-inflight", 1000, "Most unanswered queries at once; a query due while all are taken is skipped, and counts against the achieved rate.")
+	loadCmd.Flags().StringVar(&loadDomains, "domains", "", "CSV of domains to cycle through (overrides the default set).")
+	loadCmd.Flags().IntVar(&loadPool, "pool", 0, "With --tcp or --proxy, keep up to N connections open and reuse them across queries (0 disables).")
+	loadCmd.Flags().DurationVar(&loadPoolIdle, "pool-idle", 30*time.Second, "Close pooled connections idle for longer than this.")
+}
+
+func printLoadReport(server string, t dnsprobe.SoakSummary) {
+	au := newAurora()
+	elapsed := min(t.End.Sub(t.Start), loadDuration)
+	achieved := float64(t.Sent) / elapsed.Seconds()
+	fmt.Printf("\n=== load report: %s ===\n", server)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "metric\tvalue")
+	rate := fmt.Sprintf("%.1f/s (%.1f%% of %g)", achieved, achieved/loadQPS*100, loadQPS)
+	if achieved < loadQPS*0.95 {
+		rate = au.Yellow(rate).String()
+	}
+	fmt.Fprintf(w, "achieved\t%s\n", rate)
+	fmt.Fprintf(w, "answered rate\t%.1f/s\n", float64(t.Success)/elapsed.Seconds())
+	fmt.Fprintf(w, "sent\t%d\n", t.Sent)
+	fmt.Fprintf(w, "answered\t%d\n", t.Success)
+	fmt.Fprintf(w, "skipped\t%d (all --max-inflight slots busy)\n", t.Skipped)
+	fmt.Fprintf(w, "failed\t%d (%.2f%%)\n", t.Lost+t.Errors, t.FailRatio()*100)
+	fmt.Fprintf(w, "min\t%s\n", t.Min)
+	fmt.Fprintf(w, "p50\t%s\n", t.P50)
+	fmt.Fprintf(w, "p90\t%s\n", t.P90)
+	fmt.Fprintf(w, "p99\t%s\n", t.P99)
+	fmt.Fprintf(w, "max\t%s\n", t.Max)
+	fmt.Fprintf(w, "rcodes\t%s\n", formatRCodes(t.RCodes))
+	fmt.Fprintf(w, "errors\t%s\n", formatRCodes(t.Failures))
+	_ = w.Flush()
+	if t.Skipped > 0 {
+		fmt.Printf("\n%s replies came too slowly to keep %g/s going with %d in flight; raise --max-inflight, or the server is saturated\n", au.Yellow("note:"), loadQPS, loadMaxInFlight)
+	}
+	fmt.Printf("\npercentiles are estimated from streaming histogram buckets (max relative error %.2f%%); min/max are exact\n",
+		dnsprobe.QuantileRelativeError*100)
+}
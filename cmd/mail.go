@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+	"dnsdoc/internal/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	mailServer    string
+	mailSelectors string
+)
+
+var mailCmd = &cobra.Command{
+	Use:   "mail <domain>",
+	Short: "Audit a domain's mail posture: MX, SPF (expanding includes and counting lookups), DMARC, DKIM keys, MTA-STS and TLS-RPT, with what to fix.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var serverArgs []string
+		if mailServer != "" {
+			serverArgs = []string{mailServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+		var selectors []string
+		for _, s := range strings.Split(mailSelectors, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				selectors = append(selectors, s)
+			}
+		}
+
+		r, err := dnsprobe.AuditMail(cmd.Context(), server, args[0], selectors, baseOptions())
+		if err != nil {
+			return err
+		}
+		printMailReport(r, server)
+		return printFindings("mail checks: "+args[0], doctor.Mail(r))
+	},
+}
+
+func init() {
+	mailCmd.Flags().StringVar(&mailServer, "server", "", "DNS server to resolve the records with (default: system resolver).")
+	mailCmd.Flags().StringVar(&mailSelectors, "selectors", "", "CSV of DKIM selectors to check (default: try common ones). Example: --selectors google,s1")
+}
+
+func printMailReport(r dnsprobe.MailReport, server string) {
+	fmt.Printf("\n=== mail: %s via %s ===\n", r.Domain, server)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "\nMX:")
+	switch {
+	case r.NullMX:
+		fmt.Fprintln(w, "  0 .\t(null MX)")
+	case len(r.MX) == 0:
+		fmt.Fprintln(w, "  -")
+	}
+	for _, h := range r.MX {
+		fmt.Fprintf(w, "  %d %s\t%s\n", h.Preference, h.Host, orDash(strings.Join(h.Addrs, " ")))
+	}
+
+	fmt.Fprintln(w, "\nSPF:")
+	printSPF(w, r.SPF, 1)
+	if r.SPF.Err == nil {
+		fmt.Fprintf(w, "  lookups:\t%d of %d\n", r.SPF.TotalLookups(), dnsprobe.SPFLookupLimit)
+	}
+
+	fmt.Fprintln(w, "\nDMARC:")
+	fmt.Fprintf(w, "  _dmarc.%s\t%s\n", r.Domain, orDash(r.DMARC))
+
+	fmt.Fprintln(w, "\nDKIM:")
+	if len(r.DKIM) == 0 {
+		fmt.Fprintln(w, "  -\tno key at the common selectors")
+	}
+	for _, k := range r.DKIM {
+		key := k.KeyType
+		switch {
+		case k.Err != nil:
+			key = k.Err.Error()
+		case k.Revoked:
+			key = "revoked"
+		case k.Bits > 0:
+			key = fmt.Sprintf("%s %d bits", k.KeyType, k.Bits)
+		}
+		fmt.Fprintf(w, "  %s._domainkey\t%s\n", k.Selector, key)
+	}
+
+	fmt.Fprintln(w, "\nMTA-STS:")
+	fmt.Fprintf(w, "  _mta-sts.%s\t%s\n", r.Domain, orDash(r.MTASTS.Record))
+	switch s := r.MTASTS; {
+	case s.Record == "":
+	case s.Err != nil:
+		fmt.Fprintf(w, "  policy\t%v\n", s.Err)
+	default:
+		fmt.Fprintf(w, "  policy\tmode %s, max_age %d, mx %s\n", orDash(s.Mode), s.MaxAge, orDash(strings.Join(s.MX, " ")))
+	}
+
+	fmt.Fprintln(w, "\nTLS-RPT:")
+	fmt.Fprintf(w, "  _smtp._tls.%s\t%s\n", r.Domain, orDash(r.TLSRPT))
+	_ = w.Flush()
+}
+
+// printSPF prints an SPF record and, indented below it, the records it
+// includes.
+func printSPF(w *tabwriter.Writer, r *dnsprobe.SPFRecord, depth int) {
+	rec := r.Record
+	if r.Err != nil {
+		rec = r.Err.Error()
+	} else {
+		rec += fmt.Sprintf(" (%d lookup(s))", r.Lookups)
+	}
+	fmt.Fprintf(w, "%s%s\t%s\n", strings.Repeat("  ", depth), r.Domain, rec)
+	for _, c := range r.Children {
+		printSPF(w, c, depth+1)
+	}
+}
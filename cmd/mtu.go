@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mtuNames   string
+	mtuBuffers string
+	mtuLink    int
+)
+
+var mtuCmd = &cobra.Command{
+	Use:   "mtu [dns-server]",
+	Short: "Find the largest UDP reply that reaches this host from a server, and whether fragmented ones are dropped on the path: asks for progressively larger answers under growing EDNS buffer sizes, then sends queries padded to the same sizes.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		var targets []dnsprobe.MTUTarget
+		for _, s := range strings.Split(mtuNames, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			name, t, ok := strings.Cut(s, "/")
+			qtype, known := dns.StringToType[strings.ToUpper(t)]
+			if !ok || !known {
+				return fmt.Errorf("--names: %q is not name/TYPE", s)
+			}
+			targets = append(targets, dnsprobe.MTUTarget{Name: name, Type: qtype})
+		}
+		if len(targets) == 0 {
+			return fmt.Errorf("--names: no test responses given")
+		}
+		var buffers []uint16
+		for _, s := range strings.Split(mtuBuffers, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			b, err := strconv.ParseUint(s, 10, 16)
+			if err != nil || b < 512 {
+				return fmt.Errorf("--buffers: %q is not a size from 512 to 65535", s)
+			}
+			buffers = append(buffers, uint16(b))
+		}
+		if mtuLink < 1280 {
+			return fmt.Errorf("--link-mtu must be >= 1280")
+		}
+
+		r, err := dnsprobe.ProbeMTU(cmd.Context(), server, targets, buffers, mtuLink, baseOptions())
+		if err != nil {
+			return err
+		}
+
+		au := newAurora()
+		fmt.Printf("\n=== mtu: %s (IPv%d, %d-byte link MTU: UDP payloads over %d bytes fragment) ===\n", r.Server, r.Family, r.LinkMTU, r.Limit)
+		fmt.Printf("\nTest responses (sizes over TCP):\n")
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, t := range r.Targets {
+			if t.Err != nil {
+				fmt.Fprintf(w, "%s\t-\t%s\n", t, au.Red(fmt.Sprintf("error: %v", t.Err)))
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%d\n", t, t.Size)
+		}
+		_ = w.Flush()
+
+		fmt.Printf("\nResponses:\n")
+		printMTUSteps(au, r, r.Responses, "response")
+		fmt.Printf("\nPadded queries:\n")
+		printMTUSteps(au, r, r.Queries, "query")
+
+		fmt.Println()
+		resp, query := r.Path(r.Responses), r.Path(r.Queries)
+		printMTUPath(au, r, "responses", resp)
+		printMTUPath(au, r, "queries", query)
+		if r.ServerBuffer > 0 {
+			fmt.Printf("server buffer:\t%d\n", r.ServerBuffer)
+		}
+		if resp.Tested && !resp.Fragments || query.Tested && !query.Fragments {
+			fmt.Printf("\nfragmented datagrams are dropped between this host and %s: advertise an EDNS buffer of at most %d (1232, the DNS Flag Day 2020 default, fits any path) and make sure TCP fallback works\n", r.Server, min(r.Limit, max(resp.Largest, 1232)))
+			return exitError{code: exitMismatch, err: fmt.Errorf("fragmented UDP datagrams are dropped on the path")}
+		}
+		return nil
+	},
+}
+
+func init() {
+	mtuCmd.Flags().StringVar(&mtuNames, "names", "./NS,./DNSKEY,ietf.org/DNSKEY,isc.org/DNSKEY,google.com/TXT,microsoft.com/TXT,apple.com/TXT,yahoo.com/TXT", "CSV of name/TYPE queries whose answers, asked with DO, serve as test responses; the more sizes they cover the finer the result.")
+	mtuCmd.Flags().StringVar(&mtuBuffers, "buffers", "512,1232,1400,1452,1472,1500,2048,4096", "CSV of EDNS buffer sizes to step through; each also sets the size of a padded query.")
+	mtuCmd.Flags().IntVar(&mtuLink, "link-mtu", 1500, "MTU of the local link; UDP payloads over it less the IP and UDP headers travel as fragments.")
+}
+
+// printMTUSteps prints one direction of the ladder; kind names the datagram
+// whose size is stepped.
+func printMTUSteps(au *aurora.Aurora, r dnsprobe.MTUReport, steps []dnsprobe.MTUStep, kind string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "buffer\tquery\t%s size\trtt\tstatus\n", kind)
+	for _, s := range steps {
+		rtt := "-"
+		status := au.Green("arrived").String()
+		switch {
+		case s.Lost():
+			status = au.Red(fmt.Sprintf("lost (%d tries)", s.Sent)).String()
+		case s.Answered == 0:
+			status = au.Red(fmt.Sprintf("error: %v", s.Err)).String()
+		case s.Truncated:
+			rtt = s.RTT.String()
+			status = au.Yellow(fmt.Sprintf("truncated to %d bytes", s.Got)).String()
+		default:
+			rtt = s.RTT.String()
+			if s.Size > r.Limit {
+				status = au.Green("arrived in fragments").String()
+			}
+		}
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\n", s.Buffer, s.Target, s.Size, rtt, status)
+	}
+	_ = w.Flush()
+}
+
+func printMTUPath(au *aurora.Aurora, r dnsprobe.MTUReport, label string, p dnsprobe.MTUPath) {
+	line := "none arrived"
+	if p.Largest > 0 {
+		line = fmt.Sprintf("up to %d bytes arrive", p.Largest)
+	}
+	if p.SmallestLost > 0 {
+		line += fmt.Sprintf(", %d lost", p.SmallestLost)
+	}
+	switch {
+	case !p.Tested:
+		line += fmt.Sprintf("; fragments not tested (nothing over %d bytes)", r.Limit)
+	case p.Fragments:
+		line += "; fragments pass"
+	default:
+		line = au.Red(line + "; fragments are dropped").String()
+	}
+	fmt.Printf("%s:\t%s\n", label, line)
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"dnsdoc/internal/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var multisignerServer string
+
+var multisignerCmd = &cobra.Command{
+	Use:   "multisigner <zone>",
+	Short: "Check a zone signed by several DNSSEC providers (RFC 8901): every nameserver's answers must validate and every DNSKEY set must carry the other signers' ZSKs. Reports drift between signers.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var serverArgs []string
+		if multisignerServer != "" {
+			serverArgs = []string{multisignerServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+
+		findings, err := doctor.MultiSigner(cmd.Context(), server, args[0], baseOptions())
+		if err != nil {
+			return err
+		}
+		return printFindings("multisigner: "+args[0], findings)
+	},
+}
+
+func init() {
+	multisignerCmd.Flags().StringVar(&multisignerServer, "server", "", "DNS server used to find the zone's nameservers (default: system resolver).")
+}
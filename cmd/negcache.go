@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	negcacheZone string
+	negcacheWait time.Duration
+)
+
+var negcacheCmd = &cobra.Command{
+	Use:   "negcache [dns-server]",
+	Short: "Measure negative caching: ask twice for a nonexistent name and report the SOA MINIMUM, how much faster the cached NXDOMAIN is, and whether the resolver keeps to the zone's negative TTL.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		rep, err := dnsprobe.MeasureNegativeCache(cmd.Context(), server, negcacheZone, negcacheWait, baseOptions())
+		if err != nil {
+			return err
+		}
+		au := newAurora()
+
+		fmt.Printf("\n=== negative caching: %s ===\n", server)
+		fmt.Printf("name:\t%s\n", rep.Name)
+		fmt.Printf("first NXDOMAIN:\t%s\n", rep.RTT1)
+		delta := fmt.Sprintf("%s faster", rep.RTT1-rep.RTT2)
+		if rep.RTT2 > rep.RTT1 {
+			delta = fmt.Sprintf("%s slower", rep.RTT2-rep.RTT1)
+		}
+		fmt.Printf("second NXDOMAIN:\t%s (%s later, %s)\n", rep.RTT2, rep.Wait, delta)
+		if !rep.HasSOA {
+			fmt.Printf("\n%s the NXDOMAIN carries no SOA, so downstream caches cannot cache it (RFC 2308)\n", au.Yellow("warning:"))
+			return nil
+		}
+		fmt.Printf("SOA:\t%s MINIMUM %ds\n", rep.Zone, rep.Minimum)
+		fmt.Printf("SOA TTL served:\t%ds, then %ds\n", rep.TTL1, rep.TTL2)
+		if rep.AuthErr != nil {
+			fmt.Printf("authoritative:\t%s\n", au.Yellow(fmt.Sprintf("not asked: %v", rep.AuthErr)))
+		} else {
+			fmt.Printf("authoritative:\tnegative TTL %ds (lower of SOA TTL and MINIMUM)\n", rep.AuthTTL)
+		}
+
+		fmt.Println()
+		neg := rep.NegativeTTL()
+		switch {
+		case !rep.Cached():
+			fmt.Printf("%s the repeat was not faster and the TTL did not count down: NXDOMAINs are not cached, so every lookup of a missing name reaches the authoritative servers\n", au.Yellow("not cached:"))
+		case !rep.Honored():
+			fmt.Printf("%s NXDOMAIN served with TTL %ds, longer than the zone's %ds: names created since stay unresolvable longer\n", au.Red("stretched:"), rep.TTL1, neg)
+		case rep.TTL1+1 < neg:
+			fmt.Printf("%s cached, with the negative TTL capped at or below %ds (zone asks for %ds)\n", au.Green("ok:"), rep.TTL1, neg)
+		default:
+			fmt.Printf("%s cached for the zone's negative TTL of %ds\n", au.Green("ok:"), neg)
+		}
+		return nil
+	},
+}
+
+func init() {
+	negcacheCmd.Flags().StringVar(&negcacheZone, "zone", "example.com", "Zone to ask a random nonexistent name under.")
+	negcacheCmd.Flags().DurationVar(&negcacheWait, "wait", 2*time.Second, "Pause between the two queries, long enough for the TTL to count down.")
+}
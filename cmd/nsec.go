@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"dnsdoc/internal/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var nsecServer string
+
+var nsecCmd = &cobra.Command{
+	Use:   "nsec <zone>",
+	Short: "Inspect a signed zone's negative answers: NSEC or NSEC3, NSEC3 iterations, salt and opt-out, and whether the zone can be walked, with recommendations from RFC 9276.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var serverArgs []string
+		if nsecServer != "" {
+			serverArgs = []string{nsecServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+
+		findings, err := doctor.NSEC(cmd.Context(), server, args[0], baseOptions())
+		if err != nil {
+			return err
+		}
+		return printFindings("nsec: "+args[0], findings)
+	},
+}
+
+func init() {
+	nsecCmd.Flags().StringVar(&nsecServer, "server", "", "DNS server used to find the zone's nameservers (default: system resolver).")
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/spf13/cobra"
+)
+
+var (
+	portsZone     string
+	portsListen   string
+	portsCount    int
+	portsInterval time.Duration
+	portsObserve  time.Duration
+)
+
+var portsCmd = &cobra.Command{
+	Use:   "ports [dns-server]",
+	Short: "Rate how well a recursive resolver randomizes its source ports (Kaminsky-style spoofing resistance). dnsdoc serves a test zone delegated to this host, sends random names under it through the resolver and records the ports the resolver queries from.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if portsZone == "" {
+			return fmt.Errorf("--zone is required: a zone delegated to this host")
+		}
+		if portsCount < 2 && portsObserve == 0 {
+			return fmt.Errorf("--count must be at least 2")
+		}
+
+		l, err := dnsprobe.ListenPorts(portsListen, portsZone)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		if portsObserve > 0 {
+			fmt.Printf("listening on %s for queries under %s for %s (Ctrl-C to stop early)\n", portsListen, portsZone, portsObserve)
+			select {
+			case <-ctx.Done():
+			case <-time.After(portsObserve):
+			}
+		} else {
+			server, err := serverFromArgs(args)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("listening on %s; sending %d queries under %s via %s\n", portsListen, portsCount, portsZone, server)
+			failed := dnsprobe.TriggerPortQueries(ctx, server, portsZone, baseOptions(), portsCount, portsInterval)
+			if failed > 0 {
+				fmt.Printf("%d of %d queries to the resolver failed\n", failed, portsCount)
+			}
+			// Give retries and stragglers a moment to arrive.
+			time.Sleep(500 * time.Millisecond)
+		}
+
+		samples := l.Samples()
+		if len(samples) == 0 {
+			return fmt.Errorf("no queries reached the listener: check that %s is delegated to this host and %s is reachable from the resolver", portsZone, portsListen)
+		}
+		printPortReport(newAurora(), samples)
+		return nil
+	},
+}
+
+func init() {
+	portsCmd.Flags().StringVar(&portsZone, "zone", "", "Test zone delegated to this host (required).")
+	portsCmd.Flags().StringVar(&portsListen, "listen", ":53", "Address the authoritative listener binds, UDP and TCP.")
+	portsCmd.Flags().IntVarP(&portsCount, "count", "c", 100, "Number of queries to send through the resolver.")
+	portsCmd.Flags().DurationVar(&portsInterval, "interval", 0, "Pause between queries.")
+	portsCmd.Flags().DurationVar(&portsObserve, "observe", 0, "Only listen, for this long, instead of sending queries: record whatever resolvers look up names under the zone (e.g. clients elsewhere).")
+}
+
+func printPortReport(au *aurora.Aurora, samples []dnsprobe.PortSample) {
+	sources, ports := dnsprobe.PortsBySource(samples)
+
+	fmt.Printf("\n=== source ports: %d queries from %d address(es) ===\n", len(samples), len(sources))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "source\tqueries\tunique\trange\tstddev\t~bits\tsequential\trating")
+	worst, rated := "", 0
+	for _, src := range sources {
+		s := dnsprobe.SpreadOf(ports[src])
+		rating := dnsprobe.PortRating(s)
+		if s.N < 2 {
+			rating = "-"
+		} else {
+			rated++
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d-%d\t%.0f\t%.1f\t%.0f%%\t%s\n", src, s.N, s.Unique, s.Min, s.Max, s.StdDev, s.Bits, s.Sequential*100, colorPortRating(au, rating))
+		if rating == "POOR" || (rating == "GOOD" && worst == "") {
+			worst = rating
+		}
+	}
+	_ = w.Flush()
+
+	fmt.Println()
+	switch {
+	case rated == 0:
+		fmt.Printf("too few queries per source address to rate; raise --count\n")
+	case worst == "POOR":
+		fmt.Printf("%s at least one egress address uses predictable source ports; the resolver is exposed to Kaminsky-style cache poisoning. Upgrade it or stop NAT/firewalls from rewriting its ports.\n", au.Red("vulnerable:"))
+	case worst == "GOOD":
+		fmt.Printf("%s ports are randomized, but over a narrow range; more entropy makes spoofing harder.\n", au.Yellow("fair:"))
+	default:
+		fmt.Printf("%s source ports are well randomized.\n", au.Green("ok:"))
+	}
+}
+
+func colorPortRating(au *aurora.Aurora, rating string) string {
+	switch rating {
+	case "GREAT":
+		return au.Green(rating).String()
+	case "GOOD":
+		return au.Yellow(rating).String()
+	case "POOR":
+		return au.Red(rating).String()
+	}
+	return rating
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"dnsdoc/internal/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	primingBuffer uint16
+	primingDNSSEC bool
+)
+
+var primingCmd = &cobra.Command{
+	Use:   "priming [dns-server]",
+	Short: "Send a root priming query (./NS, RFC 9609) to a resolver or a root server and check the reply: every root server named, with IPv4 and IPv6 glue, within the advertised buffer, signed when DO is set. Reports its size and latency.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		if primingBuffer < 512 {
+			return fmt.Errorf("--buffer must be >= 512")
+		}
+		findings, err := doctor.Priming(cmd.Context(), server, rootHints(), primingBuffer, primingDNSSEC, baseOptions())
+		if err != nil {
+			return err
+		}
+		return printFindings("priming: "+server, findings)
+	},
+}
+
+func init() {
+	primingCmd.Flags().Uint16Var(&primingBuffer, "buffer", 1232, "EDNS UDP buffer size to advertise; 512 shows whether the reply degrades gracefully for clients without EDNS-sized buffers.")
+	primingCmd.Flags().BoolVar(&primingDNSSEC, "dnssec", true, "Set the DO bit, as validating resolvers do, which makes the reply larger.")
+}
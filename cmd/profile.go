@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+	"dnsdoc/internal/history"
+
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/spf13/cobra"
+)
+
+var (
+	profileHistory   string
+	profileEvery     time.Duration
+	profileDuration  time.Duration
+	profileOnce      bool
+	profileDomains   string
+	profileSamples   int
+	profileDays      int
+	profileCongested float64
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Sample a resolver at fixed times of day over several days and report its time-of-day latency profile.",
+}
+
+var profileRecordCmd = &cobra.Command{
+	Use:   "record [dns-server]",
+	Short: "Take samples at fixed, clock-aligned times (every --every) and append them to the history file.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		domains, err := parseDomains(profileDomains)
+		if err != nil {
+			return err
+		}
+		path, err := historyPath(profileHistory)
+		if err != nil {
+			return err
+		}
+
+		if profileOnce {
+			return recordProfileSlot(cmd.Context(), server, domains, path)
+		}
+		if profileEvery <= 0 {
+			return fmt.Errorf("--every must be > 0")
+		}
+
+		end := time.Now().Add(profileDuration)
+		for {
+			next := time.Now().Truncate(profileEvery).Add(profileEvery)
+			if next.After(end) {
+				return nil
+			}
+			fmt.Printf("next sample at %s\n", next.Format(time.RFC3339))
+			select {
+			case <-cmd.Context().Done():
+				return cmd.Context().Err()
+			case <-time.After(time.Until(next)):
+			}
+			if err := recordProfileSlot(cmd.Context(), server, domains, path); err != nil {
+				return err
+			}
+		}
+	},
+}
+
+var profileReportCmd = &cobra.Command{
+	Use:   "report [dns-server]",
+	Short: "Render the time-of-day latency profile for a resolver from the history file.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		server = dnsprobe.NormalizeServer(server)
+		path, err := historyPath(profileHistory)
+		if err != nil {
+			return err
+		}
+
+		since := time.Now().AddDate(0, 0, -profileDays)
+		recs, err := history.Load(path, func(r history.Record) bool {
+			return r.Server == server && r.Time.After(since)
+		})
+		if err != nil {
+			return err
+		}
+		if len(recs) == 0 {
+			return fmt.Errorf("no history for %s in %s (last %d days); run `dnsdoc profile record` first", server, path, profileDays)
+		}
+		printTimeOfDayProfile(newAurora(), server, recs)
+		return nil
+	},
+}
+
+func init() {
+	profileCmd.PersistentFlags().StringVar(&profileHistory, "history", "", "History file (JSON Lines). Defaults to <user config dir>/dnsdoc/history.jsonl.")
+
+	profileRecordCmd.Flags().DurationVar(&profileEvery, "every", time.Hour, "Sampling period; samples are aligned to the clock (e.g. 1h = top of every hour).")
+	profileRecordCmd.Flags().DurationVar(&profileDuration, "duration", 72*time.Hour, "How long to keep sampling.")
+	profileRecordCmd.Flags().BoolVar(&profileOnce, "once", false, "Take a single sample now and exit (for cron or other external schedulers).")
+	profileRecordCmd.Flags().StringVar(&profileDomains, "domains", "", "CSV of domains to sample (overrides the default set).")
+	profileRecordCmd.Flags().IntVar(&profileSamples, "samples", 3, "Queries per domain in every sample slot.")
+
+	profileReportCmd.Flags().IntVar(&profileDays, "days", 14, "Only use history from the last N days.")
+	profileReportCmd.Flags().Float64Var(&profileCongested, "congested", 1.5, "Flag hours whose median exceeds this multiple of the quietest hour's median.")
+
+	profileCmd.AddCommand(profileRecordCmd)
+	profileCmd.AddCommand(profileReportCmd)
+}
+
+func historyPath(flag string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+	return history.DefaultPath()
+}
+
+func recordProfileSlot(ctx context.Context, server string, domains []string, path string) error {
+	opts := baseOptions()
+
+	var recs []history.Record
+	var fails int
+	for i := 0; i < profileSamples && ctx.Err() == nil; i++ {
+		for _, name := range domains {
+			at := time.Now()
+			r, err := dnsprobe.Probe(ctx, server, name, opts)
+			if dnsprobe.CutShort(ctx, err) {
+				continue
+			}
+			if err != nil {
+				fails++
+			}
+			recs = append(recs, history.FromResult(at, r, err))
+		}
+	}
+	if err := history.Append(path, recs); err != nil {
+		return err
+	}
+	fmt.Printf("%s: recorded %d samples (%d failed) to %s\n", time.Now().Format(time.RFC3339), len(recs), fails, path)
+	if ctx.Err() != nil {
+		fmt.Printf("%s %d of %d samples (%s)\n", newAurora().Yellow("skipped:"), profileSamples*len(domains)-len(recs), profileSamples*len(domains), stopReason(ctx))
+	}
+	return nil
+}
+
+func printTimeOfDayProfile(au *aurora.Aurora, server string, recs []history.Record) {
+	var hours [24]dnsprobe.Distribution
+	var fails [24]int
+	days := map[string]bool{}
+
+	for _, r := range recs {
+		t := r.Time.Local()
+		days[t.Format("2006-01-02")] = true
+		if r.Error != "" {
+			fails[t.Hour()]++
+			continue
+		}
+		hours[t.Hour()].Add(r.Timings.Total)
+	}
+
+	var quietest time.Duration
+	for h := range hours {
+		if hours[h].Count() > 0 && (quietest == 0 || hours[h].Quantile(0.5) < quietest) {
+			quietest = hours[h].Quantile(0.5)
+		}
+	}
+
+	fmt.Printf("\n=== time-of-day latency profile: %s ===\n", server)
+	fmt.Printf("samples:\t%d over %d days (local time %s)\n", len(recs), len(days), time.Now().Format("MST"))
+	fmt.Printf("quietest hour median:\t%s\n\n", quietest)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "hour\tsamples\tfail\tp50\tp90\t\t")
+	for h := range hours {
+		d := &hours[h]
+		if d.Count() == 0 && fails[h] == 0 {
+			fmt.Fprintf(w, "%02d:00\t0\t0\t-\t-\t\t\n", h)
+			continue
+		}
+		ratio := 0.0
+		if quietest > 0 && d.Count() > 0 {
+			ratio = float64(d.Quantile(0.5)) / float64(quietest)
+		}
+		bar := strings.Repeat("#", min(int(ratio*10+0.5), 60))
+		note := ""
+		if ratio > profileCongested {
+			bar = fmt.Sprint(au.Red(bar))
+			note = fmt.Sprint(au.Red(fmt.Sprintf("congested (%.1fx)", ratio)))
+		}
+		fmt.Fprintf(w, "%02d:00\t%d\t%d\t%s\t%s\t%s\t%s\n", h, d.Count(), fails[h], d.Quantile(0.5), d.Quantile(0.9), bar, note)
+	}
+	_ = w.Flush()
+}
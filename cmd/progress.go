@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/logrusorgru/aurora/v4"
+)
+
+// progressWindow is how many of the latest replies the status line's p95
+// covers.
+const progressWindow = 1000
+
+// benchProgress is a status line on stderr for long benchmark and load
+// runs: queries done and in flight, the error rate, the p95 of recent
+// replies and a sparkline of the latest. A nil benchProgress does nothing.
+type benchProgress struct {
+	mu      sync.Mutex
+	au      *aurora.Aurora
+	label   string
+	started int
+	done    int
+	failed  int
+	recent  []time.Duration // ring of the last progressWindow latencies
+	next    int
+	spark   []time.Duration
+	drawn   time.Time
+	shown   bool
+}
+
+// newBenchProgress returns a status line labelled label when stderr is a
+// terminal and --lite is off, nil otherwise.
+func newBenchProgress(label string) *benchProgress {
+	if rootLite || !stderrIsTerminal() {
+		return nil
+	}
+	return &benchProgress{au: newAurora(), label: label}
+}
+
+func (p *benchProgress) hooks() *dnsprobe.Hooks {
+	if p == nil {
+		return nil
+	}
+	return &dnsprobe.Hooks{
+		OnProbeStart: func(int, string) { p.start() },
+		OnProbeDone: func(_ int, r dnsprobe.Result) {
+			if dnsprobe.FailureClass(r, nil) != "" {
+				p.finish(-1)
+				return
+			}
+			p.finish(r.Timings.Total)
+		},
+		OnError: func(int, dnsprobe.Result, error) { p.finish(-1) },
+	}
+}
+
+func (p *benchProgress) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.started++
+}
+
+// finish records a reply's latency, -1 for a failed query, and redraws at
+// most every sparkRedraw.
+func (p *benchProgress) finish(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if d < 0 {
+		p.failed++
+	} else if len(p.recent) < progressWindow {
+		p.recent = append(p.recent, d)
+	} else {
+		p.recent[p.next] = d
+		p.next = (p.next + 1) % progressWindow
+	}
+	p.spark = append(p.spark, d)
+	if len(p.spark) > sparkWidth {
+		p.spark = p.spark[len(p.spark)-sparkWidth:]
+	}
+	if now := time.Now(); now.Sub(p.drawn) >= sparkRedraw {
+		p.drawn = now
+		p.draw()
+	}
+}
+
+func (p *benchProgress) draw() {
+	p95 := "-"
+	if len(p.recent) > 0 {
+		s := slices.Clone(p.recent)
+		slices.Sort(s)
+		p95 = s[int(0.95*float64(len(s)-1))].Round(time.Microsecond).String()
+	}
+	errors := fmt.Sprintf("%.1f%% errors", float64(p.failed)/float64(p.done)*100)
+	if p.failed > 0 {
+		errors = p.au.Red(errors).String()
+	}
+	fmt.Fprintf(os.Stderr, "\r\033[K%s: %d done, %d in flight, %s, p95 %s %s",
+		p.label, p.done, p.started-p.done, errors, p95, sparkline(p.au, p.spark))
+	p.shown = true
+}
+
+// clear erases the status line before regular output is written; the next
+// reply draws it again.
+func (p *benchProgress) clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.shown {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		p.shown = false
+	}
+	p.drawn = time.Time{}
+}
+
+// reset clears the line and starts counting afresh, for the next
+// benchmark.
+func (p *benchProgress) reset() {
+	if p == nil {
+		return
+	}
+	p.clear()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.started, p.done, p.failed = 0, 0, 0
+	p.recent, p.next, p.spark = nil, 0, nil
+}
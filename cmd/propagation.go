@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+)
+
+var (
+	propagationServer string
+	propagationType   string
+)
+
+var propagationCmd = &cobra.Command{
+	Use:   "propagation <domain>",
+	Short: "Ask every authoritative nameserver of a domain's zone directly and compare their serials, answers and TTLs, highlighting servers that have not picked up a recent change.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var serverArgs []string
+		if propagationServer != "" {
+			serverArgs = []string{propagationServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+		qtype, ok := dns.StringToType[strings.ToUpper(propagationType)]
+		if !ok {
+			return fmt.Errorf("unknown record type %q", propagationType)
+		}
+
+		p, err := dnsprobe.CheckPropagation(cmd.Context(), server, args[0], qtype, baseOptions())
+		if err != nil {
+			return err
+		}
+
+		au := newAurora()
+		fmt.Printf("\n=== %s %s across the nameservers of %s ===\n", p.Name, p.Type, p.Zone)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "nameserver\taddress\tserial\tanswer\tttl\trtt\tstatus")
+		lagging := 0
+		for i, s := range p.Servers {
+			status := p.Status(i)
+			if s.Err != nil {
+				fmt.Fprintf(w, "%s\t%s\t-\terror: %v\t-\t-\t%s\n", s.Host, orDash(s.Addr), s.Err, au.Red(status))
+				lagging++
+				continue
+			}
+			serial := "-"
+			if s.HasSerial {
+				serial = fmt.Sprint(s.Serial)
+			}
+			answer := strings.Join(s.Answers, ", ")
+			ttl := fmt.Sprint(s.TTL)
+			if len(s.Answers) == 0 {
+				answer, ttl = s.RCode+" (no "+p.Type+")", "-"
+			}
+			switch status {
+			case dnsprobe.PropInSync:
+				status = au.Green(status).String()
+			case dnsprobe.PropBehind, dnsprobe.PropDiffers:
+				lagging++
+				status = au.Yellow(status).String()
+			default:
+				lagging++
+				status = au.Red(status).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Host, s.Addr, serial, answer, ttl, s.RTT, status)
+		}
+		_ = w.Flush()
+
+		fmt.Printf("latest serial:\t%d\n", p.Serial)
+		if lagging > 0 {
+			fmt.Printf("%s %d of %d nameserver addresses are behind, differ, lame or unreachable\n", au.Yellow("not propagated:"), lagging, len(p.Servers))
+			return exitError{code: exitMismatch, err: fmt.Errorf("%s %s has not propagated to every nameserver", p.Name, p.Type)}
+		}
+		fmt.Printf("%s every nameserver serves the same answer\n", au.Green("propagated:"))
+		return nil
+	},
+}
+
+func init() {
+	propagationCmd.Flags().StringVar(&propagationServer, "server", "", "DNS server used to find the zone and its nameservers (default: system resolver).")
+	propagationCmd.Flags().StringVar(&propagationType, "type", "A", "Record type to compare.")
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	recoveryDomainsFile string
+	recoveryQPS         float64
+	recoveryBaseline    time.Duration
+	recoveryWindow      time.Duration
+	recoveryMaxWait     time.Duration
+	recoveryTolerance   float64
+	recoveryStable      int
+	recoveryFlushCmd    string
+)
+
+var recoveryCmd = &cobra.Command{
+	Use:   "recovery [dns-server]",
+	Short: "For resolvers you control: measure how long p95 latency takes to return to baseline after a cache flush or restart, under a replayed workload.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		if recoveryQPS <= 0 {
+			return fmt.Errorf("--qps must be > 0")
+		}
+		if recoveryBaseline <= 0 || recoveryWindow <= 0 || recoveryMaxWait <= 0 {
+			return fmt.Errorf("--baseline, --window and --max-wait must be > 0")
+		}
+		if recoveryTolerance < 1 {
+			return fmt.Errorf("--tolerance must be >= 1")
+		}
+		if err := guard(guardedRun{mode: "recovery", servers: []string{server}, rate: recoveryQPS, duration: recoveryBaseline + recoveryMaxWait}); err != nil {
+			return err
+		}
+
+		domains := dataSet.Domains
+		if recoveryDomainsFile != "" {
+			domains, err = readDomainsFile(recoveryDomainsFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		ctx := cmd.Context()
+		au := newAurora()
+		workload := dnsprobe.SoakConfig{
+			Server:  server,
+			Domains: domains,
+			Options: baseOptions(),
+			QPS:     recoveryQPS,
+		}
+
+		// One pass over the workload first so the baseline is a warm cache.
+		fmt.Printf("warming cache: %d domains at %g qps\n", len(domains), recoveryQPS)
+		warm := workload
+		warm.Duration = time.Duration(float64(len(domains))/recoveryQPS*float64(time.Second)) + time.Second
+		warm.Interval = warm.Duration
+		dnsprobe.Soak(ctx, warm, nil)
+		if ctx.Err() != nil {
+			printSkipped(ctx, au, "phase", []string{"baseline", "flush", "replay"})
+			return nil
+		}
+
+		fmt.Printf("measuring baseline for %s\n", recoveryBaseline)
+		base := workload
+		base.Duration = recoveryBaseline
+		base.Interval = recoveryBaseline
+		baseline := dnsprobe.Soak(ctx, base, nil)
+		if ctx.Err() != nil {
+			printSkipped(ctx, au, "phase", []string{"flush", "replay"})
+			return nil
+		}
+		if baseline.Success == 0 {
+			return fmt.Errorf("baseline: no successful queries to %s", server)
+		}
+		target := time.Duration(float64(baseline.P95) * recoveryTolerance)
+		fmt.Printf("baseline: p50=%s p95=%s fail=%.2f%% -> recovery target p95 <= %s\n",
+			baseline.P50, baseline.P95, baseline.FailRatio()*100, target)
+
+		if err := flushResolver(); err != nil {
+			return err
+		}
+
+		fmt.Printf("\nreplaying workload (window %s, up to %s)\n", recoveryWindow, recoveryMaxWait)
+		rec := workload
+		rec.Duration = recoveryMaxWait
+		rec.Interval = recoveryWindow
+
+		start := time.Now()
+		fmt.Printf("%-10s %6s %8s %10s %10s %10s  %s\n", "t", "sent", "fail", "p50", "p95", "max", "at target")
+		res := dnsprobe.MeasureRecovery(ctx, dnsprobe.RecoveryConfig{
+			Soak:    rec,
+			Target:  target,
+			MaxFail: baseline.FailRatio() + 0.01,
+			Stable:  recoveryStable,
+		}, func(w dnsprobe.SoakSummary, ok bool) {
+			mark := au.Red("no").String()
+			if ok {
+				mark = au.Green("yes").String()
+			}
+			fmt.Printf("%-10s %6d %7.1f%% %10s %10s %10s  %s\n", w.End.Sub(start).Round(time.Second), w.Sent, w.FailRatio()*100,
+				w.P50, w.P95, w.Max, mark)
+		})
+
+		fmt.Println()
+		if !res.Recovered && ctx.Err() != nil {
+			fmt.Printf("%s the replay ended after %s, before p95 returned to %s\n", au.Yellow(stopReason(ctx)+":"), time.Since(start).Round(time.Second), target)
+			return nil
+		}
+		if !res.Recovered {
+			fmt.Printf("%s p95 did not return to %s within %s\n", au.Red("not recovered:"), target, recoveryMaxWait)
+			return nil
+		}
+		fmt.Printf("%s p95 back to baseline %s after the flush (first of %d consecutive windows at target)\n",
+			au.Green("recovered:"), res.After.Round(time.Second), recoveryStable)
+		return nil
+	},
+}
+
+func init() {
+	recoveryCmd.Flags().StringVar(&recoveryDomainsFile, "domains-file", "", "Workload to replay, one domain per line (default: built-in popular domains).")
+	recoveryCmd.Flags().Float64Var(&recoveryQPS, "qps", 20, "Replay rate (queries per second).")
+	recoveryCmd.Flags().DurationVar(&recoveryBaseline, "baseline", 30*time.Second, "How long to measure warm-cache baseline latency before the flush.")
+	recoveryCmd.Flags().DurationVar(&recoveryWindow, "window", 5*time.Second, "Window p95 is computed over after the flush.")
+	recoveryCmd.Flags().DurationVar(&recoveryMaxWait, "max-wait", 10*time.Minute, "Give up if p95 has not recovered by then.")
+	recoveryCmd.Flags().Float64Var(&recoveryTolerance, "tolerance", 1.2, "A window is at target when its p95 is within this factor of the baseline p95.")
+	recoveryCmd.Flags().IntVar(&recoveryStable, "stable", 2, "Consecutive windows at target required to call it recovered.")
+	recoveryCmd.Flags().StringVar(&recoveryFlushCmd, "flush-cmd", "", "Shell command that flushes or restarts the resolver (e.g. \"unbound-control flush_zone .\"). Without it you are asked to do it by hand.")
+}
+
+// flushResolver runs --flush-cmd, or waits for the operator to flush or
+// restart the resolver and press Enter.
+func flushResolver() error {
+	if recoveryFlushCmd == "" {
+		fmt.Printf("\nflush the cache or restart the resolver now, then press Enter ")
+		_, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		return err
+	}
+
+	fmt.Printf("\nrunning flush command: %s\n", recoveryFlushCmd)
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	c := exec.Command(shell, flag, recoveryFlushCmd)
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("--flush-cmd: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resolversProbe   bool
+	resolversDomains string
+	resolversRounds  int
+	resolversSlow    float64
+)
+
+var resolversCmd = &cobra.Command{
+	Use:   "resolvers",
+	Short: "List the resolvers configured on this host, including per-domain and interface-scoped entries. With --probe, measure each one.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rs, err := dnsprobe.SystemResolvers()
+		if err != nil {
+			return err
+		}
+		for _, r := range rs {
+			if !dnsprobe.IsResolvedStub(r.Server) {
+				continue
+			}
+			if ups, err := dnsprobe.ResolvedUpstreams(); err == nil {
+				rs = append(rs, ups...)
+			}
+			break
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "server\tdomain\tscope\tinterface\tsource")
+		for _, r := range rs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Server, orDash(r.Domain), resolverScope(r), orDash(r.Interface), r.Source)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if !resolversProbe {
+			return nil
+		}
+		if resolversRounds < 1 {
+			return fmt.Errorf("--rounds must be >= 1")
+		}
+		domains, err := parseDomains(resolversDomains)
+		if err != nil {
+			return err
+		}
+		probeSystemResolvers(cmd.Context(), newAurora(), rs, domains)
+		return nil
+	},
+}
+
+func init() {
+	resolversCmd.Flags().BoolVar(&resolversProbe, "probe", false, "Probe every listed server and compare them, flagging dead or slow ones.")
+	resolversCmd.Flags().StringVar(&resolversDomains, "domains", "", "CSV of domains to probe with (overrides the default set).")
+	resolversCmd.Flags().IntVar(&resolversRounds, "rounds", 3, "How many times to query every domain per server.")
+	resolversCmd.Flags().Float64Var(&resolversSlow, "slow-factor", 2, "Flag servers whose median latency exceeds this multiple of the fastest server's.")
+}
+
+type resolverProbeStats struct {
+	server string
+	scope  string
+	dist   dnsprobe.Distribution
+	fails  int
+}
+
+func probeSystemResolvers(ctx context.Context, au *aurora.Aurora, rs []dnsprobe.SystemResolver, domains []string) {
+	opts := baseOptions()
+
+	seen := map[string]bool{}
+	var stats []*resolverProbeStats
+	var skipped []string
+	for _, r := range rs {
+		if seen[r.Server] {
+			continue
+		}
+		seen[r.Server] = true
+		if ctx.Err() != nil {
+			skipped = append(skipped, r.Server)
+			continue
+		}
+		st := &resolverProbeStats{server: r.Server, scope: resolverScope(r)}
+		for i := 0; i < resolversRounds; i++ {
+			for _, name := range domains {
+				res, err := dnsprobe.Probe(ctx, r.Server, name, opts)
+				if dnsprobe.CutShort(ctx, err) {
+					continue
+				}
+				if err != nil {
+					st.fails++
+					continue
+				}
+				st.dist.Add(res.Timings.Total)
+			}
+		}
+		if st.dist.Count()+st.fails == 0 && ctx.Err() != nil {
+			skipped = append(skipped, r.Server)
+			continue
+		}
+		stats = append(stats, st)
+	}
+
+	var fastest time.Duration
+	for _, st := range stats {
+		if st.dist.Count() > 0 && (fastest == 0 || st.dist.Quantile(0.5) < fastest) {
+			fastest = st.dist.Quantile(0.5)
+		}
+	}
+
+	fmt.Printf("\n=== system resolver probe (%d rounds x %d domains) ===\n", resolversRounds, len(domains))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tserver\tscope\tok\tfail\tp50\tmean\tmax\tverdict")
+	for i, st := range stats {
+		verdict := fmt.Sprint(au.Green("ok"))
+		switch {
+		case st.dist.Count() == 0:
+			verdict = fmt.Sprint(au.Red("dead"))
+		case fastest > 0 && float64(st.dist.Quantile(0.5)) > float64(fastest)*resolversSlow:
+			verdict = fmt.Sprint(au.Yellow(fmt.Sprintf("slow (%.1fx fastest)", float64(st.dist.Quantile(0.5))/float64(fastest))))
+		case st.fails > 0:
+			verdict = fmt.Sprint(au.Yellow("lossy"))
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\t%s\t%s\t%s\n",
+			i+1, st.server, st.scope, st.dist.Count(), st.fails, st.dist.Quantile(0.5), st.dist.Mean(), st.dist.Max(), verdict)
+	}
+	_ = w.Flush()
+	if len(skipped) > 0 {
+		fmt.Println()
+		printSkipped(ctx, au, "server", skipped)
+	}
+
+	fmt.Printf("\nclients fall back to later servers only after the earlier ones time out, so a dead or slow secondary usually surfaces as sporadic multi-second lookups.\n")
+}
+
+func resolverScope(r dnsprobe.SystemResolver) string {
+	switch {
+	case r.Upstream:
+		return "upstream"
+	case dnsprobe.IsResolvedStub(r.Server):
+		return "stub"
+	case r.Scoped:
+		return "scoped"
+	case r.Domain != "":
+		return "per-domain"
+	default:
+		return "default"
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+	"dnsdoc/internal/doctor"
+
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/miekg/dns"
+)
+
+// Formats dnsdoc view renders saved reports in.
+const (
+	viewTable    = "table"
+	viewCompare  = "compare"
+	viewMarkdown = "markdown"
+	viewHTML     = "html"
+)
+
+// savedReport is a report written by doctor, compliance or latency with
+// --output json, loaded back for re-rendering. Exactly one of Doctor,
+// Compliance and Latency is set.
+type savedReport struct {
+	Source     string
+	Doctor     *doctorJSON
+	Compliance *complianceReportJSON
+	Latency    *latencyReportJSON
+}
+
+func parseSavedReport(source string, data []byte) (savedReport, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return savedReport{}, fmt.Errorf("%s: %w", source, err)
+	}
+	rep := savedReport{Source: source}
+	var err error
+	switch {
+	case keys["findings"] != nil:
+		rep.Doctor = new(doctorJSON)
+		err = json.Unmarshal(data, rep.Doctor)
+	case keys["results"] != nil:
+		rep.Compliance = new(complianceReportJSON)
+		err = json.Unmarshal(data, rep.Compliance)
+	case keys["probes"] != nil:
+		rep.Latency = new(latencyReportJSON)
+		err = json.Unmarshal(data, rep.Latency)
+	default:
+		return rep, fmt.Errorf("%s is not a doctor, compliance or latency report saved with --output json", source)
+	}
+	if err != nil {
+		return rep, fmt.Errorf("%s: %w", source, err)
+	}
+	return rep, nil
+}
+
+func (r savedReport) kind() string {
+	switch {
+	case r.Doctor != nil:
+		return "doctor"
+	case r.Latency != nil:
+		return "latency"
+	}
+	return "compliance"
+}
+
+func (r savedReport) server() string {
+	switch {
+	case r.Doctor != nil:
+		return r.Doctor.Server
+	case r.Latency != nil:
+		return r.Latency.Server
+	}
+	return r.Compliance.Server
+}
+
+// table returns the report as plain rows; the second column is each check's
+// status or verdict, or for latency each probe's rcode and whether each
+// benchmark had failures.
+func (r savedReport) table() (header []string, rows [][]string) {
+	if l := r.Latency; l != nil {
+		header = []string{"check", "status", "time", "detail"}
+		for _, p := range l.Probes {
+			status, detail := p.RCode, strings.Join(p.Answers, ", ")
+			if p.Error != "" {
+				status, detail = "error", p.Error
+			}
+			if p.Mismatch != "" {
+				detail += "; expect mismatch: " + p.Mismatch
+			}
+			rows = append(rows, []string{latencyCheck(l, p.Name, p.Server, ""), status, p.Timings.Total.Round(time.Microsecond).String(), detail})
+		}
+		for _, b := range l.Benchmarks {
+			status := "ok"
+			if b.Fail > 0 {
+				status = "fail"
+			}
+			detail := fmt.Sprintf("%d of %d answered, %d lost", b.Success, b.Attempts, b.Lost)
+			if len(b.Failures) > 0 {
+				detail += ", failures: " + formatRCodes(b.Failures)
+			}
+			rows = append(rows, []string{latencyCheck(l, b.Name, b.Server, b.Benchmark), status, b.Avg.Round(time.Microsecond).String(), detail})
+		}
+		return header, rows
+	}
+	if r.Doctor != nil {
+		header = []string{"check", "status", "time", "detail", "hint"}
+		for _, f := range r.Doctor.Findings {
+			rows = append(rows, []string{f.Check, f.Status.String(), f.Took.Round(time.Microsecond).String(), f.Detail, f.Hint})
+		}
+		return header, rows
+	}
+	header = []string{"check", "verdict", "expected", "got", "rtt", "notes"}
+	for _, j := range r.Compliance.Results {
+		res := j.result()
+		got, notes := complianceGot(res)
+		rows = append(rows, []string{j.Check, j.Verdict, strings.Join(j.Expected, "/"), got, j.RTT.String(), notes})
+	}
+	return header, rows
+}
+
+func (r savedReport) summary() string {
+	if d := r.Doctor; d != nil {
+		return fmt.Sprintf("pass=%d warn=%d fail=%d, took %s", d.Pass, d.Warn, d.Fail, d.Took.Round(time.Microsecond))
+	}
+	if l := r.Latency; l != nil {
+		errs := 0
+		for _, p := range l.Probes {
+			if p.Error != "" {
+				errs++
+			}
+		}
+		s := fmt.Sprintf("probes=%d errors=%d benchmarks=%d", len(l.Probes), errs, len(l.Benchmarks))
+		if l.Interrupted {
+			s += fmt.Sprintf(", interrupted with %d name(s) skipped", len(l.Skipped))
+		}
+		return s
+	}
+	counts := map[string]int{}
+	for _, j := range r.Compliance.Results {
+		counts[j.Verdict]++
+	}
+	return fmt.Sprintf("strict=%d permissive=%d silent=%d error=%d", counts[string(dnsprobe.VerdictStrict)],
+		counts[string(dnsprobe.VerdictPermissive)], counts[string(dnsprobe.VerdictSilent)], counts[string(dnsprobe.VerdictError)])
+}
+
+// result turns a saved compliance result back into the form the text report
+// prints. The query builder is not needed and stays unset.
+func (j complianceJSON) result() dnsprobe.ComplianceResult {
+	r := dnsprobe.ComplianceResult{
+		Check:   dnsprobe.ComplianceCheck{Name: j.Check, Desc: j.Desc},
+		RCode:   j.RCode,
+		Answers: j.Answers,
+		RTT:     j.RTT,
+		Verdict: dnsprobe.ComplianceVerdict(j.Verdict),
+	}
+	for _, name := range j.Expected {
+		r.Check.Expect = append(r.Check.Expect, dns.StringToRcode[name])
+	}
+	if j.Error != "" {
+		r.Err = errors.New(j.Error)
+	}
+	return r
+}
+
+// renderTable prints a saved report the way the command that produced it
+// does; latency reports, which that prints block by block, as one table.
+func renderTable(au *aurora.Aurora, r savedReport) {
+	if d := r.Doctor; d != nil {
+		fmt.Printf("\n=== doctor: %s ===\n", d.Server)
+		_ = printDoctorReport(au, d.Findings, d.Took)
+		return
+	}
+	if r.Latency != nil {
+		fmt.Printf("\n=== latency: %s ===\n", r.server())
+		header, rows := r.table()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+		for _, row := range rows {
+			row[1] = colorOutcome(au, row[1])
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		_ = w.Flush()
+		fmt.Printf("\nsummary:\t%s\n", r.summary())
+		return
+	}
+	results := make([]dnsprobe.ComplianceResult, 0, len(r.Compliance.Results))
+	for _, j := range r.Compliance.Results {
+		results = append(results, j.result())
+	}
+	printComplianceReport(au, r.Compliance.Server, results)
+}
+
+// renderCompare prints one row per check and one status column per report,
+// marking checks whose outcome differs.
+func renderCompare(au *aurora.Aurora, reports []savedReport) error {
+	for _, r := range reports[1:] {
+		if r.kind() != reports[0].kind() {
+			return fmt.Errorf("cannot compare a %s report (%s) with a %s report (%s)", reports[0].kind(), reports[0].Source, r.kind(), r.Source)
+		}
+	}
+
+	var checks []string
+	status := make([]map[string]string, len(reports))
+	for i, r := range reports {
+		status[i] = map[string]string{}
+		_, rows := r.table()
+		for _, row := range rows {
+			if !slices.Contains(checks, row[0]) {
+				checks = append(checks, row[0])
+			}
+			status[i][row[0]] = row[1]
+		}
+	}
+
+	fmt.Printf("\n=== %s: %d reports compared ===\n", reports[0].kind(), len(reports))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(w, "check")
+	for _, r := range reports {
+		fmt.Fprintf(w, "\t%s", compareLabel(r, reports))
+	}
+	fmt.Fprintln(w, "\t")
+	differ := 0
+	for _, c := range checks {
+		fmt.Fprint(w, c)
+		var seen []string
+		for i := range reports {
+			s := status[i][c]
+			if !slices.Contains(seen, s) {
+				seen = append(seen, s)
+			}
+			fmt.Fprintf(w, "\t%s", colorOutcome(au, orDash(s)))
+		}
+		mark := ""
+		if len(seen) > 1 {
+			differ++
+			mark = au.Yellow("differs").String()
+		}
+		fmt.Fprintf(w, "\t%s\n", mark)
+	}
+	_ = w.Flush()
+
+	fmt.Println()
+	for _, r := range reports {
+		fmt.Printf("%s:\t%s\n", compareLabel(r, reports), r.summary())
+	}
+	fmt.Printf("differing checks:\t%d of %d\n", differ, len(checks))
+	return nil
+}
+
+// compareLabel names a report by its server, adding the file name when two
+// reports are for the same server.
+func compareLabel(r savedReport, all []savedReport) string {
+	n := 0
+	for _, o := range all {
+		if o.server() == r.server() {
+			n++
+		}
+	}
+	if n > 1 {
+		return r.server() + " (" + filepath.Base(r.Source) + ")"
+	}
+	return r.server()
+}
+
+// latencyCheck names a latency report row: the name, the benchmark if any,
+// and the server when the report compares two.
+func latencyCheck(l *latencyReportJSON, name, server, bench string) string {
+	check := name
+	if bench != "" {
+		check += " " + bench
+	}
+	if l.Compare != "" {
+		check += " @ " + server
+	}
+	return check
+}
+
+func colorOutcome(au *aurora.Aurora, s string) string {
+	switch s {
+	case doctor.Pass.String(), string(dnsprobe.VerdictStrict), "NOERROR", "ok":
+		return au.Green(s).String()
+	case doctor.Warn.String(), string(dnsprobe.VerdictPermissive), "NXDOMAIN":
+		return au.Yellow(s).String()
+	case "-":
+		return s
+	}
+	return au.Red(s).String()
+}
+
+func renderMarkdown(w io.Writer, reports []savedReport) {
+	cell := strings.NewReplacer("|", `\|`, "\n", " ")
+	for i, r := range reports {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "## %s: %s\n\n", r.kind(), r.server())
+		header, rows := r.table()
+		fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+		fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(header)))
+		for _, row := range rows {
+			for j := range row {
+				row[j] = cell.Replace(row[j])
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+		}
+		fmt.Fprintf(w, "\n%s\n", r.summary())
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !lite
+
+package cmd
+
+import (
+	"html/template"
+	"io"
+)
+
+var reportHTML = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>dnsdoc report</title>
+<style>
+body{font-family:sans-serif;margin:2em}
+table{border-collapse:collapse;margin-bottom:.5em}
+th,td{border:1px solid #ccc;padding:.3em .6em;text-align:left;vertical-align:top}
+th{background:#f4f4f4}
+.pass,.strict,.NOERROR,.ok{color:#1a7f37}.warn,.permissive,.NXDOMAIN{color:#9a6700}.fail,.silent,.error,.SERVFAIL,.REFUSED{color:#cf222e}
+</style></head><body>
+{{range .}}<h2>{{.Kind}}: {{.Server}}</h2>
+<table><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range $i, $c := .}}{{if eq $i 1}}<td class="{{$c}}">{{$c}}</td>{{else}}<td>{{$c}}</td>{{end}}{{end}}</tr>
+{{end}}</table>
+<p>{{.Summary}}</p>
+{{end}}</body></html>
+`))
+
+func renderHTML(w io.Writer, reports []savedReport) error {
+	type section struct {
+		Kind, Server, Summary string
+		Header                []string
+		Rows                  [][]string
+	}
+	var sections []section
+	for _, r := range reports {
+		header, rows := r.table()
+		sections = append(sections, section{Kind: r.kind(), Server: r.server(), Summary: r.summary(), Header: header, Rows: rows})
+	}
+	return reportHTML.Execute(w, sections)
+}
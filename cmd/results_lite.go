@@ -0,0 +1,12 @@
+//go:build lite
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+)
+
+func renderHTML(w io.Writer, reports []savedReport) error {
+	return fmt.Errorf("--format %s is not in lite builds", viewHTML)
+}
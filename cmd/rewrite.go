@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	rewriteTargetsFile string
+	rewriteReference   string
+)
+
+var rewriteCmd = &cobra.Command{
+	Use:   "rewrite [dns-server]",
+	Short: "Check banking, login and software-update domains for rewritten answers (malware, hijacked routers) by comparing the local resolver with a trusted DoH or DNSSEC-validating reference.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		targets := dnsprobe.HighValueTargets
+		if rewriteTargetsFile != "" {
+			if targets, err = dnsprobe.ReadFilterTargets(rewriteTargetsFile); err != nil {
+				return err
+			}
+		}
+		reference := rewriteReference
+		if !dnsprobe.IsDoHURL(reference) {
+			reference = dnsprobe.NormalizeServer(reference)
+		}
+
+		ctx := cmd.Context()
+		results := dnsprobe.CheckRewrites(ctx, server, reference, targets, baseOptions())
+		results, skipped := splitSkipped(ctx, results, func(r dnsprobe.RewriteResult) (string, error) { return r.Target.Domain, r.Err })
+
+		au := newAurora()
+		fmt.Printf("\n=== rewrite check: %s vs %s ===\n", server, reference)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "category\tdomain\tlocal\treference\tverdict")
+		rewritten, failed := 0, 0
+		for _, r := range results {
+			verdict := string(r.Verdict)
+			switch {
+			case r.Err != nil:
+				failed++
+				verdict = au.Red(fmt.Sprintf("error: %v", r.Err)).String()
+			case r.Verdict.IsRewritten():
+				rewritten++
+				verdict = au.Red(verdict).String()
+			case r.Verdict == dnsprobe.RewriteBlocked:
+				verdict = au.Yellow(verdict).String()
+			default:
+				verdict = au.Green(verdict).String()
+			}
+			ref := answerSummary(r.RefRCode, r.RefAddrs)
+			if r.Validated {
+				ref += " (validated)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Target.Category, r.Target.Domain, answerSummary(r.RCode, r.Addrs), ref, verdict)
+		}
+		_ = w.Flush()
+
+		fmt.Println()
+		switch {
+		case rewritten > 0:
+			fmt.Printf("%s %d of %d domains resolve somewhere the reference does not. Check the DNS servers set on this machine and the router, and scan for malware before logging in anywhere.\n",
+				au.Red("warning:"), rewritten, len(results))
+		case len(results) == 0:
+		case failed == len(results):
+			fmt.Printf("no domain could be compared; check that %s is reachable\n", reference)
+		default:
+			fmt.Printf("%s no rewritten answers among %d domains\n", au.Green("ok:"), len(results)-failed)
+		}
+		printSkipped(ctx, au, "domain", skipped)
+		if rewritten > 0 {
+			return exitError{code: exitMismatch, err: fmt.Errorf("%d rewritten answer(s)", rewritten)}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rewriteCmd.Flags().StringVar(&rewriteTargetsFile, "targets-file", "", "File of \"category domain\" lines to check instead of the built-in list.")
+	rewriteCmd.Flags().StringVar(&rewriteReference, "reference", dnsprobe.DefaultDoHURL, "Trusted reference: a DoH URL, or a DNSSEC-validating resolver reached over a path you trust.")
+}
+
+func answerSummary(rcode string, addrs []string) string {
+	if rcode == "NOERROR" && len(addrs) > 0 {
+		return strings.Join(addrs, ",")
+	}
+	return orDash(rcode)
+}
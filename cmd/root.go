@@ -1,22 +1,226 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/logrusorgru/aurora/v4"
 	"github.com/spf13/cobra"
 )
 
 var rootCmd = &cobra.Command{
 	Use:          "dnsdoc",
 	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfig(cmd); err != nil {
+			return err
+		}
+		if err := loadData(cmd); err != nil {
+			return err
+		}
+		if rootDNS0x20 {
+			if cmd.Flags().Changed("qname-case") && rootCase != dnsprobe.CaseRandom {
+				return fmt.Errorf("--dns0x20 randomizes the name case and cannot be combined with --qname-case %s", rootCase)
+			}
+			rootCase = dnsprobe.CaseRandom
+		}
+		if rootMaxRuntime < 0 {
+			return fmt.Errorf("--max-runtime must be >= 0")
+		}
+		if rootMaxRuntime > 0 {
+			var ctx context.Context
+			ctx, rootCancel = context.WithTimeout(cmd.Context(), rootMaxRuntime)
+			cmd.SetContext(ctx)
+		}
+		return dnsprobe.CheckQNameCase(rootCase)
+	},
+}
+
+var (
+	rootUpstream bool
+	rootLite     bool
+	rootIPv4     bool
+	rootIPv6     bool
+	rootSource   string
+	rootTCP      bool
+	rootProxy    string
+	rootCase     string
+	rootDot      bool
+	rootDNS0x20  bool
+	rootNoRD     bool
+	rootDataDir  string
+
+	rootMaxRuntime time.Duration
+	rootCancel     context.CancelFunc = func() {}
+)
+
+// exitMismatch is the exit status when --expect assertions fail, so scripts
+// can tell wrong answers apart from probe errors (status 1).
+const exitMismatch = 2
+
+// exitInterrupted is the exit status of a run cut short by Ctrl-C or
+// SIGTERM after printing what it measured, as shells report a command
+// killed by SIGINT.
+const exitInterrupted = 130
+
+// exitError makes Execute exit with code instead of 1.
+type exitError struct {
+	code int
+	err  error
 }
 
+func (e exitError) Error() string { return e.err.Error() }
+
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	rootCancel()
+	if err != nil {
+		var ee exitError
+		if errors.As(err, &ee) {
+			os.Exit(ee.code)
+		}
 		os.Exit(1)
 	}
 }
 
+// newAurora returns the colorizer for terminal output; lite mode prints
+// plain text for serial consoles and log collectors on small devices.
+func newAurora() *aurora.Aurora {
+	return aurora.New(aurora.WithColors(!rootLite))
+}
+
+// baseOptions returns the probe options shared by every command, with the
+// global transport flags applied.
+func baseOptions() dnsprobe.Options {
+	opts := dnsprobe.Options{Timeout: 3 * time.Second, Source: rootSource, TCP: rootTCP, Proxy: rootProxy, QNameCase: rootCase, Verify0x20: rootDNS0x20, NoRecurse: rootNoRD}
+	switch {
+	case rootIPv4:
+		opts.Family = 4
+	case rootIPv6:
+		opts.Family = 6
+	}
+	return opts
+}
+
+// stopReason says why ctx ended a run early: --max-runtime ran out, or the
+// run was interrupted.
+func stopReason(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Sprintf("--max-runtime %s reached", rootMaxRuntime)
+	}
+	return "interrupted"
+}
+
+// interruptExit is the error a run that watched ctx ends with:
+// exitInterrupted when a signal cut it short, err otherwise. A run ended by
+// --max-runtime finished as asked.
+func interruptExit(ctx context.Context, err error) error {
+	if ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return exitError{code: exitInterrupted, err: fmt.Errorf("interrupted")}
+	}
+	return err
+}
+
+// printSkipped lists what a run ended by ctx did not get to; noun names
+// one item.
+func printSkipped(ctx context.Context, au *aurora.Aurora, noun string, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	if len(names) > 1 {
+		noun += "s"
+	}
+	fmt.Printf("%s %d %s (%s): %s\n", au.Yellow("skipped:"), len(names), noun, stopReason(ctx), strings.Join(names, ", "))
+}
+
+// splitSkipped sets aside the items that ctx ending cut short, as told by
+// the error key returns with each item's name, and returns the rest and
+// the names for printSkipped.
+func splitSkipped[T any](ctx context.Context, items []T, key func(T) (string, error)) ([]T, []string) {
+	if ctx.Err() == nil {
+		return items, nil
+	}
+	var kept []T
+	var skipped []string
+	for _, it := range items {
+		if name, err := key(it); dnsprobe.CutShort(ctx, err) {
+			skipped = append(skipped, name)
+			continue
+		}
+		kept = append(kept, it)
+	}
+	return kept, skipped
+}
+
 func init() {
+	rootCmd.PersistentFlags().StringVar(&rootConfig, "config", "", "Config file of flag defaults (default <user config dir>/dnsdoc/config.json). Precedence: flags, then DNSDOC_<FLAG> environment variables (DNSDOC_SERVER sets the default dns-server), then this file.")
+	rootCmd.PersistentFlags().StringVar(&rootAllowlist, "allowlist", "", "File of networks (CIDR or address) and domains you own, one per line; load, scan and enumeration modes target them without asking. A \"limits\" allowlist in the config file takes precedence.")
+	rootCmd.PersistentFlags().BoolVarP(&rootYes, "yes", "y", false, "Confirm that load, scan and enumeration modes may target servers or domains outside the allowlist.")
+	rootCmd.PersistentFlags().BoolVarP(&rootIPv4, "ipv4", "4", false, "Only use IPv4 transport to reach the server.")
+	rootCmd.PersistentFlags().BoolVarP(&rootIPv6, "ipv6", "6", false, "Only use IPv6 transport to reach the server.")
+	rootCmd.MarkFlagsMutuallyExclusive("ipv4", "ipv6")
+	rootCmd.PersistentFlags().StringVar(&rootSource, "source", "", "Send queries from this local IP address or interface (e.g. 192.0.2.5 or wg0) to choose the egress path.")
+	rootCmd.PersistentFlags().BoolVar(&rootTCP, "tcp", false, "Send queries over TCP instead of UDP.")
+	rootCmd.PersistentFlags().StringVar(&rootProxy, "proxy", "", "Send queries through a SOCKS5 or HTTP CONNECT proxy (socks5://host:port, http://host:port); implies --tcp, and DoT and DoH connections are tunneled through it too. Proxy connect time is reported separately.")
+	rootCmd.PersistentFlags().StringVar(&rootCase, "qname-case", dnsprobe.CasePreserve, "Letter case of query names on the wire: preserve, lower, or random (0x20-style).")
+	rootCmd.PersistentFlags().BoolVar(&rootDNS0x20, "dns0x20", false, "Randomize query name case and fail any reply whose question does not echo it exactly, exposing resolvers or paths that normalize case.")
+	rootCmd.PersistentFlags().BoolVar(&rootNoRD, "no-rd", false, "Clear the RD (recursion desired) bit so resolvers answer only from cache; see dnsdoc snoop.")
+	rootCmd.PersistentFlags().BoolVar(&rootDot, "trailing-dot", false, "Treat query names as absolute by appending the trailing dot, so search lists are skipped and OS/stub lookups get \"name.\". The wire name is always fully qualified.")
+	rootCmd.PersistentFlags().BoolVar(&rootLite, "lite", liteBuild, "Lite mode for small devices and agents: plain (uncolored) output. Default on in -tags lite builds.")
+	rootCmd.PersistentFlags().StringVar(&rootDataDir, "data-dir", "", "Data set of resolver presets, popular domains, root hints and trust anchors: a directory written by \"dnsdoc data update\", to pin one for reproducible runs, or \"embedded\" for the copy built into the binary. Default: the last update when there is one, else the built-in copy.")
+	rootCmd.PersistentFlags().DurationVar(&rootMaxRuntime, "max-runtime", 0, "Bound the whole run, e.g. to fit a CI job's time limit: when it is up, queries in flight are abandoned, the remaining domains and iterations are skipped and marked so, and the summary covers what finished (0 means no limit).")
+	rootCmd.PersistentFlags().BoolVar(&rootUpstream, "upstream", false, "When the resolver is the systemd-resolved stub (127.0.0.53), probe its first upstream server instead.")
+
+	rootCmd.AddCommand(bundleCmd)
+	rootCmd.AddCommand(caaCmd)
+	rootCmd.AddCommand(calibrateCmd)
+	rootCmd.AddCommand(catalogCmd)
+	rootCmd.AddCommand(chainCmd)
+	rootCmd.AddCommand(clientmixCmd)
+	rootCmd.AddCommand(complianceCmd)
+	rootCmd.AddCommand(connectCmd)
+	rootCmd.AddCommand(dataCmd)
+	rootCmd.AddCommand(ddrCmd)
+	rootCmd.AddCommand(delegationCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(dsCmd)
+	rootCmd.AddCommand(ecsCmd)
+	rootCmd.AddCommand(ednsCmd)
+	rootCmd.AddCommand(egressCmd)
+	rootCmd.AddCommand(filteringCmd)
+	rootCmd.AddCommand(goresolverCmd)
+	rootCmd.AddCommand(idCmd)
 	rootCmd.AddCommand(latencyCmd)
+	rootCmd.AddCommand(loadCmd)
+	rootCmd.AddCommand(mailCmd)
+	rootCmd.AddCommand(mtuCmd)
+	rootCmd.AddCommand(multisignerCmd)
+	rootCmd.AddCommand(negcacheCmd)
+	rootCmd.AddCommand(nsecCmd)
+	rootCmd.AddCommand(portsCmd)
+	rootCmd.AddCommand(primingCmd)
+	rootCmd.AddCommand(profileCmd)
+	rootCmd.AddCommand(propagationCmd)
+	rootCmd.AddCommand(recoveryCmd)
+	rootCmd.AddCommand(resolversCmd)
+	rootCmd.AddCommand(rewriteCmd)
+	rootCmd.AddCommand(rootsCmd)
+	rootCmd.AddCommand(rrsigCmd)
+	rootCmd.AddCommand(serialCmd)
+	rootCmd.AddCommand(sniffCmd)
+	rootCmd.AddCommand(snoopCmd)
+	rootCmd.AddCommand(soakCmd)
+	rootCmd.AddCommand(ttlCmd)
+	rootCmd.AddCommand(typesCmd)
+	rootCmd.AddCommand(typosquatCmd)
+	rootCmd.AddCommand(validationCmd)
+	rootCmd.AddCommand(viewCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(zonemdCmd)
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var rootsCount int
+
+var rootsCmd = &cobra.Command{
+	Use:   "roots",
+	Short: "Send priming queries to every root server letter over IPv4 and IPv6, from the root hints of the data set, and print them sorted by latency: a quick check of global connectivity from this host.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rootsCount < 1 {
+			return fmt.Errorf("--count must be >= 1")
+		}
+		probes := dnsprobe.ProbeRoots(cmd.Context(), rootHints(), rootsCount, baseOptions())
+
+		au := newAurora()
+		fmt.Printf("\n=== root servers (%s) ===\n", dataSet.Location())
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "letter\taddress\tp50\tmin\tanswered\tnsid\tstatus")
+		answered := map[int]int{}
+		total := map[int]int{}
+		for _, p := range probes {
+			total[p.Family]++
+			if p.Answered == 0 {
+				fmt.Fprintf(w, "%s\t%s\t-\t-\t0/%d\t-\t%s\n", p.Letter(), p.Addr, p.Sent, au.Red(fmt.Sprintf("error: %v", p.Err)))
+				continue
+			}
+			answered[p.Family]++
+			status := au.Green("ok").String()
+			switch {
+			case p.Problem != "":
+				status = au.Red(p.Problem).String()
+			case p.Answered < p.Sent:
+				status = au.Yellow(fmt.Sprintf("lossy: %v", p.Err)).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d/%d\t%s\t%s\n", p.Letter(), p.Addr, p.P50, p.Min, p.Answered, p.Sent, orDash(p.NSID), status)
+		}
+		_ = w.Flush()
+
+		fmt.Println()
+		for _, family := range []int{4, 6} {
+			if total[family] == 0 {
+				continue
+			}
+			line := fmt.Sprintf("%d/%d answered", answered[family], total[family])
+			switch {
+			case answered[family] == 0:
+				line = au.Red(line + ": no connectivity to the root over IPv" + fmt.Sprint(family)).String()
+			case answered[family] < total[family]:
+				line = au.Yellow(line).String()
+			}
+			fmt.Printf("IPv%d:\t%s\n", family, line)
+		}
+		if answered[4]+answered[6] == 0 {
+			return fmt.Errorf("no root server answered")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootsCmd.Flags().IntVar(&rootsCount, "count", 3, "Priming queries to send to each address.")
+}
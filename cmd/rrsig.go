@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+	"dnsdoc/internal/doctor"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rrsigServer string
+	rrsigTypes  string
+	rrsigWindow time.Duration
+)
+
+var rrsigCmd = &cobra.Command{
+	Use:   "rrsig <zone>",
+	Short: "Fetch the RRSIGs over a zone's key records (DNSKEY, SOA, NS) and warn when any expires within a window, the most common cause of sudden DNSSEC outages.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var serverArgs []string
+		if rrsigServer != "" {
+			serverArgs = []string{rrsigServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+		var types []uint16
+		for _, t := range strings.Split(rrsigTypes, ",") {
+			t = strings.ToUpper(strings.TrimSpace(t))
+			qtype, ok := dns.StringToType[t]
+			if !ok {
+				return fmt.Errorf("unknown record type %q", t)
+			}
+			types = append(types, qtype)
+		}
+
+		sigs, err := dnsprobe.ZoneSignatures(cmd.Context(), server, args[0], types, baseOptions())
+		if err != nil {
+			return err
+		}
+
+		au := newAurora()
+		now := time.Now()
+		fmt.Printf("\n=== signatures of %s via %s ===\n", dns.Fqdn(args[0]), server)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "type\tsigner\tkey\talgorithm\tinception\texpiration\texpires in\tstatus")
+		var expired, expiring int
+		for _, s := range sigs {
+			status := doctor.SignatureStatus(s, now, rrsigWindow)
+			switch status {
+			case doctor.Fail:
+				expired++
+			case doctor.Warn:
+				expiring++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", s.Type, s.Signer, s.KeyTag, s.Algorithm,
+				s.Inception.UTC().Format(time.RFC3339), s.Expiration.UTC().Format(time.RFC3339), s.ExpiresIn(now).Round(time.Minute), colorStatus(au, status))
+		}
+		_ = w.Flush()
+
+		switch {
+		case expired > 0:
+			fmt.Printf("%s %d signature(s) expired or not yet valid; validating resolvers return SERVFAIL for this zone. Re-sign it now.\n", au.Red("broken:"), expired)
+			return fmt.Errorf("%d signature(s) invalid", expired)
+		case expiring > 0:
+			fmt.Printf("%s %d signature(s) expire within %s; check that the signer is refreshing them.\n", au.Yellow("expiring:"), expiring, rrsigWindow)
+			return exitError{code: exitMismatch, err: fmt.Errorf("%d signature(s) expire within %s", expiring, rrsigWindow)}
+		}
+		fmt.Printf("%s every signature is valid for at least %s\n", au.Green("ok:"), rrsigWindow)
+		return nil
+	},
+}
+
+func init() {
+	rrsigCmd.Flags().StringVar(&rrsigServer, "server", "", "DNS server to ask, a resolver or one of the zone's nameservers (default: system resolver).")
+	rrsigCmd.Flags().StringVar(&rrsigTypes, "types", "DNSKEY,SOA,NS", "CSV of the zone's record types whose signatures to check.")
+	rrsigCmd.Flags().DurationVar(&rrsigWindow, "window", doctor.DefaultConfig.SigWindow, "Warn when a signature expires within this long.")
+}
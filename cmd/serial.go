@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serialServer     string
+	serialRecursives string
+)
+
+var serialCmd = &cobra.Command{
+	Use:   "serial <zone>",
+	Short: "Ask every authoritative nameserver of a zone and a set of popular recursive resolvers for its SOA serial, flag servers that serve an older one and show how far behind each is.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var serverArgs []string
+		if serialServer != "" {
+			serverArgs = []string{serialServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+		recursives := presetServers(serialRecursives)
+
+		rep, err := dnsprobe.CheckSerials(cmd.Context(), server, args[0], recursives, baseOptions())
+		if err != nil {
+			return err
+		}
+
+		au := newAurora()
+		fmt.Printf("\n=== SOA serial of %s ===\n", rep.Zone)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "server\tnameserver\tserial\tbehind\tttl\trtt\tstatus")
+		// Each server gets one status. Like propagation, an authoritative
+		// address that is lame, behind or unreachable fails the run; a
+		// recursive is only a comparison, so its errors and stale copies
+		// are reported but do not.
+		var lagging, failed, unreachable int
+		for i, s := range rep.Servers {
+			role := s.Host
+			if s.Recursive {
+				role = "(recursive)"
+			}
+			if s.Err != nil {
+				failed++
+				if !s.Recursive {
+					unreachable++
+				}
+				fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t-\t%s\n", orDash(s.Server), role, au.Red(fmt.Sprintf("error: %v", s.Err)))
+				continue
+			}
+			behind, status := "-", au.Green("current").String()
+			n := rep.Behind(i)
+			if n > 0 {
+				behind = serialLag(s.Serial, rep.Latest, n)
+			}
+			switch {
+			case s.Recursive && n > 0:
+				status = au.Yellow(fmt.Sprintf("cached, refreshes within %s", time.Duration(s.TTL)*time.Second)).String()
+			case s.Recursive:
+			case !s.Authoritative:
+				lagging++
+				status = au.Red("lame").String()
+			case n > 0:
+				lagging++
+				status = au.Red("behind").String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%s\t%s\n", s.Server, role, s.Serial, behind, s.TTL, s.RTT, status)
+		}
+		_ = w.Flush()
+
+		fmt.Printf("latest serial:\t%d", rep.Latest)
+		if t, ok := dnsprobe.SerialDate(rep.Latest); ok {
+			fmt.Printf(" (dated %s)", t.Format("2006-01-02"))
+		}
+		fmt.Println()
+		if failed > 0 {
+			fmt.Printf("%s %d server(s) did not return a serial, %d of them authoritative\n", au.Yellow("unreachable:"), failed, unreachable)
+		}
+		if lagging > 0 {
+			fmt.Printf("%s %d nameserver address(es) serve an older serial or answer without authority; check zone transfers (NOTIFY, IXFR/AXFR) to them\n", au.Red("mismatch:"), lagging)
+		}
+		if lagging+unreachable > 0 {
+			return exitError{code: exitMismatch, err: fmt.Errorf("%d nameserver address(es) of %s out of sync or unreachable", lagging+unreachable, rep.Zone)}
+		}
+		fmt.Printf("%s every authoritative nameserver serves serial %d\n", au.Green("in sync:"), rep.Latest)
+		return nil
+	},
+}
+
+func init() {
+	serialCmd.Flags().StringVar(&serialServer, "server", "", "DNS server used to find the zone's nameservers (default: system resolver).")
+	serialCmd.Flags().StringVar(&serialRecursives, "recursives", "public", "CSV of recursive resolvers to compare: addresses, resolver preset names, or \"public\" for every preset (see dnsdoc data); empty to skip them.")
+}
+
+// serialLag describes how far serial trails latest: the serial difference,
+// plus the days between them when both follow the YYYYMMDDnn convention.
+func serialLag(serial, latest, n uint32) string {
+	lag := fmt.Sprint(n)
+	old, ok1 := dnsprobe.SerialDate(serial)
+	cur, ok2 := dnsprobe.SerialDate(latest)
+	if days := int(cur.Sub(old).Hours() / 24); ok1 && ok2 && days > 0 {
+		lag += fmt.Sprintf(" (%dd)", days)
+	}
+	return lag
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/capture"
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/bpf"
+)
+
+var (
+	sniffInterface string
+	sniffPort      uint16
+	sniffBPF       string
+	sniffInterval  time.Duration
+	sniffDuration  time.Duration
+	sniffTimeout   time.Duration
+	sniffTop       int
+
+	sniffProbe         string
+	sniffProbeInterval time.Duration
+)
+
+var sniffCmd = &cobra.Command{
+	Use:   "sniff",
+	Short: "Passively capture DNS traffic on an interface (Linux, needs root or CAP_NET_RAW) and report latency, rcodes and sizes per client and per resolver from real queries, refreshed live. Clients are profiled for query rate, most-queried names, retry storms and search-list (ndots) junk; --probe compares active probes with what real clients see.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if sniffInterface == "" {
+			return fmt.Errorf("-i/--interface is required")
+		}
+		if sniffInterval <= 0 {
+			return fmt.Errorf("--interval must be > 0")
+		}
+		if sniffProbe != "" && sniffProbeInterval <= 0 {
+			return fmt.Errorf("--probe-interval must be > 0")
+		}
+		var filter []bpf.RawInstruction
+		var err error
+		if sniffBPF != "" {
+			if filter, err = capture.LoadFilter(sniffBPF); err != nil {
+				return err
+			}
+		} else {
+			filter = capture.PortFilter(sniffPort)
+		}
+
+		var probeAddr netip.AddrPort
+		if sniffProbe != "" {
+			probeAddr, err = netip.ParseAddrPort(dnsprobe.NormalizeServer(sniffProbe))
+			if err != nil {
+				return fmt.Errorf("--probe must be an IP address, optionally with port: %w", err)
+			}
+			probeAddr = netip.AddrPortFrom(probeAddr.Addr().Unmap(), probeAddr.Port())
+		}
+
+		src, err := capture.Open(sniffInterface, filter)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if sniffDuration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, sniffDuration)
+			defer cancel()
+		}
+
+		packets := make(chan capture.Packet, 1024)
+		readErr := make(chan error, 1)
+		go func() {
+			for {
+				p, err := src.Next()
+				if err != nil {
+					readErr <- err
+					return
+				}
+				// Next reuses its buffer.
+				p.Payload = append([]byte(nil), p.Payload...)
+				packets <- p
+			}
+		}()
+
+		if sniffBPF != "" {
+			fmt.Printf("SNIFF %s with filter %s (Ctrl-C to stop)\n", sniffInterface, sniffBPF)
+		} else {
+			fmt.Printf("SNIFF %s port %d (Ctrl-C to stop)\n", sniffInterface, sniffPort)
+		}
+		t := capture.NewTracker(sniffTimeout)
+
+		// Active probes go out from a fixed port so the tracker can tell
+		// them from client traffic and time them the same way, on the wire.
+		var probeTick <-chan time.Time
+		probeNames := make(chan string, 1)
+		if probeAddr.IsValid() {
+			if t.ProbePort, err = freeUDPPort(); err != nil {
+				return err
+			}
+			opts := baseOptions()
+			opts.SourcePort = int(t.ProbePort)
+			opts.TCP, opts.Proxy = false, ""
+			go func() {
+				for name := range probeNames {
+					_, _ = dnsprobe.Probe(ctx, probeAddr.String(), name, opts)
+				}
+			}()
+			defer close(probeNames)
+			pt := time.NewTicker(sniffProbeInterval)
+			defer pt.Stop()
+			probeTick = pt.C
+			fmt.Printf("probing %s every %s with the names clients query most\n", probeAddr, sniffProbeInterval)
+		}
+
+		start := time.Now()
+		tick := time.NewTicker(sniffInterval)
+		defer tick.Stop()
+		for seq := 0; ; {
+			select {
+			case p := <-packets:
+				t.Add(p)
+			case <-probeTick:
+				name := "example.com."
+				if top := t.TopNames(10); len(top) > 0 {
+					name = top[seq%len(top)].Name
+				}
+				seq++
+				select {
+				case probeNames <- name:
+				default: // the previous probe is still waiting for its answer
+				}
+			case now := <-tick.C:
+				t.Expire(now)
+				printSniffReport(t, now.Sub(start), probeAddr.Addr())
+			case err := <-readErr:
+				return err
+			case <-ctx.Done():
+				t.Expire(time.Now())
+				printSniffReport(t, time.Since(start), probeAddr.Addr())
+				return nil
+			}
+		}
+	},
+}
+
+func init() {
+	sniffCmd.Flags().StringVarP(&sniffInterface, "interface", "i", "", "Interface to capture on, e.g. eth0 (required).")
+	sniffCmd.Flags().Uint16Var(&sniffPort, "port", 53, "Capture UDP and TCP traffic to or from this port.")
+	sniffCmd.Flags().StringVar(&sniffBPF, "bpf", "", "File with a classic BPF program replacing the port filter, as printed by tcpdump -ddd, e.g. tcpdump -i eth0 -ddd 'port 53 and host 10.0.0.1' > dns.bpf")
+	sniffCmd.Flags().DurationVar(&sniffInterval, "interval", 5*time.Second, "How often to print the statistics.")
+	sniffCmd.Flags().DurationVar(&sniffDuration, "duration", 0, "Stop after this long (0 = run until interrupted).")
+	sniffCmd.Flags().DurationVar(&sniffTimeout, "timeout", 5*time.Second, "Count a query as lost when no response is seen within this time.")
+	sniffCmd.Flags().IntVar(&sniffTop, "top", 20, "Show at most this many clients and resolvers (0 = all).")
+	sniffCmd.Flags().StringVar(&sniffProbe, "probe", "", "Also probe this resolver (IP[:port]) while capturing, with the names clients query most, and compare the probes' latency with what real clients see.")
+	sniffCmd.Flags().DurationVar(&sniffProbeInterval, "probe-interval", time.Second, "Time between active probes with --probe.")
+}
+
+func freeUDPPort() (uint16, error) {
+	c, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer c.Close()
+	return uint16(c.LocalAddr().(*net.UDPAddr).Port), nil
+}
+
+func printSniffReport(t *capture.Tracker, elapsed time.Duration, probed netip.Addr) {
+	fmt.Printf("\n=== %s: %d messages", elapsed.Round(time.Second), t.Messages)
+	if t.Malformed > 0 {
+		fmt.Printf(", %d malformed", t.Malformed)
+	}
+	fmt.Printf(" ===\n")
+	printSniffTable("resolver", t.Resolvers())
+	printSniffTable("client", t.Clients())
+	printClientBehaviour(newAurora(), t.Clients())
+	if probed.IsValid() {
+		printPassiveVsActive(newAurora(), probed, t.Resolver(probed), t.Probe(probed))
+	}
+}
+
+// printPassiveVsActive compares what real clients of a resolver experienced
+// with dnsdoc's probes over the same window, both timed from the capture.
+func printPassiveVsActive(au *aurora.Aurora, addr netip.Addr, passive, active *capture.Endpoint) {
+	fmt.Printf("\npassive vs active (%s):\n", addr)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "traffic\tqueries\tanswered\tlost\tp50\tp95\tmax")
+	row := func(label string, e *capture.Endpoint) {
+		if e == nil || e.Answered == 0 {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\t-\n", label)
+			return
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t%s\n", label, e.Queries, e.Answered, e.Lost,
+			e.Latency.Quantile(0.5).Round(time.Microsecond), e.Latency.Quantile(0.95).Round(time.Microsecond), e.Latency.Max().Round(time.Microsecond))
+	}
+	row("real clients", passive)
+	row("dnsdoc probes", active)
+	_ = w.Flush()
+
+	if passive == nil || active == nil || passive.Answered < 5 || active.Answered < 5 {
+		fmt.Printf("not enough answered queries on both sides to compare yet\n")
+		return
+	}
+	pMed, aMed := passive.Latency.Quantile(0.5), active.Latency.Quantile(0.5)
+	pTail, aTail := passive.Latency.Quantile(0.95), active.Latency.Quantile(0.95)
+	ratio, tail := float64(pMed)/float64(aMed), float64(pTail)/float64(aTail)
+	// Ratios of sub-millisecond latencies are mostly scheduling noise.
+	switch {
+	case ratio > 2 && pMed-aMed >= time.Millisecond:
+		fmt.Printf("%s real clients see %.1fx the probes' median latency: synthetic results understate their experience (cache misses on less popular names, or a different path)\n", au.Yellow("mismatch:"), ratio)
+	case ratio < 0.5 && aMed-pMed >= time.Millisecond:
+		fmt.Printf("%s probes see %.1fx the clients' median latency: the probe host's path to the resolver is worse than the clients'\n", au.Yellow("mismatch:"), 1/ratio)
+	case tail > 2 && pTail-aTail >= time.Millisecond:
+		fmt.Printf("%s medians agree, but real clients' p95 is %.1fx the probes': the tail is worse than synthetic tests show\n", au.Yellow("tail mismatch:"), tail)
+	default:
+		fmt.Printf("%s probes match real client latency (median ratio %.2f, p95 ratio %.2f)\n", au.Green("ok:"), ratio, tail)
+	}
+}
+
+func printClientBehaviour(au *aurora.Aurora, clients []*capture.Endpoint) {
+	if len(clients) == 0 {
+		return
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "client	q/s	names	retries	search junk	nxdomain	top names")
+	var flagged []string
+	for i, c := range clients {
+		for _, m := range c.Misbehaviour() {
+			flagged = append(flagged, fmt.Sprintf("%s %s: %s", au.Yellow("misbehaving:"), c.Addr, m))
+		}
+		if sniffTop > 0 && i >= sniffTop {
+			continue
+		}
+		p := c.Profile
+		var top []string
+		for _, n := range p.TopNames(3) {
+			top = append(top, fmt.Sprintf("%s (%d)", n.Name, n.Count))
+		}
+		fmt.Fprintf(w, "%s\t%.1f\t%d\t%d\t%d\t%d\t%s\n", c.Addr, c.Rate(), len(p.Names), p.Retries, p.SearchJunk, p.NXDomain, orDash(strings.Join(top, ", ")))
+	}
+	_ = w.Flush()
+	for _, f := range flagged {
+		fmt.Println(f)
+	}
+}
+
+func printSniffTable(role string, eps []*capture.Endpoint) {
+	if len(eps) == 0 {
+		return
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tqueries\tanswered\tlost\tp50\tp95\tmax\tquery size\tresp size\trcodes\n", role)
+	for i, e := range eps {
+		if sniffTop > 0 && i == sniffTop {
+			fmt.Fprintf(w, "(%d more)\n", len(eps)-i)
+			break
+		}
+		p50, p95, slowest, rsize := "-", "-", "-", "-"
+		if e.Answered > 0 {
+			p50 = e.Latency.Quantile(0.5).Round(time.Microsecond).String()
+			p95 = e.Latency.Quantile(0.95).Round(time.Microsecond).String()
+			slowest = e.Latency.Max().Round(time.Microsecond).String()
+			rsize = fmt.Sprintf("%dB", e.RespBytes/e.Answered)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\t%s\t%dB\t%s\t%s\n", e.Addr, e.Queries, e.Answered, e.Lost,
+			p50, p95, slowest, e.QueryBytes/e.Queries, rsize, formatRCodes(e.RCodes))
+	}
+	_ = w.Flush()
+}
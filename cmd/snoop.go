@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snoopDomains     string
+	snoopDomainsFile string
+	snoopType        string
+	snoopConcurrency int
+)
+
+var snoopCmd = &cobra.Command{
+	Use:   "snoop [dns-server]",
+	Short: "Cache snooping: send non-recursive (RD=0) queries and report which names the resolver already has cached and for how much longer, for cache audits.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		domains := dataSet.Domains
+		switch {
+		case snoopDomainsFile != "":
+			domains, err = readDomainsFile(snoopDomainsFile)
+		case strings.TrimSpace(snoopDomains) != "":
+			domains, err = parseDomains(snoopDomains)
+		}
+		if err != nil {
+			return err
+		}
+		qtype, ok := dns.StringToType[strings.ToUpper(snoopType)]
+		if !ok {
+			return fmt.Errorf("unknown record type %q", snoopType)
+		}
+		opts := baseOptions()
+		opts.Type = qtype
+		if err := guard(guardedRun{mode: "snoop", servers: []string{server}, concurrency: snoopConcurrency}); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		recursed, err := dnsprobe.SnoopControl(ctx, server, opts)
+		if err != nil {
+			return err
+		}
+		results := dnsprobe.Snoop(ctx, server, domains, opts, snoopConcurrency)
+		results, skipped := splitSkipped(ctx, results, func(r dnsprobe.SnoopResult) (string, error) { return r.Name, r.Err })
+
+		au := newAurora()
+		fmt.Printf("\n=== cache snoop: %s (%s, RD=0) ===\n", server, dns.TypeToString[qtype])
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "name\tstate\tremaining ttl\trcode")
+		counts := map[dnsprobe.SnoopState]int{}
+		failed := 0
+		for _, r := range results {
+			if r.Err != nil {
+				failed++
+				fmt.Fprintf(w, "%s\t%s\t-\t-\n", r.Name, au.Red(fmt.Sprintf("error: %v", r.Err)))
+				continue
+			}
+			counts[r.State]++
+			state, ttl := string(r.State), "-"
+			switch r.State {
+			case dnsprobe.SnoopCached, dnsprobe.SnoopNegative:
+				state = au.Green(state).String()
+				ttl = (time.Duration(r.TTL) * time.Second).String()
+			case dnsprobe.SnoopRefused, dnsprobe.SnoopUnclear:
+				state = au.Yellow(state).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, state, ttl, r.RCode)
+		}
+		_ = w.Flush()
+
+		fmt.Printf("\ncached:\t%d of %d (%d negative)\n", counts[dnsprobe.SnoopCached]+counts[dnsprobe.SnoopNegative], len(results), counts[dnsprobe.SnoopNegative])
+		if failed > 0 {
+			fmt.Printf("failed:\t%d\n", failed)
+		}
+		printSkipped(ctx, au, "name", skipped)
+		switch {
+		case recursed:
+			fmt.Printf("%s the resolver answered a random never-cached name with NXDOMAIN, so it recursed despite RD=0; the states above reflect fresh lookups, not its cache\n", au.Yellow("warning:"))
+		case len(results) > failed && counts[dnsprobe.SnoopRefused] == len(results)-failed:
+			fmt.Println("the resolver refuses non-recursive queries, so its cache cannot be snooped (recommended for shared resolvers)")
+		case counts[dnsprobe.SnoopCached]+counts[dnsprobe.SnoopNegative] > 0:
+			fmt.Println("the resolver answers RD=0 queries from its cache: anyone who can query it can see which names its clients looked up recently")
+		}
+		return nil
+	},
+}
+
+func init() {
+	snoopCmd.Flags().StringVar(&snoopDomains, "domains", "", "CSV of names to check (overrides the built-in popular set).")
+	snoopCmd.Flags().StringVar(&snoopDomainsFile, "domains-file", "", "File with one name per line (# comments allowed).")
+	snoopCmd.Flags().StringVar(&snoopType, "type", "A", "Record type to check for.")
+	snoopCmd.Flags().IntVar(&snoopConcurrency, "concurrency", 16, "Queries in flight at once.")
+}
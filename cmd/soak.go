@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	soakDuration    time.Duration
+	soakInterval    time.Duration
+	soakQPS         float64
+	soakDomains     string
+	soakReport      string
+	soakPool        int
+	soakPoolIdle    time.Duration
+	soakBurst       int
+	soakBurstSpread time.Duration
+	soakBurstEvery  time.Duration
+)
+
+var soakCmd = &cobra.Command{
+	Use:   "soak [dns-server]",
+	Short: "Run a steady, moderate query load, or with --burst a train of microbursts, for a long period and print periodic stability summaries (latency percentiles, loss, rcode mix, per-burst loss).",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		if soakQPS <= 0 {
+			return fmt.Errorf("--qps must be > 0")
+		}
+		if soakDuration <= 0 || soakInterval <= 0 {
+			return fmt.Errorf("--duration and --interval must be > 0")
+		}
+		run := guardedRun{mode: "soak", servers: []string{server}, rate: soakQPS, duration: soakDuration}
+		if soakBurst > 0 {
+			if soakBurstSpread < 0 || soakBurstEvery <= soakBurstSpread {
+				return fmt.Errorf("--burst-every must be longer than --burst-spread")
+			}
+			run.rate = float64(soakBurst) / soakBurstEvery.Seconds()
+			run.concurrency = soakBurst
+		}
+		if err := guard(run); err != nil {
+			return err
+		}
+
+		domains, err := parseDomains(soakDomains)
+		if err != nil {
+			return err
+		}
+
+		pool, err := newPool(soakPool, soakPoolIdle, server)
+		if err != nil {
+			return err
+		}
+		if pool != nil {
+			defer pool.Close()
+		}
+
+		cfg := dnsprobe.SoakConfig{
+			Server:   server,
+			Domains:  domains,
+			Options:  baseOptions(),
+			QPS:      soakQPS,
+			Duration: soakDuration,
+			Interval: soakInterval,
+		}
+		cfg.Options.Pool = pool
+
+		load := fmt.Sprintf("qps=%g", soakQPS)
+		if soakBurst > 0 {
+			cfg.Burst, cfg.BurstSpread, cfg.BurstEvery = soakBurst, soakBurstSpread, soakBurstEvery
+			load = fmt.Sprintf("burst=%d in %s every %s", soakBurst, soakBurstSpread, soakBurstEvery)
+		}
+		fmt.Printf("soak: server=%s %s duration=%s interval=%s domains=%s\n",
+			server, load, soakDuration, soakInterval, strings.Join(domains, ","))
+
+		// Ctrl-C ends the soak early with the report so far.
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		var windows []dnsprobe.SoakSummary
+		total := dnsprobe.Soak(ctx, cfg, func(s dnsprobe.SoakSummary) {
+			windows = append(windows, s)
+			printSoakSummary(os.Stdout, fmt.Sprintf("interval %d", len(windows)), s)
+		})
+
+		if ctx.Err() != nil {
+			fmt.Printf("\nsoak ended after %s of %s: %s\n", total.End.Sub(total.Start).Round(time.Second), soakDuration, stopReason(ctx))
+		}
+		printSoakReport(os.Stdout, server, windows, total)
+		if pool != nil {
+			printPoolStats(os.Stdout, pool)
+		}
+
+		if soakReport != "" {
+			f, err := os.Create(soakReport)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			printSoakReport(f, server, windows, total)
+			fmt.Printf("\nreport written to %s\n", soakReport)
+		}
+		return interruptExit(ctx, nil)
+	},
+}
+
+func init() {
+	soakCmd.Flags().DurationVar(&soakDuration, "duration", 24*time.Hour, "Total soak duration. Example: --duration 24h")
+	soakCmd.Flags().DurationVar(&soakInterval, "interval", time.Hour, "How often to print a summary of the last window.")
+	soakCmd.Flags().Float64Var(&soakQPS, "qps", 5, "Steady query rate (queries per second).")
+	soakCmd.Flags().StringVar(&soakDomains, "domains", "", "CSV of domains to cycle through (overrides the default set).")
+	soakCmd.Flags().IntVar(&soakPool, "pool", 0, "With --tcp or --proxy, keep up to N connections open and reuse them across queries, so a high --qps does not open a connection per query (0 disables).")
+	soakCmd.Flags().DurationVar(&soakPoolIdle, "pool-idle", 30*time.Second, "Close pooled connections idle for longer than this.")
+	soakCmd.Flags().StringVar(&soakReport, "report", "", "Also write the final report to this file.")
+	soakCmd.Flags().IntVar(&soakBurst, "burst", 0, "Instead of a steady --qps, send microbursts of N queries; resolvers, rate limiters and firewalls often drop bursts they would absorb at the same average rate (0 disables).")
+	soakCmd.Flags().DurationVar(&soakBurstSpread, "burst-spread", 10*time.Millisecond, "With --burst, spread each burst's queries evenly over this long (0 sends them back to back).")
+	soakCmd.Flags().DurationVar(&soakBurstEvery, "burst-every", time.Second, "With --burst, start a burst this often.")
+}
+
+func printSoakSummary(out io.Writer, label string, s dnsprobe.SoakSummary) {
+	fmt.Fprintf(out, "\n=== %s (%s - %s) ===\n", label, s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "metric\tvalue")
+	fmt.Fprintf(w, "sent\t%d\n", s.Sent)
+	fmt.Fprintf(w, "success\t%d\n", s.Success)
+	fmt.Fprintf(w, "lost\t%d (%.2f%%)\n", s.Lost, s.LossRatio()*100)
+	fmt.Fprintf(w, "errors\t%d\n", s.Errors)
+	fmt.Fprintf(w, "min\t%s\n", s.Min)
+	fmt.Fprintf(w, "mean\t%s\n", s.Mean)
+	fmt.Fprintf(w, "p50\t%s\n", s.P50)
+	fmt.Fprintf(w, "p90\t%s\n", s.P90)
+	fmt.Fprintf(w, "p99\t%s\n", s.P99)
+	fmt.Fprintf(w, "max\t%s\n", s.Max)
+	fmt.Fprintf(w, "rcodes\t%s\n", formatRCodes(s.RCodes))
+	if len(s.Bursts) > 0 {
+		lossy, worst := 0, s.Bursts[0]
+		for _, b := range s.Bursts {
+			if b.Lost > 0 {
+				lossy++
+			}
+			if b.LossRatio() > worst.LossRatio() || b.LossRatio() == worst.LossRatio() && b.Max > worst.Max {
+				worst = b
+			}
+		}
+		fmt.Fprintf(w, "bursts\t%d, %d with loss\n", len(s.Bursts), lossy)
+		fmt.Fprintf(w, "worst burst\t#%d: %d/%d lost, p50 %s, max %s\n", worst.Seq, worst.Lost, worst.Sent, worst.P50, worst.Max)
+	}
+	_ = w.Flush()
+}
+
+// soakBurstRows caps the burst table of a soak report; longer runs list
+// only their worst bursts.
+const soakBurstRows = 20
+
+func printSoakBursts(out io.Writer, bursts []dnsprobe.BurstSummary) {
+	if len(bursts) == 0 {
+		return
+	}
+	title := "bursts"
+	if len(bursts) > soakBurstRows {
+		bursts = slices.Clone(bursts)
+		sort.SliceStable(bursts, func(i, j int) bool {
+			if bursts[i].LossRatio() != bursts[j].LossRatio() {
+				return bursts[i].LossRatio() > bursts[j].LossRatio()
+			}
+			return bursts[i].Max > bursts[j].Max
+		})
+		bursts = bursts[:soakBurstRows]
+		title = fmt.Sprintf("worst %d bursts", soakBurstRows)
+	}
+	fmt.Fprintf(out, "\n=== %s ===\n", title)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "burst\tstart\tspread\tsent\tlost\terrors\tp50\tmax")
+	for _, b := range bursts {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d (%.0f%%)\t%d\t%s\t%s\n",
+			b.Seq, b.Start.Format("15:04:05.000"), b.Spread.Round(time.Microsecond), b.Sent, b.Lost, b.LossRatio()*100, b.Errors, b.P50, b.Max)
+	}
+	_ = w.Flush()
+}
+
+func printSoakReport(out io.Writer, server string, windows []dnsprobe.SoakSummary, total dnsprobe.SoakSummary) {
+	fmt.Fprintf(out, "\n=== soak report: %s ===\n", server)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "interval\tstart\tsent\tloss\terrors\tp50\tp90\tp99\tmax\trcodes")
+	for i, s := range windows {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%.2f%%\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			i+1, s.Start.Format(time.RFC3339), s.Sent, s.LossRatio()*100, s.Errors, s.P50, s.P90, s.P99, s.Max, formatRCodes(s.RCodes))
+	}
+	_ = w.Flush()
+
+	printSoakSummary(out, "overall", total)
+	printSoakBursts(out, total.Bursts)
+	fmt.Fprintf(out, "\npercentiles are estimated from streaming histogram buckets (max relative error %.2f%%); min/max/mean are exact\n",
+		dnsprobe.QuantileRelativeError*100)
+}
+
+func formatRCodes(m map[string]int) string {
+	if len(m) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, m[k]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/logrusorgru/aurora/v4"
+)
+
+const sparkWidth = 40
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline scales the samples to the slowest one in view; failures,
+// negative samples, show as ×.
+func sparkline(au *aurora.Aurora, samples []time.Duration) string {
+	var max time.Duration
+	for _, s := range samples {
+		if s > max {
+			max = s
+		}
+	}
+	var b strings.Builder
+	for _, s := range samples {
+		if s < 0 {
+			b.WriteString(au.Red("×").String())
+			continue
+		}
+		i := 0
+		if max > 0 {
+			i = int(float64(s) / float64(max) * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+// sparkRedraw is how often a live sparkline is redrawn at most, so fast
+// benchmarks do not spend their time writing to the terminal.
+const sparkRedraw = 100 * time.Millisecond
+
+// liveSpark keeps the last sparkWidth latencies of a run and shows them as
+// a sparkline on a status line on stderr, below the regular output. A nil
+// liveSpark does nothing, for output that is not a terminal.
+type liveSpark struct {
+	mu      sync.Mutex
+	au      *aurora.Aurora
+	label   string
+	samples []time.Duration
+	drawn   time.Time
+	shown   bool
+}
+
+// newLiveSpark returns a liveSpark labelled label when stderr is a
+// terminal and --lite is off, nil otherwise.
+func newLiveSpark(label string) *liveSpark {
+	if rootLite || !stderrIsTerminal() {
+		return nil
+	}
+	return &liveSpark{au: newAurora(), label: label}
+}
+
+// add records a latency, -1 for a failed query, and redraws.
+func (s *liveSpark) add(d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = append(s.samples, d)
+	if len(s.samples) > sparkWidth {
+		s.samples = s.samples[len(s.samples)-sparkWidth:]
+	}
+	if now := time.Now(); !s.shown || now.Sub(s.drawn) >= sparkRedraw {
+		s.drawn = now
+		s.draw()
+	}
+}
+
+func (s *liveSpark) draw() {
+	var last string
+	if d := s.samples[len(s.samples)-1]; d >= 0 {
+		last = d.Round(time.Microsecond).String()
+	} else {
+		last = "failed"
+	}
+	fmt.Fprintf(os.Stderr, "\r\033[K%s %s %s", s.label, sparkline(s.au, s.samples), last)
+	s.shown = true
+}
+
+// clear erases the status line before regular output is written; the next
+// sample draws it again.
+func (s *liveSpark) clear() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shown {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		s.shown = false
+	}
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ttlDomains     string
+	ttlDomainsFile string
+	ttlLow         time.Duration
+	ttlConcurrency int
+	ttlCompareAuth bool
+)
+
+type ttlBucket struct {
+	label string
+	upper uint32 // exclusive, in seconds
+}
+
+var ttlBuckets = []ttlBucket{
+	{"0s", 1},
+	{"1s-29s", 30},
+	{"30s-59s", 60},
+	{"1m-4m59s", 5 * 60},
+	{"5m-14m59s", 15 * 60},
+	{"15m-59m59s", 60 * 60},
+	{"1h-5h59m", 6 * 60 * 60},
+	{"6h-23h59m", 24 * 60 * 60},
+	{">=1d", ^uint32(0)},
+}
+
+var ttlCmd = &cobra.Command{
+	Use:   "ttl [dns-server]",
+	Short: "Report the distribution of answer TTLs for a domain set and highlight low TTLs that cause cache churn; with --compare-auth, detect resolvers that clamp or stretch TTLs.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+
+		domains := dataSet.Domains
+		switch {
+		case ttlDomainsFile != "":
+			domains, err = readDomainsFile(ttlDomainsFile)
+		case strings.TrimSpace(ttlDomains) != "":
+			domains, err = parseDomains(ttlDomains)
+		}
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		obs := dnsprobe.SurveyTTLs(ctx, server, domains, baseOptions(), ttlConcurrency)
+		obs, skipped := splitSkipped(ctx, obs, func(o dnsprobe.TTLObservation) (string, error) { return o.Domain, o.Err })
+		au := newAurora()
+		printTTLReport(au, server, obs)
+		if ctx.Err() != nil {
+			fmt.Println()
+			printSkipped(ctx, au, "domain", skipped)
+			return nil
+		}
+		if ttlCompareAuth {
+			cs := dnsprobe.CompareTTLs(ctx, server, domains, baseOptions(), ttlConcurrency)
+			cs, skipped = splitSkipped(ctx, cs, func(c dnsprobe.TTLComparison) (string, error) { return c.Domain, c.Err })
+			printTTLComparison(au, server, cs)
+			if len(skipped) > 0 {
+				fmt.Println()
+				printSkipped(ctx, au, "domain", skipped)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	ttlCmd.Flags().StringVar(&ttlDomains, "domains", "", "CSV of domains to survey (overrides the built-in popular set).")
+	ttlCmd.Flags().StringVar(&ttlDomainsFile, "domains-file", "", "File with one domain per line (# comments allowed).")
+	ttlCmd.Flags().DurationVar(&ttlLow, "low", time.Minute, "TTLs below this are flagged as cache-churn risks.")
+	ttlCmd.Flags().IntVar(&ttlConcurrency, "concurrency", 16, "Queries in flight at once.")
+	ttlCmd.Flags().BoolVar(&ttlCompareAuth, "compare-auth", false, "Also ask each domain's authoritative server directly and report whether the resolver caps high TTLs or raises low ones.")
+}
+
+func printTTLReport(au *aurora.Aurora, server string, obs []dnsprobe.TTLObservation) {
+	counts := make([]int, len(ttlBuckets))
+	var answered, unanswered, failed int
+	var low []dnsprobe.TTLObservation
+	var mins []uint32
+	var refreshesPerHour float64
+
+	for _, o := range obs {
+		if o.Err != nil {
+			failed++
+			continue
+		}
+		if len(o.TTLs) == 0 {
+			unanswered++
+			continue
+		}
+		answered++
+		mins = append(mins, o.MinTTL)
+		for i, b := range ttlBuckets {
+			if o.MinTTL < b.upper {
+				counts[i]++
+				break
+			}
+		}
+		if time.Duration(o.MinTTL)*time.Second < ttlLow {
+			low = append(low, o)
+		}
+		refreshesPerHour += 3600 / float64(max(o.MinTTL, 1))
+	}
+
+	fmt.Printf("\n=== TTL distribution: %s (%d domains) ===\n", server, len(obs))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ttl\tdomains\tshare\t")
+	for i, b := range ttlBuckets {
+		share := 0.0
+		if answered > 0 {
+			share = float64(counts[i]) / float64(answered)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%s\n", b.label, counts[i], share*100, strings.Repeat("#", int(share*40+0.5)))
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\nanswered:\t%d\n", answered)
+	fmt.Printf("no answer:\t%d\n", unanswered)
+	fmt.Printf("failed:\t%d\n", failed)
+	if len(mins) > 0 {
+		sort.Slice(mins, func(i, j int) bool { return mins[i] < mins[j] })
+		fmt.Printf("median ttl:\t%s\n", time.Duration(mins[len(mins)/2])*time.Second)
+		fmt.Printf("refreshes/hour:\t%.0f (upstream queries per hour if every answered name stays hot in cache)\n", refreshesPerHour)
+	}
+
+	if len(low) == 0 {
+		return
+	}
+	sort.Slice(low, func(i, j int) bool { return low[i].MinTTL < low[j].MinTTL })
+	fmt.Printf("\nLow TTLs (< %s, cache churn):\n", ttlLow)
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "domain\tttl\trefreshes/hour")
+	for _, o := range low {
+		fmt.Fprintf(w, "%s\t%s\t%.0f\n", o.Domain, au.Red(fmt.Sprintf("%ds", o.MinTTL)), 3600/float64(max(o.MinTTL, 1)))
+	}
+	_ = w.Flush()
+}
+
+func printTTLComparison(au *aurora.Aurora, server string, cs []dnsprobe.TTLComparison) {
+	p := dnsprobe.ClassifyTTLs(cs)
+
+	fmt.Printf("\n=== resolver vs authoritative TTLs: %s ===\n", server)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "domain\tzone\tnameserver\tresolver\tauthoritative\t")
+	var failed int
+	for _, c := range cs {
+		if c.Err != nil {
+			failed++
+			continue
+		}
+		note := ""
+		switch {
+		case c.Stretched():
+			note = au.Red("stretched").String()
+		case p.Clamp > 0 && c.Auth > p.Clamp+1 && c.Resolver+1 >= p.Clamp:
+			note = au.Yellow("clamped").String()
+		case c.Resolver+1 < c.Auth:
+			note = "cached"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%ds\t%ds\t%s\n", c.Domain, c.Zone, c.Nameserver, c.Resolver, c.Auth, note)
+	}
+	_ = w.Flush()
+	if failed > 0 {
+		fmt.Printf("\nnot compared:\t%d\n", failed)
+		for _, c := range cs {
+			if c.Err != nil {
+				fmt.Printf("  %s: %v\n", c.Domain, c.Err)
+			}
+		}
+	}
+
+	fmt.Println()
+	switch {
+	case p.Compared == 0:
+		fmt.Println("no domain could be compared with its authoritative server")
+		return
+	case p.Clamp == 0 && p.Stretched == 0:
+		fmt.Printf("%s TTLs match the authoritative ones or are lower from caching (%d compared)\n", au.Green("ok:"), p.Compared)
+	}
+	if p.Clamp > 0 {
+		fmt.Printf("%s the resolver caps TTLs at %s: %d records published with longer TTLs came back at exactly that. Changes propagate sooner, but cache hits are rarer.\n",
+			au.Yellow("clamps:"), time.Duration(p.Clamp)*time.Second, p.Clamped)
+	}
+	if p.Stretched > 0 {
+		fmt.Printf("%s the resolver served %d records with a higher TTL than published (up to %s). Records the zone owner made short-lived, e.g. for failover, stay stale longer.\n",
+			au.Red("stretches:"), p.Stretched, time.Duration(p.Floor)*time.Second)
+	}
+}
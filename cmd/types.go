@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+)
+
+var (
+	typesList    string
+	typesDomains string
+	typesRounds  int
+	typesFactor  float64
+)
+
+var typesCmd = &cobra.Command{
+	Use:   "types [dns-server]",
+	Short: "Compare query latency per record type (A, AAAA, HTTPS, MX, TXT, ...) against one resolver and flag anomalously slow types.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		qtypes, err := parseQTypes(typesList)
+		if err != nil {
+			return err
+		}
+		domains, err := parseDomains(typesDomains)
+		if err != nil {
+			return err
+		}
+		if typesRounds < 1 {
+			return fmt.Errorf("--rounds must be >= 1")
+		}
+
+		ctx := cmd.Context()
+		dists := make([]dnsprobe.Distribution, len(qtypes))
+		fails := make([]int, len(qtypes))
+
+		// Interleave types within each round so drift over time hits every
+		// type equally.
+		round := 0
+		for ; round < typesRounds && ctx.Err() == nil; round++ {
+			for _, name := range domains {
+				for i, qt := range qtypes {
+					opts := baseOptions()
+					opts.Type = qt
+					r, err := dnsprobe.Probe(ctx, server, name, opts)
+					if err != nil {
+						if ctx.Err() == nil {
+							fails[i]++
+						}
+						continue
+					}
+					dists[i].Add(r.Timings.Total)
+				}
+			}
+		}
+
+		au := newAurora()
+		printTypesReport(au, server, qtypes, dists, fails)
+		if ctx.Err() != nil {
+			fmt.Printf("\n%s %d of %d rounds (%s); the last one ran only in part\n", au.Yellow("skipped:"), typesRounds-round, typesRounds, stopReason(ctx))
+		}
+		return nil
+	},
+}
+
+func init() {
+	typesCmd.Flags().StringVar(&typesList, "types", "A,AAAA,HTTPS,MX,TXT", "CSV of record types to compare.")
+	typesCmd.Flags().StringVar(&typesDomains, "domains", "", "CSV of domains to test (overrides the default set).")
+	typesCmd.Flags().IntVar(&typesRounds, "rounds", 5, "How many times to query every domain/type pair.")
+	typesCmd.Flags().Float64Var(&typesFactor, "factor", 2, "Flag a type whose median latency exceeds this multiple of the median across types.")
+}
+
+func parseQTypes(csv string) ([]uint16, error) {
+	var out []uint16
+	for _, t := range strings.Split(csv, ",") {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		qt, ok := dns.StringToType[t]
+		if !ok {
+			return nil, fmt.Errorf("unknown record type %q", t)
+		}
+		out = append(out, qt)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no record types given")
+	}
+	return out, nil
+}
+
+func printTypesReport(au *aurora.Aurora, server string, qtypes []uint16, dists []dnsprobe.Distribution, fails []int) {
+	var medians []time.Duration
+	for i := range dists {
+		if dists[i].Count() > 0 {
+			medians = append(medians, dists[i].Quantile(0.5))
+		}
+	}
+	sort.Slice(medians, func(i, j int) bool { return medians[i] < medians[j] })
+	var baseline time.Duration
+	if len(medians) > 0 {
+		baseline = medians[len(medians)/2]
+	}
+
+	fmt.Printf("\n=== record type latency: %s ===\n", server)
+	fmt.Printf("baseline (median of per-type medians):\t%s\n\n", baseline)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "type\tok\tfail\tp50\tp90\tmean\tvs baseline\tverdict")
+	for i, qt := range qtypes {
+		d := &dists[i]
+		verdict := "ok"
+		ratio := "-"
+		if d.Count() == 0 {
+			verdict = "no answers"
+		} else {
+			if baseline > 0 {
+				x := float64(d.Quantile(0.5)) / float64(baseline)
+				ratio = fmt.Sprintf("%.2fx", x)
+				if x > typesFactor {
+					verdict = "slow"
+				}
+			}
+			if fails[i] > 0 && verdict == "ok" {
+				verdict = "failures"
+			}
+		}
+		switch verdict {
+		case "ok":
+			verdict = fmt.Sprint(au.Green(verdict))
+		case "failures":
+			verdict = fmt.Sprint(au.Yellow(verdict))
+		default:
+			verdict = fmt.Sprint(au.Red(verdict))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			dns.TypeToString[qt], d.Count(), fails[i], d.Quantile(0.5), d.Quantile(0.9), d.Mean(), ratio, verdict)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\nslow or failing types against an otherwise fast resolver often point at a filtering appliance inspecting those types.\n")
+}
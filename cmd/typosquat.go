@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/logrusorgru/aurora/v4"
+	"github.com/miekg/dns"
+	"github.com/spf13/cobra"
+)
+
+var (
+	typosquatServer      string
+	typosquatConcurrency int
+	typosquatAll         bool
+)
+
+var typosquatCmd = &cobra.Command{
+	Use:   "typosquat <domain>",
+	Short: "Generate typo, homoglyph and punycode homograph look-alikes of a domain, resolve them in bulk and report which are registered and where they point.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var serverArgs []string
+		if typosquatServer != "" {
+			serverArgs = []string{typosquatServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+
+		variants, err := dnsprobe.TypoVariants(args[0])
+		if err != nil {
+			return err
+		}
+		if err := guard(guardedRun{mode: "typosquat", domains: []string{args[0]}, concurrency: typosquatConcurrency}); err != nil {
+			return err
+		}
+		names := make([]string, len(variants))
+		for i, v := range variants {
+			names[i] = v.Name
+		}
+
+		ctx := cmd.Context()
+		opts := baseOptions()
+		fmt.Printf("resolving %d variants of %s via %s\n", len(variants), args[0], server)
+
+		// A delegation (NS) means registered even when the name has no
+		// address; A shows where it points.
+		opts.Type = dns.TypeA
+		orig, origErr := dnsprobe.Probe(ctx, server, args[0], opts)
+		opts.Type = dns.TypeNS
+		nsOut := dnsprobe.ProbeMany(ctx, server, names, opts, typosquatConcurrency)
+		opts.Type = dns.TypeA
+		aOut := dnsprobe.ProbeMany(ctx, server, names, opts, typosquatConcurrency)
+
+		var own []string
+		if origErr == nil {
+			own = orig.Addrs()
+		}
+		var skipped []string
+		if ctx.Err() != nil {
+			var kept []dnsprobe.Variant
+			var keptNS, keptA []dnsprobe.Outcome
+			for i, v := range variants {
+				if dnsprobe.CutShort(ctx, nsOut[i].Err) || dnsprobe.CutShort(ctx, aOut[i].Err) {
+					skipped = append(skipped, v.Name)
+					continue
+				}
+				kept, keptNS, keptA = append(kept, v), append(keptNS, nsOut[i]), append(keptA, aOut[i])
+			}
+			variants, nsOut, aOut = kept, keptNS, keptA
+		}
+		au := newAurora()
+		printTyposquatReport(au, variants, nsOut, aOut, own)
+		printSkipped(ctx, au, "variant", skipped)
+		return nil
+	},
+}
+
+func init() {
+	typosquatCmd.Flags().StringVar(&typosquatServer, "server", "", "DNS server used for the lookups (default: system resolver).")
+	typosquatCmd.Flags().IntVar(&typosquatConcurrency, "concurrency", 20, "Queries in flight at once.")
+	typosquatCmd.Flags().BoolVar(&typosquatAll, "all", false, "List unregistered variants and failed lookups too.")
+}
+
+func printTyposquatReport(au *aurora.Aurora, variants []dnsprobe.Variant, nsOut, aOut []dnsprobe.Outcome, own []string) {
+	fmt.Printf("\n=== look-alike domains ===\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "variant\tkind\tstatus\taddresses\tnotes")
+
+	registered, foreign, failed := 0, 0, 0
+	for i, v := range variants {
+		ns, a := nsOut[i], aOut[i]
+		status, addrs, notes := "unregistered", "-", ""
+		nsOK := ns.Err == nil && ns.Result.RCode == "NOERROR"
+		aOK := a.Err == nil && a.Result.RCode == "NOERROR"
+		switch {
+		case ns.Err != nil && a.Err != nil:
+			failed++
+			status = "error"
+			notes = ns.Err.Error()
+		case !nsOK && !aOK:
+			if rc := orDash(ns.Result.RCode); rc != "NXDOMAIN" && a.Result.RCode != "NXDOMAIN" {
+				status = rc
+			}
+		default:
+			registered++
+			status = au.Yellow("registered").String()
+			vals := a.Result.Addrs()
+			if len(vals) > 0 {
+				addrs = strings.Join(vals, ",")
+			}
+			switch {
+			case len(vals) == 0:
+				notes = "no address"
+			case len(own) > 0 && slices.ContainsFunc(vals, func(s string) bool { return slices.Contains(own, s) }):
+				notes = "same addresses as the original (likely a defensive registration)"
+			default:
+				foreign++
+				status = au.Red("registered").String()
+				notes = "points elsewhere: review"
+			}
+		}
+		if !typosquatAll && (status == "unregistered" || status == "error") {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v, v.Kind, status, addrs, notes)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\nsummary:\t%d variants, %d registered, %d pointing elsewhere", len(variants), registered, foreign)
+	if failed > 0 {
+		fmt.Printf(", %d lookups failed", failed)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	validationSigned   string
+	validationUnsigned string
+	validationRounds   int
+)
+
+var validationCmd = &cobra.Command{
+	Use:   "validation [dns-server]",
+	Short: "Estimate what DNSSEC validation costs in latency: compare uncached lookups under signed zones with lookups under unsigned ones, interleaved in the same run, all with DO set.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		signed, err := parseDomains(validationSigned)
+		if err != nil {
+			return err
+		}
+		unsigned, err := parseDomains(validationUnsigned)
+		if err != nil {
+			return err
+		}
+		if validationRounds < 1 {
+			return fmt.Errorf("--rounds must be >= 1")
+		}
+
+		ctx := cmd.Context()
+		c := dnsprobe.MeasureValidationCost(ctx, server, signed, unsigned, validationRounds, baseOptions())
+
+		au := newAurora()
+		fmt.Printf("\n=== validation cost: %s ===\n", server)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "zones\tok\tfail\tp50\tp90\tmean\tvalidated")
+		fmt.Fprintf(w, "signed (%d)\t%d\t%d\t%s\t%s\t%s\t%d/%d\n", len(signed), c.Signed.Count(), c.SignedFails,
+			c.Signed.Quantile(0.5), c.Signed.Quantile(0.9), c.Signed.Mean(), c.Validated, c.Signed.Count())
+		fmt.Fprintf(w, "unsigned (%d)\t%d\t%d\t%s\t%s\t%s\t-\n", len(unsigned), c.Unsigned.Count(), c.UnsignedFails,
+			c.Unsigned.Quantile(0.5), c.Unsigned.Quantile(0.9), c.Unsigned.Mean())
+		_ = w.Flush()
+		if ctx.Err() != nil {
+			fmt.Printf("\n%s %d of %d rounds (%s); the last one ran only in part\n", au.Yellow("skipped:"), validationRounds-c.Rounds, validationRounds, stopReason(ctx))
+		}
+
+		if c.Signed.Count() == 0 || c.Unsigned.Count() == 0 {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("no answers for one of the workloads; check the names and the resolver")
+		}
+		delta := c.Delta()
+		sign := "+"
+		if delta < 0 {
+			sign, delta = "-", -delta
+		}
+		fmt.Printf("\nsigned vs unsigned:\t%s%s at the median (%.2fx)\n", sign, delta, c.Ratio())
+		switch {
+		case c.Validated == 0:
+			fmt.Printf("%s the resolver set AD on no signed answer, so it does not validate: the difference is only the extra DNSSEC records. Turning validation on would add to it.\n", au.Yellow("not validating:"))
+		case c.Validated < c.Signed.Count():
+			fmt.Printf("%s AD was set on %d of %d signed answers; the rest may be insecure delegations or lost validation state.\n", au.Yellow("partial:"), c.Validated, c.Signed.Count())
+		}
+		fmt.Printf("resolvers with aggressive NSEC caching (RFC 8198) answer some signed random names from cache, which understates the cost.\n")
+		return nil
+	},
+}
+
+func init() {
+	validationCmd.Flags().StringVar(&validationSigned, "signed", strings.Join(dnsprobe.SignedDomains, ","), "CSV of DNSSEC-signed zones to look up random names under.")
+	validationCmd.Flags().StringVar(&validationUnsigned, "unsigned", strings.Join(dnsprobe.UnsignedDomains, ","), "CSV of unsigned zones to look up random names under.")
+	validationCmd.Flags().IntVar(&validationRounds, "rounds", 5, "How many times to query every zone.")
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/bundle"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	viewList   bool
+	viewFile   string
+	viewFormat string
+)
+
+var viewCmd = &cobra.Command{
+	Use:   "view <bundle.tgz | results.json>...",
+	Short: "Open a bundle written by dnsdoc bundle, or re-render doctor, compliance and latency reports saved with --output json as a table, a side-by-side comparison, Markdown or HTML without running the checks again.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if viewFormat != "" || len(args) > 1 || !isBundle(args[0]) {
+			if viewList || viewFile != "" {
+				return fmt.Errorf("--list and --file only apply to a single bundle")
+			}
+			return viewReports(args)
+		}
+
+		files, err := bundle.Read(args[0])
+		if err != nil {
+			return err
+		}
+		if viewFile != "" {
+			f, ok := bundle.Find(files, viewFile)
+			if !ok {
+				return fmt.Errorf("%s has no %s", args[0], viewFile)
+			}
+			_, err := os.Stdout.Write(f.Data)
+			return err
+		}
+		if viewList {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, f := range files {
+				fmt.Fprintf(w, "%s\t%d bytes\n", f.Name, len(f.Data))
+			}
+			return w.Flush()
+		}
+
+		mf, ok := bundle.Find(files, bundleManifestFile)
+		if !ok {
+			return fmt.Errorf("%s is not a dnsdoc bundle: no %s", args[0], bundleManifestFile)
+		}
+		var man bundleManifest
+		if err := json.Unmarshal(mf.Data, &man); err != nil {
+			return fmt.Errorf("%s: %w", bundleManifestFile, err)
+		}
+
+		env := man.Environment
+		fmt.Printf("=== bundle: %s ===\n", args[0])
+		fmt.Printf("command:\t%s\n", strings.Join(man.Command, " "))
+		fmt.Printf("run at:\t%s, took %s, exit status %d\n", man.Created.Format(time.RFC3339), man.Took.Round(time.Millisecond), man.ExitCode)
+		fmt.Printf("host:\t%s (%s/%s)\n", orDash(env.Hostname), env.OS, env.Arch)
+		fmt.Printf("dnsdoc:\t%s, %s\n", env.Dnsdoc, env.Go)
+		for _, r := range env.Resolvers {
+			scope := "default"
+			if r.Domain != "" {
+				scope = r.Domain
+			}
+			fmt.Printf("system resolver:\t%s (%s, from %s)\n", r.Server, scope, r.Source)
+		}
+		fmt.Printf("files:\t%s\n", strings.Join(man.Files, ", "))
+		for _, n := range man.Notes {
+			fmt.Printf("note:\t%s\n", n)
+		}
+
+		if f, ok := bundle.Find(files, bundleReportFile); ok {
+			fmt.Printf("\n--- %s ---\n", bundleReportFile)
+			_, _ = os.Stdout.Write(f.Data)
+		}
+		if f, ok := bundle.Find(files, bundleStderrFile); ok {
+			fmt.Printf("\n--- %s ---\n", bundleStderrFile)
+			_, _ = os.Stdout.Write(f.Data)
+		}
+		return nil
+	},
+}
+
+func init() {
+	viewCmd.Flags().BoolVar(&viewList, "list", false, "List the files in the bundle.")
+	viewCmd.Flags().StringVar(&viewFile, "file", "", "Print one file from the bundle as-is, e.g. --file results.json (or capture.pcap > out.pcap).")
+	viewCmd.Flags().StringVar(&viewFormat, "format", "", "Render saved results (a results.json, or the one inside a bundle) as table, compare (one column per report), markdown or html. Default: table, or the bundle summary for a single bundle.")
+}
+
+// isBundle reports whether path starts like a gzip file.
+func isBundle(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, 2)
+	_, err = io.ReadFull(f, magic)
+	return err == nil && magic[0] == 0x1f && magic[1] == 0x8b
+}
+
+func viewReports(paths []string) error {
+	format := viewFormat
+	if format == "" {
+		format = viewTable
+	}
+	var reports []savedReport
+	for _, p := range paths {
+		var data []byte
+		if isBundle(p) {
+			files, err := bundle.Read(p)
+			if err != nil {
+				return err
+			}
+			f, ok := bundle.Find(files, bundleResultsFile)
+			if !ok {
+				return fmt.Errorf("%s has no %s; only commands with --output json save results", p, bundleResultsFile)
+			}
+			data = f.Data
+		} else {
+			var err error
+			if data, err = os.ReadFile(p); err != nil {
+				return err
+			}
+		}
+		r, err := parseSavedReport(p, data)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, r)
+	}
+
+	switch format {
+	case viewTable:
+		au := newAurora()
+		for _, r := range reports {
+			renderTable(au, r)
+		}
+	case viewCompare:
+		if len(reports) < 2 {
+			return fmt.Errorf("--format compare needs at least two reports")
+		}
+		return renderCompare(newAurora(), reports)
+	case viewMarkdown:
+		renderMarkdown(os.Stdout, reports)
+	case viewHTML:
+		return renderHTML(os.Stdout, reports)
+	default:
+		return fmt.Errorf("invalid --format %q (want %s, %s, %s or %s)", format, viewTable, viewCompare, viewMarkdown, viewHTML)
+	}
+	return nil
+}
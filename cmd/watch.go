@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchInterval time.Duration
+	watchDomains  string
+	watchCount    int
+	watchNSID     bool
+	watchRetries  int
+
+	watchExpect     []string
+	watchExpectFile string
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [dns-server]",
+	Short: "Probe a resolver continuously, like ping for DNS: one line per query with latency and rcode changes, and summary stats on Ctrl-C.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		server, err := serverFromArgs(args)
+		if err != nil {
+			return err
+		}
+		if watchInterval <= 0 {
+			return fmt.Errorf("--interval must be > 0")
+		}
+		if watchRetries < 0 {
+			return fmt.Errorf("--retries must be >= 0")
+		}
+		exp, err := loadExpectations(watchExpect, watchExpectFile)
+		if err != nil {
+			return err
+		}
+		domains, err := parseDomains(watchDomains)
+		if err != nil {
+			return err
+		}
+		if exp.Len() > 0 && !cmd.Flags().Changed("domains") {
+			domains = qualifyNames(exp.Domains())
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		opts := baseOptions()
+		opts.NSID = watchNSID
+		opts.Retries = watchRetries
+		fmt.Printf("WATCH %s: %s every %s (Ctrl-C to stop)\n", server, strings.Join(domains, ","), watchInterval)
+
+		st := watchStats{exp: exp}
+		if watchNSID {
+			st.sites = &dnsprobe.SiteTracker{}
+		}
+		st.rcodes = map[string]int{}
+		st.lastRCode = map[string]string{}
+		st.start = time.Now()
+
+		spark := newLiveSpark(server)
+		tick := time.NewTicker(watchInterval)
+		defer tick.Stop()
+		for seq := 1; watchCount <= 0 || seq <= watchCount; seq++ {
+			name := domains[(seq-1)%len(domains)]
+			r, err := dnsprobe.Probe(ctx, server, name, opts)
+			if ctx.Err() != nil {
+				break
+			}
+			spark.clear()
+			st.print(seq, name, r, err)
+			spark.add(st.recent[len(st.recent)-1])
+
+			if watchCount > 0 && seq == watchCount {
+				break
+			}
+			select {
+			case <-ctx.Done():
+			case <-tick.C:
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		spark.clear()
+		if ctx.Err() != nil && watchCount > 0 && st.sent < watchCount {
+			fmt.Printf("\n%s %d of %d probes (%s)\n", newAurora().Yellow("skipped:"), watchCount-st.sent, watchCount, stopReason(ctx))
+		}
+		st.summary(server)
+		return interruptExit(ctx, mismatchError(st.mismatches))
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", time.Second, "Time between probes.")
+	watchCmd.Flags().StringVar(&watchDomains, "domains", "google.com", "CSV of domains to cycle through.")
+	watchCmd.Flags().StringArrayVar(&watchExpect, "expect", nil, "Assert that a domain only returns these answers, e.g. --expect example.com=93.184.216.34 (repeatable). Mismatches are flagged per probe and make the exit status 2. Without --domains the asserted domains are watched.")
+	watchCmd.Flags().StringVar(&watchExpectFile, "expect-file", "", "File of --expect assertions, one domain=value[,value...] per line.")
+	watchCmd.Flags().BoolVar(&watchNSID, "nsid", false, "Ask for the server's NSID on every probe to follow anycast site changes (flaps) and report latency per site.")
+	watchCmd.Flags().IntVar(&watchRetries, "retries", 0, "Resend a probe that timed out up to N times, like a stub resolver; the summary tells probes answered on retry apart from lost ones (0 disables).")
+	watchCmd.Flags().IntVarP(&watchCount, "count", "c", 0, "Stop after this many probes (0 = run until interrupted).")
+}
+
+type watchStats struct {
+	exp        *dnsprobe.Expectations
+	mismatches int
+	start      time.Time
+	sent       int
+	received   int
+	lost       int
+	errors     int
+	// datagrams counts queries sent, retransmissions included, and
+	// timeouts those that went unanswered.
+	datagrams   int
+	timeouts    int
+	retransmits int
+	recovered   int
+	rcodes      map[string]int
+	lastRCode   map[string]string
+	latency     dnsprobe.Distribution
+	sites       *dnsprobe.SiteTracker
+	// recent holds the last sparkWidth latencies, -1 for failed probes.
+	recent []time.Duration
+}
+
+func (s *watchStats) print(seq int, name string, r dnsprobe.Result, err error) {
+	au := newAurora()
+	s.sent++
+	sample := r.Timings.Total
+	if err != nil {
+		sample = -1
+	}
+	s.recent = append(s.recent, sample)
+	if len(s.recent) > sparkWidth {
+		s.recent = s.recent[len(s.recent)-sparkWidth:]
+	}
+	s.datagrams += r.Retransmits + 1
+	s.timeouts += r.Retransmits
+	s.retransmits += r.Retransmits
+	if err != nil {
+		if dnsprobe.IsTimeout(err) {
+			s.lost++
+			s.timeouts++
+			fmt.Printf("seq=%d %s %s\n", seq, name, au.Red(fmt.Sprintf("timeout after %d tries of %s", r.Retransmits+1, r.Timeout)))
+		} else {
+			s.errors++
+			fmt.Printf("seq=%d %s %s\n", seq, name, au.Red(fmt.Sprintf("error: %v", err)))
+		}
+		return
+	}
+
+	s.received++
+	s.rcodes[r.RCode]++
+	if r.Retransmits > 0 {
+		s.recovered++
+	}
+	s.latency.Add(r.Timings.Total)
+
+	line := fmt.Sprintf("seq=%d %s %s %s answers=%d time=%s", seq, name, r.QType, r.RCode, r.AnswerCount, r.Timings.Total.Round(time.Microsecond))
+	if r.Retransmits > 0 {
+		line += " " + au.Yellow(fmt.Sprintf("(answered after %d retransmit(s))", r.Retransmits)).String()
+	}
+	if last := s.lastRCode[name]; last != "" && r.RCode != last {
+		line += " " + au.Yellow(fmt.Sprintf("(rcode changed %s -> %s)", last, r.RCode)).String()
+	}
+	if s.sites != nil {
+		site := orDash(r.NSID)
+		line += " site=" + site
+		if prev, changed := s.sites.Observe(site, time.Now(), r.Timings.Total); changed {
+			line += " " + au.Yellow(fmt.Sprintf("(site changed %s -> %s)", prev, site)).String()
+		}
+	}
+	if m := s.exp.Check(r); m != nil {
+		s.mismatches++
+		line += " " + au.Red("MISMATCH expected "+strings.Join(m.Expected, ",")).String()
+	}
+	fmt.Println(line)
+	s.lastRCode[name] = r.RCode
+}
+
+func (s *watchStats) summary(server string) {
+	loss := 0.0
+	if s.sent > 0 {
+		loss = float64(s.sent-s.received) / float64(s.sent) * 100
+	}
+	fmt.Printf("\n--- %s dns watch statistics ---\n", server)
+	fmt.Printf("%d queries sent, %d answered, %d lost, %d errors, %.1f%% loss, time %s\n",
+		s.sent, s.received, s.lost, s.errors, loss, time.Since(s.start).Round(time.Millisecond))
+	if s.datagrams > 0 {
+		fmt.Printf("%d datagrams sent, %d unanswered, %.1f%% packet loss, %d retransmit(s), %d probe(s) answered on retry\n",
+			s.datagrams, s.timeouts, float64(s.timeouts)/float64(s.datagrams)*100, s.retransmits, s.recovered)
+	}
+	if s.latency.Count() > 0 {
+		fmt.Printf("rtt min/avg/p50/p90/max = %s/%s/%s/%s/%s\n",
+			s.latency.Min().Round(time.Microsecond), s.latency.Mean().Round(time.Microsecond),
+			s.latency.Quantile(0.5).Round(time.Microsecond), s.latency.Quantile(0.9).Round(time.Microsecond),
+			s.latency.Max().Round(time.Microsecond))
+	}
+	if len(s.recent) > 0 {
+		fmt.Printf("last %d: %s\n", len(s.recent), sparkline(newAurora(), s.recent))
+	}
+	fmt.Printf("rcodes: %s\n", formatRCodes(s.rcodes))
+	if s.exp.Len() > 0 {
+		fmt.Printf("expect mismatches: %d\n", s.mismatches)
+	}
+	if s.sites != nil {
+		printSiteSummary(s.sites)
+	}
+}
+
+func printSiteSummary(t *dnsprobe.SiteTracker) {
+	sites := t.Sites()
+	fmt.Printf("\nanycast sites (by NSID):\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "site\tprobes\tflaps to\tmin\tavg\tp50\tp90\tmax")
+	for _, s := range sites {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\n", s.Site, s.Probes, s.FlapsIn,
+			s.Min.Round(time.Microsecond), s.Mean.Round(time.Microsecond), s.P50.Round(time.Microsecond),
+			s.P90.Round(time.Microsecond), s.Max.Round(time.Microsecond))
+	}
+	_ = w.Flush()
+
+	if t.Flaps == 0 {
+		fmt.Printf("routing stable: every answer came from the same site\n")
+		return
+	}
+	fmt.Printf("flaps: %d (one every %s on average)\n", t.Flaps, t.MeanTimeBetweenFlaps(time.Now()).Round(time.Millisecond))
+
+	fastest, slowest := sites[0], sites[0]
+	for _, s := range sites {
+		if s.P50 < fastest.P50 {
+			fastest = s
+		}
+		if s.P50 > slowest.P50 {
+			slowest = s
+		}
+	}
+	if fastest.P50 > 0 && slowest.P50 > 2*fastest.P50 {
+		fmt.Printf("unstable anycast: site %s p50 is %.1fx site %s; flaps to it show up as intermittent latency spikes\n",
+			slowest.Site, float64(slowest.P50)/float64(fastest.P50), fastest.Site)
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	zonemdServer string
+	zonemdFrom   string
+)
+
+var zonemdCmd = &cobra.Command{
+	Use:   "zonemd <zone>",
+	Short: "Transfer a zone by AXFR from each of its nameservers and verify its ZONEMD digest (RFC 8976), catching copies corrupted or tampered with between primaries and secondaries.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var serverArgs []string
+		if zonemdServer != "" {
+			serverArgs = []string{zonemdServer}
+		}
+		server, err := serverFromArgs(serverArgs)
+		if err != nil {
+			return err
+		}
+		var sources []string
+		for _, s := range strings.Split(zonemdFrom, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				sources = append(sources, s)
+			}
+		}
+
+		rep, err := dnsprobe.CheckZONEMD(cmd.Context(), server, args[0], sources, baseOptions())
+		if err != nil {
+			return err
+		}
+
+		au := newAurora()
+		fmt.Printf("\n=== zonemd: %s ===\n", rep.Zone)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "server\tnameserver\trecords\tserial\tstatus")
+		var transferred, mismatched, unverified int
+		for _, t := range rep.Sources {
+			if t.Err != nil {
+				fmt.Fprintf(w, "%s\t%s\t-\t-\t%s\n", orDash(t.Server), orDash(t.Host), au.Yellow(fmt.Sprintf("no transfer: %v", t.Err)))
+				continue
+			}
+			transferred++
+			var status string
+			switch {
+			case len(t.Digests) == 0:
+				unverified++
+				status = au.Yellow("no ZONEMD record").String()
+			case t.Verified():
+				status = au.Green("verified").String()
+			case !t.Supported():
+				unverified++
+				status = au.Yellow("only unsupported ZONEMD records").String()
+			default:
+				mismatched++
+				status = au.Red("verification failed").String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", t.Server, orDash(t.Host), t.Records, t.Serial, status)
+		}
+		_ = w.Flush()
+
+		if mismatched > 0 {
+			fmt.Printf("\nFailed digests:\n")
+			w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "server\tserial\tscheme\thash\tproblem")
+			for _, t := range rep.Sources {
+				if t.Err != nil || t.Verified() {
+					continue
+				}
+				for _, d := range t.Digests {
+					if d.Err == nil || errors.Is(d.Err, dnsprobe.ErrZONEMDUnsupported) {
+						continue
+					}
+					fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", t.Server, d.Record.Serial, d.Record.Scheme, d.Record.Hash, au.Red(d.Err.Error()))
+				}
+			}
+			_ = w.Flush()
+		}
+
+		switch {
+		case transferred == 0:
+			return fmt.Errorf("no server allowed a transfer of %s; pass the addresses that do with --from", rep.Zone)
+		case mismatched > 0:
+			fmt.Printf("%s %d copy(ies) of %s do not match their ZONEMD; compare them with the primary's and re-transfer\n", au.Red("mismatch:"), mismatched, rep.Zone)
+			return exitError{code: exitMismatch, err: fmt.Errorf("ZONEMD verification failed on %d server(s) for %s", mismatched, rep.Zone)}
+		case unverified > 0:
+			fmt.Printf("%s %d copy(ies) of %s could not be verified\n", au.Yellow("unverified:"), unverified, rep.Zone)
+		default:
+			fmt.Printf("%s every transferred copy of %s matches its ZONEMD\n", au.Green("verified:"), rep.Zone)
+		}
+		return nil
+	},
+}
+
+func init() {
+	zonemdCmd.Flags().StringVar(&zonemdServer, "server", "", "DNS server used to find the zone's nameservers (default: system resolver).")
+	zonemdCmd.Flags().StringVar(&zonemdFrom, "from", "", "CSV of servers to transfer the zone from, such as a hidden primary (default: every nameserver address).")
+}
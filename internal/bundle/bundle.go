@@ -0,0 +1,83 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// File is one member of a bundle.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Write stores files, in order, as a gzip-compressed tar archive at path.
+func Write(path string, files []File) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	tw := tar.NewWriter(zw)
+	now := time.Now()
+	for _, file := range files {
+		hdr := &tar.Header{Name: file.Name, Mode: 0o644, Size: int64(len(file.Data)), ModTime: now, Typeflag: tar.TypeReg}
+		if err = tw.WriteHeader(hdr); err != nil {
+			break
+		}
+		if _, err = tw.Write(file.Data); err != nil {
+			break
+		}
+	}
+	err = errors.Join(err, tw.Close(), zw.Close(), f.Close())
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// Read returns the regular files of the bundle at path, in archive order.
+func Read(path string) ([]File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(zr)
+	var out []File
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, File{Name: hdr.Name, Data: data})
+	}
+}
+
+// Find returns the member called name.
+func Find(files []File, name string) (File, bool) {
+	for _, f := range files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return File{}, false
+}
@@ -0,0 +1,156 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/bpf"
+)
+
+// Packet is a UDP datagram or TCP segment taken off the wire.
+type Packet struct {
+	At       time.Time
+	Src, Dst netip.AddrPort
+	TCP      bool
+	Payload  []byte
+}
+
+// PortFilter is a socket filter passing Ethernet frames that carry UDP or TCP
+// to or from port over IPv4 or IPv6 (without extension headers). IPv4
+// fragments after the first are dropped, as they have no ports.
+func PortFilter(port uint16) []bpf.RawInstruction {
+	p := uint32(port)
+	prog, _ := bpf.Assemble([]bpf.Instruction{
+		bpf.LoadAbsolute{Off: 12, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x0800, SkipTrue: 8},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x86dd, SkipFalse: 18},
+		// IPv6
+		bpf.LoadAbsolute{Off: 20, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 17, SkipTrue: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 6, SkipFalse: 15},
+		bpf.LoadAbsolute{Off: 54, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: p, SkipTrue: 12},
+		bpf.LoadAbsolute{Off: 56, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: p, SkipTrue: 10, SkipFalse: 11},
+		// IPv4
+		bpf.LoadAbsolute{Off: 23, Size: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 17, SkipTrue: 1},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: 6, SkipFalse: 8},
+		bpf.LoadAbsolute{Off: 20, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: 0x1fff, SkipTrue: 6},
+		bpf.LoadMemShift{Off: 14},
+		bpf.LoadIndirect{Off: 14, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: p, SkipTrue: 2},
+		bpf.LoadIndirect{Off: 16, Size: 2},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: p, SkipFalse: 1},
+		bpf.RetConstant{Val: 262144},
+		bpf.RetConstant{Val: 0},
+	})
+	return prog
+}
+
+// LoadFilter reads a classic BPF program in the format `tcpdump -ddd` prints:
+// an instruction count, then one "code jt jf k" line per instruction.
+func LoadFilter(path string) ([]bpf.RawInstruction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var prog []bpf.RawInstruction
+	n := -1
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var vals []uint64
+		for _, s := range fields {
+			v, err := strconv.ParseUint(s, 0, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+			vals = append(vals, v)
+		}
+		switch {
+		case n < 0 && len(vals) == 1:
+			n = int(vals[0])
+		case n >= 0 && len(vals) == 4:
+			prog = append(prog, bpf.RawInstruction{Op: uint16(vals[0]), Jt: uint8(vals[1]), Jf: uint8(vals[2]), K: uint32(vals[3])})
+		default:
+			return nil, fmt.Errorf("%s:%d: not tcpdump -ddd output", path, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if n <= 0 || len(prog) != n {
+		return nil, fmt.Errorf("%s: expected %d instructions, found %d", path, n, len(prog))
+	}
+	return prog, nil
+}
+
+// decodeFrame extracts the transport payload of an Ethernet frame.
+func decodeFrame(at time.Time, b []byte) (Packet, bool) {
+	if len(b) < 14 {
+		return Packet{}, false
+	}
+	etype, b := binary.BigEndian.Uint16(b[12:14]), b[14:]
+
+	var proto byte
+	var src, dst netip.Addr
+	switch etype {
+	case 0x0800:
+		if len(b) < 20 || b[0]>>4 != 4 {
+			return Packet{}, false
+		}
+		ihl, total := int(b[0]&0x0f)*4, int(binary.BigEndian.Uint16(b[2:4]))
+		if binary.BigEndian.Uint16(b[6:8])&0x1fff != 0 || ihl < 20 || total < ihl || total > len(b) {
+			return Packet{}, false
+		}
+		proto = b[9]
+		src, dst = netip.AddrFrom4([4]byte(b[12:16])), netip.AddrFrom4([4]byte(b[16:20]))
+		b = b[ihl:total]
+	case 0x86dd:
+		if len(b) < 40 {
+			return Packet{}, false
+		}
+		plen := int(binary.BigEndian.Uint16(b[4:6]))
+		if 40+plen > len(b) {
+			return Packet{}, false
+		}
+		proto = b[6]
+		src, dst = netip.AddrFrom16([16]byte(b[8:24])), netip.AddrFrom16([16]byte(b[24:40]))
+		b = b[40 : 40+plen]
+	default:
+		return Packet{}, false
+	}
+
+	p := Packet{At: at}
+	switch proto {
+	case 17:
+		if len(b) < 8 {
+			return Packet{}, false
+		}
+		p.Payload = b[8:]
+	case 6:
+		if len(b) < 20 || int(b[12]>>4)*4 > len(b) || int(b[12]>>4)*4 < 20 {
+			return Packet{}, false
+		}
+		p.TCP = true
+		p.Payload = b[int(b[12]>>4)*4:]
+	default:
+		return Packet{}, false
+	}
+	p.Src = netip.AddrPortFrom(src, binary.BigEndian.Uint16(b[0:2]))
+	p.Dst = netip.AddrPortFrom(dst, binary.BigEndian.Uint16(b[2:4]))
+	return p, true
+}
@@ -0,0 +1,112 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// Source reads frames from an AF_PACKET socket bound to one interface.
+type Source struct {
+	f        *os.File
+	rc       syscall.RawConn
+	loopback bool
+	buf      []byte
+}
+
+// Open starts capturing on iface with filter attached in the kernel, so that
+// only matching frames are copied to user space. It needs CAP_NET_RAW.
+func Open(iface string, filter []bpf.RawInstruction) (*Source, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	// Protocol 0 receives nothing until bind, so no unfiltered frames are
+	// queued before the filter is in place.
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, unix.EPERM) {
+			return nil, fmt.Errorf("packet socket: %w (run as root or grant CAP_NET_RAW)", err)
+		}
+		return nil, fmt.Errorf("packet socket: %w", err)
+	}
+	if len(filter) > 0 {
+		ins := make([]unix.SockFilter, len(filter))
+		for i, r := range filter {
+			ins[i] = unix.SockFilter{Code: r.Op, Jt: r.Jt, Jf: r.Jf, K: r.K}
+		}
+		prog := unix.SockFprog{Len: uint16(len(ins)), Filter: &ins[0]}
+		if err := unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &prog); err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("attach filter: %w", err)
+		}
+	}
+	sa := &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ALL), Ifindex: ifi.Index}
+	if err := unix.Bind(fd, sa); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("bind %s: %w", iface, err)
+	}
+
+	f := os.NewFile(uintptr(fd), "packet:"+iface)
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Source{f: f, rc: rc, loopback: ifi.Flags&net.FlagLoopback != 0, buf: make([]byte, 65536)}, nil
+}
+
+// Next blocks until the next IP packet carrying UDP or TCP arrives. The
+// returned payload is only valid until the following call.
+func (s *Source) Next() (Packet, error) {
+	for {
+		at, frame, err := s.ReadFrame()
+		if err != nil {
+			return Packet{}, err
+		}
+		if p, ok := decodeFrame(at, frame); ok {
+			return p, nil
+		}
+	}
+}
+
+// ReadFrame blocks until the next Ethernet frame arrives and returns it
+// undecoded, e.g. for writing to a pcap file. The frame is only valid until
+// the following call.
+func (s *Source) ReadFrame() (time.Time, []byte, error) {
+	for {
+		var n int
+		var from unix.Sockaddr
+		var rerr error
+		err := s.rc.Read(func(fd uintptr) bool {
+			n, from, rerr = unix.Recvfrom(int(fd), s.buf, 0)
+			return rerr != unix.EAGAIN
+		})
+		if err == nil {
+			err = rerr
+		}
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+		// Loopback frames are seen twice, leaving and arriving.
+		if ll, ok := from.(*unix.SockaddrLinklayer); ok && s.loopback && ll.Pkttype == unix.PACKET_OUTGOING {
+			continue
+		}
+		return time.Now(), s.buf[:n], nil
+	}
+}
+
+// Close stops the capture; a blocked Next returns an error.
+func (s *Source) Close() error {
+	return s.f.Close()
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -0,0 +1,29 @@
+//go:build !linux
+
+package capture
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/net/bpf"
+)
+
+// Source is not available on this platform.
+type Source struct{}
+
+func Open(iface string, filter []bpf.RawInstruction) (*Source, error) {
+	return nil, errors.New("passive capture is only supported on Linux")
+}
+
+func (s *Source) Next() (Packet, error) {
+	return Packet{}, errors.New("passive capture is only supported on Linux")
+}
+
+func (s *Source) ReadFrame() (time.Time, []byte, error) {
+	return time.Time{}, nil, errors.New("passive capture is only supported on Linux")
+}
+
+func (s *Source) Close() error {
+	return nil
+}
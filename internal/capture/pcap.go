@@ -0,0 +1,40 @@
+package capture
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// PcapWriter writes Ethernet frames in the classic libpcap file format,
+// readable by tcpdump and Wireshark.
+type PcapWriter struct {
+	w io.Writer
+}
+
+// NewPcapWriter writes the file header to w.
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	var h [24]byte
+	binary.LittleEndian.PutUint32(h[0:], 0xa1b23c4d) // nanosecond timestamps
+	binary.LittleEndian.PutUint16(h[4:], 2)
+	binary.LittleEndian.PutUint16(h[6:], 4)
+	binary.LittleEndian.PutUint32(h[16:], 65536) // snaplen
+	binary.LittleEndian.PutUint32(h[20:], 1)     // LINKTYPE_ETHERNET
+	if _, err := w.Write(h[:]); err != nil {
+		return nil, err
+	}
+	return &PcapWriter{w: w}, nil
+}
+
+func (p *PcapWriter) WriteFrame(at time.Time, frame []byte) error {
+	var h [16]byte
+	binary.LittleEndian.PutUint32(h[0:], uint32(at.Unix()))
+	binary.LittleEndian.PutUint32(h[4:], uint32(at.Nanosecond()))
+	binary.LittleEndian.PutUint32(h[8:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(h[12:], uint32(len(frame)))
+	if _, err := p.w.Write(h[:]); err != nil {
+		return err
+	}
+	_, err := p.w.Write(frame)
+	return err
+}
@@ -0,0 +1,332 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+)
+
+// Endpoint aggregates the traffic of one client or resolver address.
+type Endpoint struct {
+	Addr       netip.Addr
+	Queries    int
+	Answered   int
+	Lost       int // queries with no response within the tracker's timeout
+	Latency    dnsprobe.Distribution
+	RCodes     map[string]int
+	QueryBytes int
+	RespBytes  int
+
+	First, Last time.Time // first and latest query
+	Profile     *Profile  // clients only
+}
+
+// Profile is how a client behaves, for finding the hosts that generate
+// pathological load.
+type Profile struct {
+	Names      map[string]int // queries per name, at most maxNames names
+	Retries    int            // queries repeating a question that was still unanswered
+	NXDomain   int
+	SearchJunk int // NXDOMAINs for a name plus a search suffix that then resolved bare (ndots)
+
+	open   map[string]int // question -> outstanding queries
+	nxBase map[string]int // possible bare names of NXDOMAIN answers
+}
+
+const maxNames = 10000
+
+// Rate is the average queries per second between the first and latest query.
+func (e *Endpoint) Rate() float64 {
+	d := e.Last.Sub(e.First).Seconds()
+	if d <= 0 {
+		return 0
+	}
+	return float64(e.Queries) / d
+}
+
+// NameCount is a name and how often it was queried.
+type NameCount struct {
+	Name  string
+	Count int
+}
+
+// TopNames returns the n names the client queried most.
+func (p *Profile) TopNames(n int) []NameCount {
+	return topNames(p.Names, n)
+}
+
+func topNames(names map[string]int, n int) []NameCount {
+	out := make([]NameCount, 0, len(names))
+	for name, c := range names {
+		out = append(out, NameCount{name, c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out[:min(n, len(out))]
+}
+
+// Misbehaviour describes the patterns in a client's traffic that put
+// pointless load on resolvers; it is empty for well-behaved clients.
+func (e *Endpoint) Misbehaviour() []string {
+	p := e.Profile
+	if p == nil || e.Queries < 10 {
+		return nil
+	}
+	share := func(n int) float64 { return float64(n) / float64(e.Queries) * 100 }
+	var out []string
+	if p.Retries >= 10 && share(p.Retries) >= 20 {
+		out = append(out, fmt.Sprintf("retry storm: %.0f%% of queries repeat an unanswered question", share(p.Retries)))
+	}
+	if p.SearchJunk >= 10 && share(p.SearchJunk) >= 30 {
+		out = append(out, fmt.Sprintf("search-list junk: %.0f%% of queries are names plus a search suffix that fail before the bare name resolves (lower ndots or query FQDNs)", share(p.SearchJunk)))
+	}
+	// Search-list junk is already reported above.
+	if nx := p.NXDomain - p.SearchJunk; nx >= 20 && share(nx) >= 50 {
+		out = append(out, fmt.Sprintf("mostly NXDOMAIN: %.0f%% of queries are for names that do not exist (misconfiguration or malware)", share(nx)))
+	}
+	return out
+}
+
+type flow struct {
+	client, server netip.AddrPort
+	id             uint16
+}
+
+type pending struct {
+	at       time.Time
+	question string
+	client   *Endpoint
+	server   *Endpoint
+}
+
+// Tracker pairs queries with their responses by addresses and message ID and
+// keeps per-client and per-resolver statistics. Queries sent from ProbePort
+// are dnsdoc's own active probes: they are kept out of the client and
+// resolver statistics and reported by Probes instead.
+type Tracker struct {
+	Timeout   time.Duration
+	ProbePort uint16
+
+	Messages  int
+	Malformed int
+	clients   map[netip.Addr]*Endpoint
+	resolvers map[netip.Addr]*Endpoint
+	probes    map[netip.Addr]*Endpoint
+	names     map[string]int
+	pending   map[flow]pending
+}
+
+func NewTracker(timeout time.Duration) *Tracker {
+	return &Tracker{
+		Timeout:   timeout,
+		clients:   map[netip.Addr]*Endpoint{},
+		resolvers: map[netip.Addr]*Endpoint{},
+		probes:    map[netip.Addr]*Endpoint{},
+		names:     map[string]int{},
+		pending:   map[flow]pending{},
+	}
+}
+
+// Add accounts for the DNS messages in p: one per UDP datagram, or every
+// complete length-prefixed message in a TCP segment.
+func (t *Tracker) Add(p Packet) {
+	if !p.TCP {
+		t.addMsg(p, p.Payload)
+		return
+	}
+	for b := p.Payload; len(b) >= 2; {
+		n := int(binary.BigEndian.Uint16(b))
+		if n == 0 || 2+n > len(b) {
+			return
+		}
+		t.addMsg(p, b[2:2+n])
+		b = b[2+n:]
+	}
+}
+
+func (t *Tracker) addMsg(p Packet, wire []byte) {
+	var m dns.Msg
+	if err := m.Unpack(wire); err != nil {
+		t.Malformed++
+		return
+	}
+	t.Messages++
+	unmap := func(ap netip.AddrPort) netip.AddrPort {
+		return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())
+	}
+	src, dst := unmap(p.Src), unmap(p.Dst)
+
+	if !m.Response {
+		key := flow{client: src, server: dst, id: m.Id}
+		if old, ok := t.pending[key]; ok && old.client != nil {
+			old.client.Profile.closeQuestion(old.question)
+		}
+		if t.ProbePort != 0 && src.Port() == t.ProbePort {
+			r := t.endpoint(t.probes, dst.Addr())
+			r.countQuery(p.At, len(wire))
+			t.pending[key] = pending{at: p.At, server: r}
+			return
+		}
+
+		c, r := t.endpoint(t.clients, src.Addr()), t.endpoint(t.resolvers, dst.Addr())
+		question := ""
+		if c.Profile == nil {
+			c.Profile = &Profile{Names: map[string]int{}, open: map[string]int{}, nxBase: map[string]int{}}
+		}
+		if len(m.Question) > 0 {
+			q := m.Question[0]
+			name := strings.ToLower(q.Name)
+			question = name + " " + dns.TypeToString[q.Qtype]
+			if _, ok := c.Profile.Names[name]; ok || len(c.Profile.Names) < maxNames {
+				c.Profile.Names[name]++
+			}
+			if _, ok := t.names[name]; ok || len(t.names) < maxNames {
+				t.names[name]++
+			}
+			if c.Profile.open[question] > 0 {
+				c.Profile.Retries++
+			}
+			c.Profile.open[question]++
+		}
+		c.countQuery(p.At, len(wire))
+		r.countQuery(p.At, len(wire))
+		t.pending[key] = pending{at: p.At, question: question, client: c, server: r}
+		return
+	}
+
+	key := flow{client: dst, server: src, id: m.Id}
+	q, ok := t.pending[key]
+	if !ok {
+		// The query was sent before the capture started, or already
+		// timed out.
+		return
+	}
+	delete(t.pending, key)
+	rcode := dns.RcodeToString[m.Rcode]
+	for _, e := range []*Endpoint{q.client, q.server} {
+		if e == nil {
+			continue
+		}
+		if e.Profile != nil {
+			e.Profile.answered(q.question, m.Rcode)
+		}
+		e.Answered++
+		e.Latency.Add(p.At.Sub(q.at))
+		e.RCodes[rcode]++
+		e.RespBytes += len(wire)
+	}
+}
+
+func (e *Endpoint) countQuery(at time.Time, size int) {
+	e.Queries++
+	e.QueryBytes += size
+	if e.First.IsZero() {
+		e.First = at
+	}
+	e.Last = at
+}
+
+func (p *Profile) closeQuestion(question string) {
+	if p.open[question]--; p.open[question] <= 0 {
+		delete(p.open, question)
+	}
+}
+
+func (p *Profile) answered(question string, rcode int) {
+	p.closeQuestion(question)
+	name, _, _ := strings.Cut(question, " ")
+	switch rcode {
+	case dns.RcodeNameError:
+		p.NXDomain++
+		// a.example.com.corp.local. may be a.example.com. plus a search
+		// suffix; remember every shorter name it could have come from.
+		if len(p.nxBase) >= maxNames {
+			clear(p.nxBase)
+		}
+		for i := 0; i < len(name)-1; i++ {
+			if name[i] == '.' {
+				p.nxBase[name[:i+1]]++
+			}
+		}
+	case dns.RcodeSuccess:
+		if n := p.nxBase[name]; n > 0 {
+			p.SearchJunk += n
+			delete(p.nxBase, name)
+		}
+	}
+}
+
+func (t *Tracker) endpoint(m map[netip.Addr]*Endpoint, a netip.Addr) *Endpoint {
+	e, ok := m[a]
+	if !ok {
+		e = &Endpoint{Addr: a, RCodes: map[string]int{}}
+		m[a] = e
+	}
+	return e
+}
+
+// Expire counts queries older than the timeout as lost.
+func (t *Tracker) Expire(now time.Time) {
+	for k, q := range t.pending {
+		if now.Sub(q.at) > t.Timeout {
+			if q.client != nil {
+				q.client.Profile.closeQuestion(q.question)
+				q.client.Lost++
+			}
+			q.server.Lost++
+			delete(t.pending, k)
+		}
+	}
+}
+
+// Clients returns the query sources, busiest first.
+func (t *Tracker) Clients() []*Endpoint {
+	return byQueries(t.clients)
+}
+
+// Resolvers returns the query destinations, busiest first.
+func (t *Tracker) Resolvers() []*Endpoint {
+	return byQueries(t.resolvers)
+}
+
+// Probe returns the statistics of the active probes sent to resolver, or
+// nil if none were seen.
+func (t *Tracker) Probe(resolver netip.Addr) *Endpoint {
+	return t.probes[resolver]
+}
+
+// Resolver returns the passive statistics of resolver, or nil if no client
+// traffic to it was seen.
+func (t *Tracker) Resolver(resolver netip.Addr) *Endpoint {
+	return t.resolvers[resolver]
+}
+
+// TopNames returns the n names clients queried most.
+func (t *Tracker) TopNames(n int) []NameCount {
+	return topNames(t.names, n)
+}
+
+func byQueries(m map[netip.Addr]*Endpoint) []*Endpoint {
+	out := make([]*Endpoint, 0, len(m))
+	for _, e := range m {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Queries != out[j].Queries {
+			return out[i].Queries > out[j].Queries
+		}
+		return out[i].Addr.Less(out[j].Addr)
+	})
+	return out
+}
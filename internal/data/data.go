@@ -0,0 +1,351 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The files of a data set, each versioned in its manifest.
+const (
+	ResolversFile    = "resolvers.txt"
+	DomainsFile      = "domains.txt"
+	RootHintsFile    = "named.root"
+	TrustAnchorsFile = "root-anchors.xml"
+	ManifestFile     = "manifest.json"
+)
+
+// Files lists the data files in the order they are shown and updated.
+var Files = []string{ResolversFile, DomainsFile, RootHintsFile, TrustAnchorsFile}
+
+// Embedded selects the data set built into the binary in Load.
+const Embedded = "embedded"
+
+//go:embed files
+var embedded embed.FS
+
+// Entry is the manifest line of one data file: when it was fetched, from
+// where, and its digest, which Load checks so a pinned set cannot drift.
+type Entry struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	SHA256  string `json:"sha256"`
+}
+
+type Manifest struct {
+	Files []Entry `json:"files"`
+}
+
+// Entry returns the manifest line of the file called name.
+func (m Manifest) Entry(name string) (Entry, bool) {
+	for _, e := range m.Files {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Resolver is a public resolver preset. Host is the name its operator
+// publishes the addresses under.
+type Resolver struct {
+	Name  string
+	Host  string
+	Addrs []string
+}
+
+// RootServer is one root server letter from the root hints, its name
+// lower-cased and fully qualified.
+type RootServer struct {
+	Name  string
+	Addrs []string
+}
+
+// TrustAnchor is one root key digest. ValidUntil is zero while the key has
+// not been retired.
+type TrustAnchor struct {
+	ID         string
+	DS         *dns.DS
+	ValidFrom  time.Time
+	ValidUntil time.Time
+}
+
+// Valid reports whether the anchor is in its validity period at t.
+func (a TrustAnchor) Valid(t time.Time) bool {
+	return !t.Before(a.ValidFrom) && (a.ValidUntil.IsZero() || t.Before(a.ValidUntil))
+}
+
+// Set is a loaded data set. Dir is empty for the embedded one.
+type Set struct {
+	Dir          string
+	Manifest     Manifest
+	Resolvers    []Resolver
+	Domains      []string
+	RootHints    []RootServer
+	TrustAnchors []TrustAnchor
+	raw          map[string][]byte
+}
+
+// Location says where the set was loaded from.
+func (s *Set) Location() string {
+	if s.Dir == "" {
+		return "built in"
+	}
+	return s.Dir
+}
+
+// Resolver returns the preset called name.
+func (s *Set) Resolver(name string) (Resolver, bool) {
+	for _, r := range s.Resolvers {
+		if strings.EqualFold(r.Name, name) {
+			return r, true
+		}
+	}
+	return Resolver{}, false
+}
+
+// Count is the number of entries the file called name holds.
+func (s *Set) Count(name string) int {
+	switch name {
+	case ResolversFile:
+		return len(s.Resolvers)
+	case DomainsFile:
+		return len(s.Domains)
+	case RootHintsFile:
+		return len(s.RootHints)
+	case TrustAnchorsFile:
+		return len(s.TrustAnchors)
+	}
+	return 0
+}
+
+// DefaultDir is where "dnsdoc data update" writes and Load looks first.
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dnsdoc", "data"), nil
+}
+
+// Load returns the data set in dir, or the embedded one when dir is
+// Embedded. With dir empty it uses DefaultDir when an update has written
+// a set there and the embedded set otherwise.
+func Load(dir string) (*Set, error) {
+	switch dir {
+	case Embedded:
+		sub, _ := fs.Sub(embedded, "files")
+		return load(sub, "")
+	case "":
+		d, err := DefaultDir()
+		if err != nil {
+			return Load(Embedded)
+		}
+		if _, err := os.Stat(filepath.Join(d, ManifestFile)); err != nil {
+			return Load(Embedded)
+		}
+		dir = d
+	}
+	return load(os.DirFS(dir), dir)
+}
+
+func load(fsys fs.FS, dir string) (*Set, error) {
+	s := &Set{Dir: dir, raw: map[string][]byte{}}
+	b, err := fs.ReadFile(fsys, ManifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("data %s: %w", s.Location(), err)
+	}
+	if err := json.Unmarshal(b, &s.Manifest); err != nil {
+		return nil, fmt.Errorf("data %s: %s: %w", s.Location(), ManifestFile, err)
+	}
+	var errs []error
+	for _, name := range Files {
+		if err := s.add(fsys, name); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("data %s: %w", s.Location(), err)
+	}
+	return s, nil
+}
+
+func (s *Set) add(fsys fs.FS, name string) error {
+	e, ok := s.Manifest.Entry(name)
+	if !ok {
+		return fmt.Errorf("not in %s", ManifestFile)
+	}
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	if sum := digest(b); !strings.EqualFold(sum, e.SHA256) {
+		return fmt.Errorf("digest %s does not match the manifest; the file changed after it was written", sum[:12])
+	}
+	s.raw[name] = b
+	return s.parse(name, b)
+}
+
+func (s *Set) parse(name string, b []byte) (err error) {
+	switch name {
+	case ResolversFile:
+		s.Resolvers, err = parseResolvers(b)
+	case DomainsFile:
+		s.Domains, err = parseDomains(b)
+	case RootHintsFile:
+		s.RootHints, err = parseRootHints(b)
+	case TrustAnchorsFile:
+		s.TrustAnchors, err = parseTrustAnchors(b)
+	}
+	return err
+}
+
+func digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// lines returns the fields of every line of b that is neither blank nor a
+// comment.
+func lines(b []byte) [][]string {
+	var out [][]string
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, strings.Fields(line))
+	}
+	return out
+}
+
+func parseResolvers(b []byte) ([]Resolver, error) {
+	var out []Resolver
+	for _, f := range lines(b) {
+		if len(f) < 3 {
+			return nil, fmt.Errorf("%q: want a name, a host and addresses", strings.Join(f, " "))
+		}
+		for _, a := range f[2:] {
+			if net.ParseIP(a) == nil {
+				return nil, fmt.Errorf("%s: %q is not an IP address", f[0], a)
+			}
+		}
+		out = append(out, Resolver{Name: f[0], Host: f[1], Addrs: f[2:]})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no presets")
+	}
+	return out, nil
+}
+
+func parseDomains(b []byte) ([]string, error) {
+	var out []string
+	for _, f := range lines(b) {
+		if _, ok := dns.IsDomainName(f[0]); !ok || len(f) > 1 {
+			return nil, fmt.Errorf("%q is not a domain name", strings.Join(f, " "))
+		}
+		out = append(out, f[0])
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no domains")
+	}
+	return out, nil
+}
+
+// parseRootHints reads a root hints file in zone file format, such as
+// InterNIC's named.root, keeping the servers in the order of the root NS
+// set.
+func parseRootHints(b []byte) ([]RootServer, error) {
+	var names []string
+	addrs := map[string][]string{}
+	zp := dns.NewZoneParser(bytes.NewReader(b), ".", RootHintsFile)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		name := strings.ToLower(rr.Header().Name)
+		switch rr := rr.(type) {
+		case *dns.NS:
+			if name == "." {
+				names = append(names, strings.ToLower(rr.Ns))
+			}
+		case *dns.A:
+			addrs[name] = append(addrs[name], rr.A.String())
+		case *dns.AAAA:
+			addrs[name] = append(addrs[name], rr.AAAA.String())
+		}
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	var out []RootServer
+	for _, n := range names {
+		if len(addrs[n]) == 0 {
+			return nil, fmt.Errorf("no address for %s", n)
+		}
+		out = append(out, RootServer{Name: n, Addrs: addrs[n]})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no root NS records")
+	}
+	return out, nil
+}
+
+// parseTrustAnchors reads IANA's root-anchors.xml (RFC 9718).
+func parseTrustAnchors(b []byte) ([]TrustAnchor, error) {
+	var doc struct {
+		Zone    string `xml:"Zone"`
+		Digests []struct {
+			ID         string `xml:"id,attr"`
+			ValidFrom  string `xml:"validFrom,attr"`
+			ValidUntil string `xml:"validUntil,attr"`
+			KeyTag     uint16 `xml:"KeyTag"`
+			Algorithm  uint8  `xml:"Algorithm"`
+			DigestType uint8  `xml:"DigestType"`
+			Digest     string `xml:"Digest"`
+		} `xml:"KeyDigest"`
+	}
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Zone != "." {
+		return nil, fmt.Errorf("anchors for zone %q, not the root", doc.Zone)
+	}
+	var out []TrustAnchor
+	for _, d := range doc.Digests {
+		a := TrustAnchor{ID: d.ID, DS: &dns.DS{
+			Hdr:        dns.RR_Header{Name: ".", Rrtype: dns.TypeDS, Class: dns.ClassINET},
+			KeyTag:     d.KeyTag,
+			Algorithm:  d.Algorithm,
+			DigestType: d.DigestType,
+			Digest:     strings.ToUpper(strings.TrimSpace(d.Digest)),
+		}}
+		var err error
+		if a.ValidFrom, err = time.Parse(time.RFC3339, d.ValidFrom); err != nil {
+			return nil, fmt.Errorf("%s: validFrom: %w", d.ID, err)
+		}
+		if d.ValidUntil != "" {
+			if a.ValidUntil, err = time.Parse(time.RFC3339, d.ValidUntil); err != nil {
+				return nil, fmt.Errorf("%s: validUntil: %w", d.ID, err)
+			}
+		}
+		out = append(out, a)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no key digests")
+	}
+	return out, nil
+}
@@ -0,0 +1,270 @@
+package data
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+)
+
+// Upstream sources of the data files. The resolver presets have none of
+// their own: each preset's host is looked up again.
+const (
+	DomainsSource      = "https://tranco-list.eu/top-1m.csv.zip"
+	RootHintsSource    = "https://www.internic.net/domain/named.root"
+	TrustAnchorsSource = "https://data.iana.org/root-anchors/root-anchors.xml"
+	ResolversSource    = "DNS lookups of each preset's host"
+)
+
+const resolversHeader = `# Public resolver presets: name, the host name its operator publishes the
+# addresses under, and the addresses. "dnsdoc data update" looks the hosts
+# up again.
+`
+
+// UpdateConfig is a data update. Server answers the lookups of the
+// resolver presets; Domains is how many of the top sites to keep.
+type UpdateConfig struct {
+	Dir     string
+	Server  string
+	Domains int
+	Options dnsprobe.Options
+}
+
+// UpdateResult is the outcome for one file. When Err is set the file was
+// kept as it was in the current set.
+type UpdateResult struct {
+	Entry
+	Changed bool
+	Err     error
+}
+
+// Update fetches every file from its upstream source and writes a complete
+// set to cfg.Dir, keeping cur's copy of any file it could not fetch. The
+// set is written to a sibling directory first and renamed into place, so
+// an interrupted update leaves the previous set in force. cfg.Dir must be
+// missing, empty or hold a data set and nothing else, since it is replaced
+// as a whole.
+func Update(ctx context.Context, cur *Set, cfg UpdateConfig) ([]UpdateResult, error) {
+	fetchers := map[string]func() ([]byte, error){
+		ResolversFile:    func() ([]byte, error) { return fetchResolvers(ctx, cur.Resolvers, cfg) },
+		DomainsFile:      func() ([]byte, error) { return fetchDomains(ctx, cfg.Domains) },
+		RootHintsFile:    func() ([]byte, error) { return fetch(ctx, RootHintsSource) },
+		TrustAnchorsFile: func() ([]byte, error) { return fetch(ctx, TrustAnchorsSource) },
+	}
+	sources := map[string]string{
+		ResolversFile:    ResolversSource,
+		DomainsFile:      DomainsSource,
+		RootHintsFile:    RootHintsSource,
+		TrustAnchorsFile: TrustAnchorsSource,
+	}
+	if err := checkReplaceable(cfg.Dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Dir), 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(cfg.Dir), filepath.Base(cfg.Dir)+".new-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		return nil, err
+	}
+
+	var out []UpdateResult
+	var m Manifest
+	for _, name := range Files {
+		old, _ := cur.Manifest.Entry(name)
+		r := UpdateResult{Entry: old}
+		b, err := fetchers[name]()
+		if err == nil {
+			// Never replace a working file with one that does not parse.
+			err = new(Set).parse(name, b)
+		}
+		if err != nil {
+			r.Err = err
+			b = cur.raw[name]
+		} else {
+			r.Entry = Entry{Name: name, Version: time.Now().UTC().Format(time.DateOnly), Source: sources[name], SHA256: digest(b)}
+			r.Changed = r.SHA256 != old.SHA256
+			if !r.Changed {
+				r.Version = old.Version
+			}
+		}
+		if err := os.WriteFile(filepath.Join(tmp, name), b, 0o644); err != nil {
+			return out, err
+		}
+		m.Files = append(m.Files, r.Entry)
+		out = append(out, r)
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return out, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, ManifestFile), append(b, '\n'), 0o644); err != nil {
+		return out, err
+	}
+	return out, replaceDir(tmp, cfg.Dir)
+}
+
+// checkReplaceable refuses a dir that holds anything besides a data set,
+// which replaceDir would delete.
+func checkReplaceable(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	var foreign []string
+	manifest := false
+	for _, e := range entries {
+		switch name := e.Name(); {
+		case name == ManifestFile && !e.IsDir():
+			manifest = true
+		case e.IsDir() || !slices.Contains(Files, name):
+			foreign = append(foreign, name)
+		}
+	}
+	switch {
+	case len(foreign) > 0:
+		return fmt.Errorf("%s holds files that are not dnsdoc data (%s); an update replaces the whole directory, so point --dir at an empty or new one", dir, strings.Join(foreign, ", "))
+	case !manifest:
+		return fmt.Errorf("%s has no %s, so it is not a dnsdoc data set; an update replaces the whole directory, so point --dir at an empty or new one", dir, ManifestFile)
+	}
+	return nil
+}
+
+// replaceDir moves the directory src to dst, moving any existing dst aside
+// first and back again if src cannot take its place. A reader that finds no
+// dst in between falls back to the embedded set.
+func replaceDir(src, dst string) error {
+	old := dst + ".old"
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	if err := os.Rename(dst, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		os.Rename(old, dst)
+		return err
+	}
+	return os.RemoveAll(old)
+}
+
+func fetch(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// fetchDomains keeps the first n names of the Tranco top sites list, a CSV
+// of rank and domain inside a zip archive.
+func fetchDomains(ctx context.Context, n int) ([]byte, error) {
+	b, err := fetch(ctx, DomainsSource)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	if len(zr.File) == 0 {
+		return nil, fmt.Errorf("empty archive")
+	}
+	f, err := zr.File[0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Top %d sites of the Tranco list, most popular first.\n", n)
+	cr := csv.NewReader(f)
+	for i := 0; i < n; i++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) != 2 {
+			return nil, fmt.Errorf("line %d: want rank and domain", i+1)
+		}
+		fmt.Fprintln(&buf, rec[1])
+	}
+	return buf.Bytes(), nil
+}
+
+// fetchResolvers looks up the A and AAAA records of every preset's host.
+// A preset whose host has no addresses keeps its own, but any failed lookup
+// fails the file.
+func fetchResolvers(ctx context.Context, presets []Resolver, cfg UpdateConfig) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(resolversHeader)
+	var errs []error
+	for _, p := range presets {
+		var addrs []string
+		for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+			m := new(dns.Msg)
+			m.SetQuestion(dns.Fqdn(p.Host), qtype)
+			resp, _, err := dnsprobe.Exchange(ctx, cfg.Server, m, cfg.Options)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", p.Host, err))
+				continue
+			}
+			for _, rr := range resp.Answer {
+				if rr.Header().Rrtype != qtype {
+					continue
+				}
+				switch rr := rr.(type) {
+				case *dns.A:
+					addrs = append(addrs, rr.A.String())
+				case *dns.AAAA:
+					addrs = append(addrs, rr.AAAA.String())
+				}
+			}
+		}
+		if len(addrs) == 0 {
+			addrs = p.Addrs
+		}
+		fmt.Fprintf(&buf, "%s %s %s\n", p.Name, p.Host, strings.Join(addrs, " "))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,88 @@
+package dnsprobe
+
+import (
+	"sort"
+	"time"
+)
+
+// SiteTracker follows which anycast site (by NSID) answers successive probes
+// and keeps per-site latency, to spot routing that flaps between sites.
+type SiteTracker struct {
+	Flaps     int
+	current   string
+	firstSeen time.Time
+	sites     map[string]*siteStats
+	order     []string
+}
+
+type siteStats struct {
+	latency Distribution
+	flapsIn int
+}
+
+// SiteSummary is the latency profile of one site.
+type SiteSummary struct {
+	Site    string
+	Probes  int
+	FlapsIn int
+	Min     time.Duration
+	Mean    time.Duration
+	P50     time.Duration
+	P90     time.Duration
+	Max     time.Duration
+}
+
+// Observe records an answer from site. It reports the previous site when
+// this answer came from a different one.
+func (t *SiteTracker) Observe(site string, at time.Time, rtt time.Duration) (prev string, changed bool) {
+	first := t.sites == nil
+	if first {
+		t.sites = map[string]*siteStats{}
+		t.firstSeen = at
+	}
+	s, ok := t.sites[site]
+	if !ok {
+		s = &siteStats{}
+		t.sites[site] = s
+		t.order = append(t.order, site)
+	}
+	s.latency.Add(rtt)
+
+	prev = t.current
+	changed = !first && prev != site
+	if changed {
+		t.Flaps++
+		s.flapsIn++
+	}
+	t.current = site
+	return prev, changed
+}
+
+// MeanTimeBetweenFlaps is the observed span divided by the flap count, or 0
+// when routing never changed.
+func (t *SiteTracker) MeanTimeBetweenFlaps(now time.Time) time.Duration {
+	if t.Flaps == 0 {
+		return 0
+	}
+	return now.Sub(t.firstSeen) / time.Duration(t.Flaps)
+}
+
+// Sites returns per-site summaries, busiest first.
+func (t *SiteTracker) Sites() []SiteSummary {
+	out := make([]SiteSummary, 0, len(t.order))
+	for _, name := range t.order {
+		s := t.sites[name]
+		out = append(out, SiteSummary{
+			Site:    name,
+			Probes:  s.latency.Count(),
+			FlapsIn: s.flapsIn,
+			Min:     s.latency.Min(),
+			Mean:    s.latency.Mean(),
+			P50:     s.latency.Quantile(0.5),
+			P90:     s.latency.Quantile(0.9),
+			Max:     s.latency.Max(),
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Probes > out[j].Probes })
+	return out
+}
@@ -0,0 +1,162 @@
+package dnsprobe
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ResolverProfile is what an attribution pass learns about one resolver:
+// the network round trip alone, measured with non-recursive queries it
+// answers without work, the median latency of real lookups, how many of
+// those it served from cache, and the upstream address authoritatives see
+// its queries come from.
+type ResolverProfile struct {
+	Server     string
+	NetworkRTT time.Duration
+	Median     time.Duration
+	Cache      CacheEstimate
+	Egress     string
+	EgressName string // reverse name of Egress, which often names its site
+	Err        error
+}
+
+// Processing is the median latency the network round trip does not
+// explain: recursion, upstream forwarding and load.
+func (p ResolverProfile) Processing() time.Duration {
+	return max(p.Median-p.NetworkRTT, 0)
+}
+
+// Factor is one reason a resolver is slower than another. Delta is how much
+// of the latency gap it accounts for, zero for factors that explain
+// without measuring.
+type Factor struct {
+	Name   string
+	Delta  time.Duration
+	Detail string
+}
+
+const (
+	attributionRTTProbes = 5
+	attributionNames     = 5
+	attributionRepeats   = 4
+)
+
+// ProfileResolver measures server for an attribution pass, resolving up to
+// five of names a few times each.
+func ProfileResolver(ctx context.Context, server string, names []string, opts Options) ResolverProfile {
+	p := ResolverProfile{Server: server}
+	m := new(dns.Msg)
+	m.SetQuestion(".", dns.TypeNS)
+	m.RecursionDesired = false
+	var lastErr error
+	for i := 0; i < attributionRTTProbes; i++ {
+		_, rtt, err := Exchange(ctx, server, m, opts)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if p.NetworkRTT == 0 || rtt < p.NetworkRTT {
+			p.NetworkRTT = rtt
+		}
+	}
+	if p.NetworkRTT == 0 {
+		p.Err = fmt.Errorf("network round trip: %w", lastErr)
+		return p
+	}
+
+	var totals []time.Duration
+	for _, name := range names[:min(len(names), attributionNames)] {
+		var samples []cacheSample
+		for i := 0; i < attributionRepeats; i++ {
+			sent := time.Now()
+			r, err := Probe(ctx, server, name, opts)
+			if err != nil {
+				continue
+			}
+			samples = append(samples, sampleOf(r, sent))
+			totals = append(totals, r.Timings.Total)
+		}
+		e := estimateCache(samples)
+		p.Cache.Hits += e.Hits
+		p.Cache.Misses += e.Misses
+		p.Cache.TTLHits += e.TTLHits
+		p.Cache.Threshold = max(p.Cache.Threshold, e.Threshold)
+	}
+	if len(totals) == 0 {
+		p.Err = fmt.Errorf("no lookup succeeded")
+		return p
+	}
+	slices.Sort(totals)
+	p.Median = totals[len(totals)/2]
+
+	if resp, err := ecsQuery(ctx, server, ECSZone, dns.TypeTXT, nil, opts); err == nil {
+		if egress, _ := reflectedECS(resp); len(egress) > 0 {
+			p.Egress = egress[0]
+			p.EgressName = reverseName(ctx, server, p.Egress, opts)
+		}
+	}
+	return p
+}
+
+func reverseName(ctx context.Context, server, addr string, opts Options) string {
+	arpa, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return ""
+	}
+	m := new(dns.Msg)
+	m.SetQuestion(arpa, dns.TypePTR)
+	resp, _, err := Exchange(ctx, server, m, opts)
+	if err != nil {
+		return ""
+	}
+	for _, rr := range resp.Answer {
+		if ptr, ok := rr.(*dns.PTR); ok {
+			return ptr.Ptr
+		}
+	}
+	return ""
+}
+
+// Attribute explains why slow is slower than fast, largest factor first:
+// a longer network path, a colder cache, more time spent resolving and,
+// behind the latter, where the resolver's upstream queries leave from.
+func Attribute(fast, slow ResolverProfile) []Factor {
+	var out []Factor
+	if d := slow.NetworkRTT - fast.NetworkRTT; d > 0 {
+		out = append(out, Factor{Name: "network", Delta: d,
+			Detail: fmt.Sprintf("the round trip to the resolver itself is %s vs %s", roundRTT(slow.NetworkRTT), roundRTT(fast.NetworkRTT))})
+	}
+	d := slow.Processing() - fast.Processing()
+	if d <= 0 {
+		return out
+	}
+	if slow.Cache.Known() && fast.Cache.Known() && slow.Cache.Ratio()+0.1 < fast.Cache.Ratio() {
+		out = append(out, Factor{Name: "cache", Delta: d,
+			Detail: fmt.Sprintf("%.0f%% of replies came from its cache vs %.0f%%, so more lookups wait for recursion", slow.Cache.Ratio()*100, fast.Cache.Ratio()*100)})
+	} else {
+		out = append(out, Factor{Name: "resolver", Delta: d,
+			Detail: fmt.Sprintf("it spends %s vs %s per lookup beyond the round trip: recursion, upstream forwarding or load", roundRTT(slow.Processing()), roundRTT(fast.Processing()))})
+	}
+	if slow.Egress != "" && fast.Egress != "" && slow.Egress != fast.Egress {
+		out = append(out, Factor{Name: "egress",
+			Detail: fmt.Sprintf("authoritatives see its queries from %s vs %s; an egress far from them slows every cache miss", egressLabel(slow), egressLabel(fast))})
+	}
+	slices.SortStableFunc(out, func(a, b Factor) int { return cmp.Compare(b.Delta, a.Delta) })
+	return out
+}
+
+func egressLabel(p ResolverProfile) string {
+	if p.EgressName == "" {
+		return p.Egress
+	}
+	return p.Egress + " (" + p.EgressName + ")"
+}
+
+func roundRTT(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,69 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Transfer fetches zone from server by AXFR and returns its records in the
+// order they were sent, without the SOA that closes the transfer. The
+// timeout of opts applies to each message rather than to the whole
+// transfer, so large zones are not cut short.
+func Transfer(ctx context.Context, server, zone string, opts Options) ([]dns.RR, error) {
+	server = NormalizeServer(server)
+	if err := checkFamily(server, opts.Family); err != nil {
+		return nil, err
+	}
+	dialCtx, cancel := probeContext(ctx, opts.Timeout)
+	defer cancel()
+	conn, _, err := opts.dial(dialCtx, opts.network("tcp"), server)
+	if err != nil {
+		return nil, ctxError(dialCtx, err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	c := &dns.Conn{Conn: conn}
+	m := new(dns.Msg)
+	m.SetAxfr(dns.Fqdn(zone))
+	if opts.Timeout > 0 {
+		_ = conn.SetWriteDeadline(time.Now().Add(opts.Timeout))
+	}
+	if err := c.WriteMsg(m); err != nil {
+		return nil, ctxError(ctx, err)
+	}
+
+	var rrs []dns.RR
+	for {
+		if opts.Timeout > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(opts.Timeout))
+		}
+		in, err := c.ReadMsg()
+		if err != nil {
+			return nil, ctxError(ctx, err)
+		}
+		if in.Id != m.Id {
+			return nil, dns.ErrId
+		}
+		if in.Rcode != dns.RcodeSuccess {
+			return nil, fmt.Errorf("transfer %s", dns.RcodeToString[in.Rcode])
+		}
+		for _, rr := range in.Answer {
+			_, soa := rr.(*dns.SOA)
+			switch {
+			case len(rrs) == 0 && !soa:
+				return nil, fmt.Errorf("transfer does not start with the SOA")
+			case len(rrs) > 0 && soa:
+				return rrs, nil
+			}
+			rrs = append(rrs, rr)
+		}
+		if len(in.Answer) == 0 {
+			return nil, fmt.Errorf("transfer ended before the closing SOA")
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package dnsprobe
+
+import (
+	"slices"
+	"time"
+)
+
+// TTL patterns, as BenchTTLs tells them apart.
+const (
+	TTLFixed    = "fixed"
+	TTLShared   = "shared cache"
+	TTLRefilled = "refilled"
+	TTLSeveral  = "several caches"
+)
+
+// BenchTTLs sums up the answer TTLs a benchmark saw. Every reply fixes the
+// instant its record expires from the cache that served it, its send time
+// plus its TTL; replies from one cache agree on that instant, so Caches
+// counts the distinct ones. Pattern reads them as TTLFixed when the TTL
+// never counted down (the resolver resets or rewrites TTLs, or answers
+// authoritatively), TTLShared when it counted down in step with the clock
+// (one cache behind every reply), TTLRefilled when it did so on one cache
+// that refetched the record as it expired, or TTLSeveral when replies
+// alternated between caches counting down apart (backends behind a load
+// balancer, each with its own cache). Pattern is "" when the run was too
+// short to tell or saw fewer than two TTLs.
+type BenchTTLs struct {
+	Samples int
+	Min     uint32
+	Max     uint32
+	// Decrements and Rises count consecutive replies, in send order, whose
+	// TTL went down and up.
+	Decrements int
+	Rises      int
+	Caches     int
+	Span       time.Duration
+	Pattern    string
+}
+
+// ttlSlack is how far apart expiry instants from one cache may fall: TTLs
+// count whole seconds, and replies take a while to arrive.
+const ttlSlack = 1500 * time.Millisecond
+
+// ttlMinSpan is the shortest run over which a TTL that counts down shows
+// it.
+const ttlMinSpan = 2 * time.Second
+
+func benchTTLs(samples []cacheSample) BenchTTLs {
+	samples = slices.DeleteFunc(slices.Clone(samples), func(s cacheSample) bool { return !s.hasTTL })
+	var t BenchTTLs
+	if t.Samples = len(samples); t.Samples == 0 {
+		return t
+	}
+	slices.SortStableFunc(samples, func(a, b cacheSample) int { return a.at.Compare(b.at) })
+	t.Min, t.Max = samples[0].ttl, samples[0].ttl
+	for i, s := range samples {
+		t.Min, t.Max = min(t.Min, s.ttl), max(t.Max, s.ttl)
+		if i > 0 {
+			switch prev := samples[i-1].ttl; {
+			case s.ttl < prev:
+				t.Decrements++
+			case s.ttl > prev:
+				t.Rises++
+			}
+		}
+	}
+	t.Span = samples[len(samples)-1].at.Sub(samples[0].at)
+
+	// Group the expiry instants; group[i] is the cache sample i came from.
+	expiry := func(s cacheSample) time.Time { return s.at.Add(time.Duration(s.ttl) * time.Second) }
+	order := make([]int, len(samples))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return expiry(samples[a]).Compare(expiry(samples[b])) })
+	group := make([]int, len(samples))
+	var ends []time.Time
+	for j, i := range order {
+		if j == 0 || expiry(samples[i]).Sub(expiry(samples[order[j-1]])) > ttlSlack {
+			ends = append(ends, expiry(samples[i]))
+		}
+		group[i] = len(ends) - 1
+	}
+	t.Caches = len(ends)
+
+	switch {
+	case t.Samples < 2:
+	case t.Min == t.Max && t.Span >= ttlMinSpan:
+		t.Pattern = TTLFixed
+	case t.Caches == 1 && t.Span >= ttlMinSpan:
+		t.Pattern = TTLShared
+	case t.Caches == 1:
+	case refilled(samples, group, ends):
+		t.Pattern = TTLRefilled
+	default:
+		t.Pattern = TTLSeveral
+	}
+	return t
+}
+
+// refilled reports whether the groups of samples, in send order, follow
+// one another, each starting once the one before had expired: one cache
+// fetching the record again rather than several serving it in turn.
+func refilled(samples []cacheSample, group []int, ends []time.Time) bool {
+	for i := 1; i < len(samples); i++ {
+		g, prev := group[i], group[i-1]
+		switch {
+		case g == prev:
+		case g != prev+1:
+			return false
+		case samples[i].at.Add(ttlSlack).Before(ends[prev]):
+			return false
+		}
+	}
+	return true
+}
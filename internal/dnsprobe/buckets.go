@@ -0,0 +1,96 @@
+package dnsprobe
+
+import (
+	"time"
+)
+
+// BenchBucket is one slice of a timed benchmark: the queries that started
+// in it, Offset into the run.
+type BenchBucket struct {
+	Offset   time.Duration
+	Attempts int
+	Success  int
+	Fail     int
+	Lost     int
+	Mean     time.Duration
+	P50      time.Duration
+	P90      time.Duration
+	Max      time.Duration
+}
+
+// FailRatio is the share of the bucket's queries that failed.
+func (b BenchBucket) FailRatio() float64 {
+	if b.Attempts == 0 {
+		return 0
+	}
+	return float64(b.Fail) / float64(b.Attempts)
+}
+
+// bucketer sorts benchmark results into buckets by when they were sent.
+// Latencies go into a streaming Distribution per bucket, which is dropped
+// once flush says no more queries can start in it, so a long run holds only
+// the buckets still open; a nil bucketer ignores results.
+type bucketer struct {
+	start   time.Time
+	width   time.Duration
+	buckets []BenchBucket
+	open    map[int]*Distribution
+}
+
+func newBucketer(start time.Time, width time.Duration) *bucketer {
+	return &bucketer{start: start, width: width, open: map[int]*Distribution{}}
+}
+
+func (k *bucketer) add(sent time.Time, r Result, class string) {
+	if k == nil {
+		return
+	}
+	i := int(sent.Sub(k.start) / k.width)
+	for len(k.buckets) <= i {
+		k.buckets = append(k.buckets, BenchBucket{Offset: time.Duration(len(k.buckets)) * k.width})
+	}
+	b := &k.buckets[i]
+	b.Attempts++
+	switch class {
+	case "":
+		b.Success++
+		d := k.open[i]
+		if d == nil {
+			d = &Distribution{}
+			k.open[i] = d
+		}
+		d.Add(r.Timings.Total)
+	case "timeout":
+		b.Fail++
+		b.Lost++
+	default:
+		b.Fail++
+	}
+}
+
+// flush completes the buckets before the one now falls in: queries sent
+// from now on cannot land in them.
+func (k *bucketer) flush(now time.Time) {
+	if k == nil {
+		return
+	}
+	i := int(now.Sub(k.start) / k.width)
+	for j, d := range k.open {
+		if j < i {
+			k.close(j, d)
+		}
+	}
+}
+
+func (k *bucketer) close(i int, d *Distribution) {
+	b := &k.buckets[i]
+	b.Mean, b.P50, b.P90, b.Max = d.Mean(), d.Quantile(0.50), d.Quantile(0.90), d.Max()
+	delete(k.open, i)
+}
+
+func (k *bucketer) finish() []BenchBucket {
+	for i, d := range k.open {
+		k.close(i, d)
+	}
+	return k.buckets
+}
@@ -0,0 +1,40 @@
+package dnsprobe
+
+import (
+	"context"
+	"sync"
+)
+
+// Outcome is one name's result in a bulk run.
+type Outcome struct {
+	Name   string
+	Result Result
+	Err    error
+}
+
+// ProbeMany probes every name once, with up to concurrency in flight.
+// Outcomes keep the order of names.
+func ProbeMany(ctx context.Context, server string, names []string, opts Options, concurrency int) []Outcome {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	out := make([]Outcome, len(names))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			r, err := Probe(ctx, server, name, opts)
+			out[i] = Outcome{Name: name, Result: r, Err: err}
+		}(i, name)
+	}
+
+	wg.Wait()
+	return out
+}
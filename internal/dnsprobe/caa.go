@@ -0,0 +1,153 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// CAALevel is the CAA lookup of one name on the way up from a domain: its
+// records, and Alias when the name is a CNAME whose target answered.
+type CAALevel struct {
+	Name    string
+	Alias   string
+	Records []*dns.CAA
+}
+
+// CAAPolicy is a domain's CAA lookup as a CA performs it (RFC 8659, section
+// 3): the domain, then each parent in turn, up to the first name with a
+// non-empty CAA set. Relevant is that name's index in Levels, -1 when none
+// has one and any CA may issue.
+type CAAPolicy struct {
+	Domain   string
+	Levels   []CAALevel
+	Relevant int
+}
+
+// Set is the relevant CAA set, empty when no name has one.
+func (p CAAPolicy) Set() []*dns.CAA {
+	if p.Relevant < 0 {
+		return nil
+	}
+	return p.Levels[p.Relevant].Records
+}
+
+// Issuers lists the CA domains the relevant set allows to issue, for
+// wildcard certificates when wildcard is set; restricted is false when any
+// CA may issue. An empty list with restricted set means no CA may.
+func (p CAAPolicy) Issuers(wildcard bool) (issuers []string, restricted bool) {
+	set := p.Set()
+	if len(set) == 0 {
+		return nil, false
+	}
+	tag := "issue"
+	if wildcard {
+		for _, r := range set {
+			if strings.EqualFold(r.Tag, "issuewild") {
+				tag = "issuewild"
+			}
+		}
+	}
+	for _, r := range set {
+		if !strings.EqualFold(r.Tag, tag) {
+			continue
+		}
+		restricted = true
+		if issuer, _, err := ParseCAAIssue(r.Value); err == nil && issuer != "" {
+			issuers = append(issuers, issuer)
+		}
+	}
+	// A set with no issue records (only iodef, say) restricts nothing.
+	return issuers, restricted
+}
+
+// LookupCAA finds the CAA set relevant to domain through server. A failed
+// lookup is an error: CAs must not issue when they cannot tell.
+func LookupCAA(ctx context.Context, server, domain string, opts Options) (CAAPolicy, error) {
+	p := CAAPolicy{Domain: strings.ToLower(dns.Fqdn(domain)), Relevant: -1}
+	labels := dns.SplitDomainName(p.Domain)
+	for i := range labels {
+		name := dns.Fqdn(strings.Join(labels[i:], "."))
+		resp, err := ttlQuery(ctx, server, name, dns.TypeCAA, true, opts)
+		if err != nil {
+			return p, fmt.Errorf("CAA %s: %w", name, err)
+		}
+		if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+			return p, fmt.Errorf("CAA %s: %s (CAs refuse to issue while the lookup fails)", name, dns.RcodeToString[resp.Rcode])
+		}
+		l := CAALevel{Name: name}
+		for _, rr := range resp.Answer {
+			switch rr := rr.(type) {
+			case *dns.CNAME:
+				l.Alias = rr.Target
+			case *dns.CAA:
+				l.Records = append(l.Records, rr)
+			}
+		}
+		p.Levels = append(p.Levels, l)
+		if len(l.Records) > 0 {
+			p.Relevant = len(p.Levels) - 1
+			break
+		}
+	}
+	return p, nil
+}
+
+// caaTags are the property tags CAs know (RFC 8659, RFC 8657, RFC 9495).
+var caaTags = []string{"issue", "issuewild", "iodef", "issuemail", "issuevmc", "contactemail", "contactphone"}
+
+// CheckCAA validates the syntax of r. Unknown is set for a tag no CA
+// knows, which CAs ignore unless the record is critical.
+func CheckCAA(r *dns.CAA) (unknown bool, err error) {
+	tag := strings.ToLower(r.Tag)
+	for _, c := range tag {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			return false, fmt.Errorf("tag %q is not alphanumeric", r.Tag)
+		}
+	}
+	if r.Flag&^128 != 0 {
+		return false, fmt.Errorf("flags %d: only the critical bit (128) is defined", r.Flag)
+	}
+	switch tag {
+	case "issue", "issuewild", "issuemail", "issuevmc":
+		_, _, err = ParseCAAIssue(r.Value)
+	case "iodef":
+		if !strings.HasPrefix(r.Value, "mailto:") && !strings.HasPrefix(r.Value, "https://") && !strings.HasPrefix(r.Value, "http://") {
+			err = fmt.Errorf("iodef %q is not a mailto:, http: or https: URL", r.Value)
+		}
+	default:
+		unknown = true
+		for _, t := range caaTags {
+			if tag == t {
+				unknown = false
+			}
+		}
+	}
+	return unknown, err
+}
+
+// ParseCAAIssue parses an issue value: an optional CA domain, then
+// ";"-separated key=value parameters. An empty issuer forbids issuance.
+func ParseCAAIssue(v string) (issuer string, params map[string]string, err error) {
+	issuer, rest, _ := strings.Cut(v, ";")
+	issuer = strings.TrimSpace(issuer)
+	if issuer != "" {
+		if _, ok := dns.IsDomainName(issuer); !ok || strings.ContainsAny(issuer, " \t") || strings.HasSuffix(issuer, ".") {
+			return "", nil, fmt.Errorf("issuer %q is not a domain name", issuer)
+		}
+	}
+	params = map[string]string{}
+	for _, p := range strings.Split(rest, ";") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		k, val, ok := strings.Cut(p, "=")
+		if !ok || k == "" || strings.ContainsAny(k, " \t") || strings.ContainsAny(val, " \t") {
+			return "", nil, fmt.Errorf("parameter %q is not key=value", p)
+		}
+		params[k] = val
+	}
+	return strings.ToLower(issuer), params, nil
+}
@@ -0,0 +1,82 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// QueryCache dedupes identical queries within one run, so multi-check
+// commands do not ask the same thing twice and trip rate limits. Entries are
+// keyed by server, transport and everything in the query that can change the
+// answer; replies are shared, and concurrent identical queries wait for the
+// first. It is meant to live for a single invocation only.
+type QueryCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	Hits    int
+	Misses  int
+}
+
+type cacheEntry struct {
+	done chan struct{}
+	resp *dns.Msg
+	rtt  time.Duration
+	err  error
+}
+
+func NewQueryCache() *QueryCache {
+	return &QueryCache{entries: map[string]*cacheEntry{}}
+}
+
+// Exchange is Exchange with deduplication. A nil cache sends every query.
+func (c *QueryCache) Exchange(ctx context.Context, server string, msg *dns.Msg, opts Options) (*dns.Msg, time.Duration, error) {
+	if c == nil {
+		return Exchange(ctx, server, msg, opts)
+	}
+	key := cacheKey(server, msg, opts)
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok {
+		c.Hits++
+		c.mu.Unlock()
+		<-e.done
+	} else {
+		c.Misses++
+		e = &cacheEntry{done: make(chan struct{})}
+		c.entries[key] = e
+		c.mu.Unlock()
+		e.resp, e.rtt, e.err = Exchange(ctx, server, msg, opts)
+		close(e.done)
+	}
+
+	if e.err != nil {
+		return nil, e.rtt, e.err
+	}
+	resp := e.resp.Copy()
+	resp.Id = msg.Id
+	return resp, e.rtt, nil
+}
+
+func cacheKey(server string, m *dns.Msg, opts Options) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s|%s|%s|%d|%t|%t", NormalizeServer(server), opts.transport(), opts.Proxy, m.Opcode, m.RecursionDesired, m.CheckingDisabled)
+	for _, q := range m.Question {
+		fmt.Fprintf(&b, "|%s/%d/%d", strings.ToLower(q.Name), q.Qtype, q.Qclass)
+	}
+	if o := m.IsEdns0(); o != nil {
+		codes := make([]int, 0, len(o.Option))
+		for _, opt := range o.Option {
+			codes = append(codes, int(opt.Option()))
+		}
+		sort.Ints(codes)
+		fmt.Fprintf(&b, "|edns%d/%d/%t/%v", o.Version(), o.UDPSize(), o.Do(), codes)
+	}
+	return b.String()
+}
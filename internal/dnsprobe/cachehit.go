@@ -0,0 +1,126 @@
+package dnsprobe
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// CacheEstimate guesses which benchmark replies a resolver served from its
+// cache. A reply whose TTL is below the highest one seen in the run counted
+// down in a cache and is a hit. Otherwise, when latencies fall into a fast and
+// a slow group (at least twice and 1ms apart), the slow group are misses and
+// Threshold separates them. With neither signal nothing is classified.
+type CacheEstimate struct {
+	Hits      int
+	Misses    int
+	TTLHits   int
+	Threshold time.Duration
+}
+
+// Known reports whether any reply could be classified.
+func (e CacheEstimate) Known() bool {
+	return e.Hits+e.Misses > 0
+}
+
+// Ratio is the share of classified replies that were hits.
+func (e CacheEstimate) Ratio() float64 {
+	if !e.Known() {
+		return 0
+	}
+	return float64(e.Hits) / float64(e.Hits+e.Misses)
+}
+
+// cacheSample is a reply's latency and lowest answer TTL; at is when its
+// query was sent.
+type cacheSample struct {
+	at     time.Time
+	rtt    time.Duration
+	ttl    uint32
+	hasTTL bool
+}
+
+func sampleOf(r Result, at time.Time) cacheSample {
+	s := cacheSample{at: at, rtt: r.Timings.Total}
+	for i, a := range r.Answers {
+		if i == 0 || a.TTL < s.ttl {
+			s.ttl = a.TTL
+		}
+		s.hasTTL = true
+	}
+	return s
+}
+
+// maxTimedSamples bounds the cacheSamples a timed benchmark keeps for its
+// cache and TTL estimates.
+const maxTimedSamples = 4096
+
+// sampler collects cacheSamples, keeping at most limit of them when limit is
+// set: whenever it fills up, every other sample is dropped and only every
+// other one offered is kept from then on, so what remains stays spread
+// evenly over the run.
+type sampler struct {
+	limit   int
+	stride  int
+	seen    int
+	samples []cacheSample
+}
+
+func (s *sampler) add(c cacheSample) {
+	s.seen++
+	if s.stride > 1 && s.seen%s.stride != 0 {
+		return
+	}
+	s.samples = append(s.samples, c)
+	if s.limit > 0 && len(s.samples) >= s.limit {
+		kept := s.samples[:0]
+		for i := 1; i < len(s.samples); i += 2 {
+			kept = append(kept, s.samples[i])
+		}
+		s.samples, s.stride = kept, max(s.stride, 1)*2
+	}
+}
+
+func estimateCache(samples []cacheSample) CacheEstimate {
+	var e CacheEstimate
+	if len(samples) == 0 {
+		return e
+	}
+	var maxTTL uint32
+	for _, s := range samples {
+		if s.hasTTL {
+			maxTTL = max(maxTTL, s.ttl)
+		}
+	}
+
+	rtts := make([]time.Duration, len(samples))
+	for i, s := range samples {
+		rtts[i] = s.rtt
+	}
+	slices.Sort(rtts)
+	var best float64
+	for i := 1; i < len(rtts); i++ {
+		lo, hi := rtts[i-1], rtts[i]
+		if lo <= 0 || hi-lo < time.Millisecond {
+			continue
+		}
+		if ratio := float64(hi) / float64(lo); ratio >= 2 && ratio > best {
+			best = ratio
+			e.Threshold = time.Duration(math.Sqrt(float64(lo) * float64(hi)))
+		}
+	}
+
+	for _, s := range samples {
+		switch {
+		case s.hasTTL && s.ttl < maxTTL:
+			e.Hits++
+			e.TTLHits++
+		case e.Threshold == 0:
+		case s.rtt < e.Threshold:
+			e.Hits++
+		default:
+			e.Misses++
+		}
+	}
+	return e
+}
@@ -0,0 +1,128 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Calibration is the measurement noise of one target: how much each timing
+// phase varies between identical queries. Jitter, the gap between the median
+// and the 95th percentile, is the smallest difference worth reading into.
+type Calibration struct {
+	Target  string       `json:"target"`
+	Server  string       `json:"server"`
+	At      time.Time    `json:"at"`
+	Samples int          `json:"samples"`
+	Failed  int          `json:"failed"`
+	Phases  []PhaseNoise `json:"phases"`
+}
+
+type PhaseNoise struct {
+	Phase  string        `json:"phase"`
+	P50    time.Duration `json:"p50_ns"`
+	P95    time.Duration `json:"p95_ns"`
+	Jitter time.Duration `json:"jitter_ns"`
+}
+
+// Floor returns the jitter of phase, or 0 when it was not measured.
+func (c Calibration) Floor(phase string) time.Duration {
+	for _, p := range c.Phases {
+		if p.Phase == phase {
+			return p.Jitter
+		}
+	}
+	return 0
+}
+
+// LoopbackTarget is the Target of the in-process loopback server, the
+// floor every other measurement sits on.
+const LoopbackTarget = "loopback"
+
+// Calibrate sends n identical queries to server one after another and
+// measures how much every phase varies. A target that fails its first query
+// is given up on.
+func Calibrate(ctx context.Context, target, server string, opts Options, n int) Calibration {
+	c := Calibration{Target: target, Server: NormalizeServer(server), At: time.Now()}
+	phases := []struct {
+		name string
+		get  func(Timings) time.Duration
+		dist Distribution
+	}{
+		{name: "total", get: func(t Timings) time.Duration { return t.Total }},
+		{name: "dial", get: func(t Timings) time.Duration { return t.Dial }},
+		{name: "pack", get: func(t Timings) time.Duration { return t.Pack }},
+		{name: "write", get: func(t Timings) time.Duration { return t.Write }},
+		{name: "read", get: func(t Timings) time.Duration { return t.Read }},
+		{name: "unpack", get: func(t Timings) time.Duration { return t.Unpack }},
+		{name: "rtt(approx)", get: func(t Timings) time.Duration { return t.RTTApprox }},
+	}
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		r, err := Probe(ctx, server, "calibrate.dnsdoc.invalid", opts)
+		c.Samples++
+		if err != nil {
+			c.Failed++
+			if i == 0 {
+				break
+			}
+			continue
+		}
+		for j := range phases {
+			phases[j].dist.Add(phases[j].get(r.Timings))
+		}
+	}
+	for _, p := range phases {
+		if p.dist.Count() == 0 {
+			continue
+		}
+		pn := PhaseNoise{Phase: p.name, P50: p.dist.Quantile(0.50), P95: p.dist.Quantile(0.95)}
+		pn.Jitter = max(pn.P95-pn.P50, 0)
+		c.Phases = append(c.Phases, pn)
+	}
+	return c
+}
+
+// LoopbackServer answers every query at once from this process, so probing
+// it measures nothing but the machine: sockets, scheduling and packing.
+type LoopbackServer struct {
+	Addr string
+	srv  *dns.Server
+}
+
+// StartLoopbackServer listens on an ephemeral 127.0.0.1 port, over UDP or
+// TCP.
+func StartLoopbackServer(tcp bool) (*LoopbackServer, error) {
+	srv := &dns.Server{Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(req, dns.RcodeNameError)
+		_ = w.WriteMsg(m)
+	})}
+	var addr string
+	if tcp {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		srv.Listener, addr = l, l.Addr().String()
+	} else {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		srv.PacketConn, addr = pc, pc.LocalAddr().String()
+	}
+	started := make(chan error, 1)
+	srv.NotifyStartedFunc = func() { started <- nil }
+	go func() { started <- srv.ActivateAndServe() }()
+	if err := <-started; err != nil {
+		return nil, fmt.Errorf("loopback server: %w", err)
+	}
+	return &LoopbackServer{Addr: addr, srv: srv}, nil
+}
+
+func (s *LoopbackServer) Close() {
+	_ = s.srv.Shutdown()
+}
@@ -0,0 +1,186 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// CatalogVersion is the catalog zone schema version this tool understands
+// (RFC 9432).
+const CatalogVersion = "2"
+
+// CatalogMember is one member zone of a catalog zone: ID is its unique
+// label under zones, COO the catalog it is moving to (change of ownership)
+// and Ext its custom properties in presentation form. Serving holds its
+// SOA as the primary and then each consumer serves it, when asked for.
+type CatalogMember struct {
+	ID      string
+	Zone    string
+	Groups  []string
+	COO     string
+	Ext     []string
+	Serving []SerialObservation
+}
+
+// Behind reports whether consumer observation i serves an older serial than
+// the primary, the first observation.
+func (m CatalogMember) Behind(i int) bool {
+	p, s := m.Serving[0], m.Serving[i]
+	return p.HasSerial && s.HasSerial && serialOlder(s.Serial, p.Serial)
+}
+
+// Catalog is a catalog zone as one server transferred it. Problems are the
+// ways it breaks RFC 9432 that make consumers skip members or the whole
+// catalog.
+type Catalog struct {
+	Zone     string
+	Server   string
+	Serial   uint32
+	Version  string
+	Members  []CatalogMember
+	Problems []string
+}
+
+// InspectCatalog transfers the catalog zone from server and parses it. With
+// consumers, it also asks server and then each consumer for every member
+// zone's SOA, to see which of them serve it and at which serial, with up to
+// concurrency queries in flight.
+func InspectCatalog(ctx context.Context, server, zone string, consumers []string, opts Options, concurrency int) (Catalog, error) {
+	server = NormalizeServer(server)
+	rrs, err := Transfer(ctx, server, zone, opts)
+	if err != nil {
+		return Catalog{Zone: dns.Fqdn(zone), Server: server}, fmt.Errorf("transfer from %s: %w", server, err)
+	}
+	c, err := ParseCatalog(zone, rrs)
+	c.Server = server
+	if err != nil || len(consumers) == 0 {
+		return c, err
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range c.Members {
+		m := &c.Members[i]
+		m.Serving = append(m.Serving, SerialObservation{Server: server})
+		for _, s := range consumers {
+			m.Serving = append(m.Serving, SerialObservation{Server: NormalizeServer(s)})
+		}
+		for j := range m.Serving {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				o := &m.Serving[j]
+				querySerial(ctx, o, m.Zone, opts)
+				if o.Err == nil && !o.Authoritative {
+					o.Err = fmt.Errorf("answers without authority")
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	return c, nil
+}
+
+// ParseCatalog reads the members and properties of catalog zone zone from
+// its records. A missing or unsupported schema version is an error, since
+// consumers must not process such a catalog at all.
+func ParseCatalog(zone string, rrs []dns.RR) (Catalog, error) {
+	zone = strings.ToLower(dns.Fqdn(zone))
+	c := Catalog{Zone: zone}
+	zones := "zones." + zone
+	byID := map[string]*CatalogMember{}
+	member := func(id string) *CatalogMember {
+		if byID[id] == nil {
+			byID[id] = &CatalogMember{ID: id}
+		}
+		return byID[id]
+	}
+	var versions []string
+	ptrs := map[string]int{}
+
+	for _, rr := range rrs {
+		name := strings.ToLower(rr.Header().Name)
+		if soa, ok := rr.(*dns.SOA); ok && name == zone {
+			c.Serial = soa.Serial
+			continue
+		}
+		if name == "version."+zone {
+			if txt, ok := rr.(*dns.TXT); ok {
+				versions = append(versions, strings.Join(txt.Txt, ""))
+			}
+			continue
+		}
+		if !dns.IsSubDomain(zones, name) || name == zones {
+			continue
+		}
+		labels := dns.SplitDomainName(strings.TrimSuffix(name, "."+zones))
+		id := labels[len(labels)-1]
+		switch prop := strings.Join(labels[:len(labels)-1], "."); {
+		case prop == "":
+			if ptr, ok := rr.(*dns.PTR); ok {
+				ptrs[id]++
+				member(id).Zone = strings.ToLower(ptr.Ptr)
+			}
+		case prop == "group":
+			if txt, ok := rr.(*dns.TXT); ok {
+				m := member(id)
+				m.Groups = append(m.Groups, strings.Join(txt.Txt, ""))
+			}
+		case prop == "coo":
+			if ptr, ok := rr.(*dns.PTR); ok {
+				member(id).COO = strings.ToLower(ptr.Ptr)
+			}
+		case strings.HasSuffix(prop, ".ext"):
+			m := member(id)
+			rdata := strings.TrimPrefix(rr.String(), rr.Header().String())
+			m.Ext = append(m.Ext, fmt.Sprintf("%s %s %s", strings.TrimSuffix(prop, ".ext"), dns.TypeToString[rr.Header().Rrtype], rdata))
+		}
+	}
+
+	switch {
+	case len(versions) == 0:
+		return c, fmt.Errorf("%s has no version property; it is not a catalog zone", zone)
+	case len(versions) > 1:
+		return c, fmt.Errorf("%s has %d version records; consumers must not process it", zone, len(versions))
+	case versions[0] != CatalogVersion:
+		return c, fmt.Errorf("%s is catalog schema version %q; only %q is supported", zone, versions[0], CatalogVersion)
+	}
+	c.Version = versions[0]
+
+	owners := map[string][]string{}
+	for id, m := range byID {
+		switch {
+		case m.Zone == "":
+			c.Problems = append(c.Problems, fmt.Sprintf("properties for %s, which has no member PTR record", id))
+			continue
+		case ptrs[id] > 1:
+			c.Problems = append(c.Problems, fmt.Sprintf("%s has %d PTR records; consumers ignore it", id, ptrs[id]))
+		}
+		owners[m.Zone] = append(owners[m.Zone], id)
+		c.Members = append(c.Members, *m)
+	}
+	for z, ids := range owners {
+		if len(ids) > 1 {
+			slices.Sort(ids)
+			c.Problems = append(c.Problems, fmt.Sprintf("%s is listed more than once, as %s", z, strings.Join(ids, ", ")))
+		}
+	}
+	slices.Sort(c.Problems)
+	slices.SortFunc(c.Members, func(a, b CatalogMember) int {
+		if c := strings.Compare(a.Zone, b.Zone); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return c, nil
+}
@@ -0,0 +1,112 @@
+package dnsprobe
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ChainHop is one step of a CNAME chain: the name queried and either the
+// alias it points to or, at the end of the chain, the records of the type
+// asked for. TTL is the alias's, or the lowest of the final records.
+type ChainHop struct {
+	Name   string
+	Target string
+	TTL    uint32
+	RCode  string
+	Values []string
+	RTT    time.Duration
+	Err    error
+}
+
+// Chain is a CNAME chain followed one query per hop. Loop is set when an
+// alias points back to an earlier name, Truncated when the hop limit was
+// reached first.
+type Chain struct {
+	Domain    string
+	Hops      []ChainHop
+	Loop      bool
+	Truncated bool
+}
+
+// Aliases counts the CNAME hops.
+func (c Chain) Aliases() int {
+	n := 0
+	for _, h := range c.Hops {
+		if h.Target != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// TTL is how long the whole chain can be cached: the lowest TTL along it.
+func (c Chain) TTL() uint32 {
+	var ttl uint32
+	for i, h := range c.Hops {
+		if i == 0 || h.TTL < ttl {
+			ttl = h.TTL
+		}
+	}
+	return ttl
+}
+
+// Took sums the per-hop query times.
+func (c Chain) Took() time.Duration {
+	var d time.Duration
+	for _, h := range c.Hops {
+		d += h.RTT
+	}
+	return d
+}
+
+// FollowChain resolves name and then each alias it leads to with separate
+// queries to server, so every hop is timed on its own rather than hidden in
+// the resolver's single combined answer.
+func FollowChain(ctx context.Context, server, name string, opts Options, maxHops int) Chain {
+	c := Chain{Domain: name}
+	cur := dns.Fqdn(name)
+	seen := map[string]bool{strings.ToLower(cur): true}
+	for len(c.Hops) < maxHops {
+		r, err := Probe(ctx, server, cur, opts)
+		hop := ChainHop{Name: cur, RCode: r.RCode, RTT: r.Timings.Total, Err: err}
+		if err != nil {
+			c.Hops = append(c.Hops, hop)
+			return c
+		}
+
+		alias := false
+		for _, a := range r.Answers {
+			if a.Type == "CNAME" && strings.EqualFold(a.Name, cur) {
+				hop.Target, hop.TTL, alias = a.Value, a.TTL, true
+				break
+			}
+		}
+		if !alias {
+			want := dns.Type(opts.qtype()).String()
+			for _, a := range r.Answers {
+				if a.Type == want && strings.EqualFold(a.Name, cur) {
+					if len(hop.Values) == 0 || a.TTL < hop.TTL {
+						hop.TTL = a.TTL
+					}
+					hop.Values = append(hop.Values, a.Value)
+				}
+			}
+			c.Hops = append(c.Hops, hop)
+			return c
+		}
+
+		c.Hops = append(c.Hops, hop)
+		next := strings.ToLower(hop.Target)
+		if seen[next] {
+			c.Loop = true
+			return c
+		}
+		seen[next] = true
+		cur = hop.Target
+	}
+	c.Truncated = true
+	return c
+}
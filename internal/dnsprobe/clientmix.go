@@ -0,0 +1,277 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ClientProfile models how one OS's stub resolver turns a lookup into
+// queries. Parallel sends A and AAAA together, otherwise one after the other
+// (AAAA first when AAAAFirst is set). Each query is retried on timeout, with
+// Timeouts giving how long every attempt waits. Unqualified names go through
+// the search list; SearchDotted also tries it for names containing a dot
+// once the name itself fails.
+type ClientProfile struct {
+	Name         string
+	Parallel     bool
+	AAAAFirst    bool
+	Timeouts     []time.Duration
+	SearchDotted bool
+}
+
+// ClientProfiles are the built-in presets. They follow each platform's
+// default stub behavior closely enough to reproduce its query pattern, not
+// every detail of it.
+var ClientProfiles = map[string]ClientProfile{
+	// Windows DNS Client: A and AAAA together, retried after 1s, 1s, 2s and
+	// 4s; search suffixes only for single-label names.
+	"windows": {Name: "windows", Parallel: true, Timeouts: []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second}},
+	// mDNSResponder: A and AAAA together, retry interval doubling from 1s.
+	"macos": {Name: "macos", Parallel: true, Timeouts: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}, SearchDotted: true},
+	// Bionic getaddrinfo: A, then AAAA, each with a 5s timeout and one retry.
+	"android": {Name: "android", Timeouts: []time.Duration{5 * time.Second, 5 * time.Second}, SearchDotted: true},
+	// iOS: like macOS, with AAAA sent ahead of A for Happy Eyeballs.
+	"ios": {Name: "ios", Parallel: true, AAAAFirst: true, Timeouts: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second}, SearchDotted: true},
+}
+
+// ClientShare is one profile's weight in a mix.
+type ClientShare struct {
+	Profile ClientProfile
+	Weight  int
+}
+
+// ParseClientMix parses "windows=40,macos=20,..." into shares, in the order
+// given.
+func ParseClientMix(spec string) ([]ClientShare, error) {
+	var mix []ClientShare
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, weight, ok := strings.Cut(part, "=")
+		p, known := ClientProfiles[strings.ToLower(strings.TrimSpace(name))]
+		if !known {
+			return nil, fmt.Errorf("unknown client profile %q (want %s)", name, strings.Join(ClientProfileNames(), ", "))
+		}
+		w := 1
+		if ok {
+			var err error
+			if w, err = strconv.Atoi(strings.TrimSpace(weight)); err != nil || w < 1 {
+				return nil, fmt.Errorf("invalid weight in %q", part)
+			}
+		}
+		mix = append(mix, ClientShare{Profile: p, Weight: w})
+	}
+	if len(mix) == 0 {
+		return nil, fmt.Errorf("empty client mix")
+	}
+	return mix, nil
+}
+
+// ClientMixConfig describes a mixed-client workload: Rate lookups per
+// second, each made by a profile picked by weight, for Duration.
+type ClientMixConfig struct {
+	Server   string
+	Domains  []string
+	Search   SearchConfig
+	Options  Options
+	Mix      []ClientShare
+	Rate     float64
+	Duration time.Duration
+}
+
+// ClientStats summarizes one profile's lookups: Answered ones got addresses,
+// Negative ones a reply without any, and Failed ones ran out of retries or
+// hit an error. Queries counts every packet sent, retries included, so
+// Queries/Lookups is the load one lookup puts on the resolver. Latencies
+// cover the lookups that did not fail.
+type ClientStats struct {
+	Profile  string
+	Lookups  int
+	Answered int
+	Negative int
+	Failed   int
+	Queries  int
+	Retries  int
+	P50      time.Duration
+	P95      time.Duration
+	Max      time.Duration
+	latency  Distribution
+}
+
+// RunClientMix runs the workload and returns stats per profile, in mix
+// order.
+func RunClientMix(ctx context.Context, cfg ClientMixConfig) []ClientStats {
+	probeCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	var mu sync.Mutex
+	stats := make([]ClientStats, len(cfg.Mix))
+	total := 0
+	for i, s := range cfg.Mix {
+		stats[i].Profile = s.Profile.Name
+		total += s.Weight
+	}
+	// Smooth weighted round robin interleaves the profiles, so even a short
+	// run sees every one of them in proportion.
+	credit := make([]int, len(cfg.Mix))
+	pick := func() int {
+		best := 0
+		for i, s := range cfg.Mix {
+			credit[i] += s.Weight
+			if credit[i] > credit[best] {
+				best = i
+			}
+		}
+		credit[best] -= total
+		return best
+	}
+
+	var wg sync.WaitGroup
+	tick := time.NewTicker(rateInterval(cfg.Rate))
+	defer tick.Stop()
+	for n := 0; ; n++ {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			for i := range stats {
+				st := &stats[i]
+				st.P50, st.P95, st.Max = st.latency.Quantile(0.50), st.latency.Quantile(0.95), st.latency.Max()
+			}
+			return stats
+		case <-tick.C:
+		}
+		idx, name := pick(), cfg.Domains[n%len(cfg.Domains)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l := clientLookup(probeCtx, cfg, cfg.Mix[idx].Profile, name)
+			if l.failed && probeCtx.Err() != nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			st := &stats[idx]
+			st.Lookups++
+			st.Queries += l.queries
+			st.Retries += l.retries
+			switch {
+			case l.answered:
+				st.Answered++
+			case l.failed:
+				st.Failed++
+				return
+			default:
+				st.Negative++
+			}
+			st.latency.Add(l.took)
+		}()
+	}
+}
+
+type lookupOutcome struct {
+	answered bool
+	failed   bool
+	queries  int
+	retries  int
+	took     time.Duration
+}
+
+// clientLookup resolves name the way p would: candidate by candidate, both
+// address families per candidate, stopping at the first that has addresses.
+func clientLookup(ctx context.Context, cfg ClientMixConfig, p ClientProfile, name string) lookupOutcome {
+	start := time.Now()
+	var out lookupOutcome
+	cands := []string{dns.Fqdn(name)}
+	if !strings.HasSuffix(name, ".") && (!strings.Contains(name, ".") || p.SearchDotted) {
+		cands = cfg.Search.Candidates(name)
+	}
+	types := []uint16{dns.TypeA, dns.TypeAAAA}
+	if p.AAAAFirst {
+		types[0], types[1] = types[1], types[0]
+	}
+	for _, cand := range cands {
+		results := make([]clientQuery, len(types))
+		if p.Parallel {
+			var wg sync.WaitGroup
+			for i, t := range types {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					results[i] = clientQueryRetry(ctx, cfg, p, cand, t)
+				}()
+			}
+			wg.Wait()
+		} else {
+			for i, t := range types {
+				results[i] = clientQueryRetry(ctx, cfg, p, cand, t)
+			}
+		}
+		done := false
+		for _, q := range results {
+			out.queries += q.sent
+			out.retries += q.sent - 1
+			switch {
+			case q.addrs > 0:
+				out.answered, done = true, true
+			case q.err != nil:
+				out.failed, done = true, true
+			case q.rcode != "NXDOMAIN" && q.rcode != "NOERROR" && q.rcode != "SERVFAIL":
+				// Like ProbeSearch, only NXDOMAIN, no data and SERVFAIL
+				// move on to the next candidate.
+				done = true
+			}
+		}
+		if done {
+			break
+		}
+	}
+	out.took = time.Since(start)
+	return out
+}
+
+type clientQuery struct {
+	sent  int
+	rcode string
+	addrs int
+	err   error
+}
+
+func clientQueryRetry(ctx context.Context, cfg ClientMixConfig, p ClientProfile, name string, qtype uint16) clientQuery {
+	var q clientQuery
+	opts := cfg.Options
+	opts.Type = qtype
+	for _, t := range p.Timeouts {
+		opts.Timeout = t
+		q.sent++
+		r, err := Probe(ctx, cfg.Server, name, opts)
+		q.err = err
+		if err == nil {
+			q.rcode, q.addrs = r.RCode, len(r.Addrs())
+			return q
+		}
+		if !IsTimeout(err) {
+			return q
+		}
+	}
+	return q
+}
+
+// ClientProfileNames lists the presets in a stable order.
+func ClientProfileNames() []string {
+	names := make([]string, 0, len(ClientProfiles))
+	for n := range ClientProfiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,169 @@
+package dnsprobe
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type ComplianceVerdict string
+
+const (
+	VerdictStrict     ComplianceVerdict = "strict"
+	VerdictPermissive ComplianceVerdict = "permissive"
+	VerdictSilent     ComplianceVerdict = "silent"
+	VerdictError      ComplianceVerdict = "error"
+)
+
+type ComplianceCheck struct {
+	Name   string
+	Desc   string
+	Expect []int
+	Build  func(qname string) *dns.Msg
+}
+
+type ComplianceResult struct {
+	Check   ComplianceCheck
+	RCode   string
+	Answers int
+	RTT     time.Duration
+	Verdict ComplianceVerdict
+	Err     error
+}
+
+func opcodeQuery(opcode int, qtype uint16) func(string) *dns.Msg {
+	return func(qname string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(dns.Fqdn(qname), qtype)
+		m.Opcode = opcode
+		m.RecursionDesired = opcode == dns.OpcodeQuery
+		return m
+	}
+}
+
+func rareType(qtype uint16) func(string) *dns.Msg {
+	return func(qname string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(dns.Fqdn(qname), qtype)
+		return m
+	}
+}
+
+// questionCount builds a query carrying n copies of the A question for
+// qname (n=0 gives an empty question section).
+func questionCount(n int) func(string) *dns.Msg {
+	return func(qname string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(dns.Fqdn(qname), dns.TypeA)
+		m.RecursionDesired = true
+		q := m.Question[0]
+		m.Question = nil
+		for i := 0; i < n; i++ {
+			if i == 1 {
+				q.Qtype = dns.TypeAAAA
+			}
+			m.Question = append(m.Question, q)
+		}
+		return m
+	}
+}
+
+var (
+	rejectOpcode = []int{dns.RcodeNotImplemented, dns.RcodeRefused, dns.RcodeFormatError}
+	rejectType   = []int{dns.RcodeNotImplemented, dns.RcodeRefused, dns.RcodeFormatError}
+)
+
+// ComplianceChecks are the unusual-traffic probes run by RunCompliance. A
+// server that answers with one of Expect rejects the traffic cleanly; any
+// other rcode is permissive and no reply at all suggests something on the
+// path drops or chokes on it.
+var ComplianceChecks = []ComplianceCheck{
+	{Name: "opcode QUERY", Desc: "baseline A query", Expect: []int{dns.RcodeSuccess, dns.RcodeNameError}, Build: opcodeQuery(dns.OpcodeQuery, dns.TypeA)},
+	{Name: "opcode IQUERY", Desc: "obsolete inverse query (RFC 3425)", Expect: rejectOpcode, Build: opcodeQuery(dns.OpcodeIQuery, dns.TypeA)},
+	{Name: "opcode STATUS", Desc: "server status request", Expect: rejectOpcode, Build: opcodeQuery(dns.OpcodeStatus, dns.TypeA)},
+	{Name: "opcode 3", Desc: "unassigned opcode", Expect: rejectOpcode, Build: opcodeQuery(3, dns.TypeA)},
+	{Name: "opcode NOTIFY", Desc: "zone change notification (RFC 1996)", Expect: rejectOpcode, Build: opcodeQuery(dns.OpcodeNotify, dns.TypeSOA)},
+	{Name: "opcode UPDATE", Desc: "dynamic update (RFC 2136)", Expect: rejectOpcode, Build: opcodeQuery(dns.OpcodeUpdate, dns.TypeSOA)},
+	{Name: "opcode DSO", Desc: "stateful operations over UDP (RFC 8490)", Expect: rejectOpcode, Build: opcodeQuery(6, dns.TypeA)},
+	{Name: "opcode 15", Desc: "unassigned opcode", Expect: rejectOpcode, Build: opcodeQuery(15, dns.TypeA)},
+	{Name: "qtype ANY", Desc: "ANY query (RFC 8482 minimal answers)", Expect: []int{dns.RcodeSuccess, dns.RcodeNotImplemented, dns.RcodeRefused}, Build: rareType(dns.TypeANY)},
+	{Name: "qtype AXFR", Desc: "zone transfer over UDP", Expect: rejectType, Build: rareType(dns.TypeAXFR)},
+	{Name: "qtype IXFR", Desc: "incremental transfer without SOA", Expect: rejectType, Build: rareType(dns.TypeIXFR)},
+	{Name: "qtype MAILB", Desc: "obsolete meta type", Expect: rejectType, Build: rareType(dns.TypeMAILB)},
+	{Name: "qtype MAILA", Desc: "obsolete meta type", Expect: rejectType, Build: rareType(dns.TypeMAILA)},
+	{Name: "qtype NULL", Desc: "experimental NULL type", Expect: []int{dns.RcodeSuccess, dns.RcodeNameError, dns.RcodeNotImplemented}, Build: rareType(dns.TypeNULL)},
+	{Name: "qtype TYPE0", Desc: "reserved type 0", Expect: append([]int{dns.RcodeSuccess, dns.RcodeNameError}, rejectType...), Build: rareType(0)},
+	{Name: "qtype TYPE65280", Desc: "private-use type", Expect: []int{dns.RcodeSuccess, dns.RcodeNameError}, Build: rareType(65280)},
+	{Name: "qdcount 0", Desc: "no question; load balancers often drop or crash on it", Expect: []int{dns.RcodeFormatError, dns.RcodeNotImplemented, dns.RcodeRefused}, Build: questionCount(0)},
+	{Name: "qdcount 2", Desc: "A and AAAA in one message; usually FORMERR, mishandled by some balancers", Expect: []int{dns.RcodeFormatError, dns.RcodeNotImplemented, dns.RcodeRefused}, Build: questionCount(2)},
+}
+
+// Code is a stable identifier for a non-strict result, such as
+// "compliance.opcode_notify.permissive"; it is empty for strict results.
+func (r ComplianceResult) Code() string {
+	if r.Verdict == VerdictStrict {
+		return ""
+	}
+	check := strings.ToLower(strings.ReplaceAll(r.Check.Name, " ", "_"))
+	return "compliance." + check + "." + string(r.Verdict)
+}
+
+// Remediation says what to change for a non-strict result, or nil. Records
+// are the questions the check sent for qname.
+func (r ComplianceResult) Remediation(qname string) *Remediation {
+	var records []string
+	for _, q := range r.Check.Build(qname).Question {
+		records = append(records, q.Name+" "+dns.TypeToString[q.Qtype])
+	}
+	switch r.Verdict {
+	case VerdictPermissive:
+		want := make([]string, 0, len(r.Check.Expect))
+		for _, rc := range r.Check.Expect {
+			want = append(want, dns.RcodeToString[rc])
+		}
+		return &Remediation{
+			Action:    "reject-unsupported-traffic",
+			Records:   records,
+			Suggested: map[string]string{"rcode": strings.Join(want, "|")},
+		}
+	case VerdictSilent:
+		return &Remediation{Action: "investigate-path-drops", Records: records}
+	}
+	return nil
+}
+
+func RunCompliance(ctx context.Context, server, qname string, opts Options, checks []ComplianceCheck) []ComplianceResult {
+	out := make([]ComplianceResult, 0, len(checks))
+	for _, c := range checks {
+		out = append(out, runComplianceCheck(ctx, server, qname, opts, c))
+	}
+	return out
+}
+
+func runComplianceCheck(ctx context.Context, server, qname string, opts Options, c ComplianceCheck) ComplianceResult {
+	res := ComplianceResult{Check: c}
+
+	resp, rtt, err := Exchange(ctx, server, c.Build(qname), opts)
+	res.RTT = rtt
+	if err != nil {
+		res.Err = err
+		res.Verdict = VerdictError
+		if IsTimeout(err) {
+			res.Verdict = VerdictSilent
+		}
+		return res
+	}
+
+	res.RCode = dns.RcodeToString[resp.Rcode]
+	res.Answers = len(resp.Answer)
+	res.Verdict = VerdictPermissive
+	for _, rc := range c.Expect {
+		if resp.Rcode == rc {
+			res.Verdict = VerdictStrict
+			break
+		}
+	}
+	return res
+}
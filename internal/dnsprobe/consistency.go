@@ -0,0 +1,154 @@
+package dnsprobe
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Consistency summarises what concurrent replies to the same question agreed
+// on. Several answer sets can be legitimate (round-robin pools, geo load
+// balancing), but a rare one, replies carrying the wrong message ID or
+// packets from an address other than the server suggest spoofing or
+// interception on the path.
+type Consistency struct {
+	Replies      int
+	AnswerSets   []AnswerSet // most common first
+	IDMismatches int
+	SentIDs      []int          // in reply order
+	EchoedIDs    []int          // in reply order
+	Strays       map[string]int // source address -> packets
+}
+
+type AnswerSet struct {
+	RCode   string
+	Answers []string // sorted
+	Count   int
+}
+
+func (a AnswerSet) String() string {
+	if len(a.Answers) == 0 {
+		return a.RCode + " (no answers)"
+	}
+	return a.RCode + " " + strings.Join(a.Answers, ",")
+}
+
+func (c *Consistency) add(r Result) {
+	c.Replies++
+	if r.MsgID != r.QueryID {
+		c.IDMismatches++
+	}
+	c.SentIDs = append(c.SentIDs, int(r.QueryID))
+	c.EchoedIDs = append(c.EchoedIDs, int(r.MsgID))
+	c.addStrays(r.Strays)
+
+	vals := make([]string, 0, len(r.Answers))
+	for _, a := range r.Answers {
+		vals = append(vals, a.Value)
+	}
+	sort.Strings(vals)
+	set := AnswerSet{RCode: r.RCode, Answers: vals}
+	for i := range c.AnswerSets {
+		if c.AnswerSets[i].String() == set.String() {
+			c.AnswerSets[i].Count++
+			return
+		}
+	}
+	set.Count = 1
+	c.AnswerSets = append(c.AnswerSets, set)
+}
+
+// addStrays is also called for failed probes: a spoofed packet that was
+// dropped still counts.
+func (c *Consistency) addStrays(strays []string) {
+	for _, s := range strays {
+		if c.Strays == nil {
+			c.Strays = map[string]int{}
+		}
+		c.Strays[s]++
+	}
+}
+
+func (c *Consistency) sort() {
+	sort.SliceStable(c.AnswerSets, func(i, j int) bool { return c.AnswerSets[i].Count > c.AnswerSets[j].Count })
+}
+
+// Anomalies describes everything in c that looks like tampering; it is empty
+// when every reply agreed and came from the server with the right ID.
+func (c Consistency) Anomalies() []string {
+	var out []string
+	if c.IDMismatches > 0 {
+		out = append(out, fmt.Sprintf("%d of %d replies carried a message ID other than their query's", c.IDMismatches, c.Replies))
+		// Our IDs are random, so predictable echoed ones were put there
+		// by something on the path.
+		if s := SpreadOf(c.EchoedIDs); s.N >= 2 && PortRating(s) == "POOR" {
+			out = append(out, fmt.Sprintf("the rewritten IDs are predictable (%d unique of %d, stddev %.0f, %.0f%% sequential): a middlebox or stub makes spoofed replies easy to match",
+				s.Unique, s.N, s.StdDev, s.Sequential*100))
+		}
+	}
+	addrs := make([]string, 0, len(c.Strays))
+	for a := range c.Strays {
+		addrs = append(addrs, a)
+	}
+	sort.Strings(addrs)
+	for _, a := range addrs {
+		out = append(out, fmt.Sprintf("%d packets from %s, which is not the server", c.Strays[a], a))
+	}
+	if len(c.AnswerSets) > 1 {
+		rare := c.AnswerSets[len(c.AnswerSets)-1]
+		out = append(out, fmt.Sprintf("%d distinct answer sets; the rarest (%s) was seen %d of %d times",
+			len(c.AnswerSets), rare, rare.Count, c.Replies))
+	}
+	return out
+}
+
+// strayConn is an unconnected UDP socket posing as a connected one. Read
+// returns only packets from remote and records the source of any others,
+// which a connected socket would have silently dropped.
+type strayConn struct {
+	net.PacketConn
+	remote *net.UDPAddr
+	strays []string
+}
+
+func (o Options) listenUnconnected(network, server string) (*strayConn, error) {
+	remote, err := net.ResolveUDPAddr(network, server)
+	if err != nil {
+		return nil, err
+	}
+	local := &net.UDPAddr{Port: o.SourcePort}
+	if o.Source != "" {
+		ip, err := sourceIP(o.Source, network, server)
+		if err != nil {
+			return nil, err
+		}
+		local = &net.UDPAddr{IP: ip.IP, Zone: ip.Zone, Port: o.SourcePort}
+	}
+	pc, err := net.ListenUDP(network, local)
+	if err != nil {
+		return nil, err
+	}
+	return &strayConn{PacketConn: pc, remote: remote}, nil
+}
+
+func (c *strayConn) Write(b []byte) (int, error) {
+	return c.WriteTo(b, c.remote)
+}
+
+func (c *strayConn) Read(b []byte) (int, error) {
+	for {
+		n, from, err := c.ReadFrom(b)
+		if err != nil {
+			return n, err
+		}
+		if u, ok := from.(*net.UDPAddr); ok && u.IP.Equal(c.remote.IP) && u.Port == c.remote.Port {
+			return n, nil
+		}
+		c.strays = append(c.strays, from.String())
+	}
+}
+
+func (c *strayConn) RemoteAddr() net.Addr {
+	return c.remote
+}
@@ -0,0 +1,200 @@
+package dnsprobe
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DDRName is the special-use name resolvers answer SVCB queries for to
+// designate their encrypted endpoints (RFC 9462).
+const DDRName = "_dns.resolver.arpa."
+
+// DesignatedResolver is one encrypted endpoint a resolver designates. Each
+// SVCB record yields one per protocol its ALPN list names: "dot", "doh" or
+// "doq". Addrs are its host:port addresses, from the address hints or else
+// looked up through the resolver. Verified is set when its certificate
+// covers the resolver's own IP address, which clients require before they
+// upgrade (RFC 9462, section 4.2); VerifyErr says why not.
+type DesignatedResolver struct {
+	Protocol  string
+	Target    string
+	Priority  uint16
+	ALPN      []string
+	Port      uint16
+	DoHPath   string
+	Addrs     []string
+	Verified  bool
+	VerifyErr error
+}
+
+// URL is the DNS-over-HTTPS endpoint of a "doh" designation.
+func (d DesignatedResolver) URL() string {
+	path, _, _ := strings.Cut(d.DoHPath, "{")
+	return "https://" + net.JoinHostPort(strings.TrimSuffix(d.Target, "."), strconv.Itoa(int(d.Port))) + path
+}
+
+// DiscoverDesignated asks server for the SVCB records of DDRName and
+// verifies each DoT and DoH designation it returns. A server addressed by
+// name rather than IP cannot be verified.
+func DiscoverDesignated(ctx context.Context, server string, opts Options) ([]DesignatedResolver, error) {
+	server = NormalizeServer(server)
+	host, _, _ := net.SplitHostPort(server)
+	m := new(dns.Msg)
+	m.SetQuestion(DDRName, dns.TypeSVCB)
+	resp, _, err := Exchange(ctx, server, m, opts)
+	if err == nil && resp.Truncated {
+		tcp := opts
+		tcp.TCP = true
+		resp, _, err = Exchange(ctx, server, m, tcp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s SVCB: %s", DDRName, dns.RcodeToString[resp.Rcode])
+	}
+
+	var out []DesignatedResolver
+	for _, rr := range resp.Answer {
+		s, ok := rr.(*dns.SVCB)
+		if !ok || s.Priority == 0 {
+			continue
+		}
+		base := DesignatedResolver{Target: s.Target, Priority: s.Priority}
+		var hints []string
+		for _, kv := range s.Value {
+			switch kv := kv.(type) {
+			case *dns.SVCBAlpn:
+				base.ALPN = kv.Alpn
+			case *dns.SVCBPort:
+				base.Port = kv.Port
+			case *dns.SVCBDoHPath:
+				base.DoHPath = kv.Template
+			case *dns.SVCBIPv4Hint:
+				for _, ip := range kv.Hint {
+					hints = append(hints, ip.String())
+				}
+			case *dns.SVCBIPv6Hint:
+				for _, ip := range kv.Hint {
+					hints = append(hints, ip.String())
+				}
+			}
+		}
+		if len(hints) == 0 {
+			for _, a := range nameserverAddrs(ctx, server, base.Target, opts) {
+				ip, _, _ := net.SplitHostPort(a)
+				hints = append(hints, ip)
+			}
+		}
+		for _, proto := range ddrProtocols(base.ALPN) {
+			d := base
+			if d.Port == 0 {
+				d.Port = 853
+				if proto == "doh" {
+					d.Port = 443
+				}
+			}
+			for _, ip := range hints {
+				d.Addrs = append(d.Addrs, net.JoinHostPort(ip, strconv.Itoa(int(d.Port))))
+			}
+			d.Protocol = proto
+			d.VerifyErr = verifyDesignated(ctx, d, host, opts)
+			d.Verified = d.VerifyErr == nil
+			out = append(out, d)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b DesignatedResolver) int { return int(a.Priority) - int(b.Priority) })
+	return out, nil
+}
+
+// ddrProtocols maps an ALPN list to the encrypted DNS protocols it names.
+func ddrProtocols(alpn []string) []string {
+	var out []string
+	add := func(p string) {
+		if !slices.Contains(out, p) {
+			out = append(out, p)
+		}
+	}
+	for _, a := range alpn {
+		switch {
+		case a == "dot":
+			add("dot")
+		case a == "doq":
+			add("doq")
+		case a == "h2" || a == "h3" || strings.HasPrefix(a, "http/"):
+			add("doh")
+		}
+	}
+	return out
+}
+
+// verifyDesignated connects to d over TLS, validates its certificate for
+// its own name and checks that it also covers resolverIP.
+func verifyDesignated(ctx context.Context, d DesignatedResolver, resolverIP string, opts Options) error {
+	switch {
+	case net.ParseIP(resolverIP) == nil:
+		return fmt.Errorf("the resolver is addressed by name, so the designation cannot be verified")
+	case d.Protocol == "doq":
+		return fmt.Errorf("DNS over QUIC is not supported")
+	case len(d.Addrs) == 0:
+		return fmt.Errorf("no address for %s", d.Target)
+	case d.Protocol == "doh" && d.DoHPath == "":
+		return fmt.Errorf("no dohpath parameter")
+	}
+	ctx, cancel := probeContext(ctx, opts.Timeout)
+	defer cancel()
+	raw, _, err := opts.dial(ctx, opts.network("tcp"), d.Addrs[0])
+	if err != nil {
+		return ctxError(ctx, err)
+	}
+	defer raw.Close()
+	defer bindConn(ctx, raw)()
+	alpn := []string{"dot"}
+	if d.Protocol == "doh" {
+		alpn = []string{"h2", "http/1.1"}
+	}
+	conn := tls.Client(raw, &tls.Config{ServerName: strings.TrimSuffix(d.Target, "."), NextProtos: alpn})
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return ctxError(ctx, err)
+	}
+	if err := conn.ConnectionState().PeerCertificates[0].VerifyHostname(resolverIP); err != nil {
+		return fmt.Errorf("certificate does not cover %s", resolverIP)
+	}
+	return nil
+}
+
+// ProbeDesignated sends count queries for the root NS set to the first of
+// d's addresses, over DoT or DoH, and returns their round-trip times. A
+// Protocol of "do53" asks the same address over plain DNS, as a baseline.
+func ProbeDesignated(ctx context.Context, d DesignatedResolver, count int, opts Options) (*Distribution, error) {
+	var dist Distribution
+	for i := 0; i < count; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion(".", dns.TypeNS)
+		var rtt time.Duration
+		var err error
+		switch {
+		case d.Protocol == "do53" && len(d.Addrs) > 0:
+			_, rtt, err = Exchange(ctx, d.Addrs[0], m, opts)
+		case d.Protocol == "dot" && len(d.Addrs) > 0:
+			_, rtt, err = exchangeTLS(ctx, d.Addrs[0], m, opts, strings.TrimSuffix(d.Target, "."))
+		case d.Protocol == "doh" && d.DoHPath != "" && len(d.Addrs) > 0:
+			_, rtt, err = exchangeDoH(ctx, d.URL(), d.Addrs[0], m, opts)
+		default:
+			return nil, fmt.Errorf("%s endpoints cannot be probed", d.Protocol)
+		}
+		if err != nil {
+			return &dist, err
+		}
+		dist.Add(rtt)
+	}
+	return &dist, nil
+}
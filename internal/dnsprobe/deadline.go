@@ -0,0 +1,44 @@
+package dnsprobe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// probeContext bounds ctx by one query's timeout, so that dialing and every
+// later phase draw on a single budget instead of each phase starting its own.
+func probeContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// bindConn applies ctx's deadline to conn and, when ctx ends early, moves
+// the deadline into the past so blocked reads and writes return at once.
+// Call the returned stop before clearing or changing the deadline.
+func bindConn(ctx context.Context, conn net.Conn) (stop func() bool) {
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	return context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+}
+
+// ctxError reports a cancelled ctx as such rather than as the I/O timeout the
+// cancellation caused. Expired deadlines stay timeouts, i.e. lost queries.
+func ctxError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err()
+	}
+	return err
+}
+
+// CutShort reports whether a query failed because ctx, the run's context,
+// ended rather than because of the server: an interrupt, or a deadline on
+// the whole run such as --max-runtime. Such queries count neither as
+// answered nor as lost.
+func CutShort(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() != nil
+}
@@ -0,0 +1,235 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Referral is what one parent nameserver hands out for the child zone: the
+// NS set and any glue addresses (host:53) by nameserver name.
+type Referral struct {
+	Host string
+	Addr string
+	NS   []string
+	Glue map[string][]string
+	RTT  time.Duration
+	Err  error
+}
+
+// NSProbe is one child nameserver address asked for the zone's SOA and NS
+// set directly. FromGlue and FromDNS say where the address came from: the
+// parent's glue, resolving the nameserver name, or both.
+type NSProbe struct {
+	Host          string
+	Addr          string
+	FromGlue      bool
+	FromDNS       bool
+	Authoritative bool
+	RCode         string
+	Serial        uint32
+	NS            []string
+	RTT           time.Duration
+	Err           error
+}
+
+// Delegation gathers what is needed to audit a zone's delegation: the
+// parent's referrals, the nameserver addresses as DNS resolves them and the
+// answers of every child nameserver.
+type Delegation struct {
+	Zone      string
+	Parent    string
+	Referrals []Referral
+	Resolved  map[string][]string
+	Servers   []NSProbe
+}
+
+// ParentNS is the union of the NS sets the parent's nameservers refer to.
+func (d Delegation) ParentNS() []string {
+	var all []string
+	for _, r := range d.Referrals {
+		all = append(all, r.NS...)
+	}
+	return sortedSet(all)
+}
+
+// ChildNS is the union of the NS sets the child's nameservers serve.
+func (d Delegation) ChildNS() []string {
+	var all []string
+	for _, s := range d.Servers {
+		if s.Authoritative {
+			all = append(all, s.NS...)
+		}
+	}
+	return sortedSet(all)
+}
+
+// Glue returns the union of the glue addresses the parents gave for host.
+func (d Delegation) Glue(host string) []string {
+	var all []string
+	for _, r := range d.Referrals {
+		all = append(all, r.Glue[host]...)
+	}
+	return sortedSet(all)
+}
+
+// InBailiwick reports whether host lies inside the zone, so resolvers
+// cannot find its address without glue.
+func (d Delegation) InBailiwick(host string) bool {
+	return dns.IsSubDomain(d.Zone, host)
+}
+
+// AuditDelegation finds zone's parent through resolver, asks every parent
+// nameserver for the referral and then every address of every nameserver
+// named by the parent or the child for the zone's SOA and NS set. Glue and
+// resolved addresses of a family opts cannot reach are not probed.
+func AuditDelegation(ctx context.Context, resolver, zone string, opts Options) (Delegation, error) {
+	d := Delegation{Zone: strings.ToLower(dns.Fqdn(zone)), Resolved: map[string][]string{}}
+	labels := dns.SplitDomainName(d.Zone)
+	if len(labels) == 0 {
+		return d, fmt.Errorf("the root zone has no parent")
+	}
+	parent, hosts, err := zoneNameservers(ctx, resolver, dns.Fqdn(strings.Join(labels[1:], ".")), opts)
+	if err != nil {
+		return d, fmt.Errorf("parent zone: %w", err)
+	}
+	d.Parent = parent
+
+	d.Referrals = make([]Referral, len(hosts))
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.Referrals[i] = askReferral(ctx, resolver, h, d.Zone, opts)
+		}()
+	}
+	wg.Wait()
+
+	// The child's NS set is only known once its servers answer, so the
+	// names it adds are probed in a second round.
+	probed := map[string]bool{}
+	for round := 0; round < 2; round++ {
+		var names []string
+		if round == 0 {
+			names = d.ParentNS()
+		} else {
+			names = d.ChildNS()
+		}
+		var probes []NSProbe
+		for _, h := range names {
+			if probed[h] {
+				continue
+			}
+			probed[h] = true
+			d.Resolved[h] = nameserverAddrs(ctx, resolver, h, opts)
+			glue := slices.DeleteFunc(d.Glue(h), func(a string) bool { return !opts.Reaches(a) })
+			probes = append(probes, nsProbes(h, glue, d.Resolved[h])...)
+		}
+		for i := range probes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				probeChildNS(ctx, &probes[i], d.Zone, opts)
+			}()
+		}
+		wg.Wait()
+		d.Servers = append(d.Servers, probes...)
+	}
+	return d, nil
+}
+
+func askReferral(ctx context.Context, resolver, host, zone string, opts Options) Referral {
+	r := Referral{Host: host, Glue: map[string][]string{}}
+	addrs := nameserverAddrs(ctx, resolver, host, opts)
+	if len(addrs) == 0 {
+		r.Err = errNoNSAddress
+		return r
+	}
+	r.Addr = addrs[0]
+	m := new(dns.Msg)
+	m.SetQuestion(zone, dns.TypeNS)
+	m.RecursionDesired = false
+	resp, rtt, err := Exchange(ctx, r.Addr, m, opts)
+	r.RTT = rtt
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	// A parent that also serves the child answers with authority instead of
+	// referring.
+	for _, rr := range append(resp.Answer, resp.Ns...) {
+		if ns, ok := rr.(*dns.NS); ok && strings.EqualFold(ns.Hdr.Name, zone) {
+			r.NS = append(r.NS, strings.ToLower(ns.Ns))
+		}
+	}
+	r.NS = sortedSet(r.NS)
+	for _, rr := range resp.Extra {
+		name := strings.ToLower(rr.Header().Name)
+		switch a := rr.(type) {
+		case *dns.A:
+			r.Glue[name] = append(r.Glue[name], net.JoinHostPort(a.A.String(), "53"))
+		case *dns.AAAA:
+			r.Glue[name] = append(r.Glue[name], net.JoinHostPort(a.AAAA.String(), "53"))
+		}
+	}
+	if len(r.NS) == 0 {
+		r.Err = fmt.Errorf("no NS records for %s in the reply (%s)", zone, dns.RcodeToString[resp.Rcode])
+	}
+	return r
+}
+
+func nsProbes(host string, glue, resolved []string) []NSProbe {
+	var out []NSProbe
+	for _, a := range sortedSet(append(slices.Clone(glue), resolved...)) {
+		out = append(out, NSProbe{Host: host, Addr: a, FromGlue: slices.Contains(glue, a), FromDNS: slices.Contains(resolved, a)})
+	}
+	if len(out) == 0 {
+		out = append(out, NSProbe{Host: host, Err: errNoNSAddress})
+	}
+	return out
+}
+
+func probeChildNS(ctx context.Context, p *NSProbe, zone string, opts Options) {
+	if p.Err != nil {
+		return
+	}
+	resp, err := ttlQuery(ctx, p.Addr, zone, dns.TypeSOA, false, opts)
+	if err != nil {
+		p.Err = err
+		return
+	}
+	p.RCode, p.Authoritative = dns.RcodeToString[resp.Rcode], resp.Authoritative && resp.Rcode == dns.RcodeSuccess
+	for _, rr := range resp.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			p.Serial = soa.Serial
+		}
+	}
+	m := new(dns.Msg)
+	m.SetQuestion(zone, dns.TypeNS)
+	m.RecursionDesired = false
+	resp, rtt, err := Exchange(ctx, p.Addr, m, opts)
+	p.RTT = rtt
+	if err != nil {
+		p.Err = err
+		return
+	}
+	for _, rr := range resp.Answer {
+		if ns, ok := rr.(*dns.NS); ok {
+			p.NS = append(p.NS, strings.ToLower(ns.Ns))
+		}
+	}
+	p.NS = sortedSet(p.NS)
+}
+
+func sortedSet(s []string) []string {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return slices.Compact(s)
+}
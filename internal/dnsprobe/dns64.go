@@ -0,0 +1,66 @@
+package dnsprobe
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// IPv4OnlyName has A records and, by design, no AAAA (RFC 7050): an AAAA
+// answer for it was synthesized by DNS64.
+const IPv4OnlyName = "ipv4only.arpa."
+
+// IPv4OnlyAddrs are the addresses of IPv4OnlyName.
+var IPv4OnlyAddrs = []netip.Addr{netip.MustParseAddr("192.0.0.170"), netip.MustParseAddr("192.0.0.171")}
+
+// WellKnownNAT64 is the well-known NAT64 prefix (RFC 6052).
+var WellKnownNAT64 = netip.MustParsePrefix("64:ff9b::/96")
+
+// NAT64Prefixes finds the prefixes DNS64 used to synthesize aaaa from the
+// IPv4 addresses v4, trying each RFC 6052 prefix length. Answers that do not
+// embed any of v4 are ignored.
+func NAT64Prefixes(aaaa, v4 []netip.Addr) []netip.Prefix {
+	var out []netip.Prefix
+	for _, a := range aaaa {
+		if !a.Is6() || a.Is4In6() {
+			continue
+		}
+		for _, bits := range []int{96, 64, 56, 48, 40, 32} {
+			if e, ok := embeddedIPv4(a, bits); ok && slices.Contains(v4, e) {
+				p := netip.PrefixFrom(a, bits).Masked()
+				if !slices.Contains(out, p) {
+					out = append(out, p)
+				}
+				break
+			}
+		}
+	}
+	return out
+}
+
+// embeddedIPv4 extracts the IPv4 address embedded in a after a prefix of the
+// given length. Bits 64-71 are reserved and must be zero for prefixes
+// shorter than /96.
+func embeddedIPv4(a netip.Addr, bits int) (netip.Addr, bool) {
+	b := a.As16()
+	if bits < 96 && b[8] != 0 {
+		return netip.Addr{}, false
+	}
+	var v4 []byte
+	switch bits {
+	case 32:
+		v4 = b[4:8]
+	case 40:
+		v4 = []byte{b[5], b[6], b[7], b[9]}
+	case 48:
+		v4 = []byte{b[6], b[7], b[9], b[10]}
+	case 56:
+		v4 = []byte{b[7], b[9], b[10], b[11]}
+	case 64:
+		v4 = b[9:13]
+	case 96:
+		v4 = b[12:16]
+	default:
+		return netip.Addr{}, false
+	}
+	return netip.AddrFrom4([4]byte(v4)), true
+}
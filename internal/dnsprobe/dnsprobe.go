@@ -3,11 +3,10 @@ package dnsprobe
 import (
 	"context"
 	"crypto/rand"
-	"errors"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net"
-	"os"
-	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -15,9 +14,27 @@ import (
 	"github.com/miekg/dns"
 )
 
+// Answer is one record of the answer section. Name is its owner, Value its
+// data in presentation format (an address for A and AAAA) and RR the whole
+// record. Service is set for SVCB and HTTPS records, decoded.
 type Answer struct {
-	Value string
-	TTL   uint32
+	Name    string
+	Type    string
+	Value   string
+	TTL     uint32
+	RR      string
+	Service *ServiceBinding
+}
+
+// Addrs returns the A and AAAA values of the answer section, in order.
+func (r Result) Addrs() []string {
+	var addrs []string
+	for _, a := range r.Answers {
+		if a.Type == "A" || a.Type == "AAAA" {
+			addrs = append(addrs, a.Value)
+		}
+	}
+	return addrs
 }
 
 type Flags struct {
@@ -33,6 +50,7 @@ type Flags struct {
 type Timings struct {
 	Total     time.Duration
 	Dial      time.Duration
+	Proxy     time.Duration
 	Pack      time.Duration
 	Write     time.Duration
 	Read      time.Duration
@@ -43,11 +61,17 @@ type Timings struct {
 type Result struct {
 	Server            string
 	Network           string
+	Proxy             string
 	LocalAddr         string
 	RemoteAddr        string
 	Timeout           time.Duration
 	QName             string
+	WireQName         string
+	ResponseQName     string
+	QType             string
 	RCode             string
+	NSID              string
+	QueryID           uint16
 	MsgID             uint16
 	Flags             Flags
 	AnswerCount       int
@@ -56,200 +80,581 @@ type Result struct {
 	QuerySizeBytes    int
 	ResponseSizeBytes int
 	Answers           []Answer
-	Timings           Timings
+	// Authority and Additional hold the other sections' records in
+	// presentation format, with an OPT record as one line per EDNS field.
+	Authority  []string
+	Additional []string
+	// Strays are the source addresses of packets that arrived while waiting
+	// for the reply but did not come from the server. Only BenchmarkConcurrent
+	// listens for them.
+	Strays []string
+	// Reused is set when the query went over a pooled connection that an
+	// earlier query opened.
+	Reused bool
+	// Retransmits is how many times the query was resent after a timeout;
+	// see Options.Retries.
+	Retransmits int
+	Timings     Timings
+}
+
+// Options controls how a single query is built and sent.
+type Options struct {
+	// Type is the query type; the zero value means A.
+	Type    uint16
+	Timeout time.Duration
+	// Family 4 or 6 forces the transport address family.
+	Family int
+	// Source binds queries to a local IP or interface and SourcePort to a
+	// fixed local port, which makes them easy to tell apart in a capture.
+	Source     string
+	SourcePort int
+	// TCP sends queries over TCP.
+	TCP bool
+	// Proxy (socks5://host:port or http://host:port) tunnels queries through
+	// a proxy, which implies TCP; DoT and DoH connections go through it as
+	// well.
+	Proxy string
+	// NSID asks the server to identify itself (RFC 5001), which tells
+	// anycast sites apart.
+	NSID bool
+	// QNameCase is one of the Case* constants and controls the letter case
+	// of the name sent; Verify0x20 makes Probe fail with a *CaseError when
+	// the reply does not echo it exactly.
+	QNameCase  string
+	Verify0x20 bool
+	// NoRecurse clears RD so a resolver answers only from its cache.
+	NoRecurse bool
+	// Hooks, used by the benchmarks and Soak, report each query as it
+	// starts and ends.
+	Hooks *Hooks
+	// Uncached makes the benchmarks prefix every query with a fresh random
+	// label so each one misses the resolver's cache and measures recursion.
+	Uncached bool
+	// Retries resends a query that timed out up to that many times, as stub
+	// resolvers do.
+	Retries int
+	// Pool, when set, carries TCP queries over kept-open connections.
+	Pool *Pool
+	// KeepTimings makes the benchmarks hold on to every reply's timings for
+	// Benchmark.Robust, which long runs otherwise save the memory of.
+	KeepTimings bool
+
+	// unconnected sends UDP queries from an unconnected socket so replies
+	// from other addresses reach us instead of being dropped by the kernel.
+	unconnected bool
+}
+
+func (o Options) qtype() uint16 {
+	if o.Type == 0 {
+		return dns.TypeA
+	}
+	return o.Type
+}
+
+func (o Options) network(base string) string {
+	switch o.Family {
+	case 4:
+		return base + "4"
+	case 6:
+		return base + "6"
+	}
+	return base
+}
+
+// checkFamily rejects literal server addresses that cannot be reached over
+// the forced family, which would otherwise fail with an opaque dial error.
+func checkFamily(server string, family int) error {
+	host, _, err := net.SplitHostPort(server)
+	if err != nil || family == 0 {
+		return nil
+	}
+	ip := net.ParseIP(strings.SplitN(host, "%", 2)[0])
+	if ip == nil {
+		return nil
+	}
+	if is4 := ip.To4() != nil; is4 != (family == 4) {
+		return fmt.Errorf("server %s is not an IPv%d address", server, family)
+	}
+	return nil
 }
 
 type Benchmark struct {
 	Attempts int
 	Success  int
 	Fail     int
+	// Sent counts every query sent, retransmissions included, and Timeouts
+	// those that went unanswered. Recovered queries were answered only on a
+	// retransmission; Lost ones timed out on every try.
+	Sent        int
+	Timeouts    int
+	Retransmits int
+	Recovered   int
+	Lost        int
+	// Failures breaks Fail down by FailureClass; RCodes counts the replies
+	// that arrived by rcode, failed ones included.
+	Failures map[string]int
+	RCodes   map[string]int
 	Avg      Timings
+	// Buckets is only filled in by the timed benchmarks, BenchmarkSerialFor
+	// and BenchmarkConcurrentFor.
+	Buckets []BenchBucket
+	// Consistency is only filled in by BenchmarkConcurrent.
+	Consistency Consistency
+	Cache       CacheEstimate
+	// TTLs is left empty when Uncached sends every query to a new name.
+	TTLs BenchTTLs
+	// timings holds every successful query's, for Robust, when
+	// Options.KeepTimings is set.
+	timings []Timings
 }
 
-func SystemDefaultDNSServer() (string, error) {
-	if _, err := os.Stat("/etc/resolv.conf"); err == nil {
-		cfg, err := dns.ClientConfigFromFile("/etc/resolv.conf")
-		if err != nil {
-			return "", err
+// LossRatio is the share of queries sent that went unanswered.
+func (b Benchmark) LossRatio() float64 {
+	if b.Sent == 0 {
+		return 0
+	}
+	return float64(b.Timeouts) / float64(b.Sent)
+}
+
+// count adds one benchmark query's tries to the loss counters and returns
+// its FailureClass.
+func (b *Benchmark) count(r Result, err error) string {
+	class := FailureClass(r, err)
+	if class != "" {
+		if b.Failures == nil {
+			b.Failures = map[string]int{}
+		}
+		b.Failures[class]++
+	}
+	b.Sent += r.Retransmits + 1
+	b.Timeouts += r.Retransmits
+	b.Retransmits += r.Retransmits
+	switch {
+	case err == nil:
+		if b.RCodes == nil {
+			b.RCodes = map[string]int{}
 		}
-		if len(cfg.Servers) == 0 {
-			return "", errors.New("no nameserver entries in /etc/resolv.conf")
+		b.RCodes[r.RCode]++
+		if r.Retransmits > 0 {
+			b.Recovered++
 		}
-		return net.JoinHostPort(cfg.Servers[0], cfg.Port), nil
+	case IsTimeout(err):
+		b.Timeouts++
+		b.Lost++
 	}
-	return "", fmt.Errorf("unsupported auto-detection on %s; pass dns-server explicitly (e.g. 1.1.1.1 or 1.1.1.1:53)", runtime.GOOS)
+	return class
 }
 
 func ProbeA(ctx context.Context, server string, qname string, timeout time.Duration) (Result, error) {
-	server = normalizeServer(server)
+	return Probe(ctx, server, qname, Options{Type: dns.TypeA, Timeout: timeout})
+}
+
+func Probe(ctx context.Context, server string, qname string, opts Options) (Result, error) {
+	if opts.Retries > 0 {
+		return probeRetrying(ctx, server, qname, opts)
+	}
+	server = NormalizeServer(server)
+	timeout := opts.Timeout
+	qtype := opts.qtype()
+
+	network := opts.transport()
+	r := Result{
+		Server:    server,
+		Network:   network,
+		Proxy:     opts.Proxy,
+		Timeout:   timeout,
+		QName:     qname,
+		WireQName: opts.wireName(qname),
+		QType:     dns.TypeToString[qtype],
+	}
 
 	msg := new(dns.Msg)
-	msg.SetQuestion(dns.Fqdn(qname), dns.TypeA)
-	msg.RecursionDesired = true
+	msg.SetQuestion(r.WireQName, qtype)
+	r.QueryID = msg.Id
+	msg.RecursionDesired = !opts.NoRecurse
 	msg.CheckingDisabled = false
+	if opts.NSID {
+		msg.SetEdns0(1232, false)
+		o := msg.IsEdns0()
+		o.Option = append(o.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+	}
 
 	startTotal := time.Now()
+	ctx, cancel := probeContext(ctx, timeout)
+	defer cancel()
+
+	// fail returns whatever was measured up to the failing phase.
+	fail := func(err error) (Result, error) {
+		r.Timings.Total = time.Since(startTotal)
+		r.Timings.RTTApprox = r.Timings.Write + r.Timings.Read
+		return r, ctxError(ctx, err)
+	}
+
+	if err := checkFamily(server, opts.Family); err != nil {
+		return fail(err)
+	}
 
 	startPack := time.Now()
 	wire, err := msg.Pack()
-	packDur := time.Since(startPack)
+	r.Timings.Pack = time.Since(startPack)
 	if err != nil {
-		return Result{}, err
+		return fail(err)
 	}
 
-	network := "udp"
-	d := net.Dialer{Timeout: timeout}
+	// With a pool, stream queries reuse its connections; dial time then
+	// includes waiting for a free one.
+	pooled := opts.Pool != nil && strings.HasPrefix(network, "tcp")
 	startDial := time.Now()
-	conn, err := d.DialContext(ctx, network, server)
-	dialDur := time.Since(startDial)
+	var conn net.Conn
+	var proxyTime time.Duration
+	if pooled {
+		conn, r.Reused, proxyTime, err = opts.Pool.get(ctx, opts, network, server)
+	} else {
+		conn, proxyTime, err = opts.dial(ctx, network, server)
+	}
+	r.Timings.Dial = time.Since(startDial)
+	r.Timings.Proxy = proxyTime
 	if err != nil {
-		return Result{}, err
+		return fail(err)
+	}
+	stop := bindConn(ctx, conn)
+	healthy := false
+	if pooled {
+		defer func() { opts.Pool.put(network, server, conn, stop() && healthy) }()
+	} else {
+		defer conn.Close()
+		defer stop()
 	}
-	defer conn.Close()
 
-	_ = conn.SetDeadline(time.Now().Add(timeout))
+	r.LocalAddr = conn.LocalAddr().String()
+	r.RemoteAddr = conn.RemoteAddr().String()
 
-	local := conn.LocalAddr().String()
-	remote := conn.RemoteAddr().String()
+	stream := strings.HasPrefix(network, "tcp")
+	if stream {
+		wire = append([]byte{byte(len(wire) >> 8), byte(len(wire))}, wire...)
+	}
 
 	startWrite := time.Now()
 	nw, err := conn.Write(wire)
-	writeDur := time.Since(startWrite)
+	r.Timings.Write = time.Since(startWrite)
+	r.QuerySizeBytes = nw
+	if stream {
+		r.QuerySizeBytes -= 2
+	}
 	if err != nil {
-		return Result{}, err
+		return fail(err)
 	}
 
 	buf := make([]byte, 65535)
 	startRead := time.Now()
-	nr, err := conn.Read(buf)
-	readDur := time.Since(startRead)
+	var nr int
+	if stream {
+		nr, err = readStreamMsg(conn, buf)
+	} else {
+		nr, err = conn.Read(buf)
+	}
+	if sc, ok := conn.(*strayConn); ok {
+		r.Strays = sc.strays
+	}
+	r.Timings.Read = time.Since(startRead)
+	r.ResponseSizeBytes = nr
 	if err != nil {
-		return Result{}, err
+		return fail(err)
 	}
 
 	var resp dns.Msg
 	startUnpack := time.Now()
-	if err := resp.Unpack(buf[:nr]); err != nil {
-		return Result{}, err
+	err = resp.Unpack(buf[:nr])
+	r.Timings.Unpack = time.Since(startUnpack)
+	if err != nil {
+		return fail(fmt.Errorf("%w: %v", ErrUnpack, err))
 	}
-	unpackDur := time.Since(startUnpack)
+	healthy = true
 
-	totalDur := time.Since(startTotal)
+	r.Timings.Total = time.Since(startTotal)
+	r.Timings.RTTApprox = r.Timings.Write + r.Timings.Read
 
-	r := Result{
-		Server:            server,
-		Network:           network,
-		LocalAddr:         local,
-		RemoteAddr:        remote,
-		Timeout:           timeout,
-		QName:             qname,
-		RCode:             dns.RcodeToString[resp.Rcode],
-		MsgID:             resp.Id,
-		Flags: Flags{
-			QR: resp.Response,
-			AA: resp.Authoritative,
-			TC: resp.Truncated,
-			RD: resp.RecursionDesired,
-			RA: resp.RecursionAvailable,
-			AD: resp.AuthenticatedData,
-			CD: resp.CheckingDisabled,
-		},
-		AnswerCount:       len(resp.Answer),
-		NSCount:           len(resp.Ns),
-		ExtraCount:        len(resp.Extra),
-		QuerySizeBytes:    nw,
-		ResponseSizeBytes: nr,
-		Timings: Timings{
-			Total:     totalDur,
-			Dial:      dialDur,
-			Pack:      packDur,
-			Write:     writeDur,
-			Read:      readDur,
-			Unpack:    unpackDur,
-			RTTApprox: writeDur + readDur,
-		},
+	r.RCode = dns.RcodeToString[resp.Rcode]
+	r.NSID = responseNSID(&resp)
+	if len(resp.Question) > 0 {
+		r.ResponseQName = resp.Question[0].Name
+	}
+	r.MsgID = resp.Id
+	r.Flags = Flags{
+		QR: resp.Response,
+		AA: resp.Authoritative,
+		TC: resp.Truncated,
+		RD: resp.RecursionDesired,
+		RA: resp.RecursionAvailable,
+		AD: resp.AuthenticatedData,
+		CD: resp.CheckingDisabled,
 	}
+	r.AnswerCount = len(resp.Answer)
+	r.NSCount = len(resp.Ns)
+	r.ExtraCount = len(resp.Extra)
 
 	for _, rr := range resp.Answer {
-		if a, ok := rr.(*dns.A); ok {
-			r.Answers = append(r.Answers, Answer{Value: a.A.String(), TTL: a.Hdr.Ttl})
-		}
+		h := rr.Header()
+		svc, _ := DecodeServiceBinding(rr)
+		r.Answers = append(r.Answers, Answer{
+			Name:    h.Name,
+			Type:    dns.Type(h.Rrtype).String(),
+			Value:   strings.TrimPrefix(rr.String(), h.String()),
+			TTL:     h.Ttl,
+			RR:      rr.String(),
+			Service: svc,
+		})
 	}
 
+	r.Authority = presentation(resp.Ns)
+	r.Additional = presentation(resp.Extra)
+
+	if opts.Verify0x20 && r.ResponseQName != r.WireQName {
+		return r, &CaseError{Sent: r.WireQName, Got: r.ResponseQName}
+	}
 	return r, nil
 }
 
-func BenchmarkSerial(ctx context.Context, server, qname string, timeout time.Duration, n int) Benchmark {
+func presentation(rrs []dns.RR) []string {
+	var out []string
+	for _, rr := range rrs {
+		opt, ok := rr.(*dns.OPT)
+		if !ok {
+			out = append(out, rr.String())
+			continue
+		}
+		for _, line := range strings.Split(opt.String(), "\n") {
+			if line != "" && line != ";; OPT PSEUDOSECTION:" {
+				out = append(out, line)
+			}
+		}
+	}
+	return out
+}
+
+// responseNSID returns the NSID option of resp as text when it is printable,
+// or as hex otherwise.
+func responseNSID(resp *dns.Msg) string {
+	o := resp.IsEdns0()
+	if o == nil {
+		return ""
+	}
+	for _, opt := range o.Option {
+		n, ok := opt.(*dns.EDNS0_NSID)
+		if !ok {
+			continue
+		}
+		b, err := hex.DecodeString(n.Nsid)
+		if err != nil {
+			return n.Nsid
+		}
+		for _, c := range b {
+			if c < 0x20 || c > 0x7e {
+				return n.Nsid
+			}
+		}
+		return string(b)
+	}
+	return ""
+}
+
+// readStreamMsg reads one length-prefixed DNS message (RFC 1035 4.2.2) into buf.
+func readStreamMsg(conn net.Conn, buf []byte) (int, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return 0, err
+	}
+	n := int(hdr[0])<<8 | int(hdr[1])
+	return io.ReadFull(conn, buf[:n])
+}
+
+func BenchmarkSerial(ctx context.Context, server, qname string, opts Options, n int) Benchmark {
+	return benchmarkSerial(ctx, server, qname, opts, n, time.Time{}, nil)
+}
+
+// BenchmarkSerialFor sends queries one after another until d has passed,
+// with Buckets of the given width.
+func BenchmarkSerialFor(ctx context.Context, server, qname string, opts Options, d, bucket time.Duration) Benchmark {
+	k := newBucketer(time.Now(), bucket)
+	b := benchmarkSerial(ctx, server, qname, opts, -1, k.start.Add(d), k)
+	b.Buckets = k.finish()
+	return b
+}
+
+// benchmarkSerial sends n queries, or with n < 0 as many as fit before
+// until.
+func benchmarkSerial(ctx context.Context, server, qname string, opts Options, n int, until time.Time, k *bucketer) Benchmark {
 	var sum Timings
 	var ok, fail int
+	var samples sampler
+	var b Benchmark
+	if !until.IsZero() {
+		samples.limit = maxTimedSamples
+	}
 
-	for i := 0; i < n; i++ {
-		r, err := ProbeA(ctx, server, qname, timeout)
-		if err != nil {
+	for i := 0; (i < n || n < 0 && time.Now().Before(until)) && ctx.Err() == nil; i++ {
+		k.flush(time.Now())
+		name := opts.benchName(qname)
+		opts.Hooks.start(i, name)
+		sent := time.Now()
+		r, err := Probe(ctx, server, name, opts)
+		opts.Hooks.done(i, r, err)
+		if CutShort(ctx, err) {
+			break
+		}
+		class := b.count(r, err)
+		k.add(sent, r, class)
+		if class != "" {
 			fail++
 			continue
 		}
 		ok++
 		sum = add(sum, r.Timings)
+		if opts.KeepTimings {
+			b.timings = append(b.timings, r.Timings)
+		}
+		samples.add(sampleOf(r, sent))
 	}
 
-	return Benchmark{
-		Attempts: n,
-		Success:  ok,
-		Fail:     fail,
-		Avg:      avg(sum, ok),
+	b.Attempts, b.Success, b.Fail = ok+fail, ok, fail
+	b.Avg, b.Cache = avg(sum, ok), estimateCache(samples.samples)
+	if !opts.Uncached {
+		b.TTLs = benchTTLs(samples.samples)
 	}
+	return b
+}
+
+// BenchmarkConcurrent fires n identical queries at once. Besides timings it
+// checks the replies against each other and their queries for signs of
+// spoofing; see Consistency.
+func BenchmarkConcurrent(ctx context.Context, server, qname string, opts Options, n int) Benchmark {
+	return benchmarkConcurrent(ctx, server, qname, opts, n, time.Time{}, nil)
 }
 
-func BenchmarkConcurrent(ctx context.Context, server, qname string, timeout time.Duration, n int) Benchmark {
+// BenchmarkConcurrentFor fires rounds of n queries at once, each when the
+// last has been answered, until d has passed, with Buckets of the given
+// width.
+func BenchmarkConcurrentFor(ctx context.Context, server, qname string, opts Options, n int, d, bucket time.Duration) Benchmark {
+	k := newBucketer(time.Now(), bucket)
+	b := benchmarkConcurrent(ctx, server, qname, opts, n, k.start.Add(d), k)
+	b.Buckets = k.finish()
+	return b
+}
+
+// benchmarkConcurrent fires one round of n queries, or rounds until until
+// when it is set.
+func benchmarkConcurrent(ctx context.Context, server, qname string, opts Options, n int, until time.Time, k *bucketer) Benchmark {
 	type one struct {
-		t   Timings
-		err error
+		r    Result
+		err  error
+		sent time.Time
 	}
 
-	ch := make(chan one, n)
-	var wg sync.WaitGroup
-	wg.Add(n)
+	var sum Timings
+	var ok, fail int
+	var cons Consistency
+	var samples sampler
+	var b Benchmark
+	if !until.IsZero() {
+		samples.limit = maxTimedSamples
+	}
+
+	opts.unconnected = true
+	for round := 0; (round == 0 || time.Now().Before(until)) && ctx.Err() == nil; round++ {
+		k.flush(time.Now())
+		ch := make(chan one, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n && ctx.Err() == nil; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				seq := round*n + i
+				name := opts.benchName(qname)
+				opts.Hooks.start(seq, name)
+				sent := time.Now()
+				r, err := Probe(ctx, server, name, opts)
+				opts.Hooks.done(seq, r, err)
+				ch <- one{r: r, err: err, sent: sent}
+			}()
+		}
 
-	for i := 0; i < n; i++ {
-		go func() {
-			defer wg.Done()
-			r, err := ProbeA(ctx, server, qname, timeout)
-			if err != nil {
-				ch <- one{err: err}
-				return
+		wg.Wait()
+		close(ch)
+
+		for v := range ch {
+			if CutShort(ctx, v.err) {
+				continue
+			}
+			class := b.count(v.r, v.err)
+			k.add(v.sent, v.r, class)
+			if v.err != nil {
+				fail++
+				cons.addStrays(v.r.Strays)
+				continue
+			}
+			cons.add(v.r)
+			if class != "" {
+				fail++
+				continue
 			}
-			ch <- one{t: r.Timings}
-		}()
+			ok++
+			sum = add(sum, v.r.Timings)
+			if opts.KeepTimings {
+				b.timings = append(b.timings, v.r.Timings)
+			}
+			samples.add(sampleOf(v.r, v.sent))
+		}
 	}
+	cons.sort()
 
-	wg.Wait()
-	close(ch)
+	b.Attempts, b.Success, b.Fail = ok+fail, ok, fail
+	b.Avg, b.Consistency, b.Cache = avg(sum, ok), cons, estimateCache(samples.samples)
+	if !opts.Uncached {
+		b.TTLs = benchTTLs(samples.samples)
+	}
+	return b
+}
 
-	var sum Timings
-	var ok, fail int
-	for v := range ch {
-		if v.err != nil {
-			fail++
-			continue
+// probeRetrying is Probe resending after each timeout, up to opts.Retries
+// times. The result is the last try's, except that Timings.Total also
+// counts the time spent on the tries before it, as a client waits that out.
+func probeRetrying(ctx context.Context, server, qname string, opts Options) (Result, error) {
+	retries := opts.Retries
+	opts.Retries = 0
+	start := time.Now()
+	for i := 0; ; i++ {
+		try := time.Now()
+		r, err := Probe(ctx, server, qname, opts)
+		r.Retransmits = i
+		r.Timings.Total += try.Sub(start)
+		if !IsTimeout(err) || i == retries || ctx.Err() != nil {
+			return r, err
 		}
-		ok++
-		sum = add(sum, v.t)
 	}
+}
 
-	return Benchmark{
-		Attempts: n,
-		Success:  ok,
-		Fail:     fail,
-		Avg:      avg(sum, ok),
+// benchName is the name one benchmark query asks for: qname, or with
+// Uncached a random subdomain of it that no cache can hold yet.
+func (o Options) benchName(qname string) string {
+	if !o.Uncached {
+		return qname
 	}
+	label, err := randomLabel(16)
+	if err != nil {
+		return qname
+	}
+	return label + "." + qname
 }
 
-func normalizeServer(s string) string {
-	if strings.Contains(s, ":") {
-		if _, _, err := net.SplitHostPort(s); err == nil {
-			return s
-		}
+// NormalizeServer returns server as host:port, defaulting the port to 53.
+// Bare IPv6 literals ("2606:4700:4700::1111") and bracketed ones without a
+// port ("[2606:4700:4700::1111]") are accepted.
+func NormalizeServer(s string) string {
+	if _, _, err := net.SplitHostPort(s); err == nil {
+		return s
+	}
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
 	}
 	return net.JoinHostPort(s, "53")
 }
@@ -258,6 +663,7 @@ func add(a, b Timings) Timings {
 	return Timings{
 		Total:     a.Total + b.Total,
 		Dial:      a.Dial + b.Dial,
+		Proxy:     a.Proxy + b.Proxy,
 		Pack:      a.Pack + b.Pack,
 		Write:     a.Write + b.Write,
 		Read:      a.Read + b.Read,
@@ -274,6 +680,7 @@ func avg(s Timings, n int) Timings {
 	return Timings{
 		Total:     s.Total / den,
 		Dial:      s.Dial / den,
+		Proxy:     s.Proxy / den,
 		Pack:      s.Pack / den,
 		Write:     s.Write / den,
 		Read:      s.Read / den,
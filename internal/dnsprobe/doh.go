@@ -0,0 +1,89 @@
+package dnsprobe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultDoHURL is the DNS-over-HTTPS resolver used as a trusted reference.
+const DefaultDoHURL = "https://cloudflare-dns.com/dns-query"
+
+// IsDoHURL reports whether server names a DNS-over-HTTPS endpoint rather
+// than a host:port.
+func IsDoHURL(server string) bool {
+	return strings.HasPrefix(server, "https://")
+}
+
+// ExchangeDoH sends msg to a DNS-over-HTTPS endpoint (RFC 8484, POST) and
+// returns the reply and round-trip time. The message ID is zeroed on the
+// wire, as the RFC recommends for cacheability, and restored in the reply.
+// The connection is dialed like any other probe's, through opts' proxy,
+// source address and family; environment proxy settings are ignored.
+func ExchangeDoH(ctx context.Context, url string, msg *dns.Msg, opts Options) (*dns.Msg, time.Duration, error) {
+	return exchangeDoH(ctx, url, "", msg, opts)
+}
+
+// exchangeDoH is ExchangeDoH connecting to addr, when set, instead of the
+// URL's host, which then only names the server for TLS and HTTP.
+func exchangeDoH(ctx context.Context, url, addr string, msg *dns.Msg, opts Options) (*dns.Msg, time.Duration, error) {
+	id := msg.Id
+	msg.Id = 0
+	wire, err := msg.Pack()
+	msg.Id = id
+	if err != nil {
+		return nil, 0, err
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(wire))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	tr := &http.Transport{
+		Proxy: nil,
+		DialContext: func(ctx context.Context, _, host string) (net.Conn, error) {
+			if addr != "" {
+				host = addr
+			}
+			conn, _, err := opts.dial(ctx, opts.network("tcp"), host)
+			return conn, err
+		},
+		ForceAttemptHTTP2: true,
+	}
+	defer tr.CloseIdleConnections()
+
+	start := time.Now()
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 65536))
+	rtt := time.Since(start)
+	if err != nil {
+		return nil, rtt, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, rtt, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(body); err != nil {
+		return nil, rtt, fmt.Errorf("%s: %w", url, err)
+	}
+	r.Id = id
+	return r, rtt, nil
+}
@@ -0,0 +1,22 @@
+package dnsprobe
+
+// SignedDomains are DNSSEC-signed zones and UnsignedDomains unsigned ones of
+// similar reach, the two workloads MeasureValidationCost compares.
+var (
+	SignedDomains = []string{
+		"ietf.org",
+		"isc.org",
+		"iana.org",
+		"ripe.net",
+		"nic.cz",
+		"cloudflare.com",
+	}
+	UnsignedDomains = []string{
+		"google.com",
+		"amazon.com",
+		"microsoft.com",
+		"apple.com",
+		"wikipedia.org",
+		"github.com",
+	}
+)
@@ -0,0 +1,150 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// DSLink is one DS record at the parent and the child DNSKEY it points to.
+// Key is nil when no DNSKEY has its key tag and algorithm (an orphaned DS);
+// DigestOK says whether the key's digest matches. Unsupported is set when
+// the digest type is one this tool cannot compute.
+type DSLink struct {
+	KeyTag      uint16
+	Algorithm   uint8
+	DigestType  uint8
+	Digest      string
+	Key         *dns.DNSKEY
+	DigestOK    bool
+	Unsupported bool
+}
+
+// ChildKey is one DNSKEY of the zone. SignsKeys is set when an RRSIG over
+// the DNSKEY set was made with it, which a DS must point at for the chain
+// of trust to hold.
+type ChildKey struct {
+	Key       *dns.DNSKEY
+	KeyTag    uint16
+	SignsKeys bool
+}
+
+// DSCheck is a zone's DS set as its parent serves it next to the DNSKEY set
+// its own nameserver serves, and the CDS and CDNSKEY records at its apex
+// that ask the parent to change the DS set (RFC 7344, RFC 8078). CDSErr is
+// set when those could not be read, and CDS and CDNSKEY are then empty.
+type DSCheck struct {
+	Zone         string
+	Parent       string
+	ParentServer string
+	ChildServer  string
+	DS           []DSLink
+	Keys         []ChildKey
+	CDS          []*dns.CDS
+	CDNSKEY      []*dns.CDNSKEY
+	CDSErr       error
+}
+
+// CheckDS asks one of the parent's nameservers for zone's DS set and one of
+// the zone's own for its DNSKEY set, both found through resolver, and links
+// every DS to the key it names.
+func CheckDS(ctx context.Context, resolver, zone string, opts Options) (DSCheck, error) {
+	c := DSCheck{Zone: strings.ToLower(dns.Fqdn(zone))}
+	labels := dns.SplitDomainName(c.Zone)
+	if len(labels) == 0 {
+		return c, fmt.Errorf("the root zone has no DS records")
+	}
+	parent, pns, err := findAuthoritative(ctx, resolver, dns.Fqdn(strings.Join(labels[1:], ".")), opts)
+	if err != nil {
+		return c, fmt.Errorf("parent zone: %w", err)
+	}
+	c.Parent, c.ParentServer = parent, pns
+	_, cns, err := findAuthoritative(ctx, resolver, c.Zone, opts)
+	if err != nil {
+		return c, fmt.Errorf("child zone: %w", err)
+	}
+	c.ChildServer = cns
+
+	dsResp, err := dnssecQuery(ctx, pns, c.Zone, dns.TypeDS, opts)
+	if err != nil {
+		return c, fmt.Errorf("DS from %s: %w", pns, err)
+	}
+	keyResp, err := dnssecQuery(ctx, cns, c.Zone, dns.TypeDNSKEY, opts)
+	if err != nil {
+		return c, fmt.Errorf("DNSKEY from %s: %w", cns, err)
+	}
+
+	for _, qtype := range []uint16{dns.TypeCDS, dns.TypeCDNSKEY} {
+		resp, err := dnssecQuery(ctx, cns, c.Zone, qtype, opts)
+		if err != nil {
+			c.CDS, c.CDNSKEY = nil, nil
+			c.CDSErr = fmt.Errorf("%s from %s: %w", dns.TypeToString[qtype], cns, err)
+			break
+		}
+		for _, rr := range resp.Answer {
+			switch rr := rr.(type) {
+			case *dns.CDS:
+				c.CDS = append(c.CDS, rr)
+			case *dns.CDNSKEY:
+				c.CDNSKEY = append(c.CDNSKEY, rr)
+			}
+		}
+	}
+
+	signers := map[uint16]bool{}
+	for _, rr := range keyResp.Answer {
+		if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == dns.TypeDNSKEY {
+			signers[sig.KeyTag] = true
+		}
+	}
+	for _, rr := range keyResp.Answer {
+		if k, ok := rr.(*dns.DNSKEY); ok {
+			tag := k.KeyTag()
+			c.Keys = append(c.Keys, ChildKey{Key: k, KeyTag: tag, SignsKeys: signers[tag]})
+		}
+	}
+	for _, rr := range dsResp.Answer {
+		ds, ok := rr.(*dns.DS)
+		if !ok {
+			continue
+		}
+		l := DSLink{KeyTag: ds.KeyTag, Algorithm: ds.Algorithm, DigestType: ds.DigestType, Digest: strings.ToLower(ds.Digest)}
+		for _, k := range c.Keys {
+			if k.KeyTag != ds.KeyTag || k.Key.Algorithm != ds.Algorithm {
+				continue
+			}
+			l.Key = k.Key
+			want := k.Key.ToDS(ds.DigestType)
+			l.Unsupported = want == nil
+			if want != nil && strings.EqualFold(want.Digest, ds.Digest) {
+				l.DigestOK = true
+				break
+			}
+		}
+		c.DS = append(c.DS, l)
+	}
+	return c, nil
+}
+
+// dnssecQuery asks server directly, with DO set and RD clear, retrying over
+// TCP when the reply is truncated, as DNSKEY sets often are.
+func dnssecQuery(ctx context.Context, server, name string, qtype uint16, opts Options) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.RecursionDesired = false
+	m.SetEdns0(1232, true)
+	resp, _, err := Exchange(ctx, server, m, opts)
+	if err == nil && resp.Truncated {
+		opts.TCP = true
+		resp, _, err = Exchange(ctx, server, m, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("%s %s: %s", name, dns.TypeToString[qtype], dns.RcodeToString[resp.Rcode])
+	}
+	return resp, nil
+}
@@ -0,0 +1,111 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ECSZone answers TXT queries with the address of the resolver asking and,
+// as "edns0-client-subnet <prefix>", the client subnet it forwarded, if any.
+const ECSZone = "o-o.myaddr.l.google.com"
+
+// ECSReport is what a recursive resolver does with EDNS Client Subnet
+// (RFC 7871).
+type ECSReport struct {
+	Egress []string // resolver addresses the authoritative saw
+	// Forwarded is the client subnet the authoritative saw for a plain query,
+	// i.e. one the resolver derived from our address; empty when it sent none.
+	Forwarded string
+	// Sent is the subnet we put in our own ECS option, and Relayed the one
+	// the authoritative saw as a result.
+	Sent    string
+	Relayed string
+	// Echoed reports whether the resolver's reply to the ECS query for the
+	// scope name carried an ECS option; Scope is the prefix length the answer
+	// is valid for (0: the same for every client).
+	Echoed       bool
+	SourcePrefix int
+	Scope        int
+}
+
+// DetectECS asks zone, an ECSZone-style reflector, through server with and
+// without an ECS option carrying subnet, and then resolves scopeName with
+// the option to read the scope the resolver reports back.
+func DetectECS(ctx context.Context, server, zone, scopeName string, subnet *net.IPNet, opts Options) (ECSReport, error) {
+	rep := ECSReport{Sent: subnet.String()}
+
+	plain, err := ecsQuery(ctx, server, zone, dns.TypeTXT, nil, opts)
+	if err != nil {
+		return rep, err
+	}
+	rep.Egress, rep.Forwarded = reflectedECS(plain)
+
+	withECS, err := ecsQuery(ctx, server, zone, dns.TypeTXT, subnet, opts)
+	if err != nil {
+		return rep, err
+	}
+	_, rep.Relayed = reflectedECS(withECS)
+
+	scoped, err := ecsQuery(ctx, server, scopeName, dns.TypeA, subnet, opts)
+	if err != nil {
+		return rep, err
+	}
+	if o := scoped.IsEdns0(); o != nil {
+		for _, opt := range o.Option {
+			if e, ok := opt.(*dns.EDNS0_SUBNET); ok {
+				rep.Echoed = true
+				rep.SourcePrefix = int(e.SourceNetmask)
+				rep.Scope = int(e.SourceScope)
+			}
+		}
+	}
+	return rep, nil
+}
+
+func ecsQuery(ctx context.Context, server, name string, qtype uint16, subnet *net.IPNet, opts Options) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.SetEdns0(1232, false)
+	if subnet != nil {
+		addECS(m, subnet)
+	}
+	resp, _, err := Exchange(ctx, server, m, opts)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("%s %s: %s", name, dns.TypeToString[qtype], dns.RcodeToString[resp.Rcode])
+	}
+	return resp, nil
+}
+
+// reflectedECS reads a reflector's TXT answers. Google's form is
+// "edns0-client-subnet 192.0.2.0/24" next to a bare address; Akamai's
+// (whoami.ds.akahelp.net) is two strings, "ecs" "192.0.2.0/24/0" and
+// "ns" "<address>".
+func reflectedECS(m *dns.Msg) (egress []string, subnet string) {
+	for _, rr := range m.Answer {
+		t, ok := rr.(*dns.TXT)
+		if !ok || len(t.Txt) == 0 {
+			continue
+		}
+		switch {
+		case len(t.Txt) == 2 && t.Txt[0] == "ecs":
+			subnet = t.Txt[1]
+			if parts := strings.Split(subnet, "/"); len(parts) == 3 {
+				subnet = parts[0] + "/" + parts[1]
+			}
+		case len(t.Txt) == 2 && (t.Txt[0] == "ns" || t.Txt[0] == "ip"):
+			egress = append(egress, t.Txt[1])
+		case strings.HasPrefix(t.Txt[0], "edns0-client-subnet "):
+			subnet = strings.TrimPrefix(t.Txt[0], "edns0-client-subnet ")
+		case net.ParseIP(t.Txt[0]) != nil:
+			egress = append(egress, t.Txt[0])
+		}
+	}
+	return egress, subnet
+}
@@ -0,0 +1,134 @@
+package dnsprobe
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// EDNSVariant is one arm of the EDNS option A/B experiment. Apply adds the
+// option under test to a query that already carries a plain OPT record.
+type EDNSVariant struct {
+	Name  string
+	Desc  string
+	NoOPT bool
+	Apply func(m *dns.Msg)
+}
+
+type EDNSVariantResult struct {
+	Variant EDNSVariant
+	OK      int
+	Fail    int
+	P50     time.Duration
+	Mean    time.Duration
+	AvgSize int
+	RCodes  map[string]int
+	latency Distribution
+	bytes   int
+}
+
+// EDNSVariants returns the standard experiment arms: no EDNS, a bare OPT
+// baseline, and the baseline plus each option on its own. ecs is the client
+// subnet sent by the ECS arm.
+func EDNSVariants(ecs *net.IPNet) []EDNSVariant {
+	return []EDNSVariant{
+		{Name: "no-edns", Desc: "plain DNS, no OPT record", NoOPT: true},
+		{Name: "baseline", Desc: "OPT record, 1232-byte buffer, no options"},
+		{Name: "+cookie", Desc: "client cookie (RFC 7873)", Apply: addCookie},
+		{Name: "+ecs", Desc: "client subnet " + ecs.String() + " (RFC 7871)", Apply: func(m *dns.Msg) { addECS(m, ecs) }},
+		{Name: "+padding", Desc: "pad query to 128-byte blocks (RFC 7830/8467)", Apply: addPadding},
+		{Name: "+do", Desc: "DNSSEC OK bit, pulls in signatures", Apply: func(m *dns.Msg) { m.IsEdns0().SetDo() }},
+	}
+}
+
+func addCookie(m *dns.Msg) {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	o := m.IsEdns0()
+	o.Option = append(o.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: hex.EncodeToString(b)})
+}
+
+func addECS(m *dns.Msg, n *net.IPNet) {
+	ones, _ := n.Mask.Size()
+	e := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: uint8(ones), Address: n.IP}
+	if n.IP.To4() == nil {
+		e.Family = 2
+	}
+	o := m.IsEdns0()
+	o.Option = append(o.Option, e)
+}
+
+func addPadding(m *dns.Msg) {
+	o := m.IsEdns0()
+	p := &dns.EDNS0_PADDING{}
+	o.Option = append(o.Option, p)
+	// The option header itself is counted in Len, so only the payload is left.
+	if rem := m.Len() % 128; rem != 0 {
+		p.Padding = make([]byte, 128-rem)
+	}
+}
+
+// RunEDNSVariants queries every domain rounds times per variant, interleaving
+// the variants within each round so cache state and network drift affect
+// them equally. One unmeasured query per domain warms the cache first.
+// When ctx ends it stops, leaving out the queries cut short, and returns
+// how many rounds it began.
+func RunEDNSVariants(ctx context.Context, server string, domains []string, opts Options, rounds int, variants []EDNSVariant) ([]EDNSVariantResult, int) {
+	out := make([]EDNSVariantResult, len(variants))
+	for i, v := range variants {
+		out[i] = EDNSVariantResult{Variant: v, RCodes: map[string]int{}}
+	}
+
+	build := func(name string, v EDNSVariant) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(opts.wireName(name), opts.qtype())
+		m.RecursionDesired = true
+		if !v.NoOPT {
+			m.SetEdns0(1232, false)
+			if v.Apply != nil {
+				v.Apply(m)
+			}
+		}
+		return m
+	}
+
+	for _, d := range domains {
+		_, _, _ = Exchange(ctx, server, build(d, variants[0]), opts)
+	}
+
+	r := 0
+	for ; r < rounds && ctx.Err() == nil; r++ {
+		for _, d := range domains {
+			for i, v := range variants {
+				resp, rtt, err := Exchange(ctx, server, build(d, v), opts)
+				res := &out[i]
+				if CutShort(ctx, err) {
+					continue
+				}
+				if err != nil {
+					res.Fail++
+					continue
+				}
+				res.OK++
+				res.RCodes[dns.RcodeToString[resp.Rcode]]++
+				res.latency.Add(rtt)
+				resp.Compress = true
+				res.bytes += resp.Len()
+			}
+		}
+	}
+
+	for i := range out {
+		res := &out[i]
+		res.P50 = res.latency.Quantile(0.5)
+		res.Mean = res.latency.Mean()
+		if res.OK > 0 {
+			res.AvgSize = res.bytes / res.OK
+		}
+	}
+	return out, r
+}
@@ -0,0 +1,166 @@
+package dnsprobe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// EgressPath is one way of reaching a DNS server: plain DNS over UDP or TCP,
+// DNS over TLS (dot) or DNS over HTTPS (doh), on a port.
+type EgressPath struct {
+	Transport string
+	Port      int
+}
+
+func (p EgressPath) String() string {
+	return p.Transport + "/" + strconv.Itoa(p.Port)
+}
+
+// DefaultEgressPaths covers the standard DNS transports plus the alternative
+// ports some public resolvers also listen on to get past port-53 filtering.
+var DefaultEgressPaths = []EgressPath{
+	{"udp", 53}, {"tcp", 53}, {"dot", 853}, {"doh", 443},
+	{"udp", 443}, {"tcp", 443}, {"udp", 5353}, {"tcp", 5353},
+	{"udp", 5053}, {"udp", 8053},
+}
+
+// ParseEgressPaths parses "udp/53,tcp/53,dot/853,doh/443,...".
+func ParseEgressPaths(spec string) ([]EgressPath, error) {
+	var paths []EgressPath
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		transport, port, ok := strings.Cut(strings.ToLower(part), "/")
+		n, err := strconv.Atoi(port)
+		if !ok || err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid path %q (want transport/port)", part)
+		}
+		switch transport {
+		case "udp", "tcp", "dot", "doh":
+		default:
+			return nil, fmt.Errorf("unknown transport %q in %q (want udp, tcp, dot or doh)", transport, part)
+		}
+		paths = append(paths, EgressPath{Transport: transport, Port: n})
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no paths to check")
+	}
+	return paths, nil
+}
+
+// Egress verdicts. Open paths got a DNS reply. Filtered ones got nothing
+// back, the signature of a firewall dropping the traffic; refused ones were
+// actively rejected, by the host or by something on the way. TLS failures
+// on dot and doh often mean the connection was intercepted.
+const (
+	EgressOpen     = "open"
+	EgressFiltered = "filtered"
+	EgressRefused  = "refused"
+	EgressTLS      = "tls error"
+	EgressError    = "error"
+)
+
+type EgressResult struct {
+	Path    EgressPath
+	Verdict string
+	RCode   string
+	RTT     time.Duration
+	Err     error
+}
+
+// CheckEgress sends the same query to host over every path at once. TLSName
+// is the name dot and doh send as SNI and verify the certificate against,
+// and doh as the HTTP host; it defaults to host, which every path connects
+// to whatever TLSName says. DoHPath is the URL path of the doh endpoint.
+func CheckEgress(ctx context.Context, host string, paths []EgressPath, opts Options, tlsName, dohPath string) []EgressResult {
+	if tlsName == "" {
+		tlsName = host
+	}
+	out := make([]EgressResult, len(paths))
+	var wg sync.WaitGroup
+	for i, p := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[i] = checkEgressPath(ctx, host, p, opts, tlsName, dohPath)
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+func checkEgressPath(ctx context.Context, host string, p EgressPath, opts Options, tlsName, dohPath string) EgressResult {
+	res := EgressResult{Path: p}
+	m := new(dns.Msg)
+	m.SetQuestion(".", dns.TypeNS)
+	addr := net.JoinHostPort(host, strconv.Itoa(p.Port))
+
+	var resp *dns.Msg
+	var err error
+	switch p.Transport {
+	case "udp", "tcp":
+		opts.TCP = p.Transport == "tcp"
+		resp, res.RTT, err = Exchange(ctx, addr, m, opts)
+	case "dot":
+		resp, res.RTT, err = exchangeTLS(ctx, addr, m, opts, tlsName)
+	case "doh":
+		url := "https://" + net.JoinHostPort(tlsName, strconv.Itoa(p.Port)) + dohPath
+		resp, res.RTT, err = exchangeDoH(ctx, url, addr, m, opts)
+	}
+	res.Err = err
+	switch {
+	case err == nil:
+		res.Verdict, res.RCode = EgressOpen, dns.RcodeToString[resp.Rcode]
+	case IsTimeout(err) || errors.Is(err, context.DeadlineExceeded):
+		res.Verdict = EgressFiltered
+	case errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET):
+		res.Verdict = EgressRefused
+	case isTLSError(err):
+		res.Verdict = EgressTLS
+	default:
+		res.Verdict = EgressError
+	}
+	return res
+}
+
+// exchangeTLS sends msg over DNS over TLS (RFC 7858), verifying the
+// server's certificate against name.
+func exchangeTLS(ctx context.Context, server string, msg *dns.Msg, opts Options, name string) (*dns.Msg, time.Duration, error) {
+	if err := checkFamily(server, opts.Family); err != nil {
+		return nil, 0, err
+	}
+	ctx, cancel := probeContext(ctx, opts.Timeout)
+	defer cancel()
+	raw, _, err := opts.dial(ctx, opts.network("tcp"), server)
+	if err != nil {
+		return nil, 0, ctxError(ctx, err)
+	}
+	defer raw.Close()
+	defer bindConn(ctx, raw)()
+	conn := tls.Client(raw, &tls.Config{ServerName: name})
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return nil, 0, ctxError(ctx, err)
+	}
+	c := dns.Client{Net: "tcp-tls", Timeout: opts.Timeout}
+	resp, rtt, err := c.ExchangeWithConnContext(ctx, msg, &dns.Conn{Conn: conn})
+	return resp, rtt, ctxError(ctx, err)
+}
+
+func isTLSError(err error) bool {
+	var cv *tls.CertificateVerificationError
+	var rec tls.RecordHeaderError
+	var alert tls.AlertError
+	return errors.As(err, &cv) || errors.As(err, &rec) || errors.As(err, &alert) || strings.Contains(err.Error(), "tls: ")
+}
@@ -0,0 +1,33 @@
+package dnsprobe
+
+import (
+	"context"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Exchange sends msg as-is over the configured transport and returns the reply and round-trip time.
+// Unlike Probe it does not build or modify the message, so it can carry
+// deliberately unusual queries. Only the transport fields of opts are used.
+func Exchange(ctx context.Context, server string, msg *dns.Msg, opts Options) (*dns.Msg, time.Duration, error) {
+	server = NormalizeServer(server)
+	if err := checkFamily(server, opts.Family); err != nil {
+		return nil, 0, err
+	}
+	ctx, cancel := probeContext(ctx, opts.Timeout)
+	defer cancel()
+	network := opts.transport()
+	conn, _, err := opts.dial(ctx, network, server)
+	if err != nil {
+		return nil, 0, ctxError(ctx, err)
+	}
+	defer conn.Close()
+	defer bindConn(ctx, conn)()
+	if err := ctx.Err(); err != nil {
+		return nil, 0, ctxError(ctx, err)
+	}
+	c := dns.Client{Net: network, Timeout: opts.Timeout}
+	resp, rtt, err := c.ExchangeWithConnContext(ctx, msg, &dns.Conn{Conn: conn})
+	return resp, rtt, ctxError(ctx, err)
+}
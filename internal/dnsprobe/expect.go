@@ -0,0 +1,139 @@
+package dnsprobe
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Expectations maps domains to the answers they are allowed to return.
+type Expectations struct {
+	domains []string
+	values  map[string][]string
+}
+
+// Mismatch describes a probe whose answers did not match its expectation.
+type Mismatch struct {
+	Domain   string
+	Expected []string
+	Got      []string
+	RCode    string
+}
+
+func (m Mismatch) String() string {
+	got := strings.Join(m.Got, ",")
+	if got == "" {
+		got = "no answers (" + m.RCode + ")"
+	}
+	return fmt.Sprintf("%s: expected %s, got %s", m.Domain, strings.Join(m.Expected, ","), got)
+}
+
+// Add parses one "domain=value[,value...]" assertion. Repeating a domain
+// extends its list of acceptable values.
+func (e *Expectations) Add(spec string) error {
+	name, vals, ok := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf("invalid expectation %q (want domain=value[,value...])", spec)
+	}
+	key := expectKey(name)
+	if e.values == nil {
+		e.values = map[string][]string{}
+	}
+	if _, seen := e.values[key]; !seen {
+		e.domains = append(e.domains, name)
+	}
+	for _, v := range strings.Split(vals, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if ip := net.ParseIP(v); ip != nil {
+			v = ip.String()
+		}
+		e.values[key] = append(e.values[key], v)
+	}
+	if len(e.values[key]) == 0 {
+		return fmt.Errorf("expectation for %s has no values", name)
+	}
+	return nil
+}
+
+// AddFile reads assertions from path, one per line, in the same form as Add.
+// Blank lines and # comments are ignored.
+func (e *Expectations) AddFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(strings.SplitN(sc.Text(), "#", 2)[0])
+		if line == "" {
+			continue
+		}
+		if err := e.Add(line); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+	}
+	return sc.Err()
+}
+
+func (e *Expectations) Len() int {
+	return len(e.domains)
+}
+
+// Domains returns the asserted domains in the order they were added.
+func (e *Expectations) Domains() []string {
+	return e.domains
+}
+
+// Check compares r with the expectation for its name. Only expected values of
+// the queried type's address family are considered; an answer outside them,
+// or no answer at all, is a mismatch. It returns nil when r passes or nothing
+// is expected for it.
+func (e *Expectations) Check(r Result) *Mismatch {
+	want := e.values[expectKey(r.QName)]
+	if len(want) == 0 {
+		return nil
+	}
+
+	var expected []string
+	for _, v := range want {
+		ip := net.ParseIP(v)
+		switch {
+		case ip == nil:
+			expected = append(expected, v)
+		case r.QType == dns.TypeToString[dns.TypeA] && ip.To4() != nil,
+			r.QType == dns.TypeToString[dns.TypeAAAA] && ip.To4() == nil:
+			expected = append(expected, v)
+		}
+	}
+	if len(expected) == 0 {
+		return nil
+	}
+
+	m := &Mismatch{Domain: r.QName, Expected: expected, RCode: r.RCode}
+	m.Got = r.Addrs()
+	bad := len(m.Got) == 0
+	for _, a := range m.Got {
+		if !slices.Contains(expected, a) {
+			bad = true
+		}
+	}
+	if !bad {
+		return nil
+	}
+	return m
+}
+
+func expectKey(name string) string {
+	return strings.ToLower(dns.Fqdn(name))
+}
@@ -0,0 +1,61 @@
+package dnsprobe
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// ErrUnpack marks a reply that arrived but could not be parsed.
+var ErrUnpack = errors.New("malformed reply")
+
+// ErrorClass names the kind of failure err is, for error breakdowns:
+// "timeout", "conn refused" (an ICMP port unreachable, or a TCP reset),
+// "net unreachable" (no route to the network or host), "unpack" (see
+// ErrUnpack), "network" or "other".
+func ErrorClass(err error) string {
+	var ne net.Error
+	switch {
+	case IsTimeout(err):
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "conn refused"
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+		return "net unreachable"
+	case errors.Is(err, ErrUnpack):
+		return "unpack"
+	case errors.As(err, &ne):
+		return "network"
+	}
+	return "other"
+}
+
+// FailureClass names why a benchmark query failed: its ErrorClass when no
+// reply arrived; for a reply, its rcode when that is SERVFAIL, REFUSED or
+// FORMERR, or "truncated" when TC was set, which leaves the client to retry
+// over TCP. It returns "" for a query that succeeded; NXDOMAIN is an answer.
+func FailureClass(r Result, err error) string {
+	switch {
+	case err != nil:
+		return ErrorClass(err)
+	case r.RCode == "SERVFAIL", r.RCode == "REFUSED", r.RCode == "FORMERR":
+		return r.RCode
+	case r.Flags.TC:
+		return "truncated"
+	}
+	return ""
+}
+
+// FailureMeanings explains the classes FailureClass returns.
+var FailureMeanings = map[string]string{
+	"timeout":         "no reply within the timeout",
+	"conn refused":    "nothing listens on the port (ICMP port unreachable or TCP reset)",
+	"net unreachable": "no route to the server",
+	"unpack":          "reply could not be parsed",
+	"network":         "other network error",
+	"other":           "other error",
+	"SERVFAIL":        "server failed to resolve the name",
+	"REFUSED":         "server refused to answer, e.g. not an open resolver for this client",
+	"FORMERR":         "server could not parse the query",
+	"truncated":       "UDP reply with TC set; the answer needs TCP",
+}
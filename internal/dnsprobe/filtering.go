@@ -0,0 +1,199 @@
+package dnsprobe
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// FilterTarget is a domain that policy-filtering resolvers are expected to
+// block, tagged with its category. The "control" category marks domains that
+// must resolve normally.
+type FilterTarget struct {
+	Category string
+	Domain   string
+}
+
+// DefaultFilterTargets are public test domains maintained for checking
+// filtering resolvers (Cloudflare's testcategory.com, Quad9's isitblocked.org).
+var DefaultFilterTargets = []FilterTarget{
+	{"control", "example.com"},
+	{"malware", "malware.testcategory.com"},
+	{"malware", "isitblocked.org"},
+	{"phishing", "phishing.testcategory.com"},
+	{"adult", "nudity.testcategory.com"},
+}
+
+type FilterVerdict string
+
+const (
+	FilterAllowed   FilterVerdict = "allowed"
+	FilterNXDomain  FilterVerdict = "blocked (NXDOMAIN)"
+	FilterNullIP    FilterVerdict = "blocked (0.0.0.0)"
+	FilterBlockPage FilterVerdict = "blocked (block page)"
+	FilterRefused   FilterVerdict = "blocked (REFUSED)"
+	FilterRewritten FilterVerdict = "rewritten?"
+	FilterError     FilterVerdict = "error"
+)
+
+type FilterResult struct {
+	Target  FilterTarget
+	RCode   string
+	Addrs   []string
+	EDE     string
+	Verdict FilterVerdict
+	Err     error
+}
+
+// FilterConfig holds what is known about how the resolver blocks: BlockIPs
+// are its block-page addresses, and Reference, when set, is an unfiltered
+// resolver whose answers show what the domains really resolve to.
+type FilterConfig struct {
+	Targets   []FilterTarget
+	BlockIPs  []string
+	Reference string
+}
+
+// ReadFilterTargets reads "category domain" lines; # starts a comment.
+func ReadFilterTargets(path string) ([]FilterTarget, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []FilterTarget
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		fields := strings.Fields(strings.SplitN(sc.Text(), "#", 2)[0])
+		switch len(fields) {
+		case 0:
+			continue
+		case 2:
+			out = append(out, FilterTarget{Category: fields[0], Domain: fields[1]})
+		default:
+			return nil, fmt.Errorf("%s:%d: want \"category domain\"", path, n)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s: no targets found", path)
+	}
+	return out, nil
+}
+
+// CheckFiltering queries every target through server and classifies how, if
+// at all, it was blocked.
+func CheckFiltering(ctx context.Context, server string, cfg FilterConfig, opts Options) []FilterResult {
+	out := make([]FilterResult, 0, len(cfg.Targets))
+	for _, t := range cfg.Targets {
+		res := FilterResult{Target: t}
+		resp, err := filterQuery(ctx, server, t.Domain, opts)
+		if err != nil {
+			res.Err = err
+			res.Verdict = FilterError
+			out = append(out, res)
+			continue
+		}
+		res.RCode = dns.RcodeToString[resp.Rcode]
+		res.Addrs = answerAddrs(resp)
+		res.EDE = extendedError(resp)
+		res.Verdict = classifyFilter(ctx, resp, res.Addrs, t, cfg, opts)
+		out = append(out, res)
+	}
+	return out
+}
+
+func filterQuery(ctx context.Context, server, name string, opts Options) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(opts.wireName(name), dns.TypeA)
+	m.RecursionDesired = true
+	m.SetEdns0(1232, false)
+	resp, _, err := Exchange(ctx, server, m, opts)
+	return resp, err
+}
+
+func classifyFilter(ctx context.Context, resp *dns.Msg, addrs []string, t FilterTarget, cfg FilterConfig, opts Options) FilterVerdict {
+	switch resp.Rcode {
+	case dns.RcodeNameError:
+		return FilterNXDomain
+	case dns.RcodeRefused:
+		return FilterRefused
+	case dns.RcodeSuccess:
+	default:
+		return FilterError
+	}
+	for _, a := range addrs {
+		if a == "0.0.0.0" || a == "::" || strings.HasPrefix(a, "127.") {
+			return FilterNullIP
+		}
+		if slices.Contains(cfg.BlockIPs, a) {
+			return FilterBlockPage
+		}
+	}
+	if cfg.Reference == "" || len(addrs) == 0 {
+		return FilterAllowed
+	}
+
+	ref, err := filterQuery(ctx, cfg.Reference, t.Domain, opts)
+	if err != nil {
+		return FilterAllowed
+	}
+	refAddrs := answerAddrs(ref)
+	if ref.Rcode == dns.RcodeNameError && len(addrs) > 0 {
+		return FilterRewritten
+	}
+	for _, a := range addrs {
+		if slices.Contains(refAddrs, a) {
+			return FilterAllowed
+		}
+	}
+	return FilterRewritten
+}
+
+func answerAddrs(m *dns.Msg) []string {
+	var out []string
+	for _, rr := range m.Answer {
+		switch a := rr.(type) {
+		case *dns.A:
+			out = append(out, a.A.String())
+		case *dns.AAAA:
+			out = append(out, a.AAAA.String())
+		}
+	}
+	return out
+}
+
+// extendedError returns the RFC 8914 extended error in m, if any; filtering
+// resolvers use it to say a name was Blocked, Censored or Filtered.
+func extendedError(m *dns.Msg) string {
+	o := m.IsEdns0()
+	if o == nil {
+		return ""
+	}
+	for _, opt := range o.Option {
+		if e, ok := opt.(*dns.EDNS0_EDE); ok {
+			s := dns.ExtendedErrorCodeToString[e.InfoCode]
+			if s == "" {
+				s = fmt.Sprintf("EDE %d", e.InfoCode)
+			}
+			if e.ExtraText != "" {
+				s += ": " + e.ExtraText
+			}
+			return s
+		}
+	}
+	return ""
+}
+
+// IsBlocked reports whether v is one of the blocking verdicts.
+func (v FilterVerdict) IsBlocked() bool {
+	return strings.HasPrefix(string(v), "blocked") || v == FilterRewritten
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package dnsprobe
+
+import (
+	"errors"
+	"net"
+)
+
+// DefaultGateway is only implemented on Linux; elsewhere pass the gateway
+// as a server instead.
+func DefaultGateway() (net.IP, error) {
+	return nil, errors.New("default gateway detection is not supported on this platform")
+}
@@ -0,0 +1,124 @@
+package dnsprobe
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Lookup is the outcome of resolving a name to addresses through one of the
+// resolution paths an application might use.
+type Lookup struct {
+	Path     string
+	Duration time.Duration
+	Addrs    []string
+	Err      error
+}
+
+// LookupWireDual sends A and AAAA wire probes in parallel, which is what Go's
+// resolver and most stubs do for a host lookup, and times the pair.
+func LookupWireDual(ctx context.Context, server, name string, opts Options) Lookup {
+	out := Lookup{Path: "wire A+AAAA"}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	start := time.Now()
+	for _, qt := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		o := opts
+		o.Type = qt
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := Probe(ctx, server, name, o)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			out.Addrs = append(out.Addrs, r.Addrs()...)
+		}()
+	}
+	wg.Wait()
+	out.Duration = time.Since(start)
+	if len(errs) == 2 {
+		out.Err = errs[0]
+	}
+	return out
+}
+
+// LookupGo resolves name with Go's pure-Go resolver, pinned to server.
+func LookupGo(ctx context.Context, server, name string, opts Options) Lookup {
+	server = NormalizeServer(server)
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			// The resolver asks for tcp only to retry truncated answers.
+			if network[:3] == "tcp" {
+				network = opts.network("tcp")
+			} else {
+				network = opts.transport()
+			}
+			conn, _, err := opts.dial(ctx, network, server)
+			return conn, err
+		},
+	}
+	return lookupWith(ctx, r, "go resolver", name, opts.Timeout)
+}
+
+// LookupDefault resolves name the way a Go program does by default: through
+// the system configuration, using cgo/getaddrinfo or the pure-Go resolver as
+// the runtime decides (GODEBUG=netdns=go|cgo forces one).
+func LookupDefault(ctx context.Context, name string, timeout time.Duration) Lookup {
+	return lookupWith(ctx, &net.Resolver{}, "go default", name, timeout)
+}
+
+func lookupWith(ctx context.Context, r *net.Resolver, path, name string, timeout time.Duration) Lookup {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	out := Lookup{Path: path}
+	start := time.Now()
+	// Fully qualified so the resolver skips search-list expansion.
+	addrs, err := r.LookupHost(ctx, dns.Fqdn(name))
+	out.Duration = time.Since(start)
+	out.Addrs = addrs
+	out.Err = err
+	return out
+}
+
+// LookupStub resolves name through the operating system's stub resolver
+// (getaddrinfo when built with cgo). name is passed as given, so search-list
+// and ndots processing apply. getaddrinfo cannot be cancelled; on timeout the
+// call is abandoned and finishes in the background.
+func LookupStub(ctx context.Context, name string, timeout time.Duration) Lookup {
+	out := Lookup{Path: stubPath}
+
+	type result struct {
+		addrs []string
+		err   error
+	}
+	ch := make(chan result, 1)
+	start := time.Now()
+	go func() {
+		addrs, err := stubLookup(name)
+		ch <- result{addrs, err}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	select {
+	case r := <-ch:
+		out.Duration = time.Since(start)
+		out.Addrs, out.Err = r.addrs, r.err
+	case <-ctx.Done():
+		out.Duration = time.Since(start)
+		out.Err = ctx.Err()
+	}
+	return out
+}
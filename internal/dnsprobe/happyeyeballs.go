@@ -0,0 +1,216 @@
+package dnsprobe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// HappyEyeballsConfig holds the RFC 8305 timers.
+type HappyEyeballsConfig struct {
+	ResolutionDelay time.Duration
+	AttemptDelay    time.Duration
+	ConnectTimeout  time.Duration
+}
+
+var DefaultHappyEyeballsConfig = HappyEyeballsConfig{
+	ResolutionDelay: 50 * time.Millisecond,
+	AttemptDelay:    250 * time.Millisecond,
+	ConnectTimeout:  5 * time.Second,
+}
+
+// HEQuery is one of the two address lookups; At is measured from the start of
+// the whole operation.
+type HEQuery struct {
+	Type  string
+	At    time.Duration
+	Addrs []string
+	Err   error
+}
+
+type HEAttempt struct {
+	Addr   string
+	Family int
+	Start  time.Duration
+	End    time.Duration
+	Err    error
+	Won    bool
+}
+
+type HappyEyeballsResult struct {
+	Queries      []HEQuery
+	Attempts     []HEAttempt
+	FirstAttempt time.Duration
+	Total        time.Duration
+	Winner       int
+	Err          error
+}
+
+// HappyEyeballs resolves host through server with parallel AAAA and A
+// queries and races staggered TCP connections to the results per RFC 8305:
+// connecting starts as soon as AAAA answers (or ResolutionDelay after A
+// answers first), addresses alternate between families starting with IPv6,
+// and a new attempt starts every AttemptDelay or as soon as one fails.
+func HappyEyeballs(ctx context.Context, server, host, port string, opts Options, cfg HappyEyeballsConfig) HappyEyeballsResult {
+	res := HappyEyeballsResult{Winner: -1}
+	start := time.Now()
+	since := func() time.Duration { return time.Since(start) }
+
+	type dnsAnswer struct {
+		family int
+		q      HEQuery
+	}
+	dnsCh := make(chan dnsAnswer, 2)
+	for _, fam := range []int{6, 4} {
+		go func(fam int) {
+			o := opts
+			o.Type = dns.TypeA
+			if fam == 6 {
+				o.Type = dns.TypeAAAA
+			}
+			r, err := Probe(ctx, server, host, o)
+			q := HEQuery{Type: dns.TypeToString[o.Type], At: since(), Err: err}
+			if err == nil {
+				for _, a := range r.Answers {
+					if ip := net.ParseIP(a.Value); ip != nil && (ip.To4() == nil) == (fam == 6) {
+						q.Addrs = append(q.Addrs, a.Value)
+					}
+				}
+			}
+			dnsCh <- dnsAnswer{fam, q}
+		}(fam)
+	}
+
+	type attemptResult struct {
+		idx  int
+		conn net.Conn
+		err  error
+		at   time.Duration
+	}
+	actx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	attemptCh := make(chan attemptResult)
+
+	var (
+		queues     = map[int][]string{}
+		lastFamily = 4
+		dnsDone    int
+		started    bool
+		inFlight   int
+		resDelay   <-chan time.Time
+		nextTimer  <-chan time.Time
+	)
+
+	popNext := func() (string, int, bool) {
+		for _, fam := range []int{10 - lastFamily, lastFamily} {
+			if q := queues[fam]; len(q) > 0 {
+				queues[fam] = q[1:]
+				lastFamily = fam
+				return q[0], fam, true
+			}
+		}
+		return "", 0, false
+	}
+
+	startAttempt := func() bool {
+		addr, fam, ok := popNext()
+		if !ok {
+			return false
+		}
+		if len(res.Attempts) == 0 {
+			res.FirstAttempt = since()
+		}
+		idx := len(res.Attempts)
+		res.Attempts = append(res.Attempts, HEAttempt{Addr: addr, Family: fam, Start: since()})
+		inFlight++
+		nextTimer = time.After(cfg.AttemptDelay)
+
+		network := "tcp4"
+		if fam == 6 {
+			network = "tcp6"
+		}
+		go func() {
+			target := net.JoinHostPort(addr, port)
+			d, err := opts.dialer(network, target)
+			var conn net.Conn
+			if err == nil {
+				d.Timeout = cfg.ConnectTimeout
+				conn, err = d.DialContext(actx, network, target)
+			}
+			attemptCh <- attemptResult{idx: idx, conn: conn, err: err, at: since()}
+		}()
+		return true
+	}
+
+	finish := func(err error) HappyEyeballsResult {
+		res.Total = since()
+		res.Err = err
+		// Let losing attempts drain so their goroutines exit.
+		go func(n int) {
+			for ; n > 0; n-- {
+				if r := <-attemptCh; r.conn != nil {
+					r.conn.Close()
+				}
+			}
+		}(inFlight)
+		return res
+	}
+
+	for {
+		select {
+		case a := <-dnsCh:
+			dnsDone++
+			res.Queries = append(res.Queries, a.q)
+			queues[a.family] = append(queues[a.family], a.q.Addrs...)
+			switch {
+			case started:
+				if nextTimer == nil || inFlight == 0 {
+					startAttempt()
+				}
+			case a.family == 6 && len(a.q.Addrs) > 0, dnsDone == 2:
+				started = true
+				startAttempt()
+			case a.family == 4 && len(a.q.Addrs) > 0:
+				resDelay = time.After(cfg.ResolutionDelay)
+			}
+
+		case <-resDelay:
+			resDelay = nil
+			if !started {
+				started = true
+				startAttempt()
+			}
+
+		case <-nextTimer:
+			nextTimer = nil
+			startAttempt()
+
+		case r := <-attemptCh:
+			inFlight--
+			at := &res.Attempts[r.idx]
+			at.End = r.at
+			at.Err = r.err
+			if r.err == nil {
+				at.Won = true
+				res.Winner = r.idx
+				r.conn.Close()
+				cancel()
+				return finish(nil)
+			}
+			startAttempt()
+
+		case <-ctx.Done():
+			return finish(ctx.Err())
+		}
+
+		if started && inFlight == 0 && dnsDone == 2 && len(queues[4]) == 0 && len(queues[6]) == 0 {
+			if len(res.Attempts) == 0 {
+				return finish(errors.New("no addresses found"))
+			}
+			return finish(errors.New("all connection attempts failed"))
+		}
+	}
+}
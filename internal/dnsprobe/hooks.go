@@ -0,0 +1,28 @@
+package dnsprobe
+
+// Hooks report the progress of BenchmarkSerial, BenchmarkConcurrent and Soak
+// query by query, so a UI can show partial results before the aggregate is
+// returned. Seq numbers the queries of one run from 0. Any hook may be nil;
+// they are called from the probing goroutines, so they must be safe for
+// concurrent use and return quickly.
+type Hooks struct {
+	OnProbeStart func(seq int, name string)
+	OnProbeDone  func(seq int, r Result)
+	OnError      func(seq int, r Result, err error)
+}
+
+func (h *Hooks) start(seq int, name string) {
+	if h != nil && h.OnProbeStart != nil {
+		h.OnProbeStart(seq, name)
+	}
+}
+
+func (h *Hooks) done(seq int, r Result, err error) {
+	switch {
+	case h == nil:
+	case err != nil && h.OnError != nil:
+		h.OnError(seq, r, err)
+	case err == nil && h.OnProbeDone != nil:
+		h.OnProbeDone(seq, r)
+	}
+}
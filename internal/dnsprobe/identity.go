@@ -0,0 +1,65 @@
+package dnsprobe
+
+import (
+	"context"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// IdentityItem is one thing a server was asked about itself and its reply.
+type IdentityItem struct {
+	Source string
+	Value  string
+	RCode  string
+	Err    error
+}
+
+// identityNames are the CHAOS TXT names servers conventionally answer with
+// their software version (version.*) or instance name (hostname.bind,
+// id.server).
+var identityNames = []string{"version.bind", "version.server", "hostname.bind", "id.server"}
+
+// Identify asks server for its CHAOS TXT identity records and NSID and
+// returns whatever it reveals; refusals are reported as such.
+func Identify(ctx context.Context, server string, opts Options) []IdentityItem {
+	var out []IdentityItem
+	for _, name := range identityNames {
+		m := new(dns.Msg)
+		m.SetQuestion(dns.Fqdn(name), dns.TypeTXT)
+		m.Question[0].Qclass = dns.ClassCHAOS
+		m.RecursionDesired = false
+
+		item := IdentityItem{Source: "CH TXT " + name}
+		resp, _, err := Exchange(ctx, server, m, opts)
+		if err != nil {
+			item.Err = err
+			out = append(out, item)
+			continue
+		}
+		item.RCode = dns.RcodeToString[resp.Rcode]
+		var vals []string
+		for _, rr := range resp.Answer {
+			if t, ok := rr.(*dns.TXT); ok {
+				vals = append(vals, strings.Join(t.Txt, ""))
+			}
+		}
+		item.Value = strings.Join(vals, ", ")
+		out = append(out, item)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(".", dns.TypeNS)
+	m.SetEdns0(1232, false)
+	o := m.IsEdns0()
+	o.Option = append(o.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+	item := IdentityItem{Source: "NSID (RFC 5001)"}
+	resp, _, err := Exchange(ctx, server, m, opts)
+	if err != nil {
+		item.Err = err
+	} else {
+		item.RCode = dns.RcodeToString[resp.Rcode]
+		item.Value = responseNSID(resp)
+	}
+	return append(out, item)
+}
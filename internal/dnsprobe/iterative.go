@@ -0,0 +1,333 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/rand"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// IterStep is one query of an iterative resolution: which server of which
+// zone was asked, and what it said. Level is the delegation level the
+// query's time counts towards: the zone, or "address of <ns>" for lookups
+// of a nameserver that came without glue. Err is set for attempts that got
+// no usable reply, after which the next server was tried.
+type IterStep struct {
+	Level   string
+	Zone    string
+	Server  string
+	Addr    string
+	QName   string
+	RTT     time.Duration
+	Outcome string
+	Err     error
+}
+
+// Iteration is a name resolved from the root hints down, without a
+// recursive resolver. Took is the wall time of the whole resolution.
+type Iteration struct {
+	Name   string
+	Type   uint16
+	Steps  []IterStep
+	Answer []dns.RR
+	RCode  string
+	Took   time.Duration
+}
+
+// IterLevel is the time spent at one delegation level.
+type IterLevel struct {
+	Level   string
+	Time    time.Duration
+	Queries int
+}
+
+// Levels sums the query times per delegation level, in the order the levels
+// were first reached.
+func (it Iteration) Levels() []IterLevel {
+	var out []IterLevel
+	for _, s := range it.Steps {
+		i := slices.IndexFunc(out, func(l IterLevel) bool { return l.Level == s.Level })
+		if i < 0 {
+			out = append(out, IterLevel{Level: s.Level})
+			i = len(out) - 1
+		}
+		out[i].Time += s.RTT
+		out[i].Queries++
+	}
+	return out
+}
+
+// Limits of one iterative resolution: queries in all, CNAMEs followed and
+// how deep lookups of glueless nameservers may nest.
+const (
+	iterMaxQueries = 60
+	iterMaxCNAMEs  = 8
+	iterMaxDepth   = 4
+)
+
+// iterator resolves iteratively, remembering the delegations it has seen
+// so CNAME targets and nameserver lookups start at the closest known zone.
+type iterator struct {
+	opts    Options
+	cache   map[string]map[string][]string
+	queries int
+	steps   []IterStep
+}
+
+// ResolveIterative resolves name the way a recursive resolver does with a
+// cold cache: it asks a root server from hints (server names to addresses),
+// follows the referrals to the authoritative servers and restarts at the
+// closest known zone for each CNAME, timing every query.
+func ResolveIterative(ctx context.Context, name string, qtype uint16, hints map[string][]string, opts Options) (Iteration, error) {
+	it := Iteration{Name: dns.Fqdn(name), Type: qtype}
+	r := &iterator{opts: opts, cache: map[string]map[string][]string{".": maps.Clone(hints)}}
+	start := time.Now()
+	resp, err := r.resolve(ctx, it.Name, qtype, 0, "")
+	it.Took = time.Since(start)
+	it.Steps = r.steps
+	if err != nil {
+		return it, err
+	}
+	it.RCode = dns.RcodeToString[resp.Rcode]
+	it.Answer = resp.Answer
+	return it, nil
+}
+
+// resolve returns the final reply for name, with the CNAMEs followed on
+// the way prepended to its answer section. Its queries count towards level,
+// or towards the zone asked when level is empty.
+func (r *iterator) resolve(ctx context.Context, name string, qtype uint16, depth int, level string) (*dns.Msg, error) {
+	var chain []dns.RR
+	for cnames := 0; ; cnames++ {
+		resp, err := r.lookup(ctx, name, qtype, depth, level)
+		if err != nil {
+			return nil, err
+		}
+		target := ""
+		for _, rr := range resp.Answer {
+			if strings.EqualFold(rr.Header().Name, name) && rr.Header().Rrtype == qtype {
+				target = ""
+				break
+			}
+			if c, ok := rr.(*dns.CNAME); ok && strings.EqualFold(c.Hdr.Name, name) {
+				target = c.Target
+			}
+		}
+		if target == "" || qtype == dns.TypeCNAME {
+			resp.Answer = append(chain, resp.Answer...)
+			return resp, nil
+		}
+		if cnames == iterMaxCNAMEs {
+			return nil, fmt.Errorf("more than %d CNAMEs from %s", iterMaxCNAMEs, name)
+		}
+		chain = append(chain, resp.Answer...)
+		name = dns.Fqdn(target)
+	}
+}
+
+// lookup follows referrals for one name, starting at the closest zone in
+// the cache, until a server answers with authority.
+func (r *iterator) lookup(ctx context.Context, name string, qtype uint16, depth int, level string) (*dns.Msg, error) {
+	zone := r.closest(name)
+	lvl := level
+	if lvl == "" {
+		lvl = zone
+	}
+	for {
+		resp, err := r.ask(ctx, zone, lvl, name, qtype, depth)
+		if err != nil {
+			return nil, err
+		}
+		child, servers := referral(resp, zone, name)
+		if child == "" {
+			return resp, nil
+		}
+		r.cache[child] = servers
+		r.steps[len(r.steps)-1].Outcome = "referral to " + child
+		zone = child
+		if level == "" {
+			lvl = zone
+		}
+	}
+}
+
+// closest is the deepest cached zone that name is in.
+func (r *iterator) closest(name string) string {
+	best := "."
+	for z := range r.cache {
+		if dns.IsSubDomain(z, name) && dns.CountLabel(z) > dns.CountLabel(best) {
+			best = z
+		}
+	}
+	return best
+}
+
+// ask sends name to the servers of zone, in random order, until one gives
+// a usable reply: an answer, a referral below zone or an authoritative
+// negative answer. Nameservers without addresses are looked up first.
+func (r *iterator) ask(ctx context.Context, zone, level, name string, qtype uint16, depth int) (*dns.Msg, error) {
+	servers := r.cache[zone]
+	names := make([]string, 0, len(servers))
+	for ns := range servers {
+		names = append(names, ns)
+	}
+	slices.Sort(names)
+	rand.Shuffle(len(names), func(i, j int) { names[i], names[j] = names[j], names[i] })
+	// Prefer servers that came with glue.
+	slices.SortStableFunc(names, func(a, b string) int {
+		return min(len(servers[b]), 1) - min(len(servers[a]), 1)
+	})
+
+	var last error
+	for _, ns := range names {
+		addrs := servers[ns]
+		if len(addrs) == 0 {
+			if depth == iterMaxDepth || dns.IsSubDomain(zone, ns) {
+				continue
+			}
+			addrs = r.nsAddrs(ctx, ns, depth+1)
+			servers[ns] = addrs
+		}
+		for _, a := range addrs {
+			if !familyOK(a, r.opts.Family) {
+				continue
+			}
+			if r.queries == iterMaxQueries {
+				return nil, fmt.Errorf("gave up after %d queries", iterMaxQueries)
+			}
+			r.queries++
+			resp, step := r.query(ctx, zone, ns, a, name, qtype)
+			step.Level = level
+			r.steps = append(r.steps, step)
+			if step.Err == nil {
+				return resp, nil
+			}
+			last = step.Err
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+		}
+	}
+	if last == nil {
+		last = fmt.Errorf("no reachable nameserver address")
+	}
+	return nil, fmt.Errorf("%s: no server answered for %s: %w", zone, name, last)
+}
+
+// nsAddrs resolves the addresses of a nameserver that came without glue.
+func (r *iterator) nsAddrs(ctx context.Context, ns string, depth int) []string {
+	var out []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		if r.opts.Family == 4 && qtype == dns.TypeAAAA || r.opts.Family == 6 && qtype == dns.TypeA {
+			continue
+		}
+		resp, err := r.resolve(ctx, ns, qtype, depth, "address of "+ns)
+		if err != nil {
+			continue
+		}
+		for _, rr := range resp.Answer {
+			switch rr := rr.(type) {
+			case *dns.A:
+				out = append(out, rr.A.String())
+			case *dns.AAAA:
+				out = append(out, rr.AAAA.String())
+			}
+		}
+	}
+	return out
+}
+
+// query sends one non-recursive query, retrying over TCP when truncated.
+func (r *iterator) query(ctx context.Context, zone, ns, addr, name string, qtype uint16) (*dns.Msg, IterStep) {
+	step := IterStep{Zone: zone, Server: ns, Addr: net.JoinHostPort(addr, "53"), QName: name}
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.RecursionDesired = false
+	m.SetEdns0(1232, false)
+	resp, rtt, err := Exchange(ctx, step.Addr, m, r.opts)
+	step.RTT = rtt
+	if err == nil && resp.Truncated {
+		tcp := r.opts
+		tcp.TCP = true
+		resp, rtt, err = Exchange(ctx, step.Addr, m, tcp)
+		step.RTT += rtt
+	}
+	switch {
+	case err != nil:
+		step.Err = err
+	case resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError:
+		step.Err = fmt.Errorf("%s", dns.RcodeToString[resp.Rcode])
+	case len(resp.Answer) > 0:
+		step.Outcome = "answer"
+		for _, rr := range resp.Answer {
+			if c, ok := rr.(*dns.CNAME); ok && strings.EqualFold(c.Hdr.Name, name) {
+				step.Outcome = "CNAME to " + c.Target
+			}
+		}
+	case resp.Authoritative:
+		step.Outcome = dns.RcodeToString[resp.Rcode]
+		if resp.Rcode == dns.RcodeSuccess {
+			step.Outcome = "NODATA"
+		}
+	default:
+		if child, _ := referral(resp, zone, name); child == "" {
+			step.Err = fmt.Errorf("lame: neither an answer nor a referral")
+		}
+	}
+	if step.Err != nil {
+		return nil, step
+	}
+	return resp, step
+}
+
+// referral returns the zone below zone that resp delegates name to, with
+// its nameservers and their glue, or "" when resp is not a referral.
+func referral(resp *dns.Msg, zone, name string) (string, map[string][]string) {
+	if len(resp.Answer) > 0 {
+		return "", nil
+	}
+	child := ""
+	servers := map[string][]string{}
+	for _, rr := range resp.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+		owner := strings.ToLower(ns.Hdr.Name)
+		if owner == strings.ToLower(zone) || !dns.IsSubDomain(zone, owner) || !dns.IsSubDomain(owner, name) {
+			continue
+		}
+		child = owner
+		servers[strings.ToLower(ns.Ns)] = nil
+	}
+	if child == "" {
+		return "", nil
+	}
+	for _, rr := range resp.Extra {
+		host := strings.ToLower(rr.Header().Name)
+		if _, ok := servers[host]; !ok {
+			continue
+		}
+		switch rr := rr.(type) {
+		case *dns.A:
+			servers[host] = append(servers[host], rr.A.String())
+		case *dns.AAAA:
+			servers[host] = append(servers[host], rr.AAAA.String())
+		}
+	}
+	return child, servers
+}
+
+func familyOK(addr string, family int) bool {
+	if family == 0 {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	return ip != nil && (ip.To4() != nil) == (family == 4)
+}
@@ -0,0 +1,423 @@
+package dnsprobe
+
+import (
+	"bufio"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ErrNoRecord is the error of a mail record that is not published.
+var ErrNoRecord = errors.New("no record")
+
+// SPFLookupLimit is the number of DNS-querying SPF terms a receiver
+// evaluates before failing with permerror (RFC 7208, section 4.6.4).
+const SPFLookupLimit = 10
+
+// DefaultDKIMSelectors are tried when no selectors are given; DKIM keys can
+// only be found by name.
+var DefaultDKIMSelectors = []string{"default", "selector1", "selector2", "google", "k1", "k2", "s1", "s2", "dkim", "mail"}
+
+// MailHost is one MX of a domain and the addresses its name resolves to.
+type MailHost struct {
+	Preference uint16
+	Host       string
+	Addrs      []string
+}
+
+// SPFRecord is one record of an SPF include chain: the record of Domain and
+// the ones its include: and redirect= terms pull in. Lookups counts its own
+// terms that cost a DNS lookup, All is its all mechanism with qualifier
+// ("~all") and Err is set when the record is missing, duplicated or loops.
+type SPFRecord struct {
+	Domain   string
+	Record   string
+	Lookups  int
+	All      string
+	Redirect bool
+	Children []*SPFRecord
+	Err      error
+}
+
+// TotalLookups counts the lookups of r and everything it includes.
+func (r *SPFRecord) TotalLookups() int {
+	n := r.Lookups
+	for _, c := range r.Children {
+		n += c.TotalLookups()
+	}
+	return n
+}
+
+// DKIMKey is the key published for one DKIM selector. Bits is the size of
+// an RSA key; Revoked is set when the key is published empty.
+type DKIMKey struct {
+	Selector string
+	Record   string
+	KeyType  string
+	Bits     int
+	Revoked  bool
+	Err      error
+}
+
+// MTASTS is a domain's MTA-STS record and the policy it announces (RFC
+// 8461); Err is set when the policy cannot be fetched or read.
+type MTASTS struct {
+	Record string
+	Mode   string
+	MaxAge int
+	MX     []string
+	Err    error
+}
+
+// MailReport is a domain's mail posture as its DNS (and, for MTA-STS, its
+// policy host) publishes it. DKIMGuessed is set when the selectors were
+// DefaultDKIMSelectors, and then DKIM only holds the keys found.
+// ReportAuth maps each DMARC report destination outside the domain to
+// whether it accepts reports for it (RFC 7489, section 7.1).
+type MailReport struct {
+	Domain      string
+	MX          []MailHost
+	NullMX      bool
+	SPF         *SPFRecord
+	DMARC       string
+	DMARCErr    error
+	DMARCTags   map[string]string
+	ReportAuth  map[string]bool
+	DKIM        []DKIMKey
+	DKIMGuessed bool
+	MTASTS      MTASTS
+	TLSRPT      string
+}
+
+// AuditMail looks up domain's MX, SPF (expanding includes), DMARC, the DKIM
+// keys of selectors, MTA-STS and TLS-RPT through server. Missing records are
+// part of the report; only failing to get an MX answer at all is an error.
+func AuditMail(ctx context.Context, server, domain string, selectors []string, opts Options) (MailReport, error) {
+	r := MailReport{Domain: strings.ToLower(dns.Fqdn(domain))}
+	resp, err := ttlQuery(ctx, server, r.Domain, dns.TypeMX, true, opts)
+	if err != nil {
+		return r, fmt.Errorf("MX: %w", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return r, fmt.Errorf("MX %s: %s", r.Domain, dns.RcodeToString[resp.Rcode])
+	}
+	for _, rr := range resp.Answer {
+		mx, ok := rr.(*dns.MX)
+		if !ok {
+			continue
+		}
+		if mx.Mx == "." {
+			r.NullMX = true
+			continue
+		}
+		r.MX = append(r.MX, MailHost{Preference: mx.Preference, Host: strings.ToLower(mx.Mx), Addrs: hostAddrs(ctx, server, mx.Mx, opts)})
+	}
+	slices.SortStableFunc(r.MX, func(a, b MailHost) int { return int(a.Preference) - int(b.Preference) })
+
+	r.SPF = spfChain(ctx, server, r.Domain, opts, map[string]bool{}, 0)
+
+	dmarc, err := prefixedTXT(ctx, server, "_dmarc."+r.Domain, "v=DMARC1", opts)
+	switch {
+	case err != nil:
+		r.DMARCErr = err
+	case len(dmarc) > 1:
+		r.DMARC, r.DMARCErr = dmarc[0], fmt.Errorf("%d DMARC records; receivers ignore them all", len(dmarc))
+	default:
+		r.DMARC = dmarc[0]
+	}
+	if r.DMARC != "" {
+		r.DMARCTags = tagList(r.DMARC)
+		r.ReportAuth = map[string]bool{}
+		for _, tag := range []string{"rua", "ruf"} {
+			for _, uri := range strings.Split(r.DMARCTags[tag], ",") {
+				_, addr, ok := strings.Cut(strings.TrimSpace(uri), "@")
+				if !ok {
+					continue
+				}
+				dest := strings.ToLower(dns.Fqdn(strings.SplitN(addr, "!", 2)[0]))
+				if _, done := r.ReportAuth[dest]; done || dns.IsSubDomain(r.Domain, dest) || dns.IsSubDomain(dest, r.Domain) {
+					continue
+				}
+				auth, _ := prefixedTXT(ctx, server, r.Domain+"_report._dmarc."+dest, "v=DMARC1", opts)
+				r.ReportAuth[dest] = len(auth) > 0
+			}
+		}
+	}
+
+	if len(selectors) == 0 {
+		selectors, r.DKIMGuessed = DefaultDKIMSelectors, true
+	}
+	for _, sel := range selectors {
+		k := dkimKey(ctx, server, sel, r.Domain, opts)
+		if r.DKIMGuessed && errors.Is(k.Err, ErrNoRecord) {
+			continue
+		}
+		r.DKIM = append(r.DKIM, k)
+	}
+
+	if sts, err := prefixedTXT(ctx, server, "_mta-sts."+r.Domain, "v=STSv1", opts); err == nil {
+		r.MTASTS = fetchMTASTS(ctx, r.Domain, sts[0], opts)
+	}
+	if rpt, err := prefixedTXT(ctx, server, "_smtp._tls."+r.Domain, "v=TLSRPTv1", opts); err == nil {
+		r.TLSRPT = rpt[0]
+	}
+	return r, nil
+}
+
+// hostAddrs resolves host to its IPv4 and IPv6 addresses.
+func hostAddrs(ctx context.Context, server, host string, opts Options) []string {
+	var addrs []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		resp, err := ttlQuery(ctx, server, dns.Fqdn(host), qtype, true, opts)
+		if err != nil {
+			continue
+		}
+		for _, rr := range resp.Answer {
+			switch a := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, a.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, a.AAAA.String())
+			}
+		}
+	}
+	return addrs
+}
+
+// prefixedTXT returns the TXT records of name that start with prefix, as
+// mail records are told apart; none is ErrNoRecord.
+func prefixedTXT(ctx context.Context, server, name, prefix string, opts Options) ([]string, error) {
+	txts, err := txtStrings(ctx, server, name, opts)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, s := range txts {
+		if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) && (len(s) == len(prefix) || s[len(prefix)] == ' ' || s[len(prefix)] == ';') {
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrNoRecord
+	}
+	return out, nil
+}
+
+// txtStrings returns the TXT records of name, each with its strings joined.
+func txtStrings(ctx context.Context, server, name string, opts Options) ([]string, error) {
+	resp, err := ttlQuery(ctx, server, dns.Fqdn(name), dns.TypeTXT, true, opts)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s TXT: %s", name, dns.RcodeToString[resp.Rcode])
+	}
+	var out []string
+	for _, rr := range resp.Answer {
+		if t, ok := rr.(*dns.TXT); ok {
+			out = append(out, strings.Join(t.Txt, ""))
+		}
+	}
+	return out, nil
+}
+
+// tagList parses "k=v; k=v" records (DMARC, DKIM, MTA-STS, TLS-RPT), with
+// lower-case keys.
+func tagList(s string) map[string]string {
+	tags := map[string]string{}
+	for _, part := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if ok {
+			tags[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	return tags
+}
+
+// spfChain fetches domain's SPF record and, depth first, the records of its
+// include: and redirect= targets.
+func spfChain(ctx context.Context, server, domain string, opts Options, seen map[string]bool, depth int) *SPFRecord {
+	r := &SPFRecord{Domain: domain}
+	if seen[domain] {
+		r.Err = errors.New("include loop")
+		return r
+	}
+	// seen holds the records above this one only: a domain included twice
+	// from different branches is evaluated, and its lookups counted, twice.
+	seen[domain] = true
+	defer delete(seen, domain)
+	recs, err := prefixedTXT(ctx, server, domain, "v=spf1", opts)
+	switch {
+	case err != nil:
+		r.Err = err
+		return r
+	case len(recs) > 1:
+		r.Err = fmt.Errorf("%d SPF records (permerror)", len(recs))
+		return r
+	}
+	r.Record = recs[0]
+
+	var targets []string
+	for _, term := range strings.Fields(r.Record)[1:] {
+		t := strings.ToLower(term)
+		mech := strings.TrimLeft(t, "+-~?")
+		name, arg, _ := strings.Cut(mech, ":")
+		name, _, _ = strings.Cut(name, "/")
+		switch {
+		case strings.HasPrefix(mech, "redirect="):
+			r.Lookups++
+			r.Redirect = true
+			targets = append(targets, strings.TrimPrefix(mech, "redirect="))
+		case name == "include":
+			r.Lookups++
+			targets = append(targets, arg)
+		case name == "a" || name == "mx" || name == "ptr" || name == "exists":
+			r.Lookups++
+		case name == "all":
+			r.All = t
+			if r.All == "all" {
+				r.All = "+all"
+			}
+		}
+	}
+	for _, target := range targets {
+		// Macros expand per message, so their targets cannot be followed.
+		if strings.Contains(target, "%{") || depth >= SPFLookupLimit {
+			continue
+		}
+		r.Children = append(r.Children, spfChain(ctx, server, strings.ToLower(dns.Fqdn(target)), opts, seen, depth+1))
+	}
+	return r
+}
+
+func dkimKey(ctx context.Context, server, selector, domain string, opts Options) DKIMKey {
+	k := DKIMKey{Selector: selector}
+	name := selector + "._domainkey." + domain
+	txts, err := txtStrings(ctx, server, name, opts)
+	if err != nil {
+		k.Err = err
+		return k
+	}
+	// v= is optional in key records (RFC 6376, section 3.6.1); the p= tag
+	// is not.
+	var tags map[string]string
+	for _, t := range txts {
+		tags = tagList(t)
+		if _, ok := tags["p"]; ok {
+			k.Record = t
+			break
+		}
+	}
+	if k.Record == "" {
+		k.Err = ErrNoRecord
+		return k
+	}
+	k.KeyType = strings.ToLower(tags["k"])
+	if k.KeyType == "" {
+		k.KeyType = "rsa"
+	}
+	p := strings.Join(strings.Fields(tags["p"]), "")
+	if p == "" {
+		k.Revoked = true
+		return k
+	}
+	if k.KeyType != "rsa" {
+		return k
+	}
+	der, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		k.Err = fmt.Errorf("p=: %w", err)
+		return k
+	}
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		if rk, ok := pub.(*rsa.PublicKey); ok {
+			k.Bits = rk.N.BitLen()
+		}
+	} else if rk, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		k.Bits = rk.N.BitLen()
+	} else {
+		k.Err = fmt.Errorf("p=: not an RSA public key")
+	}
+	return k
+}
+
+// fetchMTASTS reads the policy announced by record from the domain's
+// policy host.
+func fetchMTASTS(ctx context.Context, domain, record string, opts Options) MTASTS {
+	s := MTASTS{Record: record}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	url := "https://mta-sts." + strings.TrimSuffix(domain, ".") + "/.well-known/mta-sts.txt"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		s.Err = err
+		return s
+	}
+	// Senders must not follow redirects for the policy (RFC 8461, section
+	// 3.3), so a redirect is reported as the status it is.
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Do(req)
+	if err != nil {
+		s.Err = err
+		return s
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s.Err = fmt.Errorf("%s: %s", url, resp.Status)
+		return s
+	}
+	version := ""
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		switch strings.TrimSpace(k) {
+		case "version":
+			version = v
+		case "mode":
+			s.Mode = v
+		case "mx":
+			s.MX = append(s.MX, strings.ToLower(v))
+		case "max_age":
+			s.MaxAge, _ = strconv.Atoi(v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		s.Err = err
+	} else if version != "STSv1" {
+		s.Err = fmt.Errorf("%s: not an STSv1 policy", url)
+	}
+	return s
+}
+
+// MatchesMTASTS reports whether host is covered by one of an MTA-STS
+// policy's mx patterns, where "*." matches exactly one label.
+func MatchesMTASTS(host string, patterns []string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, p := range patterns {
+		p = strings.TrimSuffix(p, ".")
+		if rest, ok := strings.CutPrefix(p, "*."); ok {
+			if _, suffix, found := strings.Cut(host, "."); found && suffix == rest {
+				return true
+			}
+		} else if host == p {
+			return true
+		}
+	}
+	return false
+}
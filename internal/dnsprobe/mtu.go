@@ -0,0 +1,200 @@
+package dnsprobe
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// MTUTarget is a query whose answer serves as a test response; its size
+// is measured over TCP before the UDP ladder.
+type MTUTarget struct {
+	Name string
+	Type uint16
+	Size int
+	Err  error
+}
+
+func (t MTUTarget) String() string {
+	return dns.Fqdn(t.Name) + " " + dns.TypeToString[t.Type]
+}
+
+// MTUStep is one rung of an MTU ladder: a UDP query advertising Buffer
+// bytes that makes Size the largest datagram of the exchange, either the
+// reply to Target (its TCP size until a full reply arrives) or, for a
+// padded query, the query itself. Got is the size of the reply that
+// arrived.
+type MTUStep struct {
+	Buffer    uint16
+	Target    MTUTarget
+	Size      int
+	Got       int
+	Truncated bool
+	Sent      int
+	Answered  int
+	RTT       time.Duration
+	Err       error
+}
+
+// Lost reports whether every try timed out.
+func (s MTUStep) Lost() bool { return s.Answered == 0 && IsTimeout(s.Err) }
+
+// MTUReport is the outcome of ProbeMTU. Limit is the largest UDP payload
+// that fits the link MTU unfragmented; ServerBuffer the largest UDP size
+// the server advertised.
+type MTUReport struct {
+	Server       string
+	Family       int
+	LinkMTU      int
+	Limit        int
+	ServerBuffer uint16
+	Targets      []MTUTarget
+	Responses    []MTUStep
+	Queries      []MTUStep
+}
+
+// MTUPath sums up one direction of a ladder: the largest datagram that
+// arrived, the smallest that was lost, and whether datagrams over the
+// limit, which travel as fragments, got through. Tested is false when no
+// datagram over the limit was sent.
+type MTUPath struct {
+	Largest      int
+	SmallestLost int
+	Tested       bool
+	Fragments    bool
+}
+
+// Path sums up steps, Responses or Queries of r.
+func (r MTUReport) Path(steps []MTUStep) MTUPath {
+	var p MTUPath
+	for _, s := range steps {
+		switch {
+		case s.Answered > 0 && !s.Truncated:
+			p.Largest = max(p.Largest, s.Size)
+			if s.Size > r.Limit {
+				p.Tested, p.Fragments = true, true
+			}
+		case s.Lost():
+			if p.SmallestLost == 0 || s.Size < p.SmallestLost {
+				p.SmallestLost = s.Size
+			}
+			p.Tested = p.Tested || s.Size > r.Limit
+		}
+	}
+	return p
+}
+
+// mtuTries is how often a step is sent before it counts as lost, so that a
+// single dropped datagram is not taken for a size limit.
+const mtuTries = 2
+
+// ProbeMTU walks server through a ladder of EDNS buffer sizes. For each
+// buffer it asks for the largest target whose answer fits, with DO set, so
+// the replies grow with the buffer; then it sends queries padded (RFC 7830)
+// to each buffer size to test the other direction. linkMTU is the MTU of
+// the local link, 1500 on Ethernet.
+func ProbeMTU(ctx context.Context, server string, targets []MTUTarget, buffers []uint16, linkMTU int, opts Options) (MTUReport, error) {
+	r := MTUReport{Server: NormalizeServer(server), Family: 4, LinkMTU: linkMTU}
+	host, _, _ := net.SplitHostPort(r.Server)
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil || ip == nil && opts.Family == 6 {
+		r.Family = 6
+	}
+	r.Limit = linkMTU - 28
+	if r.Family == 6 {
+		r.Limit = linkMTU - 48
+	}
+	buffers = slices.Clone(buffers)
+	slices.Sort(buffers)
+
+	tcp, udp := opts, opts
+	tcp.TCP, udp.TCP = true, false
+	for _, t := range targets {
+		m := mtuQuery(t.Name, t.Type, dns.MaxMsgSize, true, opts)
+		resp, _, err := Exchange(ctx, r.Server, m, tcp)
+		if err == nil {
+			t.Size = wireLen(resp)
+		}
+		t.Err = err
+		r.Targets = append(r.Targets, t)
+		if ctx.Err() != nil {
+			return r, ctx.Err()
+		}
+	}
+	sized := slices.DeleteFunc(slices.Clone(r.Targets), func(t MTUTarget) bool { return t.Err != nil })
+	if len(sized) == 0 {
+		return r, fmt.Errorf("no test response could be fetched over TCP: %w", r.Targets[0].Err)
+	}
+	slices.SortStableFunc(sized, func(a, b MTUTarget) int { return cmp.Compare(a.Size, b.Size) })
+
+	var last MTUTarget
+	for _, b := range buffers {
+		i := len(sized) - 1
+		for i >= 0 && sized[i].Size > int(b) {
+			i--
+		}
+		if i < 0 || sized[i] == last {
+			continue
+		}
+		last = sized[i]
+		m := mtuQuery(last.Name, last.Type, b, true, opts)
+		s := r.step(ctx, m, MTUStep{Buffer: b, Target: last, Size: last.Size}, udp)
+		if s.Answered > 0 && !s.Truncated {
+			s.Size = s.Got
+		}
+		r.Responses = append(r.Responses, s)
+		if ctx.Err() != nil {
+			return r, ctx.Err()
+		}
+	}
+
+	for _, b := range buffers {
+		m := mtuQuery(".", dns.TypeSOA, b, false, opts)
+		pad := int(b) - wireLen(m) - 4
+		if pad < 0 {
+			continue
+		}
+		o := m.IsEdns0()
+		o.Option = append(o.Option, &dns.EDNS0_PADDING{Padding: make([]byte, pad)})
+		t := MTUTarget{Name: ".", Type: dns.TypeSOA, Size: int(b)}
+		r.Queries = append(r.Queries, r.step(ctx, m, MTUStep{Buffer: b, Target: t, Size: int(b)}, udp))
+		if ctx.Err() != nil {
+			return r, ctx.Err()
+		}
+	}
+	return r, nil
+}
+
+// step sends m up to mtuTries times, retrying only on timeouts.
+func (r *MTUReport) step(ctx context.Context, m *dns.Msg, s MTUStep, opts Options) MTUStep {
+	for s.Sent < mtuTries {
+		s.Sent++
+		resp, rtt, err := Exchange(ctx, r.Server, m, opts)
+		if err != nil {
+			s.Err = err
+			if !IsTimeout(err) || ctx.Err() != nil {
+				return s
+			}
+			continue
+		}
+		s.Answered++
+		s.RTT, s.Got, s.Truncated, s.Err = rtt, wireLen(resp), resp.Truncated, nil
+		if o := resp.IsEdns0(); o != nil {
+			r.ServerBuffer = max(r.ServerBuffer, o.UDPSize())
+		}
+		return s
+	}
+	return s
+}
+
+func mtuQuery(name string, qtype, buffer uint16, do bool, opts Options) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.RecursionDesired = !opts.NoRecurse
+	m.SetEdns0(buffer, do)
+	return m
+}
@@ -0,0 +1,88 @@
+package dnsprobe
+
+import (
+	"context"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SignerView is one nameserver address's view of a signed zone: the DNSKEY
+// set it serves and the RRSIGs it returns over that set and the SOA.
+type SignerView struct {
+	Host    string
+	Addr    string
+	Keys    []dns.RR
+	KeySigs []*dns.RRSIG
+	SOA     []dns.RR
+	SOASigs []*dns.RRSIG
+	Err     error
+}
+
+// KeyTags lists the tags of the DNSKEYs v serves.
+func (v SignerView) KeyTags() []uint16 {
+	var tags []uint16
+	for _, rr := range v.Keys {
+		tags = append(tags, rr.(*dns.DNSKEY).KeyTag())
+	}
+	return tags
+}
+
+// Key returns the DNSKEY v serves with tag and algorithm, or nil.
+func (v SignerView) Key(tag uint16, alg uint8) *dns.DNSKEY {
+	for _, rr := range v.Keys {
+		if k := rr.(*dns.DNSKEY); k.KeyTag() == tag && k.Algorithm == alg {
+			return k
+		}
+	}
+	return nil
+}
+
+// SignerViews asks every address of every nameserver of zone, found through
+// resolver, for the zone's DNSKEY and SOA sets with their signatures, as a
+// check of multi-signer setups (RFC 8901), where each provider signs with
+// its own keys and must publish the others'.
+func SignerViews(ctx context.Context, resolver, zone string, opts Options) (string, []SignerView, error) {
+	zone, hosts, err := zoneNameservers(ctx, resolver, strings.ToLower(dns.Fqdn(zone)), opts)
+	if err != nil {
+		return zone, nil, err
+	}
+	var views []SignerView
+	for _, h := range hosts {
+		addrs := nameserverAddrs(ctx, resolver, h, opts)
+		if len(addrs) == 0 {
+			views = append(views, SignerView{Host: h, Err: errNoNSAddress})
+			continue
+		}
+		for _, a := range addrs {
+			views = append(views, signerView(ctx, h, a, zone, opts))
+		}
+	}
+	return zone, views, nil
+}
+
+func signerView(ctx context.Context, host, addr, zone string, opts Options) SignerView {
+	v := SignerView{Host: host, Addr: addr}
+	for _, qtype := range []uint16{dns.TypeDNSKEY, dns.TypeSOA} {
+		resp, err := dnssecQuery(ctx, addr, zone, qtype, opts)
+		if err != nil {
+			v.Err = err
+			return v
+		}
+		for _, rr := range resp.Answer {
+			switch rr := rr.(type) {
+			case *dns.DNSKEY:
+				v.Keys = append(v.Keys, rr)
+			case *dns.SOA:
+				v.SOA = append(v.SOA, rr)
+			case *dns.RRSIG:
+				if rr.TypeCovered == dns.TypeDNSKEY {
+					v.KeySigs = append(v.KeySigs, rr)
+				} else if rr.TypeCovered == dns.TypeSOA {
+					v.SOASigs = append(v.SOASigs, rr)
+				}
+			}
+		}
+	}
+	return v
+}
@@ -0,0 +1,117 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// NegCacheReport describes how a resolver caches a nonexistent name (RFC
+// 2308). TTL1 and TTL2 are the TTLs of the SOA in the authority section of
+// the first and second NXDOMAIN; Minimum is that SOA's MINIMUM field.
+// AuthTTL is the negative TTL the zone's own server gives, the lower of its
+// SOA TTL and MINIMUM, or 0 when it could not be asked (AuthErr).
+type NegCacheReport struct {
+	Name    string
+	Zone    string
+	RCode   string
+	RTT1    time.Duration
+	RTT2    time.Duration
+	TTL1    uint32
+	TTL2    uint32
+	Minimum uint32
+	HasSOA  bool
+	Wait    time.Duration
+	AuthTTL uint32
+	AuthErr error
+}
+
+// NegativeTTL is the longest the resolver may cache the NXDOMAIN.
+func (r NegCacheReport) NegativeTTL() uint32 {
+	if r.AuthTTL > 0 {
+		return r.AuthTTL
+	}
+	return r.Minimum
+}
+
+// Cached reports whether the second answer came from cache: much faster, or
+// with a TTL that counted down.
+func (r NegCacheReport) Cached() bool {
+	return r.RTT2 < r.RTT1/2 || (r.HasSOA && r.TTL2 < r.TTL1)
+}
+
+// Honored reports whether the resolver kept within the zone's negative TTL.
+// Serving less is allowed; resolvers commonly cap it.
+func (r NegCacheReport) Honored() bool {
+	return !r.HasSOA || r.TTL1 <= r.NegativeTTL()+1
+}
+
+// MeasureNegativeCache asks server twice, wait apart, for a random name
+// under zone and asks the zone's authoritative server the same question to
+// learn the negative TTL the resolver should have used.
+func MeasureNegativeCache(ctx context.Context, server, zone string, wait time.Duration, opts Options) (NegCacheReport, error) {
+	label, err := randomLabel(20)
+	if err != nil {
+		return NegCacheReport{}, err
+	}
+	rep := NegCacheReport{Name: dns.Fqdn(label + "." + zone), Wait: wait}
+
+	m := new(dns.Msg)
+	m.SetQuestion(rep.Name, opts.qtype())
+	first, rtt1, err := Exchange(ctx, server, m, opts)
+	if err != nil {
+		return rep, err
+	}
+	rep.RCode, rep.RTT1 = dns.RcodeToString[first.Rcode], rtt1
+	if first.Rcode != dns.RcodeNameError {
+		return rep, fmt.Errorf("nonexistent %s returned %s, not NXDOMAIN", rep.Name, rep.RCode)
+	}
+	if soa := authoritySOA(first); soa != nil {
+		rep.HasSOA, rep.Zone, rep.TTL1, rep.Minimum = true, soa.Hdr.Name, soa.Hdr.Ttl, soa.Minttl
+	}
+
+	select {
+	case <-time.After(wait):
+	case <-ctx.Done():
+		return rep, ctx.Err()
+	}
+	m.Id = dns.Id()
+	second, rtt2, err := Exchange(ctx, server, m, opts)
+	if err != nil {
+		return rep, err
+	}
+	rep.RTT2 = rtt2
+	if soa := authoritySOA(second); soa != nil {
+		rep.TTL2 = soa.Hdr.Ttl
+	}
+
+	rep.AuthTTL, rep.AuthErr = authNegativeTTL(ctx, server, rep.Name, opts)
+	return rep, nil
+}
+
+func authNegativeTTL(ctx context.Context, server, name string, opts Options) (uint32, error) {
+	_, ns, err := findAuthoritative(ctx, server, name, opts)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := ttlQuery(ctx, ns, name, opts.qtype(), false, opts)
+	if err != nil {
+		return 0, fmt.Errorf("authoritative %s: %w", ns, err)
+	}
+	soa := authoritySOA(resp)
+	if soa == nil {
+		return 0, fmt.Errorf("authoritative %s: no SOA with the negative answer", ns)
+	}
+	return min(soa.Hdr.Ttl, soa.Minttl), nil
+}
+
+func authoritySOA(m *dns.Msg) *dns.SOA {
+	for _, rr := range m.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa
+		}
+	}
+	return nil
+}
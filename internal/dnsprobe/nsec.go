@@ -0,0 +1,132 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Denial is how a signed zone proves names and types do not exist, read
+// from the negative answers of one of its nameservers: Kind is "NSEC" or
+// "NSEC3". For NSEC, Exposed lists the names of the zone the proofs gave
+// away, and Minimal is set when the server synthesized minimally covering
+// records (online signing) instead. For NSEC3, Param is the zone's
+// NSEC3PARAM, nil when it has none.
+type Denial struct {
+	Zone    string
+	Server  string
+	Kind    string
+	Exposed []string
+	Minimal bool
+
+	Hash       uint8
+	Iterations uint16
+	Salt       string
+	OptOut     bool
+	Param      *dns.NSEC3PARAM
+}
+
+// AnalyzeDenial finds zone's nameserver through resolver and asks it, with
+// DO set, for a random name in the zone (NXDOMAIN) and for a type the apex
+// has not got (NODATA), reading the NSEC or NSEC3 records that prove them.
+// A zone whose negative answers carry neither is an error.
+func AnalyzeDenial(ctx context.Context, resolver, zone string, opts Options) (Denial, error) {
+	d := Denial{Zone: strings.ToLower(dns.Fqdn(zone))}
+	_, ns, err := findAuthoritative(ctx, resolver, d.Zone, opts)
+	if err != nil {
+		return d, err
+	}
+	d.Server = ns
+	label, err := randomLabel(12)
+	if err != nil {
+		return d, err
+	}
+
+	var proofs []dns.RR
+	for _, q := range []struct {
+		name  string
+		qtype uint16
+	}{{label + "." + d.Zone, dns.TypeA}, {d.Zone, dns.TypeNULL}} {
+		resp, err := denialQuery(ctx, ns, q.name, q.qtype, opts)
+		if err != nil {
+			return d, err
+		}
+		for _, rr := range resp.Ns {
+			switch rr := rr.(type) {
+			case *dns.NSEC:
+				d.Kind = "NSEC"
+				if synthesized(rr) {
+					d.Minimal = true
+				}
+			case *dns.NSEC3:
+				d.Kind = "NSEC3"
+				d.Hash, d.Iterations, d.Salt = rr.Hash, rr.Iterations, rr.Salt
+				d.OptOut = d.OptOut || rr.Flags&1 != 0
+			default:
+				continue
+			}
+			proofs = append(proofs, rr)
+		}
+	}
+	if d.Kind == "" {
+		return d, fmt.Errorf("%s: negative answers from %s carry no NSEC or NSEC3 (unsigned zone, or the server strips DNSSEC records)", d.Zone, ns)
+	}
+
+	if d.Kind == "NSEC" && !d.Minimal {
+		for _, rr := range proofs {
+			n, ok := rr.(*dns.NSEC)
+			if !ok {
+				continue
+			}
+			for _, name := range []string{n.Hdr.Name, n.NextDomain} {
+				name = strings.ToLower(name)
+				if name != d.Zone && dns.IsSubDomain(d.Zone, name) && !slices.Contains(d.Exposed, name) {
+					d.Exposed = append(d.Exposed, name)
+				}
+			}
+		}
+		slices.Sort(d.Exposed)
+	}
+	if d.Kind == "NSEC3" {
+		if resp, err := denialQuery(ctx, ns, d.Zone, dns.TypeNSEC3PARAM, opts); err == nil {
+			for _, rr := range resp.Answer {
+				if p, ok := rr.(*dns.NSEC3PARAM); ok {
+					d.Param = p
+				}
+			}
+		}
+	}
+	return d, nil
+}
+
+// synthesized reports whether n was made up for one query, as online
+// signers do (RFC 4470, and the NOERROR "black lies" form): its next name is
+// the immediate successor of a name, starting with a \000 label, rather
+// than a real one.
+func synthesized(n *dns.NSEC) bool {
+	return strings.HasPrefix(n.NextDomain, `\000.`)
+}
+
+// denialQuery is dnssecQuery for answers that are expected to be negative:
+// NXDOMAIN is not an error.
+func denialQuery(ctx context.Context, server, name string, qtype uint16, opts Options) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.RecursionDesired = false
+	m.SetEdns0(1232, true)
+	resp, _, err := Exchange(ctx, server, m, opts)
+	if err == nil && resp.Truncated {
+		opts.TCP = true
+		resp, _, err = Exchange(ctx, server, m, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s %s: %s", name, dns.TypeToString[qtype], dns.RcodeToString[resp.Rcode])
+	}
+	return resp, nil
+}
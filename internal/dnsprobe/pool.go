@@ -0,0 +1,172 @@
+package dnsprobe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Pool keeps TCP connections (direct or through a proxy) open between
+// queries so a sustained load does not pay a handshake per query. It
+// carries plain DNS over TCP only; DoT and DoH connections are not pooled. Each
+// server gets up to MaxConns connections, each carrying one query at a
+// time; connections idle longer than IdleTimeout, or found closed by the
+// server when taken from the pool, are replaced. Set Options.Pool to use it.
+type Pool struct {
+	MaxConns    int
+	IdleTimeout time.Duration
+
+	mu      sync.Mutex
+	servers map[string]*poolServer
+	stats   PoolStats
+}
+
+// PoolStats counts what a pool did: connections opened, queries sent on a
+// reused connection, and idle connections dropped as stale or dead.
+type PoolStats struct {
+	Dials     int
+	Reuses    int
+	Discarded int
+}
+
+type poolServer struct {
+	slots chan struct{}
+	idle  []idleConn
+}
+
+// idleConn is a pooled connection waiting for its next query. A background
+// read notices when the server closes it; see watch.
+type idleConn struct {
+	conn  net.Conn
+	since time.Time
+	read  chan error
+}
+
+// NewPool returns a pool of up to maxConns connections per server.
+func NewPool(maxConns int, idleTimeout time.Duration) *Pool {
+	if maxConns < 1 {
+		maxConns = 1
+	}
+	return &Pool{MaxConns: maxConns, IdleTimeout: idleTimeout, servers: map[string]*poolServer{}}
+}
+
+// Stats returns the counters so far.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// Close closes every idle connection. Connections in use are closed when
+// they are released.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.servers {
+		for _, ic := range s.idle {
+			ic.conn.Close()
+		}
+		s.idle = nil
+	}
+}
+
+func (p *Pool) server(key string) *poolServer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.servers[key]
+	if !ok {
+		s = &poolServer{slots: make(chan struct{}, p.MaxConns)}
+		p.servers[key] = s
+	}
+	return s
+}
+
+// get returns a connection to server, reused when a healthy idle one is
+// available, waiting for a free slot when all MaxConns are busy. Every
+// connection it returns must be handed back with put.
+func (p *Pool) get(ctx context.Context, opts Options, network, server string) (conn net.Conn, reused bool, proxyTime time.Duration, err error) {
+	s := p.server(network + " " + server)
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, false, 0, ctx.Err()
+	}
+
+	for {
+		p.mu.Lock()
+		n := len(s.idle)
+		if n == 0 {
+			p.mu.Unlock()
+			break
+		}
+		ic := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		p.mu.Unlock()
+
+		if (p.IdleTimeout > 0 && time.Since(ic.since) > p.IdleTimeout) || !ic.alive() {
+			ic.conn.Close()
+			p.count(func(st *PoolStats) { st.Discarded++ })
+			continue
+		}
+		p.count(func(st *PoolStats) { st.Reuses++ })
+		return ic.conn, true, 0, nil
+	}
+
+	conn, proxyTime, err = opts.dial(ctx, network, server)
+	if err != nil {
+		<-s.slots
+		return nil, false, proxyTime, err
+	}
+	p.count(func(st *PoolStats) { st.Dials++ })
+	return conn, false, proxyTime, nil
+}
+
+// put releases a connection taken with get. Connections that saw an error
+// may be mid-message and are closed instead of kept.
+func (p *Pool) put(network, server string, conn net.Conn, healthy bool) {
+	s := p.server(network + " " + server)
+	if healthy && conn.SetDeadline(time.Time{}) == nil {
+		p.mu.Lock()
+		s.idle = append(s.idle, watch(conn))
+		p.mu.Unlock()
+	} else {
+		conn.Close()
+	}
+	<-s.slots
+}
+
+func (p *Pool) count(f func(*PoolStats)) {
+	p.mu.Lock()
+	f(&p.stats)
+	p.mu.Unlock()
+}
+
+// watch parks conn with a read pending, which returns as soon as the server
+// closes the connection or sends something nobody asked for.
+func watch(conn net.Conn) idleConn {
+	ic := idleConn{conn: conn, since: time.Now(), read: make(chan error, 1)}
+	go func() {
+		var b [1]byte
+		_, err := conn.Read(b[:])
+		if err == nil {
+			err = errors.New("unsolicited data")
+		}
+		ic.read <- err
+	}()
+	return ic
+}
+
+// alive stops the pending read and reports whether it was still waiting,
+// i.e. the connection is open with nothing unread on it.
+func (ic idleConn) alive() bool {
+	if ic.conn.SetReadDeadline(time.Unix(1, 0)) != nil {
+		return false
+	}
+	if err := <-ic.read; !errors.Is(err, os.ErrDeadlineExceeded) {
+		return false
+	}
+	return ic.conn.SetReadDeadline(time.Time{}) == nil
+}
@@ -0,0 +1,90 @@
+package dnsprobe
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Priming is the reply to a root priming query (RFC 9609): the root NS set
+// and the addresses that came with it. Size is the UDP reply's length and
+// FullSize the complete reply's, fetched over TCP when the UDP one was
+// truncated. ServerBuffer is the UDP payload size the server advertised.
+type Priming struct {
+	Server        string
+	Buffer        uint16
+	DNSSEC        bool
+	RTT           time.Duration
+	RCode         string
+	Authoritative bool
+	Truncated     bool
+	Size          int
+	FullSize      int
+	ServerBuffer  uint16
+	NS            []string
+	Glue          map[string][]string
+	Signed        bool
+}
+
+// Prime sends server a priming query, ./IN/NS with an EDNS buffer of
+// buffer bytes and DO set when dnssec is, and retries over TCP when the
+// reply does not fit.
+func Prime(ctx context.Context, server string, buffer uint16, dnssec bool, opts Options) (Priming, error) {
+	p := Priming{Server: NormalizeServer(server), Buffer: buffer, DNSSEC: dnssec, Glue: map[string][]string{}}
+	m := new(dns.Msg)
+	m.SetQuestion(".", dns.TypeNS)
+	m.RecursionDesired = !opts.NoRecurse
+	m.SetEdns0(buffer, dnssec)
+	resp, rtt, err := Exchange(ctx, p.Server, m, opts)
+	if err != nil {
+		return p, err
+	}
+	p.RTT, p.Size = rtt, wireLen(resp)
+	p.FullSize = p.Size
+	p.Truncated = resp.Truncated
+	if o := resp.IsEdns0(); o != nil {
+		p.ServerBuffer = o.UDPSize()
+	}
+	if p.Truncated {
+		tcp := opts
+		tcp.TCP = true
+		full, _, err := Exchange(ctx, p.Server, m, tcp)
+		if err != nil {
+			return p, err
+		}
+		resp, p.FullSize = full, wireLen(full)
+	}
+
+	p.RCode = dns.RcodeToString[resp.Rcode]
+	p.Authoritative = resp.Authoritative
+	for _, rr := range resp.Answer {
+		switch rr := rr.(type) {
+		case *dns.NS:
+			if rr.Hdr.Name == "." {
+				p.NS = append(p.NS, strings.ToLower(rr.Ns))
+			}
+		case *dns.RRSIG:
+			p.Signed = p.Signed || rr.TypeCovered == dns.TypeNS
+		}
+	}
+	for _, rr := range resp.Extra {
+		host := strings.ToLower(rr.Header().Name)
+		switch rr := rr.(type) {
+		case *dns.A:
+			p.Glue[host] = append(p.Glue[host], rr.A.String())
+		case *dns.AAAA:
+			p.Glue[host] = append(p.Glue[host], rr.AAAA.String())
+		}
+	}
+	return p, nil
+}
+
+// wireLen is the length of m on the wire, with name compression as servers
+// send it.
+func wireLen(m *dns.Msg) int {
+	c := *m
+	c.Compress = true
+	return c.Len()
+}
@@ -0,0 +1,158 @@
+package dnsprobe
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// NSAnswer is what one authoritative server address returned for a name,
+// queried directly with RD=0, together with the zone serial it serves.
+type NSAnswer struct {
+	Host          string
+	Addr          string
+	Serial        uint32
+	HasSerial     bool
+	RCode         string
+	Authoritative bool
+	Answers       []string
+	TTL           uint32
+	RTT           time.Duration
+	Err           error
+}
+
+func (a NSAnswer) answerKey() string {
+	return a.RCode + " " + strings.Join(a.Answers, ",")
+}
+
+// Propagation is a name's answers across every address of every nameserver
+// of its zone. Serial is the newest serial any of them serves, and Expected
+// the answer the servers on that serial agree on.
+type Propagation struct {
+	Zone     string
+	Name     string
+	Type     string
+	Servers  []NSAnswer
+	Serial   uint32
+	Expected string
+}
+
+// Propagation states of one server.
+const (
+	PropInSync  = "in sync"
+	PropBehind  = "behind"
+	PropDiffers = "differs"
+	PropLame    = "lame"
+	PropError   = "error"
+)
+
+// Status says whether server i has caught up: behind when it serves an
+// older serial, differs when its answer is not the expected one, lame when
+// it does not answer authoritatively.
+func (p Propagation) Status(i int) string {
+	s := p.Servers[i]
+	switch {
+	case s.Err != nil:
+		return PropError
+	case !s.Authoritative:
+		return PropLame
+	case s.HasSerial && serialOlder(s.Serial, p.Serial):
+		return PropBehind
+	case s.answerKey() != p.Expected:
+		return PropDiffers
+	}
+	return PropInSync
+}
+
+// CheckPropagation finds name's zone and nameservers through resolver, then
+// asks every nameserver address for name and the zone's SOA directly.
+func CheckPropagation(ctx context.Context, resolver, name string, qtype uint16, opts Options) (Propagation, error) {
+	name = dns.Fqdn(name)
+	p := Propagation{Name: name, Type: dns.TypeToString[qtype]}
+	zone, hosts, err := zoneNameservers(ctx, resolver, name, opts)
+	if err != nil {
+		return p, err
+	}
+	p.Zone = zone
+	for _, h := range hosts {
+		addrs := nameserverAddrs(ctx, resolver, h, opts)
+		if len(addrs) == 0 {
+			p.Servers = append(p.Servers, NSAnswer{Host: h, Err: errNoNSAddress})
+			continue
+		}
+		for _, a := range addrs {
+			p.Servers = append(p.Servers, NSAnswer{Host: h, Addr: a})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range p.Servers {
+		if p.Servers[i].Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queryNameserver(ctx, &p.Servers[i], zone, name, qtype, opts)
+		}()
+	}
+	wg.Wait()
+
+	first := true
+	for _, s := range p.Servers {
+		if s.Err == nil && s.HasSerial && (first || serialOlder(p.Serial, s.Serial)) {
+			p.Serial, first = s.Serial, false
+		}
+	}
+	// The expected answer is the most common one among the servers on the
+	// newest serial.
+	counts := map[string]int{}
+	for _, s := range p.Servers {
+		if s.Err == nil && s.Authoritative && (!s.HasSerial || s.Serial == p.Serial) {
+			counts[s.answerKey()]++
+		}
+	}
+	for k, n := range counts {
+		if n > counts[p.Expected] || n == counts[p.Expected] && k < p.Expected {
+			p.Expected = k
+		}
+	}
+	return p, nil
+}
+
+var errNoNSAddress = errors.New("no address for this nameserver")
+
+func queryNameserver(ctx context.Context, s *NSAnswer, zone, name string, qtype uint16, opts Options) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.RecursionDesired = false
+	resp, rtt, err := Exchange(ctx, s.Addr, m, opts)
+	s.RTT = rtt
+	if err != nil {
+		s.Err = err
+		return
+	}
+	s.RCode = dns.RcodeToString[resp.Rcode]
+	s.Authoritative = resp.Authoritative
+	for i, rr := range resp.Answer {
+		h := rr.Header()
+		if i == 0 || h.Ttl < s.TTL {
+			s.TTL = h.Ttl
+		}
+		s.Answers = append(s.Answers, dns.Type(h.Rrtype).String()+" "+strings.TrimPrefix(rr.String(), h.String()))
+	}
+	slices.Sort(s.Answers)
+
+	if soa, err := ttlQuery(ctx, s.Addr, zone, dns.TypeSOA, false, opts); err == nil {
+		for _, rr := range soa.Answer {
+			if r, ok := rr.(*dns.SOA); ok {
+				s.Serial, s.HasSerial = r.Serial, true
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package dnsprobe
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// transport is the base network for queries: TCP when asked for, or when a
+// proxy is set since proxies only tunnel streams.
+func (o Options) transport() string {
+	if o.TCP || o.Proxy != "" {
+		return o.network("tcp")
+	}
+	return o.network("udp")
+}
+
+// dial connects to server over network, going through o.Proxy when set. The
+// returned duration is the time spent connecting to the proxy itself and is
+// zero for direct connections.
+func (o Options) dial(ctx context.Context, network, server string) (net.Conn, time.Duration, error) {
+	if o.Proxy == "" {
+		if o.unconnected && !strings.HasPrefix(network, "tcp") {
+			conn, err := o.listenUnconnected(network, server)
+			return conn, 0, err
+		}
+		d, err := o.dialer(network, server)
+		if err != nil {
+			return nil, 0, err
+		}
+		conn, err := d.DialContext(ctx, network, server)
+		return conn, 0, err
+	}
+
+	u, err := url.Parse(o.Proxy)
+	if err != nil || u.Host == "" {
+		return nil, 0, fmt.Errorf("invalid proxy URL %q", o.Proxy)
+	}
+	d, err := o.dialer(network, u.Host)
+	if err != nil {
+		return nil, 0, err
+	}
+	td := &timedDialer{d: d, network: network}
+
+	var conn net.Conn
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		var pd proxy.Dialer
+		pd, err = proxy.FromURL(u, td)
+		if err != nil {
+			return nil, 0, err
+		}
+		conn, err = pd.(proxy.ContextDialer).DialContext(ctx, "tcp", server)
+	case "http":
+		conn, err = httpConnect(ctx, td, u, server)
+	default:
+		return nil, 0, fmt.Errorf("unsupported proxy scheme %q (want socks5 or http)", u.Scheme)
+	}
+	if err != nil {
+		return nil, td.took, fmt.Errorf("via proxy %s: %w", u.Host, err)
+	}
+	return conn, td.took, nil
+}
+
+// timedDialer records how long the connection to the proxy took, separately
+// from the proxy handshake that follows.
+type timedDialer struct {
+	d       *net.Dialer
+	network string
+	took    time.Duration
+}
+
+func (t *timedDialer) Dial(_, addr string) (net.Conn, error) {
+	return t.DialContext(context.Background(), "", addr)
+}
+
+func (t *timedDialer) DialContext(ctx context.Context, _, addr string) (net.Conn, error) {
+	start := time.Now()
+	conn, err := t.d.DialContext(ctx, t.network, addr)
+	t.took = time.Since(start)
+	return conn, err
+}
+
+// httpConnect opens a tunnel to server with an HTTP CONNECT request.
+func httpConnect(ctx context.Context, td *timedDialer, u *url.URL, server string) (net.Conn, error) {
+	conn, err := td.DialContext(ctx, "", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := ctx.Deadline(); !ok && td.d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, td.d.Timeout)
+		defer cancel()
+	}
+	stop := bindConn(ctx, conn)
+
+	req := "CONNECT " + server + " HTTP/1.1\r\nHost: " + server + "\r\n"
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		cred := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + pass))
+		req += "Proxy-Authorization: Basic " + cred + "\r\n"
+	}
+	if _, err := conn.Write([]byte(req + "\r\n")); err != nil {
+		conn.Close()
+		return nil, ctxError(ctx, err)
+	}
+
+	// The tunnel carries no data until we write, so nothing past the
+	// response header can be buffered here. The body is the tunnel itself
+	// and must not be read or closed.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		conn.Close()
+		return nil, ctxError(ctx, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("CONNECT %s: %s", server, resp.Status)
+	}
+	if !stop() {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return conn, nil
+}
@@ -0,0 +1,64 @@
+package dnsprobe
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Values for Options.QNameCase. Some appliances match names case-sensitively
+// or mishandle 0x20 randomization, so the case sent is selectable.
+const (
+	CasePreserve = "preserve"
+	CaseLower    = "lower"
+	CaseRandom   = "random"
+)
+
+func CheckQNameCase(s string) error {
+	switch s {
+	case "", CasePreserve, CaseLower, CaseRandom:
+		return nil
+	}
+	return fmt.Errorf("invalid qname case %q (want %s, %s or %s)", s, CasePreserve, CaseLower, CaseRandom)
+}
+
+// CaseError reports a reply whose question does not echo the query name
+// byte for byte. A 0x20-aware resolver would discard such a reply; a resolver
+// or middlebox that normalizes case defeats 0x20 as spoofing protection.
+type CaseError struct {
+	Sent string
+	Got  string
+}
+
+func (e *CaseError) Error() string {
+	if e.Got == "" {
+		return fmt.Sprintf("0x20: reply has no question section to echo %s", e.Sent)
+	}
+	return fmt.Sprintf("0x20: reply question %s does not echo %s exactly (case normalized by the resolver or the path)", e.Got, e.Sent)
+}
+
+// wireName returns qname exactly as it goes on the wire: fully qualified,
+// with its case changed per o.QNameCase. When verifying 0x20 at least one
+// letter is upper case, so a lower-casing resolver cannot pass by chance.
+func (o Options) wireName(qname string) string {
+	switch o.QNameCase {
+	case CaseLower:
+		qname = strings.ToLower(qname)
+	case CaseRandom:
+		b := []byte(qname)
+		for i, c := range b {
+			if ('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') && rand.IntN(2) == 0 {
+				b[i] = c ^ 0x20
+			}
+		}
+		if o.Verify0x20 && strings.ToLower(string(b)) == string(b) {
+			if i := strings.IndexFunc(qname, func(c rune) bool { return 'a' <= c && c <= 'z' }); i >= 0 {
+				b[i] ^= 0x20
+			}
+		}
+		qname = string(b)
+	}
+	return dns.Fqdn(qname)
+}
@@ -0,0 +1,64 @@
+package dnsprobe
+
+import (
+	"context"
+	"time"
+)
+
+// RecoveryConfig describes how to judge recovery after a cache flush or
+// restart. Soak.Interval is the window p95 is computed over and Soak.Duration
+// is how long to wait for recovery before giving up.
+type RecoveryConfig struct {
+	Soak SoakConfig
+	// Target is the p95 a window must not exceed, usually the baseline p95
+	// times a tolerance.
+	Target time.Duration
+	// MaxFail is the highest fail ratio (lost + errors) a window may have.
+	MaxFail float64
+	// Stable is how many consecutive windows must meet the target.
+	Stable int
+}
+
+type RecoveryResult struct {
+	Start     time.Time
+	Windows   []SoakSummary
+	Recovered bool
+	// After is the time from Start to the end of the first window of the
+	// stable run.
+	After time.Duration
+}
+
+// MeasureRecovery replays cfg.Soak's workload right after a flush or restart
+// and stops once cfg.Stable consecutive windows are back under cfg.Target.
+// onWindow, when set, sees every window as it completes.
+func MeasureRecovery(ctx context.Context, cfg RecoveryConfig, onWindow func(SoakSummary, bool)) RecoveryResult {
+	if cfg.Stable < 1 {
+		cfg.Stable = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	res := RecoveryResult{Start: time.Now()}
+	run := 0
+	Soak(ctx, cfg.Soak, func(w SoakSummary) {
+		if res.Recovered {
+			return
+		}
+		res.Windows = append(res.Windows, w)
+		ok := w.Success > 0 && w.P95 <= cfg.Target && w.FailRatio() <= cfg.MaxFail
+		if ok {
+			run++
+		} else {
+			run = 0
+		}
+		if onWindow != nil {
+			onWindow(w, ok)
+		}
+		if run == cfg.Stable {
+			res.Recovered = true
+			res.After = res.Windows[len(res.Windows)-cfg.Stable].End.Sub(res.Start)
+			cancel()
+		}
+	})
+	return res
+}
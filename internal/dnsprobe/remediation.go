@@ -0,0 +1,11 @@
+package dnsprobe
+
+// Remediation is the machine-readable side of a finding's hint, for tooling
+// that turns reports into tickets. Action is a stable verb-phrase slug;
+// Records lists the affected names ("name TYPE") and Suggested maps a setting
+// to the value to use.
+type Remediation struct {
+	Action    string            `json:"action"`
+	Records   []string          `json:"records,omitempty"`
+	Suggested map[string]string `json:"suggested,omitempty"`
+}
@@ -0,0 +1,86 @@
+package dnsprobe
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const resolvedUpstreamConf = "/run/systemd/resolve/resolv.conf"
+
+// IsResolvedStub reports whether server is the systemd-resolved stub listener
+// (127.0.0.53, or 127.0.0.54 in proxy mode).
+func IsResolvedStub(server string) bool {
+	host := server
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	}
+	return host == "127.0.0.53" || host == "127.0.0.54"
+}
+
+// ResolvedUpstreams returns the servers systemd-resolved forwards to, taken
+// from `resolvectl dns` or, failing that, the upstream resolv.conf resolved
+// maintains.
+func ResolvedUpstreams() ([]SystemResolver, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if out, err := exec.CommandContext(ctx, "resolvectl", "dns").Output(); err == nil {
+		if rs := parseResolvectlDNS(string(out)); len(rs) > 0 {
+			return rs, nil
+		}
+	}
+
+	cfg, err := dns.ClientConfigFromFile(resolvedUpstreamConf)
+	if err != nil {
+		return nil, err
+	}
+	var rs []SystemResolver
+	for _, s := range cfg.Servers {
+		rs = append(rs, SystemResolver{Server: net.JoinHostPort(s, cfg.Port), Upstream: true, Source: resolvedUpstreamConf})
+	}
+	if len(rs) == 0 {
+		return nil, errors.New("systemd-resolved has no upstream DNS servers configured")
+	}
+	return rs, nil
+}
+
+// parseResolvectlDNS parses lines such as
+//
+//	Global: 1.1.1.1#cloudflare-dns.com 9.9.9.9
+//	Link 2 (eth0): 192.168.1.1 fe80::1%eth0
+func parseResolvectlDNS(out string) []SystemResolver {
+	var rs []SystemResolver
+	seen := map[string]bool{}
+
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		label, servers, ok := strings.Cut(sc.Text(), ":")
+		if !ok {
+			continue
+		}
+		iface := ""
+		if i, j := strings.IndexByte(label, '('), strings.IndexByte(label, ')'); i >= 0 && j > i {
+			iface = label[i+1 : j]
+		}
+		for _, s := range strings.Fields(servers) {
+			// drop the DoT server name suffix
+			s, _, _ = strings.Cut(s, "#")
+			if net.ParseIP(strings.SplitN(s, "%", 2)[0]) != nil {
+				s = net.JoinHostPort(s, "53")
+			}
+			if seen[s+iface] {
+				continue
+			}
+			seen[s+iface] = true
+			rs = append(rs, SystemResolver{Server: s, Upstream: true, Interface: iface, Source: "resolvectl dns"})
+		}
+	}
+	return rs
+}
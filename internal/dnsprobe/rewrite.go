@@ -0,0 +1,181 @@
+package dnsprobe
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// HighValueTargets are domains that malware and compromised routers redirect
+// to phishing or fake-update servers, by category.
+var HighValueTargets = []FilterTarget{
+	{"banking", "www.paypal.com"},
+	{"banking", "www.chase.com"},
+	{"banking", "www.bankofamerica.com"},
+	{"banking", "www.wellsfargo.com"},
+	{"banking", "www.hsbc.com"},
+	{"login", "accounts.google.com"},
+	{"login", "login.microsoftonline.com"},
+	{"login", "login.live.com"},
+	{"login", "appleid.apple.com"},
+	{"login", "www.facebook.com"},
+	{"login", "github.com"},
+	{"update", "update.microsoft.com"},
+	{"update", "download.windowsupdate.com"},
+	{"update", "swscan.apple.com"},
+	{"update", "dl.google.com"},
+	{"update", "deb.debian.org"},
+	{"update", "security.ubuntu.com"},
+}
+
+type RewriteVerdict string
+
+const (
+	RewriteMatch    RewriteVerdict = "match"
+	RewriteCDN      RewriteVerdict = "differs (same provider)"
+	RewriteBlocked  RewriteVerdict = "blocked locally"
+	RewritePrivate  RewriteVerdict = "rewritten (private address)"
+	RewriteStripped RewriteVerdict = "rewritten (signatures stripped)"
+	RewriteSuspect  RewriteVerdict = "suspicious"
+	RewriteError    RewriteVerdict = "error"
+)
+
+// IsRewritten reports whether v means the local answer cannot be trusted.
+func (v RewriteVerdict) IsRewritten() bool {
+	return v == RewritePrivate || v == RewriteStripped || v == RewriteSuspect
+}
+
+type RewriteResult struct {
+	Target    FilterTarget
+	RCode     string
+	Addrs     []string
+	RefRCode  string
+	RefAddrs  []string
+	Validated bool // the reference answer was DNSSEC-validated (AD)
+	LocalSigs bool // the local answer carried RRSIGs
+	Verdict   RewriteVerdict
+	Err       error
+}
+
+// CheckRewrites resolves every target through server and through reference,
+// a DoH URL or a validating resolver, and classifies how the local answers
+// differ. Different addresses alone are common (CDNs answer by location),
+// so only answers that point somewhere no CDN would, drop the zone's
+// signatures, or share nothing with the reference are flagged.
+func CheckRewrites(ctx context.Context, server, reference string, targets []FilterTarget, opts Options) []RewriteResult {
+	out := make([]RewriteResult, 0, len(targets))
+	for _, t := range targets {
+		res := RewriteResult{Target: t}
+		local, err := rewriteQuery(ctx, server, t.Domain, opts)
+		if err == nil {
+			var ref *dns.Msg
+			if ref, err = rewriteQuery(ctx, reference, t.Domain, opts); err == nil {
+				res.RCode, res.RefRCode = dns.RcodeToString[local.Rcode], dns.RcodeToString[ref.Rcode]
+				res.Addrs, res.RefAddrs = answerAddrs(local), answerAddrs(ref)
+				res.Validated = ref.AuthenticatedData
+				res.LocalSigs = hasRRSIG(local, dns.TypeA)
+				res.Verdict = classifyRewrite(res, cnameDomains(local), cnameDomains(ref))
+			}
+		}
+		if err != nil {
+			res.Err = err
+			res.Verdict = RewriteError
+		}
+		out = append(out, res)
+	}
+	return out
+}
+
+func rewriteQuery(ctx context.Context, server, name string, opts Options) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), dns.TypeA)
+	m.RecursionDesired = true
+	m.SetEdns0(1232, true)
+	if IsDoHURL(server) {
+		resp, _, err := ExchangeDoH(ctx, server, m, opts)
+		return resp, err
+	}
+	resp, _, err := Exchange(ctx, server, m, opts)
+	return resp, err
+}
+
+func classifyRewrite(r RewriteResult, localCNAMEs, refCNAMEs []string) RewriteVerdict {
+	if r.RCode != "NOERROR" || len(r.Addrs) == 0 {
+		if r.RefRCode == "NOERROR" && len(r.RefAddrs) > 0 {
+			return RewriteBlocked
+		}
+		return RewriteMatch
+	}
+	for _, a := range r.Addrs {
+		ip, err := netip.ParseAddr(a)
+		if err == nil && (ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast()) {
+			return RewritePrivate
+		}
+	}
+	for _, a := range r.Addrs {
+		if slices.Contains(r.RefAddrs, a) {
+			return RewriteMatch
+		}
+	}
+	for _, c := range localCNAMEs {
+		if slices.Contains(refCNAMEs, c) {
+			return RewriteCDN
+		}
+	}
+	// Same /24 (or /48): another address of the same server farm.
+	for _, a := range r.Addrs {
+		for _, b := range r.RefAddrs {
+			if samePrefix(a, b) {
+				return RewriteCDN
+			}
+		}
+	}
+	// The zone is signed, yet the local answer, which shares nothing with
+	// the validated one, came without signatures.
+	if r.Validated && !r.LocalSigs {
+		return RewriteStripped
+	}
+	return RewriteSuspect
+}
+
+func samePrefix(a, b string) bool {
+	x, err1 := netip.ParseAddr(a)
+	y, err2 := netip.ParseAddr(b)
+	if err1 != nil || err2 != nil || x.Is4() != y.Is4() {
+		return false
+	}
+	bits := 24
+	if !x.Is4() {
+		bits = 48
+	}
+	p, _ := x.Prefix(bits)
+	return p.Contains(y)
+}
+
+// cnameDomains returns the registrable domains of the CNAME targets in m,
+// which name the CDN serving the answer.
+func cnameDomains(m *dns.Msg) []string {
+	var out []string
+	for _, rr := range m.Answer {
+		if c, ok := rr.(*dns.CNAME); ok {
+			d, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimSuffix(strings.ToLower(c.Target), "."))
+			if err == nil && !slices.Contains(out, d) {
+				out = append(out, d)
+			}
+		}
+	}
+	return out
+}
+
+func hasRRSIG(m *dns.Msg, covered uint16) bool {
+	for _, rr := range m.Answer {
+		if s, ok := rr.(*dns.RRSIG); ok && s.TypeCovered == covered {
+			return true
+		}
+	}
+	return false
+}
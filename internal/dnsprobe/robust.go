@@ -0,0 +1,71 @@
+package dnsprobe
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+)
+
+// RobustAvg is a benchmark's average over the successful queries left
+// after setting aside outliers and trimming the tails; see
+// Benchmark.Robust. Outliers are the totals above High, fastest first;
+// High is 0 when outliers were not looked for. Trimmed counts the queries
+// trimmed.
+type RobustAvg struct {
+	Avg      Timings
+	Used     int
+	Outliers []time.Duration
+	High     time.Duration
+	Trimmed  int
+}
+
+// Robust averages b's successful queries without the distortion of a few
+// slow ones. With iqr > 0 and at least four queries, those whose total lies
+// more than iqr interquartile ranges above the third quartile, such as one
+// held up by a retransmission, are set aside as outliers; Tukey's far-out
+// fence is iqr 3. Fast queries are never outliers: they are cache hits,
+// not noise. With trim > 0, that fraction of the remaining queries is then
+// dropped from each end, slowest and fastest. b must come from a benchmark
+// run with Options.KeepTimings.
+func (b Benchmark) Robust(iqr, trim float64) RobustAvg {
+	ts := slices.Clone(b.timings)
+	slices.SortFunc(ts, func(x, y Timings) int { return cmp.Compare(x.Total, y.Total) })
+	var r RobustAvg
+	if iqr > 0 && len(ts) >= 4 {
+		q1, q3 := quartile(ts, 0.25), quartile(ts, 0.75)
+		spread := float64(q3 - q1)
+		r.High = q3 + time.Duration(iqr*spread)
+		n := len(ts)
+		for n > 0 && ts[n-1].Total > r.High {
+			n--
+		}
+		for _, t := range ts[n:] {
+			r.Outliers = append(r.Outliers, t.Total)
+		}
+		ts = ts[:n]
+	}
+	if trim > 0 {
+		n := int(math.Floor(float64(len(ts)) * min(trim, 0.49)))
+		r.Trimmed = 2 * n
+		ts = ts[n : len(ts)-n]
+	}
+	var sum Timings
+	for _, t := range ts {
+		sum = add(sum, t)
+	}
+	r.Avg, r.Used = avg(sum, len(ts)), len(ts)
+	return r
+}
+
+// quartile interpolates the q quantile of the totals of ts, sorted by
+// total.
+func quartile(ts []Timings, q float64) time.Duration {
+	pos := q * float64(len(ts)-1)
+	i := int(pos)
+	if i+1 >= len(ts) {
+		return ts[i].Total
+	}
+	frac := pos - float64(i)
+	return ts[i].Total + time.Duration(frac*float64(ts[i+1].Total-ts[i].Total))
+}
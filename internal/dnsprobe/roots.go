@@ -0,0 +1,137 @@
+package dnsprobe
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// RootProbe is one root server address asked count priming queries. P50
+// and Min cover the answered ones; Problem is set when a reply is not a
+// usable priming response, Err when none came back.
+type RootProbe struct {
+	Name     string
+	Addr     string
+	Family   int
+	Sent     int
+	Answered int
+	P50      time.Duration
+	Min      time.Duration
+	NSID     string
+	Problem  string
+	Err      error
+}
+
+// Letter is the root server letter, "a" for a.root-servers.net.
+func (p RootProbe) Letter() string {
+	letter, _, _ := strings.Cut(p.Name, ".")
+	return letter
+}
+
+// ProbeRoots sends count priming queries (RFC 9609) to every address of
+// hints, root server names to addresses, in parallel, skipping addresses
+// outside opts.Family. Answered servers come first, fastest first.
+func ProbeRoots(ctx context.Context, hints map[string][]string, count int, opts Options) []RootProbe {
+	var out []RootProbe
+	for name, addrs := range hints {
+		for _, a := range addrs {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				continue
+			}
+			family := 6
+			if ip.To4() != nil {
+				family = 4
+			}
+			if opts.Family != 0 && opts.Family != family {
+				continue
+			}
+			out = append(out, RootProbe{Name: name, Addr: net.JoinHostPort(a, "53"), Family: family})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range out {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			primeRoot(ctx, &out[i], count, len(hints), opts)
+		}()
+	}
+	wg.Wait()
+
+	slices.SortFunc(out, func(a, b RootProbe) int {
+		switch {
+		case (a.Answered == 0) != (b.Answered == 0):
+			if a.Answered == 0 {
+				return 1
+			}
+			return -1
+		case a.Answered > 0 && a.P50 != b.P50:
+			return cmp.Compare(a.P50, b.P50)
+		case a.Name != b.Name:
+			return strings.Compare(a.Name, b.Name)
+		}
+		return a.Family - b.Family
+	})
+	return out
+}
+
+// primeRoot asks p.Addr for the root NS set with RD clear, as a resolver
+// does at startup, and checks that the reply is an authoritative one that
+// names as many servers as the hints.
+func primeRoot(ctx context.Context, p *RootProbe, count, want int, opts Options) {
+	var rtts []time.Duration
+	for i := 0; i < count; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion(".", dns.TypeNS)
+		m.RecursionDesired = false
+		m.SetEdns0(1232, true)
+		o := m.IsEdns0()
+		o.Option = append(o.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+
+		p.Sent++
+		resp, rtt, err := Exchange(ctx, p.Addr, m, opts)
+		if err == nil && resp.Truncated {
+			tcp := opts
+			tcp.TCP = true
+			resp, rtt, err = Exchange(ctx, p.Addr, m, tcp)
+		}
+		if err != nil {
+			p.Err = err
+			continue
+		}
+		rtts = append(rtts, rtt)
+		if nsid := responseNSID(resp); nsid != "" {
+			p.NSID = nsid
+		}
+		var ns int
+		for _, rr := range resp.Answer {
+			if _, ok := rr.(*dns.NS); ok && rr.Header().Name == "." {
+				ns++
+			}
+		}
+		switch {
+		case resp.Rcode != dns.RcodeSuccess:
+			p.Problem = dns.RcodeToString[resp.Rcode]
+		case !resp.Authoritative:
+			p.Problem = "reply not authoritative"
+		case ns == 0:
+			p.Problem = "no root NS set in the reply"
+		case ns != want:
+			p.Problem = fmt.Sprintf("root NS set has %d names, the hints %d", ns, want)
+		}
+	}
+	p.Answered = len(rtts)
+	if len(rtts) > 0 {
+		slices.Sort(rtts)
+		p.Min, p.P50 = rtts[0], rtts[(len(rtts)-1)/2]
+	}
+}
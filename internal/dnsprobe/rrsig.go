@@ -0,0 +1,85 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Signature is the validity window of one RRSIG over a zone's RRset.
+type Signature struct {
+	Type       string
+	Signer     string
+	KeyTag     uint16
+	Algorithm  string
+	Inception  time.Time
+	Expiration time.Time
+}
+
+// ExpiresIn is how long the signature stays valid after now; negative once
+// it has expired.
+func (s Signature) ExpiresIn(now time.Time) time.Duration {
+	return s.Expiration.Sub(now)
+}
+
+// DefaultSignedTypes are the RRsets whose signatures take a whole zone down
+// when they lapse.
+var DefaultSignedTypes = []uint16{dns.TypeDNSKEY, dns.TypeSOA, dns.TypeNS}
+
+// ZoneSignatures asks server, with the DO bit set, for each of zone's RRsets
+// of types and returns the RRSIGs covering them, soonest to expire first. An
+// RRset that comes back unsigned is an error, as is a reply that fails.
+func ZoneSignatures(ctx context.Context, server, zone string, types []uint16, opts Options) ([]Signature, error) {
+	zone = dns.Fqdn(zone)
+	var sigs []Signature
+	for _, t := range types {
+		m := new(dns.Msg)
+		m.SetQuestion(zone, t)
+		m.RecursionDesired = !opts.NoRecurse
+		m.SetEdns0(1232, true)
+		resp, _, err := Exchange(ctx, server, m, opts)
+		if err == nil && resp.Truncated {
+			tcp := opts
+			tcp.TCP = true
+			resp, _, err = Exchange(ctx, server, m, tcp)
+		}
+		if err != nil {
+			return sigs, fmt.Errorf("%s %s: %w", zone, dns.TypeToString[t], err)
+		}
+		if resp.Rcode != dns.RcodeSuccess {
+			return sigs, fmt.Errorf("%s %s: %s", zone, dns.TypeToString[t], dns.RcodeToString[resp.Rcode])
+		}
+		found := false
+		for _, rr := range resp.Answer {
+			sig, ok := rr.(*dns.RRSIG)
+			if !ok || sig.TypeCovered != t {
+				continue
+			}
+			found = true
+			sigs = append(sigs, Signature{
+				Type:       dns.TypeToString[t],
+				Signer:     sig.SignerName,
+				KeyTag:     sig.KeyTag,
+				Algorithm:  dns.AlgorithmToString[sig.Algorithm],
+				Inception:  sigTime(sig.Inception),
+				Expiration: sigTime(sig.Expiration),
+			})
+		}
+		if !found {
+			return sigs, fmt.Errorf("%s %s: no RRSIG in the answer (unsigned zone, or the server strips DNSSEC records)", zone, dns.TypeToString[t])
+		}
+	}
+	slices.SortStableFunc(sigs, func(a, b Signature) int { return a.Expiration.Compare(b.Expiration) })
+	return sigs, nil
+}
+
+// sigTime turns an RRSIG timestamp into a time. The field is seconds modulo
+// 2^32 (RFC 4034, section 3.1.5), so it is read as the instant nearest to
+// now.
+func sigTime(t uint32) time.Time {
+	now := time.Now().Unix()
+	return time.Unix(now+int64(int32(t-uint32(now))), 0)
+}
@@ -0,0 +1,61 @@
+package dnsprobe
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+)
+
+// SearchConfig holds the stub-resolver search list and ndots threshold that
+// applications on the host apply to unqualified names.
+type SearchConfig struct {
+	Search []string
+	Ndots  int
+}
+
+func SystemSearchConfig() (SearchConfig, error) {
+	return systemSearchConfig()
+}
+
+// Candidates returns the fully-qualified names a stub resolver would try for
+// name, in order.
+func (c SearchConfig) Candidates(name string) []string {
+	cc := dns.ClientConfig{Search: c.Search, Ndots: c.Ndots}
+	return cc.NameList(name)
+}
+
+type SearchAttempt struct {
+	Name    string
+	RCode   string
+	Answers int
+	Err     error
+}
+
+// ProbeSearch walks the search candidates for name like a stub resolver:
+// NXDOMAIN, empty NOERROR and SERVFAIL move on to the next candidate; the
+// first answer, any other rcode or a transport error ends the walk. The
+// returned Result is the last probe made.
+func ProbeSearch(ctx context.Context, server, name string, cfg SearchConfig, opts Options) (Result, []SearchAttempt, error) {
+	var (
+		r        Result
+		err      error
+		attempts []SearchAttempt
+	)
+	for _, cand := range cfg.Candidates(name) {
+		r, err = Probe(ctx, server, cand, opts)
+		attempts = append(attempts, SearchAttempt{Name: cand, RCode: r.RCode, Answers: r.AnswerCount, Err: err})
+		if err != nil {
+			return r, attempts, err
+		}
+		switch r.RCode {
+		case "NXDOMAIN", "SERVFAIL":
+			continue
+		case "NOERROR":
+			if r.AnswerCount == 0 {
+				continue
+			}
+		}
+		break
+	}
+	return r, attempts, err
+}
@@ -0,0 +1,128 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// SerialObservation is the SOA serial one server returned for a zone. Host
+// is the nameserver name for authoritative servers and empty for
+// recursives, whose TTL is what is left of their cached copy.
+type SerialObservation struct {
+	Server        string
+	Host          string
+	Recursive     bool
+	Serial        uint32
+	HasSerial     bool
+	Authoritative bool
+	TTL           uint32
+	RTT           time.Duration
+	Err           error
+}
+
+// SerialReport is a zone's SOA serial as seen by each of its nameservers and
+// by a set of recursives. Latest is the newest serial any of them returned.
+type SerialReport struct {
+	Zone    string
+	Latest  uint32
+	Servers []SerialObservation
+}
+
+// Behind returns how many serials server i lags Latest by, 0 when it is
+// current or has no serial.
+func (r SerialReport) Behind(i int) uint32 {
+	s := r.Servers[i]
+	if !s.HasSerial || !serialOlder(s.Serial, r.Latest) {
+		return 0
+	}
+	return r.Latest - s.Serial
+}
+
+// CheckSerials asks every address of every nameserver of zone, found through
+// resolver, and each of recursives for the zone's SOA.
+func CheckSerials(ctx context.Context, resolver, zone string, recursives []string, opts Options) (SerialReport, error) {
+	rep := SerialReport{}
+	apex, hosts, err := zoneNameservers(ctx, resolver, dns.Fqdn(zone), opts)
+	if err != nil {
+		return rep, err
+	}
+	rep.Zone = apex
+	for _, h := range hosts {
+		addrs := nameserverAddrs(ctx, resolver, h, opts)
+		if len(addrs) == 0 {
+			rep.Servers = append(rep.Servers, SerialObservation{Host: h, Err: errNoNSAddress})
+			continue
+		}
+		for _, a := range addrs {
+			rep.Servers = append(rep.Servers, SerialObservation{Server: a, Host: h})
+		}
+	}
+	for _, r := range recursives {
+		rep.Servers = append(rep.Servers, SerialObservation{Server: NormalizeServer(r), Recursive: true})
+	}
+
+	var wg sync.WaitGroup
+	for i := range rep.Servers {
+		if rep.Servers[i].Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			querySerial(ctx, &rep.Servers[i], apex, opts)
+		}()
+	}
+	wg.Wait()
+
+	first := true
+	for _, s := range rep.Servers {
+		if s.HasSerial && (first || serialOlder(rep.Latest, s.Serial)) {
+			rep.Latest, first = s.Serial, false
+		}
+	}
+	return rep, nil
+}
+
+func querySerial(ctx context.Context, s *SerialObservation, zone string, opts Options) {
+	m := new(dns.Msg)
+	m.SetQuestion(zone, dns.TypeSOA)
+	m.RecursionDesired = s.Recursive
+	resp, rtt, err := Exchange(ctx, s.Server, m, opts)
+	s.RTT = rtt
+	if err != nil {
+		s.Err = err
+		return
+	}
+	s.Authoritative = resp.Authoritative
+	for _, rr := range resp.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			s.Serial, s.HasSerial, s.TTL = soa.Serial, true, soa.Hdr.Ttl
+			return
+		}
+	}
+	s.Err = fmt.Errorf("no SOA for %s (%s)", zone, dns.RcodeToString[resp.Rcode])
+}
+
+// serialOlder compares serials with RFC 1982 arithmetic, so a serial that
+// wrapped around still counts as newer.
+func serialOlder(a, b uint32) bool {
+	return a != b && b-a < 1<<31
+}
+
+// SerialDate reads a serial in the common YYYYMMDDnn convention and returns
+// the day it encodes.
+func SerialDate(serial uint32) (time.Time, bool) {
+	if serial < 1970010100 {
+		return time.Time{}, false
+	}
+	day := serial / 100
+	t, err := time.Parse("20060102", fmt.Sprint(day))
+	if err != nil || t.After(time.Now().AddDate(1, 0, 0)) {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,110 @@
+package dnsprobe
+
+import (
+	"context"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+type SnoopState string
+
+const (
+	SnoopCached    SnoopState = "cached"
+	SnoopNegative  SnoopState = "cached (negative)"
+	SnoopNotCached SnoopState = "not cached"
+	SnoopRefused   SnoopState = "refused"
+	SnoopUnclear   SnoopState = "unclear"
+)
+
+// SnoopResult is what a non-recursive query reveals about one name. TTL is
+// the remaining lifetime of the cached answer, or of the negative answer's
+// SOA.
+type SnoopResult struct {
+	Name  string
+	State SnoopState
+	TTL   uint32
+	RCode string
+	Err   error
+}
+
+// Snoop asks server for every name with RD clear, so that a resolver which
+// allows it answers from its cache only, and reads whether each name is
+// cached. opts.Type selects the record type.
+func Snoop(ctx context.Context, server string, names []string, opts Options, concurrency int) []SnoopResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	out := make([]SnoopResult, len(names))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			out[i] = snoopOne(ctx, server, name, opts)
+		}(i, name)
+	}
+	wg.Wait()
+	return out
+}
+
+func snoopOne(ctx context.Context, server, name string, opts Options) SnoopResult {
+	res := SnoopResult{Name: name}
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), opts.qtype())
+	m.RecursionDesired = false
+	resp, _, err := Exchange(ctx, server, m, opts)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.RCode = dns.RcodeToString[resp.Rcode]
+	res.State, res.TTL = classifySnoop(resp)
+	return res
+}
+
+// classifySnoop reads a reply to a non-recursive query. Answers are cached
+// data; an SOA in the authority section is a cached negative answer; a
+// referral (NS records only) or an empty reply means the resolver has
+// nothing cached and points elsewhere. SERVFAIL is what some resolvers send
+// instead of a referral.
+func classifySnoop(resp *dns.Msg) (SnoopState, uint32) {
+	switch resp.Rcode {
+	case dns.RcodeRefused:
+		return SnoopRefused, 0
+	case dns.RcodeServerFailure:
+		return SnoopNotCached, 0
+	case dns.RcodeSuccess, dns.RcodeNameError:
+	default:
+		return SnoopUnclear, 0
+	}
+	if len(resp.Answer) > 0 {
+		ttl := resp.Answer[0].Header().Ttl
+		for _, rr := range resp.Answer[1:] {
+			ttl = min(ttl, rr.Header().Ttl)
+		}
+		return SnoopCached, ttl
+	}
+	if soa := authoritySOA(resp); soa != nil {
+		return SnoopNegative, soa.Hdr.Ttl
+	}
+	return SnoopNotCached, 0
+}
+
+// SnoopControl snoops a random name that cannot be cached. A resolver that
+// answers it with NXDOMAIN recursed despite RD being clear, so its other
+// snoop results say nothing about the cache.
+func SnoopControl(ctx context.Context, server string, opts Options) (recursed bool, err error) {
+	label, err := randomLabel(30)
+	if err != nil {
+		return false, err
+	}
+	r := snoopOne(ctx, server, label+".com", opts)
+	if r.Err != nil {
+		return false, r.Err
+	}
+	return r.RCode == dns.RcodeToString[dns.RcodeNameError], nil
+}
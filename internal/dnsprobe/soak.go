@@ -0,0 +1,396 @@
+package dnsprobe
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SoakConfig is a soak run. Burst, when set, replaces the steady QPS with
+// microbursts: Burst queries spread evenly over BurstSpread (0 sends them
+// back to back), one burst every BurstEvery.
+//
+// Bucket, when set, paces the steady QPS from a token bucket holding up to
+// Bucket tokens instead of a ticker, so sends held up by a stall go out
+// back to back afterwards. MaxInFlight, when set, caps unanswered queries:
+// a query due while every slot is taken is skipped, so a server that slows
+// down shows up as a shortfall in the rate rather than as client-side
+// queueing.
+type SoakConfig struct {
+	Server      string
+	Domains     []string
+	Options     Options
+	QPS         float64
+	Duration    time.Duration
+	Interval    time.Duration
+	Burst       int
+	BurstSpread time.Duration
+	BurstEvery  time.Duration
+	Bucket      int
+	MaxInFlight int
+}
+
+// errSkipped stands in for the result of a query skipped for want of a
+// MaxInFlight slot.
+var errSkipped = errors.New("skipped: every in-flight slot taken")
+
+// BurstSummary is one microburst of a soak run. Spread is how long sending
+// it actually took, which the scheduler can only approximate for tight
+// bursts; P50 and Max are exact.
+type BurstSummary struct {
+	Seq     int
+	Start   time.Time
+	Spread  time.Duration
+	Sent    int
+	Success int
+	Lost    int
+	Errors  int
+	P50     time.Duration
+	Max     time.Duration
+}
+
+func (b BurstSummary) LossRatio() float64 {
+	if b.Sent == 0 {
+		return 0
+	}
+	return float64(b.Lost) / float64(b.Sent)
+}
+
+// burstAcc collects the replies of one burst until all of them are in.
+type burstAcc struct {
+	BurstSummary
+	last time.Time
+	rtts []time.Duration
+	n    int
+}
+
+func (a *burstAcc) finish() BurstSummary {
+	b := a.BurstSummary
+	b.Spread = a.last.Sub(a.Start)
+	if len(a.rtts) > 0 {
+		slices.Sort(a.rtts)
+		b.P50, b.Max = a.rtts[(len(a.rtts)-1)/2], a.rtts[len(a.rtts)-1]
+	}
+	return b
+}
+
+// SoakSummary is one interval of a soak run, or the whole run. Failures
+// breaks Lost and Errors down by ErrorClass; Skipped counts queries that
+// were due but never sent (see SoakConfig.MaxInFlight).
+type SoakSummary struct {
+	Start    time.Time
+	End      time.Time
+	Sent     int
+	Skipped  int
+	Success  int
+	Lost     int
+	Errors   int
+	Failures map[string]int
+	Min      time.Duration
+	Mean     time.Duration
+	P50      time.Duration
+	P90      time.Duration
+	P95      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+	RCodes   map[string]int
+	Bursts   []BurstSummary
+	latency  Distribution
+}
+
+func newSoakSummary(start time.Time) *SoakSummary {
+	return &SoakSummary{Start: start, RCodes: map[string]int{}, Failures: map[string]int{}}
+}
+
+func (s *SoakSummary) record(r Result, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return
+	case errors.Is(err, errSkipped):
+		s.Skipped++
+		return
+	}
+	s.Sent++
+	if err != nil {
+		s.Failures[ErrorClass(err)]++
+		if IsTimeout(err) {
+			s.Lost++
+		} else {
+			s.Errors++
+		}
+		return
+	}
+	s.Success++
+	s.RCodes[r.RCode]++
+	s.latency.Add(r.Timings.Total)
+}
+
+func (s *SoakSummary) absorb(o *SoakSummary) {
+	s.Sent += o.Sent
+	s.Skipped += o.Skipped
+	s.Success += o.Success
+	s.Lost += o.Lost
+	s.Errors += o.Errors
+	for k, v := range o.RCodes {
+		s.RCodes[k] += v
+	}
+	for k, v := range o.Failures {
+		s.Failures[k] += v
+	}
+	s.Bursts = append(s.Bursts, o.Bursts...)
+	s.latency.Merge(&o.latency)
+}
+
+func (s *SoakSummary) finish(end time.Time) SoakSummary {
+	s.End = end
+	s.Min = s.latency.Min()
+	s.Mean = s.latency.Mean()
+	s.P50 = s.latency.Quantile(0.50)
+	s.P90 = s.latency.Quantile(0.90)
+	s.P95 = s.latency.Quantile(0.95)
+	s.P99 = s.latency.Quantile(0.99)
+	s.Max = s.latency.Max()
+	return *s
+}
+
+func (s SoakSummary) LossRatio() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Lost) / float64(s.Sent)
+}
+
+// QPS is the rate queries completed at over the summary.
+func (s SoakSummary) QPS() float64 {
+	d := s.End.Sub(s.Start).Seconds()
+	if d <= 0 {
+		return 0
+	}
+	return float64(s.Sent) / d
+}
+
+// FailRatio counts both lost queries and errors.
+func (s SoakSummary) FailRatio() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Lost+s.Errors) / float64(s.Sent)
+}
+
+// Soak sends queries at a steady cfg.QPS, or in cfg.Burst microbursts,
+// until cfg.Duration elapses or ctx is cancelled, calling onInterval with a
+// summary of every cfg.Interval window. The returned summary covers the
+// whole run; each burst is reported in the window its last reply arrived in.
+func Soak(ctx context.Context, cfg SoakConfig, onInterval func(SoakSummary)) SoakSummary {
+	// Queries still in flight when the duration ends may finish; cancelling
+	// ctx abandons them.
+	probeCtx := ctx
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	type one struct {
+		r     Result
+		err   error
+		burst int
+		sent  time.Time
+	}
+
+	results := make(chan one, 1024)
+	var wg sync.WaitGroup
+	var slots chan struct{}
+	if cfg.MaxInFlight > 0 {
+		slots = make(chan struct{}, cfg.MaxInFlight)
+	}
+
+	go func() {
+		defer close(results)
+		defer wg.Wait()
+
+		i := 0
+		send := func(burst int) {
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+				default:
+					results <- one{err: errSkipped, burst: burst, sent: time.Now()}
+					return
+				}
+			}
+			seq, name := i, cfg.Domains[i%len(cfg.Domains)]
+			i++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sent := time.Now()
+				cfg.Options.Hooks.start(seq, name)
+				r, err := Probe(probeCtx, cfg.Server, name, cfg.Options)
+				cfg.Options.Hooks.done(seq, r, err)
+				if slots != nil {
+					<-slots
+				}
+				if CutShort(probeCtx, err) {
+					err = context.Canceled
+				}
+				results <- one{r: r, err: err, burst: burst, sent: sent}
+			}()
+		}
+		if cfg.Burst == 0 && cfg.Bucket > 0 {
+			tokenBucket(ctx, cfg.QPS, cfg.Bucket, func() { send(-1) })
+			return
+		}
+
+		every := rateInterval(cfg.QPS)
+		if cfg.Burst > 0 {
+			every = cfg.BurstEvery
+		}
+		tick := time.NewTicker(every)
+		defer tick.Stop()
+		for burst := 0; ; burst++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if cfg.Burst == 0 {
+					send(-1)
+					continue
+				}
+				start := time.Now()
+				for j := 0; j < cfg.Burst; j++ {
+					waitUntil(start.Add(cfg.BurstSpread * time.Duration(j) / time.Duration(cfg.Burst)))
+					send(burst)
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	total := newSoakSummary(start)
+	window := newSoakSummary(start)
+	pending := map[int]*burstAcc{}
+
+	interval := time.NewTicker(cfg.Interval)
+	defer interval.Stop()
+
+	for {
+		select {
+		case v, ok := <-results:
+			if !ok {
+				end := time.Now()
+				seqs := make([]int, 0, len(pending))
+				for seq := range pending {
+					seqs = append(seqs, seq)
+				}
+				slices.Sort(seqs)
+				for _, seq := range seqs {
+					if b := pending[seq].finish(); b.Sent > 0 {
+						window.Bursts = append(window.Bursts, b)
+					}
+				}
+				if window.Sent > 0 && onInterval != nil {
+					onInterval(window.finish(end))
+				}
+				total.absorb(window)
+				return total.finish(end)
+			}
+			window.record(v.r, v.err)
+			if v.burst < 0 {
+				continue
+			}
+			a := pending[v.burst]
+			if a == nil {
+				a = &burstAcc{BurstSummary: BurstSummary{Seq: v.burst + 1, Start: v.sent}}
+				pending[v.burst] = a
+			}
+			a.add(v.r, v.err, v.sent)
+			if a.n == cfg.Burst {
+				window.Bursts = append(window.Bursts, a.finish())
+				delete(pending, v.burst)
+			}
+		case now := <-interval.C:
+			if onInterval != nil {
+				onInterval(window.finish(now))
+			}
+			total.absorb(window)
+			window = newSoakSummary(now)
+		}
+	}
+}
+
+func (a *burstAcc) add(r Result, err error, sent time.Time) {
+	a.n++
+	if sent.Before(a.Start) {
+		a.Start = sent
+	}
+	if sent.After(a.last) {
+		a.last = sent
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, errSkipped) {
+		return
+	}
+	a.Sent++
+	switch {
+	case err == nil:
+		a.Success++
+		a.rtts = append(a.rtts, r.Timings.Total)
+	case IsTimeout(err):
+		a.Lost++
+	default:
+		a.Errors++
+	}
+}
+
+// tokenBucket calls send at qps from a bucket of up to size tokens until
+// ctx is done. After a stall up to size sends go out back to back to catch
+// up; any beyond that are dropped.
+func tokenBucket(ctx context.Context, qps float64, size int, send func()) {
+	full := float64(max(size, 1))
+	tokens, last := full, time.Now()
+	for ctx.Err() == nil {
+		now := time.Now()
+		tokens = math.Min(full, tokens+now.Sub(last).Seconds()*qps)
+		last = now
+		if tokens < 1 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Duration((1 - tokens) / qps * float64(time.Second))):
+			}
+			continue
+		}
+		tokens--
+		send()
+	}
+}
+
+// waitUntil returns at t, sleeping while it is far off and yielding for
+// the last millisecond, where timers are too coarse for microbursts.
+func waitUntil(t time.Time) {
+	for {
+		d := time.Until(t)
+		switch {
+		case d <= 0:
+			return
+		case d > time.Millisecond:
+			time.Sleep(d - time.Millisecond)
+		default:
+			runtime.Gosched()
+		}
+	}
+}
+
+// IsTimeout reports whether err is a network timeout, i.e. a lost query
+// rather than an outright failure.
+func IsTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// rateInterval is the time between sends at rate per second. Rates past
+// one per nanosecond, which a ticker cannot run at, send as fast as it can.
+func rateInterval(rate float64) time.Duration {
+	return max(time.Duration(float64(time.Second)/rate), time.Nanosecond)
+}
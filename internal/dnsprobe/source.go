@@ -0,0 +1,80 @@
+package dnsprobe
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// dialer returns a dialer for reaching server over network, bound to
+// opts.Source and opts.SourcePort when set.
+func (o Options) dialer(network, server string) (*net.Dialer, error) {
+	d := &net.Dialer{Timeout: o.Timeout}
+	if o.Source == "" && o.SourcePort == 0 {
+		return d, nil
+	}
+	var ip net.IPAddr
+	if o.Source != "" {
+		var err error
+		if ip, err = sourceIP(o.Source, network, server); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasPrefix(network, "tcp") {
+		d.LocalAddr = &net.TCPAddr{IP: ip.IP, Zone: ip.Zone, Port: o.SourcePort}
+	} else {
+		d.LocalAddr = &net.UDPAddr{IP: ip.IP, Zone: ip.Zone, Port: o.SourcePort}
+	}
+	return d, nil
+}
+
+// sourceIP resolves source, either a literal IP or an interface name, to the
+// address to bind. For interfaces the address family follows the network
+// suffix or the server literal, preferring IPv4 for hostnames.
+func sourceIP(source, network, server string) (net.IPAddr, error) {
+	if ip := net.ParseIP(source); ip != nil {
+		return net.IPAddr{IP: ip}, nil
+	}
+
+	ifi, err := net.InterfaceByName(source)
+	if err != nil {
+		return net.IPAddr{}, fmt.Errorf("--source %q is neither an IP address nor an interface: %w", source, err)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return net.IPAddr{}, err
+	}
+
+	want6 := strings.HasSuffix(network, "6")
+	if !strings.HasSuffix(network, "4") && !want6 {
+		if host, _, err := net.SplitHostPort(server); err == nil {
+			if ip := net.ParseIP(strings.SplitN(host, "%", 2)[0]); ip != nil {
+				want6 = ip.To4() == nil
+			}
+		}
+	}
+
+	var linkLocal *net.IPAddr
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok || (ipn.IP.To4() == nil) != want6 {
+			continue
+		}
+		if ipn.IP.IsLinkLocalUnicast() {
+			if linkLocal == nil {
+				linkLocal = &net.IPAddr{IP: ipn.IP, Zone: ifi.Name}
+			}
+			continue
+		}
+		return net.IPAddr{IP: ipn.IP}, nil
+	}
+	if linkLocal != nil {
+		return *linkLocal, nil
+	}
+
+	fam := "IPv4"
+	if want6 {
+		fam = "IPv6"
+	}
+	return net.IPAddr{}, fmt.Errorf("interface %s has no %s address", source, fam)
+}
@@ -0,0 +1,199 @@
+package dnsprobe
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// PortSample is one query a recursive resolver sent to the PortListener.
+type PortSample struct {
+	Source string // resolver egress address
+	Port   int
+	ID     uint16
+	QName  string
+	At     time.Time
+}
+
+// PortListener is a minimal authoritative server for a test zone delegated to
+// this host. It records where each query for the zone came from and answers
+// it with a TXT record naming that address, so resolver egress ports can be
+// observed from the outside.
+type PortListener struct {
+	zone    string
+	servers []*dns.Server
+
+	mu      sync.Mutex
+	samples []PortSample
+}
+
+// ListenPorts starts a PortListener for zone on addr, over UDP and TCP.
+func ListenPorts(addr, zone string) (*PortListener, error) {
+	l := &PortListener{zone: dns.CanonicalName(zone)}
+	for _, network := range []string{"udp", "tcp"} {
+		srv := &dns.Server{Addr: addr, Net: network, Handler: dns.HandlerFunc(l.handle)}
+		started := make(chan error, 1)
+		srv.NotifyStartedFunc = func() { started <- nil }
+		go func() { started <- srv.ListenAndServe() }()
+		if err := <-started; err != nil {
+			l.Close()
+			return nil, fmt.Errorf("listen %s/%s: %w", addr, network, err)
+		}
+		l.servers = append(l.servers, srv)
+	}
+	return l, nil
+}
+
+func (l *PortListener) handle(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+	if len(req.Question) != 1 || !dns.IsSubDomain(l.zone, dns.CanonicalName(req.Question[0].Name)) {
+		m.Rcode = dns.RcodeRefused
+		_ = w.WriteMsg(m)
+		return
+	}
+	q := req.Question[0]
+
+	host, port := w.RemoteAddr().String(), 0
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host = h
+		port, _ = strconv.Atoi(p)
+	}
+	l.mu.Lock()
+	l.samples = append(l.samples, PortSample{Source: host, Port: port, ID: req.Id, QName: q.Name, At: time.Now()})
+	l.mu.Unlock()
+
+	if q.Qtype == dns.TypeTXT {
+		m.Answer = append(m.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET},
+			Txt: []string{net.JoinHostPort(host, strconv.Itoa(port))},
+		})
+	}
+	_ = w.WriteMsg(m)
+}
+
+// Samples returns the queries seen so far, in arrival order.
+func (l *PortListener) Samples() []PortSample {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]PortSample(nil), l.samples...)
+}
+
+func (l *PortListener) Close() {
+	for _, srv := range l.servers {
+		_ = srv.Shutdown()
+	}
+}
+
+// TriggerPortQueries asks server n TXT questions for random names under zone,
+// one after another, so that it has to query the zone's authoritative server
+// each time. It returns how many of them failed.
+func TriggerPortQueries(ctx context.Context, server, zone string, opts Options, n int, interval time.Duration) int {
+	opts.Type = dns.TypeTXT
+	failed := 0
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		var b [6]byte
+		_, _ = rand.Read(b[:])
+		name := "p" + hex.EncodeToString(b[:]) + "." + dns.Fqdn(zone)
+		if _, err := Probe(ctx, server, name, opts); err != nil {
+			failed++
+		}
+		if interval > 0 {
+			time.Sleep(interval)
+		}
+	}
+	return failed
+}
+
+// Spread summarises how a sample of 16-bit values (ports, message IDs) is
+// distributed. Bits estimates the entropy of the source from the standard
+// deviation, as the width of a uniform range with that deviation; Sequential
+// is the share of consecutive samples less than 16 apart, which catches
+// counters that a standard deviation alone would miss.
+type Spread struct {
+	N          int
+	Unique     int
+	Min        int
+	Max        int
+	StdDev     float64
+	Bits       float64
+	Sequential float64
+}
+
+func SpreadOf(vals []int) Spread {
+	s := Spread{N: len(vals)}
+	if len(vals) == 0 {
+		return s
+	}
+	seen := map[int]bool{}
+	s.Min, s.Max = vals[0], vals[0]
+	var sum float64
+	for i, v := range vals {
+		seen[v] = true
+		s.Min, s.Max = min(s.Min, v), max(s.Max, v)
+		sum += float64(v)
+		if i > 0 && abs(v-vals[i-1]) < 16 {
+			s.Sequential++
+		}
+	}
+	s.Unique = len(seen)
+	mean := sum / float64(len(vals))
+	var sq float64
+	for _, v := range vals {
+		sq += (float64(v) - mean) * (float64(v) - mean)
+	}
+	s.StdDev = math.Sqrt(sq / float64(len(vals)))
+	if s.StdDev > 0 {
+		s.Bits = math.Max(0, math.Log2(s.StdDev*math.Sqrt(12)))
+	}
+	if len(vals) > 1 {
+		s.Sequential /= float64(len(vals) - 1)
+	}
+	return s
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// PortRating grades source-port randomness with the DNS-OARC porttest
+// thresholds on the standard deviation: GREAT from 3980, GOOD from 296,
+// POOR below. Fixed or sequential ports are POOR whatever the deviation.
+// The same thresholds rate message IDs, as in txidtest.
+func PortRating(s Spread) string {
+	switch {
+	case s.Unique <= 1 || s.Sequential > 0.5:
+		return "POOR"
+	case s.StdDev >= 3980:
+		return "GREAT"
+	case s.StdDev >= 296:
+		return "GOOD"
+	}
+	return "POOR"
+}
+
+// PortsBySource groups samples by resolver egress address, sorted by address.
+func PortsBySource(samples []PortSample) (sources []string, ports map[string][]int) {
+	ports = map[string][]int{}
+	for _, s := range samples {
+		if _, ok := ports[s.Source]; !ok {
+			sources = append(sources, s.Source)
+		}
+		ports[s.Source] = append(ports[s.Source], s.Port)
+	}
+	sort.Strings(sources)
+	return sources, ports
+}
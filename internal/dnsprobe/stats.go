@@ -0,0 +1,136 @@
+package dnsprobe
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// Distribution is a streaming latency histogram with fixed log-linear
+// (HDR-style) buckets: values below subBuckets ns are counted exactly, larger
+// values fall into buckets whose width is 1/subBuckets of their lower bound.
+// Memory use is constant no matter how many samples are added.
+type Distribution struct {
+	counts []uint64
+	n      uint64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+const (
+	subBucketBits = 7
+	subBuckets    = 1 << subBucketBits
+	bucketCount   = (64 - subBucketBits + 1) * subBuckets
+)
+
+// QuantileRelativeError is the worst-case relative error of Quantile: results
+// are bucket midpoints, so they are off by at most half a bucket width.
+const QuantileRelativeError = 1.0 / (2 * subBuckets)
+
+func bucketIndex(v uint64) int {
+	if v < subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - 1 - subBucketBits
+	sub := int(v>>uint(shift)) - subBuckets
+	return subBuckets + shift*subBuckets + sub
+}
+
+func bucketMidpoint(idx int) time.Duration {
+	if idx < subBuckets {
+		return time.Duration(idx)
+	}
+	shift := (idx - subBuckets) / subBuckets
+	sub := (idx - subBuckets) % subBuckets
+	lower := uint64(subBuckets+sub) << uint(shift)
+	width := uint64(1) << uint(shift)
+	return time.Duration(lower + width/2)
+}
+
+func (d *Distribution) Add(v time.Duration) {
+	if d.counts == nil {
+		d.counts = make([]uint64, bucketCount)
+	}
+	if v < 0 {
+		v = 0
+	}
+	d.counts[bucketIndex(uint64(v))]++
+	if d.n == 0 || v < d.min {
+		d.min = v
+	}
+	if v > d.max {
+		d.max = v
+	}
+	d.n++
+	d.sum += v
+}
+
+func (d *Distribution) Merge(o *Distribution) {
+	if o.n == 0 {
+		return
+	}
+	if d.counts == nil {
+		d.counts = make([]uint64, bucketCount)
+	}
+	for i, c := range o.counts {
+		d.counts[i] += c
+	}
+	if d.n == 0 || o.min < d.min {
+		d.min = o.min
+	}
+	if o.max > d.max {
+		d.max = o.max
+	}
+	d.n += o.n
+	d.sum += o.sum
+}
+
+func (d *Distribution) Count() int {
+	return int(d.n)
+}
+
+func (d *Distribution) Mean() time.Duration {
+	if d.n == 0 {
+		return 0
+	}
+	return d.sum / time.Duration(d.n)
+}
+
+func (d *Distribution) Min() time.Duration {
+	return d.min
+}
+
+func (d *Distribution) Max() time.Duration {
+	return d.max
+}
+
+// Quantile uses the nearest-rank method; q is in [0,1]. The result is accurate
+// to within QuantileRelativeError and is clamped to the exact min/max seen.
+func (d *Distribution) Quantile(q float64) time.Duration {
+	if d.n == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return d.min
+	}
+	if q >= 1 {
+		return d.max
+	}
+	rank := uint64(math.Ceil(q * float64(d.n)))
+	var seen uint64
+	for i, c := range d.counts {
+		seen += c
+		if seen >= rank {
+			v := bucketMidpoint(i)
+			if v < d.min {
+				v = d.min
+			}
+			if v > d.max {
+				v = d.max
+			}
+			return v
+		}
+	}
+	return d.max
+}
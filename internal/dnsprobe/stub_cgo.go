@@ -0,0 +1,55 @@
+//go:build cgo && unix
+
+package dnsprobe
+
+/*
+#include <sys/types.h>
+#include <sys/socket.h>
+#include <netdb.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"net"
+	"unsafe"
+)
+
+const stubPath = "getaddrinfo"
+
+// stubLookup calls the C library's getaddrinfo directly, so the lookup goes
+// through nsswitch, any local caching daemon and search-list processing
+// exactly as it does for C programs on the host.
+func stubLookup(name string) ([]string, error) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	var hints C.struct_addrinfo
+	hints.ai_family = C.AF_UNSPEC
+	hints.ai_socktype = C.SOCK_STREAM
+
+	var res *C.struct_addrinfo
+	if rc := C.getaddrinfo(cname, nil, &hints, &res); rc != 0 {
+		return nil, fmt.Errorf("getaddrinfo: %s", C.GoString(C.gai_strerror(rc)))
+	}
+	defer C.freeaddrinfo(res)
+
+	var out []string
+	for ai := res; ai != nil; ai = ai.ai_next {
+		sa := C.GoBytes(unsafe.Pointer(ai.ai_addr), C.int(ai.ai_addrlen))
+		// The address sits at the same offset in the Linux and BSD layouts
+		// of sockaddr_in (4) and sockaddr_in6 (8).
+		switch ai.ai_family {
+		case C.AF_INET:
+			if len(sa) >= 8 {
+				out = append(out, net.IP(sa[4:8]).String())
+			}
+		case C.AF_INET6:
+			if len(sa) >= 24 {
+				out = append(out, net.IP(sa[8:24]).String())
+			}
+		}
+	}
+	return out, nil
+}
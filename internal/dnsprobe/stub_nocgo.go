@@ -0,0 +1,14 @@
+//go:build !cgo || !unix
+
+package dnsprobe
+
+import (
+	"context"
+	"net"
+)
+
+const stubPath = "go default (no cgo getaddrinfo in this build)"
+
+func stubLookup(name string) ([]string, error) {
+	return net.DefaultResolver.LookupHost(context.Background(), name)
+}
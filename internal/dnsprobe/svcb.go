@@ -0,0 +1,124 @@
+package dnsprobe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ServiceBinding is a decoded SVCB or HTTPS record (RFC 9460). A zero
+// Priority is alias mode, where Target names another service binding and
+// Params is empty.
+type ServiceBinding struct {
+	Priority uint16
+	Target   string
+	Params   []SvcParam
+}
+
+// SvcParam is one SvcParam of a service binding with its value decoded for
+// display.
+type SvcParam struct {
+	Key   string
+	Value string
+}
+
+// Mode is "alias" or "service".
+func (b ServiceBinding) Mode() string {
+	if b.Priority == 0 {
+		return "alias"
+	}
+	return "service"
+}
+
+// DecodeServiceBinding decodes rr when it is an SVCB or HTTPS record.
+func DecodeServiceBinding(rr dns.RR) (*ServiceBinding, bool) {
+	var s *dns.SVCB
+	switch rr := rr.(type) {
+	case *dns.SVCB:
+		s = rr
+	case *dns.HTTPS:
+		s = &rr.SVCB
+	default:
+		return nil, false
+	}
+	b := &ServiceBinding{Priority: s.Priority, Target: s.Target}
+	for _, kv := range s.Value {
+		p := SvcParam{Key: kv.Key().String(), Value: kv.String()}
+		switch kv := kv.(type) {
+		case *dns.SVCBAlpn:
+			p.Value = strings.Join(kv.Alpn, ", ")
+		case *dns.SVCBIPv4Hint:
+			p.Value = joinIPs(kv.Hint)
+		case *dns.SVCBIPv6Hint:
+			p.Value = joinIPs(kv.Hint)
+		case *dns.SVCBMandatory:
+			keys := make([]string, len(kv.Code))
+			for i, k := range kv.Code {
+				keys[i] = k.String()
+			}
+			p.Value = strings.Join(keys, ", ")
+		case *dns.SVCBNoDefaultAlpn:
+			p.Value = "clients must not fall back to the default ALPN"
+		case *dns.SVCBECHConfig:
+			p.Value = describeECH(kv.ECH)
+		}
+		b.Params = append(b.Params, p)
+	}
+	return b, true
+}
+
+func joinIPs[T fmt.Stringer](ips []T) string {
+	parts := make([]string, len(ips))
+	for i, ip := range ips {
+		parts[i] = ip.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describeECH summarizes an Encrypted ClientHello ECHConfigList: how many
+// configs it holds and the public names clients put in the outer
+// ClientHello.
+func describeECH(b []byte) string {
+	if len(b) < 2 || int(binary.BigEndian.Uint16(b)) != len(b)-2 {
+		return fmt.Sprintf("malformed ECHConfigList (%d bytes)", len(b))
+	}
+	var n int
+	var names []string
+	for list := b[2:]; len(list) > 0; n++ {
+		if len(list) < 4 || 4+int(binary.BigEndian.Uint16(list[2:])) > len(list) {
+			return fmt.Sprintf("malformed ECHConfigList (%d bytes)", len(b))
+		}
+		version, body := binary.BigEndian.Uint16(list), list[4:4+int(binary.BigEndian.Uint16(list[2:]))]
+		list = list[4+len(body):]
+		if version != 0xfe0d {
+			names = append(names, fmt.Sprintf("(version 0x%04x)", version))
+			continue
+		}
+		if name, ok := echPublicName(body); ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("%d config(s), %d bytes", n, len(b))
+	}
+	return fmt.Sprintf("%d config(s), public name %s, %d bytes", n, strings.Join(names, ", "), len(b))
+}
+
+// echPublicName reads public_name from the contents of a version 0xfe0d
+// ECHConfig.
+func echPublicName(b []byte) (string, bool) {
+	off := 3 // config_id, kem_id
+	for i := 0; i < 2; i++ {
+		if off+2 > len(b) {
+			return "", false
+		}
+		off += 2 + int(binary.BigEndian.Uint16(b[off:])) // public_key, cipher_suites
+	}
+	off++ // maximum_name_length
+	if off >= len(b) || off+1+int(b[off]) > len(b) {
+		return "", false
+	}
+	return string(b[off+1 : off+1+int(b[off])]), true
+}
@@ -0,0 +1,67 @@
+package dnsprobe
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	"github.com/miekg/dns"
+)
+
+// SystemResolver is one resolver entry from the host configuration. Domain is
+// set for per-domain (split DNS) resolvers; Scoped marks resolvers that only
+// apply to queries bound to Interface; Upstream marks servers a local stub
+// forwards to rather than ones applications talk to.
+type SystemResolver struct {
+	Server    string
+	Domain    string
+	Scoped    bool
+	Upstream  bool
+	Interface string
+	Source    string
+}
+
+func SystemResolvers() ([]SystemResolver, error) {
+	return systemResolvers()
+}
+
+func SystemDefaultDNSServer() (string, error) {
+	rs, err := systemResolvers()
+	if err != nil {
+		return "", err
+	}
+	for _, r := range rs {
+		if r.Domain == "" && !r.Scoped {
+			return r.Server, nil
+		}
+	}
+	return rs[0].Server, nil
+}
+
+const resolvConfPath = "/etc/resolv.conf"
+
+func resolvConfResolvers() ([]SystemResolver, error) {
+	if _, err := os.Stat(resolvConfPath); err != nil {
+		return nil, err
+	}
+	cfg, err := dns.ClientConfigFromFile(resolvConfPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Servers) == 0 {
+		return nil, errors.New("no nameserver entries in /etc/resolv.conf")
+	}
+	out := make([]SystemResolver, 0, len(cfg.Servers))
+	for _, s := range cfg.Servers {
+		out = append(out, SystemResolver{Server: net.JoinHostPort(s, cfg.Port), Source: resolvConfPath})
+	}
+	return out, nil
+}
+
+func resolvConfSearch() (SearchConfig, error) {
+	cfg, err := dns.ClientConfigFromFile(resolvConfPath)
+	if err != nil {
+		return SearchConfig{Ndots: 1}, err
+	}
+	return SearchConfig{Search: cfg.Search, Ndots: cfg.Ndots}, nil
+}
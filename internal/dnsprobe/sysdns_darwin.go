@@ -0,0 +1,114 @@
+//go:build darwin
+
+package dnsprobe
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// systemResolvers reads the live resolver configuration from
+// SystemConfiguration via scutil. /etc/resolv.conf on macOS only mirrors the
+// primary resolver and misses VPN scoped and per-domain entries, so it is only
+// used as a fallback.
+func systemResolvers() ([]SystemResolver, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "scutil", "--dns").Output()
+	if err == nil {
+		if rs := parseScutilDNS(string(out)); len(rs) > 0 {
+			return rs, nil
+		}
+	}
+	return resolvConfResolvers()
+}
+
+func parseScutilDNS(out string) []SystemResolver {
+	var (
+		res    []SystemResolver
+		scoped bool
+		cur    struct {
+			servers []string
+			domain  string
+			port    string
+			iface   string
+			mdns    bool
+			scoped  bool
+		}
+	)
+
+	flush := func() {
+		if !cur.mdns {
+			port := cur.port
+			if port == "" {
+				port = "53"
+			}
+			for _, s := range cur.servers {
+				res = append(res, SystemResolver{
+					Server:    net.JoinHostPort(s, port),
+					Domain:    cur.domain,
+					Scoped:    scoped || cur.scoped,
+					Interface: cur.iface,
+					Source:    "scutil --dns",
+				})
+			}
+		}
+		cur.servers, cur.domain, cur.port, cur.iface, cur.mdns, cur.scoped = nil, "", "", "", false, false
+	}
+
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "DNS configuration"):
+			flush()
+			scoped = strings.Contains(line, "scoped")
+			continue
+		case strings.HasPrefix(line, "resolver #"):
+			flush()
+			continue
+		}
+
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		val = strings.TrimSpace(val)
+		if i := strings.IndexByte(key, '['); i >= 0 {
+			key = key[:i]
+		}
+
+		switch key {
+		case "nameserver":
+			// scoped IPv6 link-local entries look like fe80::1%en0
+			cur.servers = append(cur.servers, val)
+		case "domain":
+			cur.domain = val
+		case "port":
+			cur.port = val
+		case "if_index":
+			if i, j := strings.IndexByte(val, '('), strings.IndexByte(val, ')'); i >= 0 && j > i {
+				cur.iface = val[i+1 : j]
+			}
+		case "options":
+			cur.mdns = strings.Contains(val, "mdns")
+		case "flags":
+			cur.scoped = strings.Contains(val, "Scoped")
+		}
+	}
+	flush()
+
+	return res
+}
+
+// The resolv.conf mirror on macOS carries the primary service's search list,
+// which is what unscoped lookups use.
+func systemSearchConfig() (SearchConfig, error) {
+	return resolvConfSearch()
+}
@@ -0,0 +1,21 @@
+//go:build !windows && !darwin
+
+package dnsprobe
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+func systemResolvers() ([]SystemResolver, error) {
+	rs, err := resolvConfResolvers()
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("unsupported auto-detection on %s; pass dns-server explicitly (e.g. 1.1.1.1 or 1.1.1.1:53)", runtime.GOOS)
+	}
+	return rs, err
+}
+
+func systemSearchConfig() (SearchConfig, error) {
+	return resolvConfSearch()
+}
@@ -0,0 +1,103 @@
+//go:build windows
+
+package dnsprobe
+
+import (
+	"errors"
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	gaaFlagSkipUnicast   = 0x0001
+	gaaFlagSkipAnycast   = 0x0002
+	gaaFlagSkipMulticast = 0x0004
+)
+
+func adapterAddresses() (*windows.IpAdapterAddresses, error) {
+	size := uint32(15 * 1024)
+	for {
+		buf := make([]byte, size)
+		first := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0]))
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC,
+			gaaFlagSkipUnicast|gaaFlagSkipAnycast|gaaFlagSkipMulticast, 0, first, &size)
+		if err == nil {
+			return first, nil
+		}
+		if err != windows.ERROR_BUFFER_OVERFLOW {
+			return nil, err
+		}
+	}
+}
+
+// systemResolvers asks the IP Helper API for the DNS servers of every
+// adapter that is up, in adapter order.
+func systemResolvers() ([]SystemResolver, error) {
+	first, err := adapterAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var res []SystemResolver
+	for aa := first; aa != nil; aa = aa.Next {
+		if aa.OperStatus != windows.IfOperStatusUp {
+			continue
+		}
+		for ds := aa.FirstDnsServerAddress; ds != nil; ds = ds.Next {
+			ip := ds.Address.IP()
+			if ip == nil || isWindowsSiteLocalDefault(ip) {
+				continue
+			}
+			s := net.JoinHostPort(ip.String(), "53")
+			if seen[s] {
+				continue
+			}
+			seen[s] = true
+			res = append(res, SystemResolver{
+				Server:    s,
+				Interface: windows.UTF16PtrToString(aa.FriendlyName),
+				Source:    "GetAdaptersAddresses",
+			})
+		}
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New("no DNS servers configured on any active network adapter")
+	}
+	return res, nil
+}
+
+// Windows reports fec0:0:0:ffff::1-3 on adapters without IPv6 DNS configured.
+func isWindowsSiteLocalDefault(ip net.IP) bool {
+	if ip.To4() != nil {
+		return false
+	}
+	siteLocal := net.ParseIP("fec0:0:0:ffff::")
+	return ip.Mask(net.CIDRMask(64, 128)).Equal(siteLocal.Mask(net.CIDRMask(64, 128))) && ip[15] >= 1 && ip[15] <= 3
+}
+
+// systemSearchConfig uses the connection-specific DNS suffixes of active
+// adapters. Windows devolution has no ndots knob; single-label names get the
+// suffixes appended, which matches ndots=1.
+func systemSearchConfig() (SearchConfig, error) {
+	first, err := adapterAddresses()
+	if err != nil {
+		return SearchConfig{Ndots: 1}, err
+	}
+
+	cfg := SearchConfig{Ndots: 1}
+	seen := map[string]bool{}
+	for aa := first; aa != nil; aa = aa.Next {
+		if aa.OperStatus != windows.IfOperStatusUp {
+			continue
+		}
+		if s := windows.UTF16PtrToString(aa.DnsSuffix); s != "" && !seen[s] {
+			seen[s] = true
+			cfg.Search = append(cfg.Search, s)
+		}
+	}
+	return cfg, nil
+}
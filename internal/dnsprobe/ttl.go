@@ -0,0 +1,315 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+type TTLObservation struct {
+	Domain string
+	RCode  string
+	TTLs   []uint32
+	MinTTL uint32
+	Err    error
+}
+
+// SurveyTTLs queries every domain once (with up to concurrency in flight) and
+// records the TTLs of the answers. Results keep the order of domains.
+func SurveyTTLs(ctx context.Context, server string, domains []string, opts Options, concurrency int) []TTLObservation {
+	out := make([]TTLObservation, 0, len(domains))
+	for _, p := range ProbeMany(ctx, server, domains, opts, concurrency) {
+		o := TTLObservation{Domain: p.Name, Err: p.Err}
+		if p.Err == nil {
+			o.RCode = p.Result.RCode
+			for j, a := range p.Result.Answers {
+				o.TTLs = append(o.TTLs, a.TTL)
+				if j == 0 || a.TTL < o.MinTTL {
+					o.MinTTL = a.TTL
+				}
+			}
+		}
+		out = append(out, o)
+	}
+	return out
+}
+
+// TTLComparison is one name's TTL as served by the resolver and by an
+// authoritative server of its zone, asked directly without recursion.
+type TTLComparison struct {
+	Domain     string
+	Zone       string
+	Nameserver string
+	Resolver   uint32
+	Auth       uint32
+	Err        error
+}
+
+// Stretched reports whether the resolver served a higher TTL than the zone
+// publishes, which caching alone can never produce.
+func (c TTLComparison) Stretched() bool {
+	return c.Err == nil && c.Resolver > c.Auth+1
+}
+
+// CompareTTLs resolves every domain through server and through one of its
+// zone's authoritative servers, found via server, and records both TTLs of
+// the record owned by the name itself (a CNAME or the address). Results keep
+// the order of domains.
+func CompareTTLs(ctx context.Context, server string, domains []string, opts Options, concurrency int) []TTLComparison {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	out := make([]TTLComparison, len(domains))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, d := range domains {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, d string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			out[i] = compareTTL(ctx, server, d, opts)
+		}(i, d)
+	}
+	wg.Wait()
+	return out
+}
+
+func compareTTL(ctx context.Context, server, domain string, opts Options) TTLComparison {
+	c := TTLComparison{Domain: domain}
+	name := dns.Fqdn(domain)
+	resp, err := ttlQuery(ctx, server, name, opts.qtype(), true, opts)
+	if err != nil {
+		c.Err = err
+		return c
+	}
+	var ok bool
+	if c.Resolver, ok = ownerTTL(resp, name); !ok {
+		c.Err = fmt.Errorf("resolver: no answer (%s)", dns.RcodeToString[resp.Rcode])
+		return c
+	}
+
+	if c.Zone, c.Nameserver, err = findAuthoritative(ctx, server, name, opts); err != nil {
+		c.Err = err
+		return c
+	}
+	resp, err = ttlQuery(ctx, c.Nameserver, name, opts.qtype(), false, opts)
+	if err != nil {
+		c.Err = fmt.Errorf("authoritative %s: %w", c.Nameserver, err)
+		return c
+	}
+	if !resp.Authoritative {
+		c.Err = fmt.Errorf("%s is not authoritative for %s", c.Nameserver, c.Zone)
+		return c
+	}
+	if c.Auth, ok = ownerTTL(resp, name); !ok {
+		c.Err = fmt.Errorf("authoritative %s: no answer (%s)", c.Nameserver, dns.RcodeToString[resp.Rcode])
+	}
+	return c
+}
+
+// findAuthoritative asks server for the zone cut above name (the owner of
+// the SOA it returns), the zone's nameservers and an address for the first
+// of them, in the address family the options force.
+func findAuthoritative(ctx context.Context, server, name string, opts Options) (zone, ns string, err error) {
+	zone, hosts, err := zoneNameservers(ctx, server, name, opts)
+	if err != nil {
+		return zone, "", err
+	}
+	for _, h := range hosts {
+		if addrs := nameserverAddrs(ctx, server, h, opts); len(addrs) > 0 {
+			return zone, addrs[0], nil
+		}
+	}
+	return zone, "", fmt.Errorf("no address for any nameserver of %s", zone)
+}
+
+// zoneNameservers asks server for the zone cut above name (the owner of the
+// SOA it returns) and the zone's nameserver names, sorted.
+func zoneNameservers(ctx context.Context, server, name string, opts Options) (zone string, hosts []string, err error) {
+	resp, err := ttlQuery(ctx, server, name, dns.TypeSOA, true, opts)
+	if err != nil {
+		return "", nil, fmt.Errorf("zone lookup: %w", err)
+	}
+	for _, rr := range append(resp.Answer, resp.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok {
+			zone = soa.Hdr.Name
+			break
+		}
+	}
+	if zone == "" {
+		return "", nil, fmt.Errorf("zone lookup: no SOA for %s", name)
+	}
+
+	if resp, err = ttlQuery(ctx, server, zone, dns.TypeNS, true, opts); err != nil {
+		return zone, nil, fmt.Errorf("nameserver lookup: %w", err)
+	}
+	for _, rr := range resp.Answer {
+		if n, ok := rr.(*dns.NS); ok {
+			hosts = append(hosts, n.Ns)
+		}
+	}
+	if len(hosts) == 0 {
+		return zone, nil, fmt.Errorf("nameserver lookup: no NS records for %s", zone)
+	}
+	slices.Sort(hosts)
+	return zone, hosts, nil
+}
+
+// nameserverAddrs resolves host through server to host:53 addresses, IPv4
+// first, limited to the family the options force. Without one it leaves
+// out a family this host has no route for, so an IPv4-only host does not
+// count every IPv6 address as an unreachable server.
+func nameserverAddrs(ctx context.Context, server, host string, opts Options) []string {
+	var addrs []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		family := 4
+		if qtype == dns.TypeAAAA {
+			family = 6
+		}
+		if !opts.usesFamily(family) {
+			continue
+		}
+		resp, err := ttlQuery(ctx, server, host, qtype, true, opts)
+		if err != nil {
+			continue
+		}
+		for _, rr := range resp.Answer {
+			switch a := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, net.JoinHostPort(a.A.String(), "53"))
+			case *dns.AAAA:
+				addrs = append(addrs, net.JoinHostPort(a.AAAA.String(), "53"))
+			}
+		}
+	}
+	return addrs
+}
+
+// usesFamily reports whether queries under o can go to IPv4 (4) or IPv6
+// (6) servers: the family o forces, or without one a family this host has
+// a route for. A proxy connects on the host's behalf, so it takes both.
+func (o Options) usesFamily(family int) bool {
+	if o.Family != 0 {
+		return o.Family == family
+	}
+	return o.Proxy != "" || hasRoute[family]()
+}
+
+// Reaches reports whether queries under o can go to the server addr, by
+// its address family; see nameserverAddrs.
+func (o Options) Reaches(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return true
+	}
+	if ip.To4() != nil {
+		return o.usesFamily(4)
+	}
+	return o.usesFamily(6)
+}
+
+// hasRoute reports whether this host has a route to the public IPv4 or
+// IPv6 internet. Connecting a UDP socket only looks the route up; nothing
+// is sent.
+var hasRoute = map[int]func() bool{
+	4: sync.OnceValue(func() bool { return routeTo("udp4", "192.0.2.1:53") }),
+	6: sync.OnceValue(func() bool { return routeTo("udp6", "[2001:db8::1]:53") }),
+}
+
+func routeTo(network, addr string) bool {
+	c, err := net.Dial(network, addr)
+	if err != nil {
+		return false
+	}
+	c.Close()
+	return true
+}
+
+func ttlQuery(ctx context.Context, server, name string, qtype uint16, rd bool, opts Options) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.RecursionDesired = rd
+	resp, _, err := Exchange(ctx, server, m, opts)
+	return resp, err
+}
+
+// ownerTTL returns the TTL of the first answer record owned by name.
+func ownerTTL(m *dns.Msg, name string) (uint32, bool) {
+	for _, rr := range m.Answer {
+		if strings.EqualFold(rr.Header().Name, name) {
+			return rr.Header().Ttl, true
+		}
+	}
+	return 0, false
+}
+
+// TTLPolicy is what a set of comparisons reveals about how the resolver
+// rewrites TTLs. Clamp is the cap it applies to high TTLs and Floor the
+// highest TTL it served for a record published with a lower one; either is
+// 0 when not seen.
+type TTLPolicy struct {
+	Compared  int
+	Clamp     uint32
+	Floor     uint32
+	Stretched int
+	Clamped   int
+}
+
+// ClassifyTTLs looks for clamping and stretching. A lower TTL than the
+// authoritative one is normal for a cached record, so clamping is only
+// reported when no record comes back above some value and at least two
+// records with different, higher authoritative TTLs come back at exactly
+// it: aging from independent cache fills rarely lines up like that.
+func ClassifyTTLs(cs []TTLComparison) TTLPolicy {
+	var p TTLPolicy
+	var lower []TTLComparison
+	for _, c := range cs {
+		if c.Err != nil {
+			continue
+		}
+		p.Compared++
+		switch {
+		case c.Stretched():
+			p.Stretched++
+			p.Floor = max(p.Floor, c.Resolver)
+		case c.Resolver+1 < c.Auth:
+			lower = append(lower, c)
+		}
+	}
+
+	var capAt uint32
+	for _, c := range lower {
+		capAt = max(capAt, c.Resolver)
+	}
+	auths := map[uint32]bool{}
+	for _, c := range cs {
+		if c.Err != nil || c.Auth <= capAt+1 {
+			continue
+		}
+		if c.Resolver > capAt+1 {
+			return p
+		}
+		if c.Resolver+1 >= capAt {
+			auths[c.Auth] = true
+		}
+	}
+	if len(auths) >= 2 {
+		p.Clamp = capAt
+		for _, c := range lower {
+			if c.Resolver+1 >= capAt {
+				p.Clamped++
+			}
+		}
+	}
+	return p
+}
@@ -0,0 +1,192 @@
+package dnsprobe
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Variant is a look-alike of a domain. Name is what goes on the wire
+// (punycode for IDNs); Display is how a user would see it.
+type Variant struct {
+	Name    string
+	Display string
+	Kind    string
+}
+
+// Typo kinds, roughly in order of how often they are registered by squatters.
+const (
+	KindOmission      = "omission"
+	KindRepetition    = "repetition"
+	KindTransposition = "transposition"
+	KindReplacement   = "replacement"
+	KindInsertion     = "insertion"
+	KindHyphenation   = "hyphenation"
+	KindHomoglyph     = "homoglyph"
+	KindHomograph     = "homograph"
+	KindBitsquat      = "bitsquat"
+	KindTLD           = "tld"
+)
+
+var typoKinds = []string{KindOmission, KindRepetition, KindTransposition, KindReplacement, KindInsertion,
+	KindHyphenation, KindHomoglyph, KindHomograph, KindBitsquat, KindTLD}
+
+// qwertyAdjacent lists the keys next to each key on a US keyboard.
+var qwertyAdjacent = map[byte]string{
+	'1': "2q", '2': "13wq", '3': "24ew", '4': "35re", '5': "46tr", '6': "57yt", '7': "68uy", '8': "79iu", '9': "80oi", '0': "9po",
+	'q': "12wa", 'w': "qe23sa", 'e': "wr34ds", 'r': "et45fd", 't': "ry56gf", 'y': "tu67hg", 'u': "yi78jh", 'i': "uo89kj", 'o': "ip90lk", 'p': "o0l",
+	'a': "qwsz", 's': "awedxz", 'd': "serfcx", 'f': "drtgvc", 'g': "ftyhbv", 'h': "gyujnb", 'j': "huikmn", 'k': "jiolm", 'l': "kop",
+	'z': "asx", 'x': "zsdc", 'c': "xdfv", 'v': "cfgb", 'b': "vghn", 'n': "bhjm", 'm': "njk",
+}
+
+// asciiHomoglyphs are ASCII sequences that read alike in most fonts.
+var asciiHomoglyphs = map[string][]string{
+	"o": {"0"}, "0": {"o"}, "l": {"1", "i"}, "i": {"1", "l"}, "1": {"l", "i"},
+	"m": {"rn"}, "rn": {"m"}, "w": {"vv"}, "vv": {"w"}, "d": {"cl"}, "cl": {"d"},
+	"g": {"q"}, "q": {"g"}, "s": {"5"}, "b": {"6"}, "e": {"3"},
+}
+
+// cyrillicHomographs are Cyrillic letters indistinguishable from Latin ones.
+var cyrillicHomographs = map[rune]rune{
+	'a': 'а', 'c': 'с', 'e': 'е', 'o': 'о', 'p': 'р', 'x': 'х', 'y': 'у',
+	'i': 'і', 'j': 'ј', 's': 'ѕ', 'h': 'һ',
+}
+
+// SquatTLDs are the suffixes tried for the tld kind.
+var SquatTLDs = []string{"com", "net", "org", "co", "io", "info", "biz"}
+
+// TypoVariants generates typo, homoglyph and IDN homograph variants of the
+// registrable part of domain (www.example.co.uk gives example.co.uk
+// variants), deduplicated and without the domain itself.
+func TypoVariants(domain string) ([]Variant, error) {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	reg, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return nil, err
+	}
+	dot := strings.Index(reg, ".")
+	label, suffix := reg[:dot], reg[dot+1:]
+
+	seen := map[string]bool{reg: true}
+	var out []Variant
+	add := func(kind, l, sfx string) {
+		if !validLabel(l) {
+			return
+		}
+		name := l + "." + sfx
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, Variant{Name: name, Display: name, Kind: kind})
+		}
+	}
+
+	for i := range label {
+		add(KindOmission, label[:i]+label[i+1:], suffix)
+		add(KindRepetition, label[:i+1]+label[i:], suffix)
+		if i+1 < len(label) {
+			add(KindTransposition, label[:i]+string(label[i+1])+string(label[i])+label[i+2:], suffix)
+		}
+		for _, k := range qwertyAdjacent[label[i]] {
+			add(KindReplacement, label[:i]+string(k)+label[i+1:], suffix)
+		}
+	}
+	for i := range label {
+		for _, k := range qwertyAdjacent[label[i]] {
+			add(KindInsertion, label[:i]+string(k)+label[i:], suffix)
+			add(KindInsertion, label[:i+1]+string(k)+label[i+1:], suffix)
+		}
+	}
+	for i := 1; i < len(label); i++ {
+		add(KindHyphenation, label[:i]+"-"+label[i:], suffix)
+	}
+	for from, tos := range asciiHomoglyphs {
+		for i := 0; ; {
+			j := strings.Index(label[i:], from)
+			if j < 0 {
+				break
+			}
+			j += i
+			for _, to := range tos {
+				add(KindHomoglyph, label[:j]+to+label[j+len(from):], suffix)
+			}
+			i = j + 1
+		}
+	}
+	for i := range label {
+		for bit := 0; bit < 8; bit++ {
+			c := label[i] ^ 1<<bit
+			if 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' {
+				add(KindBitsquat, label[:i]+string(c)+label[i+1:], suffix)
+			}
+		}
+	}
+	for _, tld := range SquatTLDs {
+		if tld != suffix {
+			add(KindTLD, label, tld)
+		}
+	}
+
+	// IDN homographs: one letter at a time, then the whole label when every
+	// letter has a Cyrillic twin (single-script names pass registry rules).
+	runes := []rune(label)
+	whole, complete := make([]rune, len(runes)), true
+	for i, r := range runes {
+		cy, ok := cyrillicHomographs[r]
+		if !ok {
+			whole[i], complete = r, false
+			continue
+		}
+		whole[i] = cy
+		v := append(append(append([]rune{}, runes[:i]...), cy), runes[i+1:]...)
+		out = addIDN(out, seen, string(v), suffix)
+	}
+	if complete {
+		out = addIDN(out, seen, string(whole), suffix)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return slices.Index(typoKinds, out[i].Kind) < slices.Index(typoKinds, out[j].Kind)
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+func addIDN(out []Variant, seen map[string]bool, label, suffix string) []Variant {
+	ascii, err := idna.Punycode.ToASCII(label)
+	if err != nil {
+		return out
+	}
+	name := ascii + "." + suffix
+	if seen[name] {
+		return out
+	}
+	seen[name] = true
+	return append(out, Variant{Name: name, Display: label + "." + suffix, Kind: KindHomograph})
+}
+
+func validLabel(l string) bool {
+	if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' || strings.HasPrefix(l, "xn--") {
+		return false
+	}
+	for i := 0; i < len(l); i++ {
+		c := l[i]
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// String is the variant as shown to users, with the wire form for IDNs.
+func (v Variant) String() string {
+	if v.Display == v.Name {
+		return v.Name
+	}
+	return fmt.Sprintf("%s (%s)", v.Display, v.Name)
+}
@@ -0,0 +1,104 @@
+package dnsprobe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ValidationCost is a resolver's latency on names in signed zones next to
+// names in unsigned ones, measured in the same run. Validated counts the
+// signed answers that came back with AD set; without any, the resolver does
+// not validate and the difference is only the extra DNSSEC records.
+// Rounds counts the rounds begun, fewer than asked when the run ended
+// early.
+type ValidationCost struct {
+	Signed        Distribution
+	Unsigned      Distribution
+	SignedFails   int
+	UnsignedFails int
+	Validated     int
+	Rounds        int
+}
+
+// Delta is how much slower the median signed lookup was.
+func (c *ValidationCost) Delta() time.Duration {
+	return c.Signed.Quantile(0.5) - c.Unsigned.Quantile(0.5)
+}
+
+// Ratio is the signed median over the unsigned one, 0 when either is
+// missing.
+func (c *ValidationCost) Ratio() float64 {
+	u := c.Unsigned.Quantile(0.5)
+	if c.Signed.Count() == 0 || u <= 0 {
+		return 0
+	}
+	return float64(c.Signed.Quantile(0.5)) / float64(u)
+}
+
+// MeasureValidationCost asks server rounds times for a fresh random name
+// under each of signed and unsigned, alternating between the two so drift
+// over the run hits both equally. Every query sets DO, so the only
+// difference left is whether the resolver has signatures to check; random
+// names keep each lookup out of the cache, where validation happens.
+// Truncated answers are retried over TCP, the retry counting toward the
+// lookup. Answers other than NOERROR and NXDOMAIN count as failures. When
+// ctx ends it stops, leaving out the lookups cut short.
+func MeasureValidationCost(ctx context.Context, server string, signed, unsigned []string, rounds int, opts Options) *ValidationCost {
+	c := &ValidationCost{}
+	for ; c.Rounds < rounds && ctx.Err() == nil; c.Rounds++ {
+		for i := 0; i < max(len(signed), len(unsigned)) && ctx.Err() == nil; i++ {
+			if i < len(signed) {
+				rtt, ad, err := validationQuery(ctx, server, signed[i], opts)
+				switch {
+				case CutShort(ctx, err):
+				case err != nil:
+					c.SignedFails++
+				default:
+					c.Signed.Add(rtt)
+					if ad {
+						c.Validated++
+					}
+				}
+			}
+			if i < len(unsigned) {
+				rtt, _, err := validationQuery(ctx, server, unsigned[i], opts)
+				switch {
+				case CutShort(ctx, err):
+				case err != nil:
+					c.UnsignedFails++
+				default:
+					c.Unsigned.Add(rtt)
+				}
+			}
+		}
+	}
+	return c
+}
+
+func validationQuery(ctx context.Context, server, zone string, opts Options) (time.Duration, bool, error) {
+	label, err := randomLabel(16)
+	if err != nil {
+		return 0, false, err
+	}
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(label+"."+zone), dns.TypeA)
+	m.SetEdns0(1232, true)
+	resp, rtt, err := Exchange(ctx, server, m, opts)
+	if err == nil && resp.Truncated {
+		tcp := opts
+		tcp.TCP = true
+		var retry time.Duration
+		resp, retry, err = Exchange(ctx, server, m, tcp)
+		rtt += retry
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return 0, false, fmt.Errorf("%s: %s", m.Question[0].Name, dns.RcodeToString[resp.Rcode])
+	}
+	return rtt, resp.AuthenticatedData, nil
+}
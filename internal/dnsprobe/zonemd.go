@@ -0,0 +1,317 @@
+package dnsprobe
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// ErrZONEMDUnsupported is the error of a ZONEMD record whose scheme or hash
+// algorithm this tool cannot compute; RFC 8976 has verifiers ignore those.
+var ErrZONEMDUnsupported = errors.New("unsupported scheme or hash algorithm")
+
+// ZoneDigest is one ZONEMD record at a zone's apex checked against the
+// zone's contents. Err is nil when the digest verifies.
+type ZoneDigest struct {
+	Record   *dns.ZONEMD
+	Computed string
+	Err      error
+}
+
+// ZoneTransfer is a zone as one server transferred it. Host is the
+// nameserver name, empty for sources given by address.
+type ZoneTransfer struct {
+	Server  string
+	Host    string
+	Records int
+	Serial  uint32
+	Digests []ZoneDigest
+	Err     error
+}
+
+// Verified reports whether one of the zone's ZONEMD records verifies, which
+// is all RFC 8976 asks for.
+func (t ZoneTransfer) Verified() bool {
+	for _, d := range t.Digests {
+		if d.Err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Supported reports whether any ZONEMD record could be checked at all.
+func (t ZoneTransfer) Supported() bool {
+	for _, d := range t.Digests {
+		if !errors.Is(d.Err, ErrZONEMDUnsupported) {
+			return true
+		}
+	}
+	return false
+}
+
+// ZONEMDReport is a zone's ZONEMD verification on each server it was
+// transferred from.
+type ZONEMDReport struct {
+	Zone    string
+	Sources []ZoneTransfer
+}
+
+// CheckZONEMD transfers zone from each of sources, or from every address of
+// every nameserver of the zone found through resolver when sources is
+// empty, and verifies the ZONEMD records of each copy.
+func CheckZONEMD(ctx context.Context, resolver, zone string, sources []string, opts Options) (ZONEMDReport, error) {
+	rep := ZONEMDReport{Zone: strings.ToLower(dns.Fqdn(zone))}
+	if len(sources) > 0 {
+		for _, s := range sources {
+			rep.Sources = append(rep.Sources, ZoneTransfer{Server: NormalizeServer(s)})
+		}
+	} else {
+		apex, hosts, err := zoneNameservers(ctx, resolver, rep.Zone, opts)
+		if err != nil {
+			return rep, err
+		}
+		rep.Zone = apex
+		for _, h := range hosts {
+			addrs := nameserverAddrs(ctx, resolver, h, opts)
+			if len(addrs) == 0 {
+				rep.Sources = append(rep.Sources, ZoneTransfer{Host: h, Err: errNoNSAddress})
+				continue
+			}
+			for _, a := range addrs {
+				rep.Sources = append(rep.Sources, ZoneTransfer{Server: a, Host: h})
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range rep.Sources {
+		t := &rep.Sources[i]
+		if t.Err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rrs, err := Transfer(ctx, t.Server, rep.Zone, opts)
+			if err != nil {
+				t.Err = err
+				return
+			}
+			t.Records = len(rrs)
+			t.Serial, t.Digests, t.Err = VerifyZONEMD(rep.Zone, rrs)
+		}()
+	}
+	wg.Wait()
+	return rep, nil
+}
+
+// VerifyZONEMD checks each ZONEMD record at the apex of zone against the
+// zone's records, and returns the zone's SOA serial. Records of the same
+// scheme and hash algorithm appearing twice fail verification, as do those
+// for another serial than the SOA's (RFC 8976, section 4).
+func VerifyZONEMD(zone string, rrs []dns.RR) (uint32, []ZoneDigest, error) {
+	zone = strings.ToLower(dns.Fqdn(zone))
+	var soa *dns.SOA
+	var digests []ZoneDigest
+	for _, rr := range rrs {
+		if !strings.EqualFold(rr.Header().Name, zone) {
+			continue
+		}
+		switch rr := rr.(type) {
+		case *dns.SOA:
+			soa = rr
+		case *dns.ZONEMD:
+			digests = append(digests, ZoneDigest{Record: rr})
+		}
+	}
+	if soa == nil {
+		return 0, nil, fmt.Errorf("no SOA at %s", zone)
+	}
+
+	seen := map[[2]uint8]int{}
+	for _, d := range digests {
+		seen[[2]uint8{d.Record.Scheme, d.Record.Hash}]++
+	}
+	for i := range digests {
+		d := &digests[i]
+		r := d.Record
+		if seen[[2]uint8{r.Scheme, r.Hash}] > 1 {
+			d.Err = fmt.Errorf("more than one ZONEMD with scheme %d and hash algorithm %d", r.Scheme, r.Hash)
+			continue
+		}
+		if r.Serial != soa.Serial {
+			d.Err = fmt.Errorf("serial %d does not match the SOA serial %d", r.Serial, soa.Serial)
+			continue
+		}
+		sum, err := ZONEMDDigest(zone, rrs, r.Scheme, r.Hash)
+		if err != nil {
+			d.Err = err
+			continue
+		}
+		d.Computed = sum
+		if !strings.EqualFold(sum, r.Digest) {
+			d.Err = fmt.Errorf("digest mismatch: the zone's contents differ from those it was digested with")
+		}
+	}
+	return soa.Serial, digests, nil
+}
+
+// ZONEMDDigest computes the hex digest of zone's records with the given
+// ZONEMD scheme and hash algorithm. Only the SIMPLE scheme is supported,
+// with SHA-384 and SHA-512.
+func ZONEMDDigest(zone string, rrs []dns.RR, scheme, alg uint8) (string, error) {
+	var h hash.Hash
+	switch {
+	case scheme != dns.ZoneMDSchemeSimple:
+		return "", ErrZONEMDUnsupported
+	case alg == dns.ZoneMDHashAlgSHA384:
+		h = sha512.New384()
+	case alg == dns.ZoneMDHashAlgSHA512:
+		h = sha512.New()
+	default:
+		return "", ErrZONEMDUnsupported
+	}
+
+	zone = strings.ToLower(dns.Fqdn(zone))
+	type wireRR struct {
+		owner [][]byte
+		rtype uint16
+		rdata []byte
+		wire  []byte
+	}
+	var all []wireRR
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		if !dns.IsSubDomain(zone, strings.ToLower(hdr.Name)) {
+			continue
+		}
+		if strings.EqualFold(hdr.Name, zone) {
+			if hdr.Rrtype == dns.TypeZONEMD {
+				continue
+			}
+			if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == dns.TypeZONEMD {
+				continue
+			}
+		}
+		rr = canonicalRR(rr)
+		buf := make([]byte, dns.Len(rr))
+		n, err := dns.PackRR(rr, buf, 0, nil, false)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", rr.Header().Name, err)
+		}
+		buf = buf[:n]
+		owner, end := wireLabels(buf)
+		all = append(all, wireRR{owner: owner, rtype: rr.Header().Rrtype, rdata: buf[end+10:], wire: buf})
+	}
+
+	slices.SortFunc(all, func(a, b wireRR) int {
+		if c := compareOwners(a.owner, b.owner); c != 0 {
+			return c
+		}
+		if a.rtype != b.rtype {
+			return int(a.rtype) - int(b.rtype)
+		}
+		return bytes.Compare(a.rdata, b.rdata)
+	})
+	for i, r := range all {
+		if i > 0 && bytes.Equal(r.wire, all[i-1].wire) {
+			continue
+		}
+		h.Write(r.wire)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// canonicalRR returns a copy of rr with its owner and the domain names in
+// its RDATA lowercased, for the types RFC 4034 section 6.2 lists as amended
+// by RFC 6840.
+func canonicalRR(rr dns.RR) dns.RR {
+	rr = dns.Copy(rr)
+	h := rr.Header()
+	h.Name = strings.ToLower(h.Name)
+	switch rr := rr.(type) {
+	case *dns.NS:
+		rr.Ns = strings.ToLower(rr.Ns)
+	case *dns.MD:
+		rr.Md = strings.ToLower(rr.Md)
+	case *dns.MF:
+		rr.Mf = strings.ToLower(rr.Mf)
+	case *dns.CNAME:
+		rr.Target = strings.ToLower(rr.Target)
+	case *dns.SOA:
+		rr.Ns, rr.Mbox = strings.ToLower(rr.Ns), strings.ToLower(rr.Mbox)
+	case *dns.MB:
+		rr.Mb = strings.ToLower(rr.Mb)
+	case *dns.MG:
+		rr.Mg = strings.ToLower(rr.Mg)
+	case *dns.MR:
+		rr.Mr = strings.ToLower(rr.Mr)
+	case *dns.PTR:
+		rr.Ptr = strings.ToLower(rr.Ptr)
+	case *dns.MINFO:
+		rr.Rmail, rr.Email = strings.ToLower(rr.Rmail), strings.ToLower(rr.Email)
+	case *dns.MX:
+		rr.Mx = strings.ToLower(rr.Mx)
+	case *dns.RP:
+		rr.Mbox, rr.Txt = strings.ToLower(rr.Mbox), strings.ToLower(rr.Txt)
+	case *dns.AFSDB:
+		rr.Hostname = strings.ToLower(rr.Hostname)
+	case *dns.RT:
+		rr.Host = strings.ToLower(rr.Host)
+	case *dns.SIG:
+		rr.SignerName = strings.ToLower(rr.SignerName)
+	case *dns.RRSIG:
+		rr.SignerName = strings.ToLower(rr.SignerName)
+	case *dns.PX:
+		rr.Map822, rr.Mapx400 = strings.ToLower(rr.Map822), strings.ToLower(rr.Mapx400)
+	case *dns.NAPTR:
+		rr.Replacement = strings.ToLower(rr.Replacement)
+	case *dns.KX:
+		rr.Exchanger = strings.ToLower(rr.Exchanger)
+	case *dns.SRV:
+		rr.Target = strings.ToLower(rr.Target)
+	case *dns.DNAME:
+		rr.Target = strings.ToLower(rr.Target)
+	}
+	return rr
+}
+
+// wireLabels splits the uncompressed name at the start of msg into its
+// labels and returns them with the offset just past the name.
+func wireLabels(msg []byte) ([][]byte, int) {
+	var labels [][]byte
+	off := 0
+	for off < len(msg) && msg[off] != 0 {
+		n := int(msg[off])
+		labels = append(labels, msg[off+1:off+1+n])
+		off += 1 + n
+	}
+	return labels, off + 1
+}
+
+// compareOwners orders names canonically (RFC 4034, section 6.1): label by
+// label from the root, an absent label sorting first.
+func compareOwners(a, b [][]byte) int {
+	for i, j := len(a)-1, len(b)-1; i >= 0 || j >= 0; i, j = i-1, j-1 {
+		switch {
+		case i < 0:
+			return -1
+		case j < 0:
+			return 1
+		}
+		if c := bytes.Compare(a[i], b[j]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
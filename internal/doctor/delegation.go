@@ -0,0 +1,173 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"dnsdoc/internal/dnsprobe"
+)
+
+// Delegation audits zone's delegation as resolver finds it: the parent's
+// referrals, parent and child NS set agreement, glue for in-zone nameservers
+// and whether every nameserver address answers authoritatively. Codes are
+// "delegation.<check>.<reason>" and Took is the latency of the query behind
+// each finding.
+func Delegation(ctx context.Context, resolver, zone string, opts dnsprobe.Options) ([]Finding, error) {
+	d, err := dnsprobe.AuditDelegation(ctx, resolver, zone, opts)
+	if err != nil {
+		return nil, err
+	}
+	var out []Finding
+	add := func(check, code string, f Finding) {
+		f.Check = check
+		if f.Code != "" {
+			f.Code = "delegation." + code + "." + f.Code
+		}
+		out = append(out, f)
+	}
+
+	for _, r := range d.Referrals {
+		check := "referral from " + r.Host
+		if r.Err != nil {
+			add(check, "referral", Finding{Status: Fail, Code: "failed", Detail: r.Err.Error(), Took: r.RTT,
+				Hint:        fmt.Sprintf("a nameserver of the parent zone %s does not refer to %s; if it persists, raise it with the registry or parent operator", d.Parent, d.Zone),
+				Remediation: &dnsprobe.Remediation{Action: "recheck-connectivity", Records: []string{r.Host}}})
+			continue
+		}
+		f := Finding{Status: Pass, Detail: fmt.Sprintf("%s: %s", r.Addr, strings.Join(r.NS, " ")), Took: r.RTT}
+		if p := d.ParentNS(); !slices.Equal(r.NS, p) {
+			f.Status, f.Code = Warn, "inconsistent"
+			f.Hint = "the parent's nameservers disagree on the NS set; the registry update may not have reached all of them yet"
+			f.Remediation = &dnsprobe.Remediation{Action: "rerun-check", Records: recordsNS(d.Zone, p)}
+		}
+		add(check, "referral", f)
+	}
+
+	parent, child := d.ParentNS(), d.ChildNS()
+	switch {
+	case len(parent) == 0:
+		// Every referral failed, which is reported above.
+	case len(child) == 0:
+		add("ns set", "nsset", Finding{Status: Fail, Code: "no_child_ns", Detail: "no nameserver returned an authoritative NS set",
+			Hint:        "none of the delegated nameservers serve the zone; see the nameserver findings",
+			Remediation: &dnsprobe.Remediation{Action: "fix-lame-delegation", Records: recordsNS(d.Zone, parent)}})
+	case !slices.Equal(parent, child):
+		f := Finding{Status: Warn, Code: "mismatch", Detail: nsSetDiff(parent, child),
+			Hint:        "the NS set at the parent (delegation) and in the zone itself should be identical; update whichever is stale",
+			Remediation: &dnsprobe.Remediation{Action: "sync-ns-set", Records: recordsNS(d.Zone, child)}}
+		if !overlaps(parent, child) {
+			f.Status, f.Code = Fail, "disjoint"
+			f.Hint = "the parent delegates to nameservers the zone does not list at all; the zone was probably moved without updating the registrar"
+		}
+		add("ns set", "nsset", f)
+	default:
+		add("ns set", "nsset", Finding{Status: Pass, Detail: fmt.Sprintf("parent and child agree: %s", strings.Join(parent, " "))})
+	}
+
+	for _, h := range parent {
+		glue, resolved := d.Glue(h), d.Resolved[h]
+		// Resolved holds only the families opts reaches; compare like with
+		// like.
+		usable := slices.DeleteFunc(slices.Clone(glue), func(a string) bool { return !opts.Reaches(a) })
+		check := "glue " + h
+		switch {
+		case !d.InBailiwick(h):
+			continue
+		case len(glue) == 0:
+			add(check, "glue", Finding{Status: Fail, Code: "missing", Detail: h + " is inside the zone but the parent gives no address for it",
+				Hint:        "in-zone nameservers need glue at the parent, or resolvers cannot reach them; add the address records at the registrar",
+				Remediation: &dnsprobe.Remediation{Action: "add-glue", Records: []string{h}}})
+		case len(usable) > 0 && len(resolved) > 0 && !slices.Equal(usable, sortedAddrs(resolved)):
+			add(check, "glue", Finding{Status: Fail, Code: "incorrect", Detail: fmt.Sprintf("glue %s, zone says %s", strings.Join(usable, " "), strings.Join(sortedAddrs(resolved), " ")),
+				Hint:        "the glue at the parent no longer matches the nameserver's address in the zone; update it at the registrar",
+				Remediation: &dnsprobe.Remediation{Action: "update-glue", Records: []string{h}}})
+		default:
+			add(check, "glue", Finding{Status: Pass, Detail: strings.Join(glue, " ")})
+		}
+	}
+
+	for _, s := range d.Servers {
+		check := "nameserver " + s.Host
+		if s.Addr != "" {
+			check += " " + s.Addr
+		}
+		switch {
+		case s.Err != nil:
+			add(check, "server", Finding{Status: Fail, Code: "unreachable", Detail: s.Err.Error(), Took: s.RTT,
+				Hint:        "the nameserver does not answer; check that it is up and that port 53 is open to it over UDP and TCP",
+				Remediation: &dnsprobe.Remediation{Action: "allow-dns-port", Records: []string{s.Host}, Suggested: map[string]string{"port": "53/udp 53/tcp"}}})
+		case !s.Authoritative:
+			add(check, "server", Finding{Status: Fail, Code: "lame", Detail: fmt.Sprintf("answered %s without authority for %s", s.RCode, d.Zone), Took: s.RTT,
+				Hint:        "a lame delegation: the server is listed for the zone but does not serve it; load the zone there or remove it from the NS set",
+				Remediation: &dnsprobe.Remediation{Action: "fix-lame-delegation", Records: []string{s.Host}}})
+		default:
+			add(check, "server", Finding{Status: Pass, Detail: fmt.Sprintf("authoritative, serial %d%s", s.Serial, addrSource(s)), Took: s.RTT})
+		}
+	}
+	return out, nil
+}
+
+// Verdict sums up findings in one word: broken when any failed, degraded
+// when any warned, healthy otherwise.
+func Verdict(findings []Finding) string {
+	v := "healthy"
+	for _, f := range findings {
+		switch f.Status {
+		case Fail:
+			return "broken"
+		case Warn:
+			v = "degraded"
+		}
+	}
+	return v
+}
+
+// addrSource flags addresses only the parent's glue knows about, which
+// resolvers stop using once they learn the zone's own records.
+func addrSource(s dnsprobe.NSProbe) string {
+	if s.FromGlue && !s.FromDNS {
+		return ", address from glue only"
+	}
+	return ""
+}
+
+func nsSetDiff(parent, child []string) string {
+	var parts []string
+	if only := setMinus(parent, child); len(only) > 0 {
+		parts = append(parts, "only at parent: "+strings.Join(only, " "))
+	}
+	if only := setMinus(child, parent); len(only) > 0 {
+		parts = append(parts, "only in zone: "+strings.Join(only, " "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func setMinus(a, b []string) []string {
+	var out []string
+	for _, s := range a {
+		if !slices.Contains(b, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func overlaps(a, b []string) bool {
+	return len(setMinus(a, b)) < len(a)
+}
+
+func sortedAddrs(addrs []string) []string {
+	addrs = slices.Clone(addrs)
+	slices.Sort(addrs)
+	return slices.Compact(addrs)
+}
+
+func recordsNS(zone string, hosts []string) []string {
+	var out []string
+	for _, h := range hosts {
+		out = append(out, zone+" NS "+h)
+	}
+	return out
+}
@@ -0,0 +1,578 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+)
+
+type Status int
+
+const (
+	Pass Status = iota
+	Warn
+	Fail
+)
+
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Status) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "pass":
+		*s = Pass
+	case "warn":
+		*s = Warn
+	case "fail":
+		*s = Fail
+	default:
+		return fmt.Errorf("unknown status %q", b)
+	}
+	return nil
+}
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "pass"
+	case Warn:
+		return "warn"
+	default:
+		return "fail"
+	}
+}
+
+// Finding is the outcome of one check. Hint says what to do about a warning
+// or failure and is empty on pass; Code ("<run>.<check>.<reason>") and
+// Remediation carry the same advice for machines. Took is how long the check
+// ran.
+type Finding struct {
+	Check       string                `json:"check"`
+	Status      Status                `json:"status"`
+	Code        string                `json:"code,omitempty"`
+	Detail      string                `json:"detail"`
+	Hint        string                `json:"hint,omitempty"`
+	Remediation *dnsprobe.Remediation `json:"remediation,omitempty"`
+	Took        time.Duration         `json:"took_ns"`
+}
+
+type Config struct {
+	Server  string
+	Options dnsprobe.Options
+	// Domain is an ordinary name expected to resolve.
+	Domain string
+	// Signed is a DNSSEC-signed zone a validating resolver marks AD.
+	Signed string
+	// Bogus is a zone with deliberately broken signatures that a validating
+	// resolver must refuse with SERVFAIL.
+	Bogus string
+	// Large is a name/type whose answer exceeds 1232 bytes with DNSSEC
+	// records, to exercise truncation and TCP fallback.
+	Large     string
+	LargeType uint16
+	// HTTPSName publishes an HTTPS record (RFC 9460), which resolvers and
+	// firewalls that predate the type drop or refuse.
+	HTTPSName string
+	// Cache, when set, dedupes identical queries across checks.
+	Cache *dnsprobe.QueryCache
+	// SigWindow is how close to expiry the signatures of Signed's DNSKEY,
+	// SOA and NS sets may get before they warrant a warning.
+	SigWindow time.Duration
+	// Registration looks Domain up in RDAP for expiry, transfer lock and
+	// parking, except in lite builds, which leave the check out; ExpiryWarn
+	// is how close to expiry warrants a warning.
+	Registration bool
+	ExpiryWarn   time.Duration
+}
+
+var DefaultConfig = Config{
+	Domain:    "example.com",
+	Signed:    "ietf.org",
+	Bogus:     "dnssec-failed.org",
+	Large:     "ietf.org",
+	LargeType: dns.TypeDNSKEY,
+	HTTPSName: "cloudflare.com",
+	SigWindow: 7 * 24 * time.Hour,
+
+	Registration: true,
+	ExpiryWarn:   30 * 24 * time.Hour,
+}
+
+// ParkedNameservers are name server domains used by parking and domain-sale
+// services; a domain delegated to them usually lapsed or was never set up.
+var ParkedNameservers = []string{
+	"above.com",
+	"afternic.com",
+	"bodis.com",
+	"dan.com",
+	"dsredirection.com",
+	"parkingcrew.net",
+	"parklogic.com",
+	"sedoparking.com",
+}
+
+// check is one node of the doctor's dependency graph: it starts once every
+// check named in after has finished, and is skipped if any of them failed or
+// was skipped itself. after may only name checks listed before it, which
+// keeps the graph acyclic. Checks set Finding.Code to the reason alone; Run
+// prefixes it with "doctor." and the check's code.
+type check struct {
+	name  string
+	code  string
+	after []string
+	run   func(ctx context.Context, cfg Config) Finding
+}
+
+var checks = []check{
+	{"udp reachability", "udp", nil, checkUDP},
+	{"tcp reachability", "tcp", nil, checkTCP},
+	{"edns", "edns", []string{"udp reachability"}, checkEDNS},
+	{"dnssec validation", "dnssec", []string{"edns"}, checkDNSSEC},
+	{"signature expiry", "rrsig", []string{"edns"}, checkSignatures},
+	{"large responses", "large", []string{"edns", "tcp reachability"}, checkLarge},
+	{"negative caching", "negcache", []string{"udp reachability"}, checkNegativeCache},
+	{"nxdomain hijacking", "nxdomain", []string{"udp reachability"}, checkHijack},
+	{"dns64 synthesis", "dns64", []string{"udp reachability"}, checkDNS64},
+	{"https records", "https", []string{"udp reachability"}, checkHTTPS},
+}
+
+// wants reports whether c runs under cfg; only the registration check, which
+// needs HTTPS access to RDAP, can be turned off. Nothing may depend on a
+// check that can be turned off.
+func (cfg Config) wants(c check) bool {
+	return c.code != "registration" || cfg.Registration
+}
+
+// Run executes the checks against cfg.Server, each as soon as the checks it
+// depends on are done, and calls onFinding, when set, as each one completes.
+// The findings are returned in check order. Checks that ctx ends before they
+// finish are skipped rather than failed.
+func Run(ctx context.Context, cfg Config, onFinding func(Finding)) []Finding {
+	active := slices.DeleteFunc(slices.Clone(checks), func(c check) bool { return !cfg.wants(c) })
+	out := make([]Finding, len(active))
+	broken := make([]bool, len(active))
+	done := make(map[string]chan struct{}, len(active))
+	index := make(map[string]int, len(active))
+	for i, c := range active {
+		done[c.name] = make(chan struct{})
+		index[c.name] = i
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, c := range active {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[c.name])
+
+			var failed []string
+			for _, dep := range c.after {
+				<-done[dep]
+				if broken[index[dep]] {
+					failed = append(failed, dep)
+				}
+			}
+
+			start := time.Now()
+			var f Finding
+			switch {
+			case len(failed) > 0:
+				f = Finding{Status: Warn, Code: "skipped", Detail: "skipped: " + strings.Join(failed, ", ") + " did not pass",
+					Hint: "fix the checks it depends on first", Remediation: &dnsprobe.Remediation{Action: "fix-prerequisite-checks", Suggested: map[string]string{"checks": strings.Join(failed, ",")}}}
+				broken[i] = true
+			case ctx.Err() != nil:
+				f = ended(ctx)
+				broken[i] = true
+			default:
+				f = c.run(ctx, cfg)
+				if f.Status != Pass && ctx.Err() != nil {
+					f = ended(ctx)
+				}
+				broken[i] = f.Status == Fail
+			}
+			f.Check = c.name
+			if f.Code != "" {
+				f.Code = "doctor." + c.code + "." + f.Code
+			}
+			f.Took = time.Since(start)
+			out[i] = f
+
+			if onFinding != nil {
+				mu.Lock()
+				onFinding(f)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// ended is the finding of a check that ctx ended before it could finish,
+// which says nothing about the server.
+func ended(ctx context.Context) Finding {
+	why := "the run was interrupted"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		why = "the run's deadline was reached"
+	}
+	return Finding{Status: Warn, Code: "skipped", Detail: "skipped: " + why, Hint: "run doctor again, with more time if a deadline cut it short",
+		Remediation: &dnsprobe.Remediation{Action: "rerun-check"}}
+}
+
+func query(name string, qtype uint16, do bool) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.RecursionDesired = true
+	if do {
+		m.SetEdns0(1232, true)
+	}
+	return m
+}
+
+// exchange sends m over UDP or TCP as the check requires, whatever the global
+// transport flags say, answering repeats from cfg.Cache.
+func exchange(ctx context.Context, cfg Config, m *dns.Msg, tcp bool) (*dns.Msg, time.Duration, error) {
+	opts := cfg.Options
+	opts.TCP = tcp
+	return cfg.Cache.Exchange(ctx, cfg.Server, m, opts)
+}
+
+// exchangeFresh always goes to the server, for checks that repeat a query on
+// purpose.
+func exchangeFresh(ctx context.Context, cfg Config, m *dns.Msg) (*dns.Msg, time.Duration, error) {
+	cfg.Cache = nil
+	return exchange(ctx, cfg, m, false)
+}
+
+func checkUDP(ctx context.Context, cfg Config) Finding {
+	if cfg.Options.Proxy != "" {
+		return Finding{Status: Warn, Code: "skipped_proxy", Detail: "skipped: --proxy only carries TCP", Hint: "run without --proxy to test UDP",
+			Remediation: &dnsprobe.Remediation{Action: "rerun-without-proxy"}}
+	}
+	r, rtt, err := exchange(ctx, cfg, query(cfg.Domain, dns.TypeA, false), false)
+	if err != nil {
+		return Finding{Status: Fail, Code: "unreachable", Detail: err.Error(), Hint: "the resolver does not answer over UDP: check the address and any firewall between here and port 53",
+			Remediation: &dnsprobe.Remediation{Action: "allow-dns-port", Suggested: map[string]string{"port": "53/udp"}}}
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return Finding{Status: Fail, Code: "lookup_failed", Detail: fmt.Sprintf("%s %s in %s", cfg.Domain, dns.RcodeToString[r.Rcode], rtt),
+			Hint:        "the resolver answers but cannot resolve an ordinary name: check its upstream connectivity and ACLs (REFUSED usually means this client is not allowed)",
+			Remediation: &dnsprobe.Remediation{Action: "check-upstream-and-acl", Records: []string{record(cfg.Domain, dns.TypeA)}}}
+	}
+	return Finding{Status: Pass, Detail: fmt.Sprintf("%s answered in %s", cfg.Domain, rtt)}
+}
+
+func checkTCP(ctx context.Context, cfg Config) Finding {
+	r, rtt, err := exchange(ctx, cfg, query(cfg.Domain, dns.TypeA, false), true)
+	if err != nil {
+		return Finding{Status: Fail, Code: "unreachable", Detail: err.Error(), Hint: "TCP is mandatory (RFC 7766); without it truncated and large DNSSEC answers fail. Allow TCP port 53 to the resolver",
+			Remediation: &dnsprobe.Remediation{Action: "allow-dns-port", Suggested: map[string]string{"port": "53/tcp"}}}
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return Finding{Status: Warn, Code: "lookup_failed", Detail: fmt.Sprintf("%s over TCP in %s", dns.RcodeToString[r.Rcode], rtt), Hint: "the resolver accepts TCP but does not answer normally over it",
+			Remediation: &dnsprobe.Remediation{Action: "check-tcp-service", Records: []string{record(cfg.Domain, dns.TypeA)}}}
+	}
+	return Finding{Status: Pass, Detail: fmt.Sprintf("answered in %s", rtt)}
+}
+
+func checkEDNS(ctx context.Context, cfg Config) Finding {
+	r, _, err := exchange(ctx, cfg, query(cfg.Domain, dns.TypeA, true), false)
+	if err != nil {
+		return Finding{Status: Fail, Code: "dropped", Detail: err.Error(), Hint: "queries with EDNS are dropped: a firewall or DNS ALG may be filtering OPT records; disable DNS inspection",
+			Remediation: &dnsprobe.Remediation{Action: "disable-dns-inspection"}}
+	}
+	o := r.IsEdns0()
+	switch {
+	case r.Rcode == dns.RcodeFormatError:
+		return Finding{Status: Fail, Code: "unsupported", Detail: "FORMERR on an EDNS query", Hint: "the resolver does not support EDNS (RFC 6891); upgrade it, as DNSSEC and large answers depend on it",
+			Remediation: &dnsprobe.Remediation{Action: "upgrade-resolver"}}
+	case o == nil:
+		return Finding{Status: Fail, Code: "stripped", Detail: "no OPT record in the response", Hint: "EDNS is stripped on the path or unsupported; check for middleboxes rewriting DNS",
+			Remediation: &dnsprobe.Remediation{Action: "disable-dns-inspection"}}
+	case o.UDPSize() < 1232:
+		return Finding{Status: Warn, Code: "small_buffer", Detail: fmt.Sprintf("advertised UDP size %d", o.UDPSize()), Hint: "a buffer below 1232 bytes forces extra TCP fallbacks; raise edns-buffer-size to 1232",
+			Remediation: &dnsprobe.Remediation{Action: "set-edns-buffer-size", Suggested: map[string]string{"edns-buffer-size": "1232"}}}
+	}
+	return Finding{Status: Pass, Detail: fmt.Sprintf("EDNS version %d, UDP size %d", o.Version(), o.UDPSize())}
+}
+
+func checkDNSSEC(ctx context.Context, cfg Config) Finding {
+	r, _, err := exchange(ctx, cfg, query(cfg.Signed, dns.TypeA, true), false)
+	if err != nil {
+		return Finding{Status: Fail, Code: "query_failed", Detail: err.Error(), Hint: "DNSSEC-OK queries fail; see the edns and large responses checks",
+			Remediation: &dnsprobe.Remediation{Action: "fix-edns", Records: []string{record(cfg.Signed, dns.TypeA)}}}
+	}
+	if !r.AuthenticatedData {
+		return Finding{Status: Warn, Code: "not_validating", Detail: fmt.Sprintf("%s answered without the AD bit", cfg.Signed),
+			Hint:        "the resolver does not validate DNSSEC (or strips AD); enable validation or use a validating resolver",
+			Remediation: &dnsprobe.Remediation{Action: "enable-dnssec-validation", Records: []string{record(cfg.Signed, dns.TypeA)}}}
+	}
+
+	b, _, err := exchange(ctx, cfg, query(cfg.Bogus, dns.TypeA, true), false)
+	if err != nil {
+		return Finding{Status: Warn, Code: "bogus_query_failed", Detail: fmt.Sprintf("validates %s, but %s query failed: %v", cfg.Signed, cfg.Bogus, err),
+			Hint:        "whether the resolver rejects bogus signatures is unknown; check that it answers reliably and run the check again",
+			Remediation: &dnsprobe.Remediation{Action: "recheck-connectivity", Records: []string{record(cfg.Bogus, dns.TypeA)}}}
+	}
+	if b.Rcode != dns.RcodeServerFailure {
+		return Finding{Status: Fail, Code: "bogus_accepted", Detail: fmt.Sprintf("%s (bogus signatures) returned %s", cfg.Bogus, dns.RcodeToString[b.Rcode]),
+			Hint: "the resolver sets AD but accepts bogus data: validation is misconfigured or something between you and it forges AD",
+			Remediation: &dnsprobe.Remediation{Action: "fix-dnssec-validation", Records: []string{record(cfg.Bogus, dns.TypeA)},
+				Suggested: map[string]string{"rcode": "SERVFAIL"}}}
+	}
+	return Finding{Status: Pass, Detail: fmt.Sprintf("%s validated (AD), %s rejected (SERVFAIL)", cfg.Signed, cfg.Bogus)}
+}
+
+func checkSignatures(ctx context.Context, cfg Config) Finding {
+	sigs, err := dnsprobe.ZoneSignatures(ctx, cfg.Server, cfg.Signed, dnsprobe.DefaultSignedTypes, cfg.Options)
+	if err != nil {
+		return Finding{Status: Warn, Code: "query_failed", Detail: err.Error(), Hint: "signatures could not be read; see the dnssec validation check",
+			Remediation: &dnsprobe.Remediation{Action: "recheck-connectivity", Records: []string{record(cfg.Signed, dns.TypeRRSIG)}}}
+	}
+	now := time.Now()
+	first := sigs[0]
+	detail := fmt.Sprintf("%d signature(s); first to expire: %s (key %d) in %s", len(sigs), first.Type, first.KeyTag, first.ExpiresIn(now).Round(time.Minute))
+	switch SignatureStatus(first, now, cfg.SigWindow) {
+	case Fail:
+		detail = fmt.Sprintf("%s RRSIG (key %d) expired %s ago", first.Type, first.KeyTag, (-first.ExpiresIn(now)).Round(time.Minute))
+		if first.Inception.After(now) {
+			detail = fmt.Sprintf("%s RRSIG (key %d) is not valid until %s", first.Type, first.KeyTag, first.Inception.UTC().Format(time.RFC3339))
+		}
+		return Finding{Status: Fail, Code: "expired", Detail: detail,
+			Hint:        fmt.Sprintf("%s fails validation for every validating resolver; re-sign the zone and check why automatic re-signing stopped", cfg.Signed),
+			Remediation: &dnsprobe.Remediation{Action: "resign-zone", Records: []string{record(cfg.Signed, dns.StringToType[first.Type])}}}
+	case Warn:
+		return Finding{Status: Warn, Code: "expiring", Detail: detail,
+			Hint:        fmt.Sprintf("%s goes dark for validating resolvers when this signature lapses; make sure the signer refreshes it before then", cfg.Signed),
+			Remediation: &dnsprobe.Remediation{Action: "resign-zone", Records: []string{record(cfg.Signed, dns.StringToType[first.Type])}}}
+	}
+	return Finding{Status: Pass, Detail: detail}
+}
+
+// SignatureStatus rates a signature: failed once expired (or not yet
+// valid), a warning within window of expiry, passed otherwise.
+func SignatureStatus(s dnsprobe.Signature, now time.Time, window time.Duration) Status {
+	switch {
+	case s.ExpiresIn(now) <= 0 || s.Inception.After(now):
+		return Fail
+	case s.ExpiresIn(now) < window:
+		return Warn
+	}
+	return Pass
+}
+
+func checkLarge(ctx context.Context, cfg Config) Finding {
+	name := fmt.Sprintf("%s %s", cfg.Large, dns.TypeToString[cfg.LargeType])
+	m := query(cfg.Large, cfg.LargeType, true)
+	r, rtt, err := exchange(ctx, cfg, m, false)
+	if err != nil {
+		return Finding{Status: Fail, Code: "udp_lost", Detail: fmt.Sprintf("%s over UDP: %v", name, err),
+			Hint: "large UDP answers are lost, usually IP fragments dropped on the path; set the resolver's EDNS buffer to 1232 so it truncates instead",
+			Remediation: &dnsprobe.Remediation{Action: "set-edns-buffer-size", Records: []string{record(cfg.Large, cfg.LargeType)},
+				Suggested: map[string]string{"edns-buffer-size": "1232"}}}
+	}
+	size := r.Len()
+	if !r.Truncated {
+		return Finding{Status: Pass, Detail: fmt.Sprintf("%s: %d bytes over UDP in %s", name, size, rtt)}
+	}
+	t, rtt, err := exchange(ctx, cfg, m, true)
+	if err != nil {
+		return Finding{Status: Fail, Code: "tcp_fallback_failed", Detail: fmt.Sprintf("%s truncated over UDP and TCP retry failed: %v", name, err),
+			Hint: "clients cannot fetch large answers: allow TCP port 53 to the resolver",
+			Remediation: &dnsprobe.Remediation{Action: "allow-dns-port", Records: []string{record(cfg.Large, cfg.LargeType)},
+				Suggested: map[string]string{"port": "53/tcp"}}}
+	}
+	return Finding{Status: Pass, Detail: fmt.Sprintf("%s: truncated over UDP, %d bytes over TCP in %s", name, t.Len(), rtt)}
+}
+
+func checkNegativeCache(ctx context.Context, cfg Config) Finding {
+	label, err := randomName()
+	if err != nil {
+		return Finding{Status: Warn, Code: "error", Detail: err.Error(), Hint: "no random name could be drawn, so the check did not run; run it again",
+			Remediation: &dnsprobe.Remediation{Action: "rerun-check"}}
+	}
+	name := label + "." + cfg.Domain
+	first, rtt1, err := exchange(ctx, cfg, query(name, dns.TypeA, false), false)
+	if err != nil {
+		return Finding{Status: Warn, Code: "query_failed", Detail: err.Error(), Hint: "negative caching could not be tested; check that the resolver answers reliably",
+			Remediation: &dnsprobe.Remediation{Action: "recheck-connectivity", Records: []string{record(name, dns.TypeA)}}}
+	}
+	if first.Rcode != dns.RcodeNameError {
+		return Finding{Status: Warn, Code: "no_nxdomain", Detail: fmt.Sprintf("nonexistent %s returned %s", name, dns.RcodeToString[first.Rcode]), Hint: "see the nxdomain hijacking check",
+			Remediation: &dnsprobe.Remediation{Action: "disable-nxdomain-redirection", Records: []string{record(name, dns.TypeA)},
+				Suggested: map[string]string{"rcode": "NXDOMAIN"}}}
+	}
+	soa1 := negativeSOA(first)
+	if soa1 == nil {
+		return Finding{Status: Warn, Code: "missing_soa", Detail: "NXDOMAIN without an SOA in the authority section",
+			Hint:        "without the SOA, downstream caches cannot cache the negative answer (RFC 2308)",
+			Remediation: &dnsprobe.Remediation{Action: "include-negative-soa", Records: []string{record(name, dns.TypeA)}}}
+	}
+
+	second, rtt2, err := exchangeFresh(ctx, cfg, query(name, dns.TypeA, false))
+	if err != nil {
+		return Finding{Status: Warn, Code: "query_failed", Detail: err.Error(), Hint: "the repeat query failed, so negative caching could not be tested; check that the resolver answers reliably",
+			Remediation: &dnsprobe.Remediation{Action: "recheck-connectivity", Records: []string{record(name, dns.TypeA)}}}
+	}
+	soa2 := negativeSOA(second)
+	cached := rtt2 < rtt1/2 || (soa2 != nil && soa2.Hdr.Ttl < soa1.Hdr.Ttl)
+	detail := fmt.Sprintf("repeat NXDOMAIN %s vs %s first, SOA TTL %d", rtt2, rtt1, soa1.Hdr.Ttl)
+	if !cached {
+		return Finding{Status: Warn, Code: "not_cached", Detail: detail,
+			Hint:        "the repeat was not noticeably faster and the SOA TTL did not count down: negative answers may not be cached (check the resolver's negative-cache TTL)",
+			Remediation: &dnsprobe.Remediation{Action: "enable-negative-caching", Records: []string{record(name, dns.TypeA)}}}
+	}
+	return Finding{Status: Pass, Detail: detail}
+}
+
+func checkHijack(ctx context.Context, cfg Config) Finding {
+	label, err := randomName()
+	if err != nil {
+		return Finding{Status: Warn, Code: "error", Detail: err.Error(), Hint: "no random name could be drawn, so the check did not run; run it again",
+			Remediation: &dnsprobe.Remediation{Action: "rerun-check"}}
+	}
+	name := label + ".com"
+	r, _, err := exchange(ctx, cfg, query(name, dns.TypeA, false), false)
+	if err != nil {
+		return Finding{Status: Warn, Code: "query_failed", Detail: err.Error(), Hint: "NXDOMAIN rewriting could not be tested; check that the resolver answers reliably",
+			Remediation: &dnsprobe.Remediation{Action: "recheck-connectivity", Records: []string{record(name, dns.TypeA)}}}
+	}
+	if r.Rcode == dns.RcodeNameError {
+		return Finding{Status: Pass, Detail: "nonexistent names return NXDOMAIN"}
+	}
+	var addrs []string
+	for _, rr := range r.Answer {
+		if a, ok := rr.(*dns.A); ok {
+			addrs = append(addrs, a.A.String())
+		}
+	}
+	if len(addrs) > 0 {
+		return Finding{Status: Fail, Code: "rewritten", Detail: fmt.Sprintf("nonexistent %s resolved to %v", name, addrs),
+			Hint: "the resolver (or an ISP box in the path) rewrites NXDOMAIN to an ad or search page; disable NXDOMAIN redirection or use another resolver",
+			Remediation: &dnsprobe.Remediation{Action: "disable-nxdomain-redirection", Records: []string{record(name, dns.TypeA)},
+				Suggested: map[string]string{"rcode": "NXDOMAIN"}}}
+	}
+	return Finding{Status: Warn, Code: "unexpected_rcode", Detail: fmt.Sprintf("nonexistent %s returned %s", name, dns.RcodeToString[r.Rcode]),
+		Hint: "expected NXDOMAIN; the resolver may be filtering or failing lookups",
+		Remediation: &dnsprobe.Remediation{Action: "check-upstream-and-acl", Records: []string{record(name, dns.TypeA)},
+			Suggested: map[string]string{"rcode": "NXDOMAIN"}}}
+}
+
+// checkDNS64 asks for AAAA of a name that only has A records (RFC 7050): any
+// answer was synthesized, and the IPv4 address embedded in it reveals the
+// NAT64 prefix. DNS64 is expected on IPv6-only networks, so it passes either
+// way.
+func checkDNS64(ctx context.Context, cfg Config) Finding {
+	r, _, err := exchange(ctx, cfg, query(dnsprobe.IPv4OnlyName, dns.TypeAAAA, false), false)
+	if err != nil {
+		return Finding{Status: Warn, Code: "query_failed", Detail: err.Error(),
+			Hint:        "the AAAA query for " + dnsprobe.IPv4OnlyName + " got no reply, so DNS64 could not be checked; check that the resolver answers and run doctor again",
+			Remediation: &dnsprobe.Remediation{Action: "recheck-connectivity", Records: []string{record(dnsprobe.IPv4OnlyName, dns.TypeAAAA)}}}
+	}
+	var aaaa []netip.Addr
+	for _, rr := range r.Answer {
+		if rr, ok := rr.(*dns.AAAA); ok {
+			if a, ok := netip.AddrFromSlice(rr.AAAA); ok {
+				aaaa = append(aaaa, a)
+			}
+		}
+	}
+	if len(aaaa) == 0 {
+		return Finding{Status: Pass, Detail: "no DNS64: " + dnsprobe.IPv4OnlyName + " has no AAAA"}
+	}
+
+	prefixes := dnsprobe.NAT64Prefixes(aaaa, dnsprobe.IPv4OnlyAddrs)
+	if len(prefixes) == 0 {
+		return Finding{Status: Warn, Code: "unrecognized_synthesis", Detail: fmt.Sprintf("%s has AAAA %v, which embeds none of its IPv4 addresses", dnsprobe.IPv4OnlyName, aaaa),
+			Hint: "something answers AAAA for IPv4-only names without a recognizable NAT64 prefix; IPv6-only clients may not reach IPv4 services",
+			Remediation: &dnsprobe.Remediation{Action: "check-upstream-and-acl", Records: []string{record(dnsprobe.IPv4OnlyName, dns.TypeAAAA)},
+				Suggested: map[string]string{"nat64-prefix": dnsprobe.WellKnownNAT64.String()}}}
+	}
+	var parts []string
+	for _, p := range prefixes {
+		s := p.String()
+		if p == dnsprobe.WellKnownNAT64 {
+			s += " (well-known)"
+		} else {
+			s += " (network-specific)"
+		}
+		parts = append(parts, s)
+	}
+	return Finding{Status: Pass, Detail: "DNS64 in use, NAT64 prefix " + strings.Join(parts, ", ")}
+}
+
+// checkHTTPS asks for the HTTPS record of a name known to publish one.
+// Browsers ask for it next to A and AAAA, so a resolver that drops the query
+// stalls every page load, and one that strips the answer loses ECH and
+// HTTP/3 hints.
+func checkHTTPS(ctx context.Context, cfg Config) Finding {
+	r, rtt, err := exchange(ctx, cfg, query(cfg.HTTPSName, dns.TypeHTTPS, false), false)
+	if err != nil {
+		return Finding{Status: Fail, Code: "dropped", Detail: err.Error(),
+			Hint: "HTTPS queries go unanswered, usually a firewall or old resolver dropping types it does not know; browsers wait for them before connecting, so allow every query type through",
+			Remediation: &dnsprobe.Remediation{Action: "allow-query-type", Records: []string{record(cfg.HTTPSName, dns.TypeHTTPS)},
+				Suggested: map[string]string{"qtype": "HTTPS"}}}
+	}
+	if r.Rcode != dns.RcodeSuccess {
+		return Finding{Status: Fail, Code: "rejected", Detail: fmt.Sprintf("%s HTTPS returned %s", cfg.HTTPSName, dns.RcodeToString[r.Rcode]),
+			Hint:        "the resolver rejects the HTTPS type (RFC 9460); upgrade it, as browsers look it up for every site",
+			Remediation: &dnsprobe.Remediation{Action: "upgrade-resolver", Records: []string{record(cfg.HTTPSName, dns.TypeHTTPS)}}}
+	}
+	var keys []string
+	var records int
+	for _, rr := range r.Answer {
+		b, ok := dnsprobe.DecodeServiceBinding(rr)
+		if !ok {
+			continue
+		}
+		records++
+		for _, p := range b.Params {
+			if !slices.Contains(keys, p.Key) {
+				keys = append(keys, p.Key)
+			}
+		}
+	}
+	if records == 0 {
+		return Finding{Status: Warn, Code: "stripped", Detail: fmt.Sprintf("%s HTTPS: NOERROR without an HTTPS record", cfg.HTTPSName),
+			Hint:        fmt.Sprintf("%s publishes an HTTPS record, so the resolver or a filter on the path strips the type; clients lose HTTP/3 and ECH hints", cfg.HTTPSName),
+			Remediation: &dnsprobe.Remediation{Action: "disable-dns-inspection", Records: []string{record(cfg.HTTPSName, dns.TypeHTTPS)}}}
+	}
+	params := "no SvcParams"
+	if len(keys) > 0 {
+		params = "SvcParams " + strings.Join(keys, ", ")
+	}
+	return Finding{Status: Pass, Detail: fmt.Sprintf("%s: %d HTTPS record(s) with %s in %s", cfg.HTTPSName, records, params, rtt)}
+}
+
+// record formats a name and type the way Remediation.Records lists them.
+func record(name string, qtype uint16) string {
+	return dns.Fqdn(name) + " " + dns.TypeToString[qtype]
+}
+
+func negativeSOA(m *dns.Msg) *dns.SOA {
+	for _, rr := range m.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa
+		}
+	}
+	return nil
+}
+
+func randomName() (string, error) {
+	d, err := dnsprobe.RandomDomain128WithCOM()
+	if err != nil {
+		return "", err
+	}
+	return "dnsdoc-" + strings.TrimRight(d[:20], "-"), nil
+}
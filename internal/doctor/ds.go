@@ -0,0 +1,257 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+)
+
+// DS checks that zone's DS records at the parent match its DNSKEYs: every
+// DS should name a key the zone serves, by algorithm and digest, and at
+// least one such key must sign the DNSKEY set. Codes are
+// "ds.<check>.<reason>".
+func DS(ctx context.Context, resolver, zone string, opts dnsprobe.Options) ([]Finding, error) {
+	c, err := dnsprobe.CheckDS(ctx, resolver, zone, opts)
+	if err != nil {
+		return nil, err
+	}
+	signs := map[uint16]bool{}
+	for _, k := range c.Keys {
+		signs[k.KeyTag] = k.SignsKeys
+	}
+	valid := 0
+	for _, l := range c.DS {
+		if l.DigestOK && signs[l.KeyTag] {
+			valid++
+		}
+	}
+
+	var out []Finding
+	add := func(check, code string, f Finding) {
+		f.Check = check
+		if f.Code != "" {
+			f.Code = "ds." + code + "." + f.Code
+		}
+		out = append(out, f)
+	}
+	fix := &dnsprobe.Remediation{Action: "update-ds", Records: []string{record(c.Zone, dns.TypeDS), record(c.Zone, dns.TypeDNSKEY)}}
+
+	check := "chain of trust"
+	switch {
+	case len(c.DS) == 0 && len(c.Keys) == 0:
+		add(check, "chain", Finding{Status: Pass, Detail: fmt.Sprintf("unsigned, and %s has no DS for it: an insecure delegation", c.Parent)})
+	case len(c.DS) == 0:
+		add(check, "chain", Finding{Status: Warn, Code: "no_ds", Detail: fmt.Sprintf("signed with %d key(s), but %s has no DS for it", len(c.Keys), c.Parent),
+			Hint: "validating resolvers treat the zone as unsigned; publish a DS for its key-signing key at the registrar", Remediation: fix})
+	case len(c.Keys) == 0:
+		add(check, "chain", Finding{Status: Fail, Code: "ds_without_dnskey", Detail: fmt.Sprintf("%s has %d DS record(s), but the zone serves no DNSKEY", c.Parent, len(c.DS)),
+			Hint: "validating resolvers return SERVFAIL for every name in the zone; sign the zone again or remove the DS at the registrar", Remediation: fix})
+	case valid == 0:
+		add(check, "chain", Finding{Status: Fail, Code: "broken", Detail: fmt.Sprintf("none of %d DS record(s) matches a key that signs the DNSKEY set", len(c.DS)),
+			Hint: "validating resolvers return SERVFAIL for every name in the zone; replace the DS at the registrar with one for the current key-signing key", Remediation: fix})
+	default:
+		add(check, "chain", Finding{Status: Pass, Detail: fmt.Sprintf("%d of %d DS record(s) match a key that signs the DNSKEY set", valid, len(c.DS))})
+	}
+	if len(c.Keys) == 0 {
+		return out, nil
+	}
+
+	// A DS that does not work is harmless while another one does, as during
+	// a key rollover, and breaks validation when none does.
+	broken := Fail
+	if valid > 0 {
+		broken = Warn
+	}
+	for _, l := range c.DS {
+		check := fmt.Sprintf("DS %d %s %s", l.KeyTag, dns.AlgorithmToString[l.Algorithm], digestName(l.DigestType))
+		switch {
+		case l.Key == nil:
+			add(check, "record", Finding{Status: broken, Code: "orphaned", Detail: "no DNSKEY with this key tag and algorithm",
+				Hint: "the DS names a key the zone no longer serves; remove it at the registrar once no resolver needs it", Remediation: fix})
+		case l.Unsupported:
+			add(check, "record", Finding{Status: Warn, Code: "unsupported_digest", Detail: fmt.Sprintf("digest type %d cannot be checked", l.DigestType),
+				Hint: "validators that do not know this digest type ignore the DS, as dnsdoc does; publish a SHA-256 (type 2) DS for the same key",
+				Remediation: &dnsprobe.Remediation{Action: "update-ds", Records: fix.Records,
+					Suggested: map[string]string{"digest_type": fmt.Sprint(dns.SHA256)}}})
+		case !l.DigestOK:
+			add(check, "record", Finding{Status: broken, Code: "digest_mismatch", Detail: fmt.Sprintf("key %d exists but its digest is not %s", l.KeyTag, l.Digest),
+				Hint: "the DS was made from a different key with the same tag, or copied wrongly; generate it again from the published DNSKEY", Remediation: fix})
+		case !signs[l.KeyTag]:
+			add(check, "record", Finding{Status: broken, Code: "key_not_signing", Detail: fmt.Sprintf("matches key %d (flags %d), which does not sign the DNSKEY set", l.KeyTag, l.Key.Flags),
+				Hint: "the DS must point at a key that signs the DNSKEY set; sign it with that key or publish the DS of the key that does", Remediation: fix})
+		default:
+			add(check, "record", Finding{Status: Pass, Detail: fmt.Sprintf("matches key %d (flags %d), which signs the DNSKEY set", l.KeyTag, l.Key.Flags)})
+		}
+	}
+	cds(c, signs, add)
+	return out, nil
+}
+
+// cds rates the CDS and CDNSKEY records at c's apex, which a parent polling
+// them (RFC 7344, RFC 8078) turns into its DS set: each must describe a live
+// key that signs the DNSKEY set, and the parent's DS set should follow them.
+func cds(c dnsprobe.DSCheck, signs map[uint16]bool, add func(check, code string, f Finding)) {
+	fix := &dnsprobe.Remediation{Action: "update-cds", Records: []string{record(c.Zone, dns.TypeCDS), record(c.Zone, dns.TypeCDNSKEY)}}
+	if c.CDSErr != nil {
+		add("cds/cdnskey", "cds", Finding{Status: Warn, Code: "query_failed", Detail: c.CDSErr.Error(),
+			Hint:        "the zone's CDS and CDNSKEY records could not be fetched, so they were not checked; check that its nameservers answer and run the check again",
+			Remediation: &dnsprobe.Remediation{Action: "recheck-connectivity", Records: fix.Records}})
+		return
+	}
+	if len(c.CDS)+len(c.CDNSKEY) == 0 {
+		add("cds/cdnskey", "cds", Finding{Status: Pass, Detail: "none published: the DS set at the parent is maintained by hand"})
+		return
+	}
+	if deleteRequest(c) {
+		f := Finding{Status: Pass, Detail: "delete request (RFC 8078, section 4), and the parent has no DS"}
+		if len(c.DS) > 0 {
+			f = Finding{Status: Warn, Code: "delete_pending", Detail: fmt.Sprintf("delete request (RFC 8078, section 4), but %s still has %d DS record(s)", c.Parent, len(c.DS)),
+				Hint:        "the parent has not acted on it yet; if it does not poll CDS, remove the DS at the registrar",
+				Remediation: &dnsprobe.Remediation{Action: "update-ds", Records: []string{record(c.Zone, dns.TypeDS)}, Suggested: map[string]string{"ds": "none"}}}
+		}
+		add("cds/cdnskey", "cds", f)
+		return
+	}
+
+	var cdsKeys, cdnskeyKeys []string
+	for _, r := range c.CDS {
+		check := fmt.Sprintf("CDS %d %s %s", r.KeyTag, dns.AlgorithmToString[r.Algorithm], digestName(r.DigestType))
+		cdsKeys = append(cdsKeys, fmt.Sprint(r.KeyTag))
+		var key *dns.DNSKEY
+		match, unsupported := false, false
+		for _, k := range c.Keys {
+			if k.KeyTag != r.KeyTag || k.Key.Algorithm != r.Algorithm {
+				continue
+			}
+			key = k.Key
+			want := k.Key.ToDS(r.DigestType)
+			unsupported = want == nil
+			if match = want != nil && strings.EqualFold(want.Digest, r.Digest); match {
+				break
+			}
+		}
+		switch {
+		case key == nil:
+			add(check, "cds", Finding{Status: Fail, Code: "orphaned", Detail: "no live DNSKEY with this key tag and algorithm",
+				Hint: "a parent that polls CDS would install a DS that breaks validation; publish CDS only for keys the zone serves", Remediation: fix})
+		case unsupported:
+			add(check, "cds", Finding{Status: Warn, Code: "unsupported_digest", Detail: fmt.Sprintf("digest type %d cannot be checked", r.DigestType),
+				Hint: "a parent that does not know this digest type ignores the CDS; publish a SHA-256 (type 2) CDS for the same key",
+				Remediation: &dnsprobe.Remediation{Action: "update-cds", Records: fix.Records,
+					Suggested: map[string]string{"digest_type": fmt.Sprint(dns.SHA256)}}})
+		case !match:
+			add(check, "cds", Finding{Status: Fail, Code: "digest_mismatch", Detail: fmt.Sprintf("key %d exists but its digest is not %s", r.KeyTag, strings.ToLower(r.Digest)),
+				Hint: "a parent that polls CDS would install a DS that breaks validation; generate the CDS again from the published DNSKEY", Remediation: fix})
+		case !signs[r.KeyTag]:
+			add(check, "cds", Finding{Status: Fail, Code: "key_not_signing", Detail: fmt.Sprintf("matches key %d (flags %d), which does not sign the DNSKEY set", r.KeyTag, key.Flags),
+				Hint: "the resulting DS would point at a key that does not sign the DNSKEY set; publish CDS for the key-signing key", Remediation: fix})
+		default:
+			add(check, "cds", Finding{Status: Pass, Detail: fmt.Sprintf("matches key %d (flags %d), which signs the DNSKEY set", r.KeyTag, key.Flags)})
+		}
+	}
+	for _, r := range c.CDNSKEY {
+		tag := r.KeyTag()
+		check := fmt.Sprintf("CDNSKEY %d %s", tag, dns.AlgorithmToString[r.Algorithm])
+		cdnskeyKeys = append(cdnskeyKeys, fmt.Sprint(tag))
+		live := false
+		for _, k := range c.Keys {
+			if k.Key.Flags == r.Flags && k.Key.Algorithm == r.Algorithm && k.Key.PublicKey == r.PublicKey {
+				live = true
+			}
+		}
+		switch {
+		case !live:
+			add(check, "cds", Finding{Status: Fail, Code: "unknown_key", Detail: "not among the zone's DNSKEYs",
+				Hint: "a parent that polls CDNSKEY would install a DS that breaks validation; publish CDNSKEY only for keys the zone serves", Remediation: fix})
+		case !signs[tag]:
+			add(check, "cds", Finding{Status: Fail, Code: "key_not_signing", Detail: fmt.Sprintf("key %d does not sign the DNSKEY set", tag),
+				Hint: "the resulting DS would point at a key that does not sign the DNSKEY set; publish CDNSKEY for the key-signing key", Remediation: fix})
+		default:
+			add(check, "cds", Finding{Status: Pass, Detail: fmt.Sprintf("key %d (flags %d), which signs the DNSKEY set", tag, r.Flags)})
+		}
+	}
+	slices.Sort(cdsKeys)
+	slices.Sort(cdnskeyKeys)
+	cdsKeys, cdnskeyKeys = slices.Compact(cdsKeys), slices.Compact(cdnskeyKeys)
+	if len(c.CDS) > 0 && len(c.CDNSKEY) > 0 && !slices.Equal(cdsKeys, cdnskeyKeys) {
+		add("cds/cdnskey", "cds", Finding{Status: Warn, Code: "inconsistent", Detail: fmt.Sprintf("CDS names key(s) %s, CDNSKEY %s", strings.Join(cdsKeys, " "), strings.Join(cdnskeyKeys, " ")),
+			Hint: "parents may read either set; publish both for the same keys", Remediation: fix})
+	}
+
+	// The parent's DS set is in sync when it holds exactly what CDS asks for,
+	// or, with CDNSKEY alone, DS records for exactly those keys.
+	var want, have []string
+	for _, r := range c.CDS {
+		want = append(want, fmt.Sprintf("%d %d %d %s", r.KeyTag, r.Algorithm, r.DigestType, strings.ToLower(r.Digest)))
+	}
+	for _, l := range c.DS {
+		if len(c.CDS) > 0 {
+			have = append(have, fmt.Sprintf("%d %d %d %s", l.KeyTag, l.Algorithm, l.DigestType, l.Digest))
+		} else {
+			have = append(have, fmt.Sprint(l.KeyTag))
+		}
+	}
+	if len(c.CDS) == 0 {
+		want = cdnskeyKeys
+	}
+	slices.Sort(want)
+	slices.Sort(have)
+	if slices.Equal(slices.Compact(want), slices.Compact(have)) {
+		add("parent sync", "sync", Finding{Status: Pass, Detail: fmt.Sprintf("the DS set at %s matches the published CDS/CDNSKEY", c.Parent)})
+		return
+	}
+	add("parent sync", "sync", Finding{Status: Warn, Code: "pending", Detail: fmt.Sprintf("the DS set at %s (key(s) %s) does not match the published CDS/CDNSKEY (key(s) %s)", c.Parent, orNone(dsKeys(c.DS)), orNone(unionKeys(cdsKeys, cdnskeyKeys))),
+		Hint: "parents poll CDS/CDNSKEY on their own schedule and some only after the registrar enables it; if it stays pending for days, check that the parent supports RFC 8078",
+		Remediation: &dnsprobe.Remediation{Action: "update-ds", Records: []string{record(c.Zone, dns.TypeDS)},
+			Suggested: map[string]string{"keys": orNone(unionKeys(cdsKeys, cdnskeyKeys))}}})
+}
+
+// deleteRequest reports whether c's apex asks for the DS set to be removed:
+// CDS 0 0 0 00 or CDNSKEY 0 3 0 AA== (RFC 8078, section 4).
+func deleteRequest(c dnsprobe.DSCheck) bool {
+	for _, r := range c.CDS {
+		if r.Algorithm == 0 {
+			return true
+		}
+	}
+	for _, r := range c.CDNSKEY {
+		if r.Algorithm == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func dsKeys(ds []dnsprobe.DSLink) []string {
+	var out []string
+	for _, l := range ds {
+		out = append(out, fmt.Sprint(l.KeyTag))
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+func unionKeys(a, b []string) []string {
+	out := append(slices.Clone(a), b...)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+func orNone(s []string) string {
+	if len(s) == 0 {
+		return "none"
+	}
+	return strings.Join(s, " ")
+}
+
+func digestName(t uint8) string {
+	if n, ok := dns.HashToString[t]; ok {
+		return n
+	}
+	return fmt.Sprintf("digest%d", t)
+}
@@ -0,0 +1,318 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+)
+
+// Mail rates a domain's mail posture: MX reachability, SPF validity and
+// lookup count, DMARC policy and reporting, DKIM key strength, MTA-STS and
+// TLS-RPT. A null MX domain sends and receives no mail, so only SPF and
+// DMARC are rated for it. Codes are "mail.<check>.<reason>".
+func Mail(r dnsprobe.MailReport) []Finding {
+	var out []Finding
+	add := func(check, code string, f Finding) {
+		f.Check = check
+		if f.Code != "" {
+			f.Code = "mail." + code + "." + f.Code
+		}
+		out = append(out, f)
+	}
+
+	switch {
+	case r.NullMX:
+		add("mx", "mx", Finding{Status: Pass, Detail: "null MX: the domain accepts no mail (RFC 7505)"})
+	case len(r.MX) == 0:
+		add("mx", "mx", Finding{Status: Warn, Code: "none", Detail: "no MX records; senders fall back to the domain's own address",
+			Hint:        "publish MX records for the hosts that receive mail, or a null MX (\"0 .\") if the domain receives none",
+			Remediation: &dnsprobe.Remediation{Action: "publish-mx", Records: []string{record(r.Domain, dns.TypeMX)}, Suggested: map[string]string{"null_mx": "0 ."}}})
+	}
+	for _, h := range r.MX {
+		check := "mx " + h.Host
+		if len(h.Addrs) == 0 {
+			add(check, "mx", Finding{Status: Fail, Code: "no_address", Detail: "the MX host name has no A or AAAA record",
+				Hint:        "senders cannot deliver to this MX; fix its address records or remove it",
+				Remediation: &dnsprobe.Remediation{Action: "fix-mx-address", Records: []string{record(h.Host, dns.TypeA), record(h.Host, dns.TypeAAAA)}}})
+			continue
+		}
+		add(check, "mx", Finding{Status: Pass, Detail: fmt.Sprintf("preference %d: %s", h.Preference, strings.Join(h.Addrs, " "))})
+	}
+
+	spf(r, add)
+	dmarc(r, add)
+	if r.NullMX {
+		return out
+	}
+	dkim(r, add)
+	mtaSTS(r, add)
+
+	if r.TLSRPT == "" {
+		add("tls-rpt", "tlsrpt", Finding{Status: Warn, Code: "missing", Detail: "no _smtp._tls TXT record",
+			Hint: "without TLS-RPT (RFC 8460) you do not hear about senders failing to set up TLS to your MX; publish \"v=TLSRPTv1; rua=mailto:...\"",
+			Remediation: &dnsprobe.Remediation{Action: "publish-tls-rpt", Records: []string{record("_smtp._tls."+r.Domain, dns.TypeTXT)},
+				Suggested: map[string]string{"record": "v=TLSRPTv1; rua=mailto:tlsrpt@" + strings.TrimSuffix(r.Domain, ".")}}})
+	} else {
+		add("tls-rpt", "tlsrpt", Finding{Status: Pass, Detail: r.TLSRPT})
+	}
+	return out
+}
+
+func spf(r dnsprobe.MailReport, add func(check, code string, f Finding)) {
+	s := r.SPF
+	txt := []string{record(r.Domain, dns.TypeTXT)}
+	switch {
+	case errors.Is(s.Err, dnsprobe.ErrNoRecord):
+		f := Finding{Status: Fail, Code: "missing", Detail: "no v=spf1 TXT record",
+			Hint:        "receivers cannot tell legitimate senders from forgeries; publish an SPF record listing the hosts that send for the domain",
+			Remediation: &dnsprobe.Remediation{Action: "publish-spf", Records: txt, Suggested: map[string]string{"record": "v=spf1 mx ~all"}}}
+		if r.NullMX {
+			f.Status, f.Hint = Warn, "a domain that sends no mail should say so with \"v=spf1 -all\""
+			f.Remediation.Suggested["record"] = "v=spf1 -all"
+		}
+		add("spf", "spf", f)
+		return
+	case s.Err != nil:
+		add("spf", "spf", Finding{Status: Fail, Code: "invalid", Detail: s.Err.Error(),
+			Hint:        "receivers treat the domain's SPF as a permanent error; publish exactly one v=spf1 record",
+			Remediation: &dnsprobe.Remediation{Action: "fix-spf", Records: txt}})
+		return
+	}
+
+	var broken []string
+	brokenTXT := slices.Clone(txt)
+	walkSPF(s, func(c *dnsprobe.SPFRecord) {
+		if c != s && c.Err != nil {
+			broken = append(broken, fmt.Sprintf("%s: %v", strings.TrimSuffix(c.Domain, "."), c.Err))
+			brokenTXT = append(brokenTXT, record(c.Domain, dns.TypeTXT))
+		}
+	})
+	if len(broken) > 0 {
+		add("spf includes", "spf", Finding{Status: Fail, Code: "broken_include", Detail: strings.Join(broken, "; "),
+			Hint:        "an include or redirect without a usable SPF record makes the whole evaluation a permanent error; fix or remove it",
+			Remediation: &dnsprobe.Remediation{Action: "fix-spf-include", Records: brokenTXT}})
+	}
+
+	n := s.TotalLookups()
+	lookups := fmt.Sprintf("%d DNS lookup(s) of %d", n, dnsprobe.SPFLookupLimit)
+	reduce := &dnsprobe.Remediation{Action: "reduce-spf-lookups", Records: txt,
+		Suggested: map[string]string{"max_lookups": strconv.Itoa(dnsprobe.SPFLookupLimit - 3)}}
+	switch {
+	case n > dnsprobe.SPFLookupLimit:
+		add("spf lookups", "spf", Finding{Status: Fail, Code: "too_many_lookups", Detail: lookups,
+			Hint: "receivers stop at 10 and return permerror, failing SPF for all mail; drop unused includes or replace them with ip4:/ip6: ranges", Remediation: reduce})
+	case n >= dnsprobe.SPFLookupLimit-2:
+		add("spf lookups", "spf", Finding{Status: Warn, Code: "near_limit", Detail: lookups,
+			Hint: "one more include, or a provider growing its own record, breaks SPF; trim includes you no longer use", Remediation: reduce})
+	default:
+		add("spf lookups", "spf", Finding{Status: Pass, Detail: lookups})
+	}
+
+	all := s.All
+	tighten := &dnsprobe.Remediation{Action: "tighten-spf-all", Records: txt, Suggested: map[string]string{"record": spfWithAll(s.Record, "~all")}}
+	switch {
+	case all == "+all":
+		add("spf policy", "spf", Finding{Status: Fail, Code: "pass_all", Detail: "+all: every host on the internet may send as the domain",
+			Hint: "end the record with -all or ~all", Remediation: tighten})
+	case all == "?all":
+		add("spf policy", "spf", Finding{Status: Warn, Code: "neutral", Detail: "?all: unlisted senders are neither allowed nor denied",
+			Hint: "end the record with -all or ~all once it lists every sender", Remediation: tighten})
+	case all == "" && !s.Redirect:
+		add("spf policy", "spf", Finding{Status: Warn, Code: "no_all", Detail: "no all mechanism: unlisted senders get a neutral result",
+			Hint: "end the record with -all or ~all", Remediation: tighten})
+	case all == "":
+		add("spf policy", "spf", Finding{Status: Pass, Detail: "set by redirect"})
+	default:
+		add("spf policy", "spf", Finding{Status: Pass, Detail: all})
+	}
+}
+
+// spfWithAll returns record with its all mechanism, if any, replaced by all,
+// or all appended when it has none.
+func spfWithAll(record, all string) string {
+	var terms []string
+	for _, t := range strings.Fields(record) {
+		if strings.TrimLeft(strings.ToLower(t), "+-~?") != "all" {
+			terms = append(terms, t)
+		}
+	}
+	return strings.Join(append(terms, all), " ")
+}
+
+func walkSPF(r *dnsprobe.SPFRecord, fn func(*dnsprobe.SPFRecord)) {
+	fn(r)
+	for _, c := range r.Children {
+		walkSPF(c, fn)
+	}
+}
+
+func dmarc(r dnsprobe.MailReport, add func(check, code string, f Finding)) {
+	txt := []string{record("_dmarc."+r.Domain, dns.TypeTXT)}
+	rua := "mailto:dmarc@" + strings.TrimSuffix(r.Domain, ".")
+	fix := func(action, record string) *dnsprobe.Remediation {
+		return &dnsprobe.Remediation{Action: action, Records: txt, Suggested: map[string]string{"record": record}}
+	}
+	switch {
+	case errors.Is(r.DMARCErr, dnsprobe.ErrNoRecord):
+		add("dmarc", "dmarc", Finding{Status: Fail, Code: "missing", Detail: "no v=DMARC1 record at _dmarc." + strings.TrimSuffix(r.Domain, "."),
+			Hint:        "publish \"v=DMARC1; p=none; rua=mailto:...\" to start collecting reports, then move to p=quarantine or p=reject",
+			Remediation: fix("publish-dmarc", "v=DMARC1; p=none; rua="+rua)})
+		return
+	case r.DMARCErr != nil:
+		add("dmarc", "dmarc", Finding{Status: Fail, Code: "invalid", Detail: r.DMARCErr.Error(),
+			Hint: "publish exactly one v=DMARC1 record", Remediation: fix("fix-dmarc", "v=DMARC1; p=none; rua="+rua)})
+		return
+	}
+
+	tags := r.DMARCTags
+	switch p := strings.ToLower(tags["p"]); p {
+	case "reject", "quarantine":
+		add("dmarc policy", "dmarc", Finding{Status: Pass, Detail: "p=" + p})
+	case "none":
+		add("dmarc policy", "dmarc", Finding{Status: Warn, Code: "monitor_only", Detail: "p=none: failing mail is delivered as usual",
+			Hint:        "once the reports show all legitimate mail passing, move to p=quarantine and then p=reject",
+			Remediation: fix("enforce-dmarc", withTag(r.DMARC, "p", "quarantine"))})
+	default:
+		add("dmarc policy", "dmarc", Finding{Status: Fail, Code: "invalid_policy", Detail: fmt.Sprintf("p=%q", tags["p"]),
+			Hint:        "p= is required and must be none, quarantine or reject; receivers ignore the record without it",
+			Remediation: fix("fix-dmarc", withTag(r.DMARC, "p", "none"))})
+	}
+	if pct, ok := tags["pct"]; ok {
+		if n, err := strconv.Atoi(pct); err != nil || n < 100 {
+			add("dmarc pct", "dmarc", Finding{Status: Warn, Code: "partial", Detail: "pct=" + pct,
+				Hint:        "the policy applies to only part of the failing mail; raise pct to 100 once you are confident",
+				Remediation: fix("enforce-dmarc", withTag(r.DMARC, "pct", "100"))})
+		}
+	}
+	if tags["rua"] == "" {
+		add("dmarc reports", "dmarc", Finding{Status: Warn, Code: "no_reports", Detail: "no rua= address",
+			Hint:        "without aggregate reports you cannot see who sends as the domain; add rua=mailto:...",
+			Remediation: fix("add-dmarc-rua", withTag(r.DMARC, "rua", rua))})
+	} else {
+		add("dmarc reports", "dmarc", Finding{Status: Pass, Detail: "rua=" + tags["rua"]})
+	}
+	var unauthorized []string
+	for dest, ok := range r.ReportAuth {
+		if !ok {
+			unauthorized = append(unauthorized, dest)
+		}
+	}
+	slices.Sort(unauthorized)
+	for _, dest := range unauthorized {
+		add("dmarc report "+dest, "dmarc", Finding{Status: Warn, Code: "unauthorized_destination", Detail: "the destination does not accept reports for the domain",
+			Hint: fmt.Sprintf("receivers only send reports to another domain if it publishes %s_report._dmarc.%s TXT \"v=DMARC1\"", r.Domain, dest),
+			Remediation: &dnsprobe.Remediation{Action: "authorize-dmarc-destination", Records: []string{record(r.Domain+"_report._dmarc."+dest, dns.TypeTXT)},
+				Suggested: map[string]string{"record": "v=DMARC1"}}})
+	}
+}
+
+// withTag returns the DMARC record with tag set to value, replacing the tag
+// where it appears and adding it after p= (or v=) otherwise.
+func withTag(record, tag, value string) string {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimSpace(record), ";"), ";")
+	at := -1
+	for i, p := range parts {
+		name, _, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case tag:
+			parts[i] = " " + tag + "=" + value
+			return strings.TrimSpace(strings.Join(parts, ";"))
+		case "v", "p":
+			at = i
+		}
+	}
+	parts = slices.Insert(parts, at+1, " "+tag+"="+value)
+	return strings.TrimSpace(strings.Join(parts, ";"))
+}
+
+func dkim(r dnsprobe.MailReport, add func(check, code string, f Finding)) {
+	if r.DKIMGuessed && len(r.DKIM) == 0 {
+		add("dkim", "dkim", Finding{Status: Warn, Code: "not_found", Detail: "no key at the common selectors " + strings.Join(dnsprobe.DefaultDKIMSelectors, " "),
+			Hint:        "DKIM keys can only be found by selector; pass --selectors with the ones your mail is signed with",
+			Remediation: &dnsprobe.Remediation{Action: "set-dkim-selectors", Suggested: map[string]string{"flag": "--selectors"}}})
+		return
+	}
+	for _, k := range r.DKIM {
+		check := "dkim " + k.Selector
+		txt := []string{record(k.Selector+"._domainkey."+r.Domain, dns.TypeTXT)}
+		rotate := &dnsprobe.Remediation{Action: "rotate-dkim-key", Records: txt, Suggested: map[string]string{"k": "rsa", "bits": "2048"}}
+		switch {
+		case errors.Is(k.Err, dnsprobe.ErrNoRecord):
+			add(check, "dkim", Finding{Status: Fail, Code: "missing", Detail: "no key record",
+				Hint:        "mail signed with this selector fails DKIM; publish its key or stop signing with it",
+				Remediation: &dnsprobe.Remediation{Action: "publish-dkim-key", Records: txt}})
+		case k.Err != nil:
+			add(check, "dkim", Finding{Status: Fail, Code: "invalid", Detail: k.Err.Error(),
+				Hint:        "receivers cannot read the key, so signatures with this selector fail",
+				Remediation: &dnsprobe.Remediation{Action: "fix-dkim-key", Records: txt}})
+		case k.Revoked:
+			add(check, "dkim", Finding{Status: Warn, Code: "revoked", Detail: "empty p=: the key is revoked",
+				Hint:        "fine for a retired selector; make sure nothing still signs with it",
+				Remediation: &dnsprobe.Remediation{Action: "retire-dkim-selector", Records: txt}})
+		case k.KeyType == "rsa" && k.Bits < 1024:
+			add(check, "dkim", Finding{Status: Fail, Code: "weak", Detail: fmt.Sprintf("RSA %d bits", k.Bits),
+				Hint: "receivers reject RSA keys below 1024 bits (RFC 8301); rotate to a 2048-bit key", Remediation: rotate})
+		case k.KeyType == "rsa" && k.Bits < 2048:
+			add(check, "dkim", Finding{Status: Warn, Code: "short", Detail: fmt.Sprintf("RSA %d bits", k.Bits),
+				Hint: "1024-bit keys are the minimum; rotate to a 2048-bit key", Remediation: rotate})
+		case k.KeyType == "rsa":
+			add(check, "dkim", Finding{Status: Pass, Detail: fmt.Sprintf("RSA %d bits", k.Bits)})
+		default:
+			add(check, "dkim", Finding{Status: Pass, Detail: k.KeyType})
+		}
+	}
+}
+
+func mtaSTS(r dnsprobe.MailReport, add func(check, code string, f Finding)) {
+	s := r.MTASTS
+	domain := strings.TrimSuffix(r.Domain, ".")
+	txt := []string{record("_mta-sts."+r.Domain, dns.TypeTXT)}
+	policy := "https://mta-sts." + domain + "/.well-known/mta-sts.txt"
+	// Any change to the policy only reaches senders once the id in the
+	// _mta-sts record changes too, so every policy fix lists that record.
+	update := func(action, key, value string) *dnsprobe.Remediation {
+		return &dnsprobe.Remediation{Action: action, Records: txt, Suggested: map[string]string{"policy": policy, key: value}}
+	}
+	switch {
+	case s.Record == "":
+		add("mta-sts", "mtasts", Finding{Status: Warn, Code: "missing", Detail: "no _mta-sts TXT record",
+			Hint:        "without MTA-STS (RFC 8461) senders use TLS to your MX only opportunistically, and an attacker on the path can strip it",
+			Remediation: update("publish-mta-sts", "mode", "testing")})
+		return
+	case s.Err != nil:
+		add("mta-sts", "mtasts", Finding{Status: Fail, Code: "policy_unavailable", Detail: s.Err.Error(),
+			Hint: "the record announces a policy that cannot be fetched; serve it at https://mta-sts.<domain>/.well-known/mta-sts.txt with a valid certificate",
+			Remediation: &dnsprobe.Remediation{Action: "serve-mta-sts-policy",
+				Records: []string{record("mta-sts."+r.Domain, dns.TypeA), record("mta-sts."+r.Domain, dns.TypeAAAA)}, Suggested: map[string]string{"policy": policy}}})
+		return
+	}
+	switch s.Mode {
+	case "enforce":
+		add("mta-sts", "mtasts", Finding{Status: Pass, Detail: fmt.Sprintf("mode enforce, max_age %d", s.MaxAge)})
+	case "testing", "none":
+		add("mta-sts", "mtasts", Finding{Status: Warn, Code: "not_enforced", Detail: "mode " + s.Mode,
+			Hint:        "senders report failures but still deliver without TLS; move to mode: enforce once TLS-RPT reports are clean",
+			Remediation: update("enforce-mta-sts", "mode", "enforce")})
+	default:
+		add("mta-sts", "mtasts", Finding{Status: Fail, Code: "invalid_mode", Detail: fmt.Sprintf("mode %q", s.Mode),
+			Hint: "mode must be enforce, testing or none", Remediation: update("fix-mta-sts-mode", "mode", "testing")})
+	}
+	if s.MaxAge < 86400 {
+		add("mta-sts max_age", "mtasts", Finding{Status: Warn, Code: "short_max_age", Detail: fmt.Sprintf("%d seconds", s.MaxAge),
+			Hint:        "senders forget the policy quickly and fall back to opportunistic TLS; use at least a week (604800)",
+			Remediation: update("raise-mta-sts-max-age", "max_age", "604800")})
+	}
+	for _, h := range r.MX {
+		if !dnsprobe.MatchesMTASTS(h.Host, s.MX) {
+			add("mta-sts "+h.Host, "mtasts", Finding{Status: Fail, Code: "mx_not_covered", Detail: "not matched by the policy's mx: lines " + strings.Join(s.MX, " "),
+				Hint:        "with mode enforce, senders refuse to deliver to this MX; add it to the policy",
+				Remediation: update("add-mta-sts-mx", "mx", strings.TrimSuffix(h.Host, "."))})
+		}
+	}
+}
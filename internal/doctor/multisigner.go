@@ -0,0 +1,171 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+)
+
+// MultiSigner checks that a zone served by several DNSSEC signers (RFC
+// 8901) validates whichever nameserver a resolver asks: every server's
+// answers must verify against its own DNSKEY set, and every DNSKEY set must
+// carry the ZSKs all the other signers sign with, or resolvers mixing
+// servers fail to validate. Codes are "multisigner.<check>.<reason>".
+func MultiSigner(ctx context.Context, resolver, zone string, opts dnsprobe.Options) ([]Finding, error) {
+	zone, views, err := dnsprobe.SignerViews(ctx, resolver, zone, opts)
+	if err != nil {
+		return nil, err
+	}
+	var out []Finding
+	add := func(check, code string, f Finding) {
+		f.Check = check
+		if f.Code != "" {
+			f.Code = "multisigner." + code + "." + f.Code
+		}
+		out = append(out, f)
+	}
+	name := func(v dnsprobe.SignerView) string {
+		return strings.TrimSpace(v.Host + " " + v.Addr)
+	}
+
+	now := time.Now()
+	var live []dnsprobe.SignerView
+	signers := map[string][]string{}
+	var signerOrder []string
+	for _, v := range views {
+		check := "nameserver " + name(v)
+		switch {
+		case v.Err != nil:
+			add(check, "server", Finding{Status: Fail, Code: "unreachable", Detail: v.Err.Error(),
+				Hint:        "the nameserver does not answer; check that it is up and serves the zone",
+				Remediation: &dnsprobe.Remediation{Action: "fix-lame-delegation", Records: []string{record(zone, dns.TypeNS)}}})
+			continue
+		case len(v.Keys) == 0:
+			add(check, "server", Finding{Status: Fail, Code: "unsigned", Detail: "serves no DNSKEY set",
+				Hint:        "this provider serves the zone unsigned; validating resolvers that ask it get bogus answers",
+				Remediation: &dnsprobe.Remediation{Action: "enable-zone-signing", Records: []string{record(zone, dns.TypeDNSKEY)}}})
+			continue
+		}
+		live = append(live, v)
+		keyErr := verifySigs(v, v.KeySigs, v.Keys, now)
+		soaErr := verifySigs(v, v.SOASigs, v.SOA, now)
+		switch {
+		case keyErr != nil:
+			add(check, "server", Finding{Status: Fail, Code: "bad_dnskey_signature", Detail: "DNSKEY set: " + keyErr.Error(),
+				Hint:        "validators reject the DNSKEY set this server serves; check the provider's signer",
+				Remediation: &dnsprobe.Remediation{Action: "resign-zone", Records: []string{record(zone, dns.TypeDNSKEY)}}})
+		case soaErr != nil:
+			add(check, "server", Finding{Status: Fail, Code: "bad_soa_signature", Detail: "SOA: " + soaErr.Error(),
+				Hint:        "validators reject this server's answers; check the provider's signer",
+				Remediation: &dnsprobe.Remediation{Action: "resign-zone", Records: []string{record(zone, dns.TypeSOA)}}})
+		default:
+			add(check, "server", Finding{Status: Pass, Detail: fmt.Sprintf("%d key(s) %s, answers sign with %s", len(v.Keys), tagList(v.KeyTags()), tagList(sigTags(v.SOASigs)))})
+		}
+		set := tagList(sigTags(v.SOASigs))
+		if _, ok := signers[set]; !ok {
+			signerOrder = append(signerOrder, set)
+		}
+		signers[set] = append(signers[set], name(v))
+	}
+	if len(live) == 0 {
+		return out, nil
+	}
+
+	if len(signers) < 2 {
+		add("signers", "signers", Finding{Status: Pass, Detail: fmt.Sprintf("one signer: every nameserver signs with key(s) %s", signerOrder[0])})
+	} else {
+		var parts []string
+		for _, set := range signerOrder {
+			parts = append(parts, fmt.Sprintf("%s at %s", set, strings.Join(signers[set], ", ")))
+		}
+		add("signers", "signers", Finding{Status: Pass, Detail: fmt.Sprintf("%d signers: %s", len(signers), strings.Join(parts, "; "))})
+	}
+
+	// Every server's DNSKEY set should hold the ZSKs of all signers, and must
+	// at least hold those some server signs its answers with. KSKs may
+	// differ: each provider signs its DNSKEY set with its own (RFC 8901,
+	// section 2.1.2).
+	for _, v := range live {
+		var missing, missingZSK []string
+		for _, u := range live {
+			for _, rr := range u.Keys {
+				k := rr.(*dns.DNSKEY)
+				tag := fmt.Sprint(k.KeyTag())
+				if k.Flags&dns.SEP != 0 || v.Key(k.KeyTag(), k.Algorithm) != nil || slices.Contains(missing, tag) {
+					continue
+				}
+				missing = append(missing, tag)
+				if slices.Contains(sigTags(u.SOASigs), k.KeyTag()) {
+					missingZSK = append(missingZSK, tag)
+				}
+			}
+		}
+		check := "dnskey set " + name(v)
+		switch {
+		case len(missingZSK) > 0:
+			add(check, "keys", Finding{Status: Fail, Code: "zsk_missing", Detail: fmt.Sprintf("lacks ZSK(s) %s that other signers sign answers with", strings.Join(missingZSK, " ")),
+				Hint:        "resolvers that fetched the DNSKEY set here cannot validate answers from the other signers (RFC 8901, section 2.1); import their ZSKs into this provider's DNSKEY set",
+				Remediation: &dnsprobe.Remediation{Action: "sync-dnskey-set", Records: []string{record(zone, dns.TypeDNSKEY)}}})
+		case len(missing) > 0:
+			add(check, "keys", Finding{Status: Warn, Code: "drift", Detail: fmt.Sprintf("lacks ZSK(s) %s published by other nameservers", strings.Join(missing, " ")),
+				Hint:        "the signers' ZSKs have drifted apart, as when one provider pre-publishes a new ZSK the other has not imported; once it signs with it, validation breaks",
+				Remediation: &dnsprobe.Remediation{Action: "sync-dnskey-set", Records: []string{record(zone, dns.TypeDNSKEY)}}})
+		default:
+			add(check, "keys", Finding{Status: Pass, Detail: "holds every ZSK the zone's nameservers publish"})
+		}
+	}
+	return out, nil
+}
+
+// verifySigs checks that at least one of sigs over rrset verifies with a key
+// v serves and is in its validity period now.
+func verifySigs(v dnsprobe.SignerView, sigs []*dns.RRSIG, rrset []dns.RR, now time.Time) error {
+	if len(sigs) == 0 {
+		return errors.New("no RRSIG")
+	}
+	var last error
+	for _, sig := range sigs {
+		k := v.Key(sig.KeyTag, sig.Algorithm)
+		switch {
+		case k == nil:
+			last = fmt.Errorf("signed with key %d, which the server does not publish", sig.KeyTag)
+		case !sig.ValidityPeriod(now):
+			last = fmt.Errorf("signature by key %d is outside its validity period", sig.KeyTag)
+		default:
+			if last = sig.Verify(k, rrset); last == nil {
+				return nil
+			}
+			last = fmt.Errorf("signature by key %d: %w", sig.KeyTag, last)
+		}
+	}
+	return last
+}
+
+func sigTags(sigs []*dns.RRSIG) []uint16 {
+	var tags []uint16
+	for _, s := range sigs {
+		if !slices.Contains(tags, s.KeyTag) {
+			tags = append(tags, s.KeyTag)
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+func tagList(tags []uint16) string {
+	if len(tags) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(tags))
+	for i, t := range tags {
+		parts[i] = fmt.Sprint(t)
+	}
+	return strings.Join(parts, " ")
+}
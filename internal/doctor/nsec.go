@@ -0,0 +1,114 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+)
+
+// nsec3IterationLimit is the NSEC3 iteration count above which validators
+// commonly stop trusting a zone's denials (RFC 9276, section 3.2).
+const nsec3IterationLimit = 100
+
+// NSEC reports how zone proves non-existence and rates it against current
+// practice (RFC 9276 for NSEC3 parameters): whether the zone can be walked,
+// and its NSEC3 iterations, salt and opt-out. Codes are
+// "nsec.<check>.<reason>".
+func NSEC(ctx context.Context, resolver, zone string, opts dnsprobe.Options) ([]Finding, error) {
+	d, err := dnsprobe.AnalyzeDenial(ctx, resolver, zone, opts)
+	if err != nil {
+		return nil, err
+	}
+	var out []Finding
+	add := func(check, code string, f Finding) {
+		f.Check = check
+		if f.Code != "" {
+			f.Code = "nsec." + code + "." + f.Code
+		}
+		out = append(out, f)
+	}
+	// RFC 9276 parameters: SHA-1, no opt-out, no extra iterations, no salt.
+	param := []string{record(zone, dns.TypeNSEC3PARAM)}
+	recommended := &dnsprobe.Remediation{Action: "set-nsec3-parameters", Records: param, Suggested: map[string]string{"nsec3param": "1 0 0 -"}}
+
+	switch {
+	case d.Kind == "NSEC" && d.Minimal:
+		add("walkability", "walk", Finding{Status: Pass, Detail: "NSEC, minimally covering (online signing): proofs name no existing records"})
+	case d.Kind == "NSEC":
+		add("walkability", "walk", Finding{Status: Warn, Code: "walkable", Detail: fmt.Sprintf("NSEC: trivially walkable, these proofs alone named %s", exposed(d.Exposed)),
+			Hint:        "anyone can list every name in the zone by following the NSEC chain; if that matters, sign with NSEC3 or have the signer synthesize minimally covering NSEC records",
+			Remediation: &dnsprobe.Remediation{Action: "switch-to-nsec3", Records: param, Suggested: map[string]string{"nsec3param": "1 0 0 -"}}})
+	default:
+		add("walkability", "walk", Finding{Status: Pass, Detail: "NSEC3: not trivially walkable; collected hashes of short or common names can still be guessed offline"})
+	}
+	if d.Kind != "NSEC3" {
+		return out, nil
+	}
+
+	hash := fmt.Sprintf("algorithm %d", d.Hash)
+	if d.Hash == dns.SHA1 {
+		add("nsec3 hash", "hash", Finding{Status: Pass, Detail: "SHA-1"})
+	} else {
+		add("nsec3 hash", "hash", Finding{Status: Fail, Code: "unknown", Detail: hash,
+			Hint: "SHA-1 is the only NSEC3 hash defined; validators cannot check any other and treat the zone as insecure", Remediation: recommended})
+	}
+
+	iter := fmt.Sprintf("%d additional iteration(s)", d.Iterations)
+	switch {
+	case d.Iterations == 0:
+		add("nsec3 iterations", "iterations", Finding{Status: Pass, Detail: "0"})
+	case d.Iterations > nsec3IterationLimit:
+		add("nsec3 iterations", "iterations", Finding{Status: Fail, Code: "excessive", Detail: iter,
+			Hint: fmt.Sprintf("above %d, validators commonly treat the zone as insecure or answer SERVFAIL; set iterations to 0 (RFC 9276)", nsec3IterationLimit), Remediation: recommended})
+	default:
+		add("nsec3 iterations", "iterations", Finding{Status: Warn, Code: "nonzero", Detail: iter,
+			Hint: "extra iterations cost every resolver CPU without slowing down offline guessing much; set them to 0 (RFC 9276)", Remediation: recommended})
+	}
+
+	if d.Salt == "" || d.Salt == "-" {
+		add("nsec3 salt", "salt", Finding{Status: Pass, Detail: "none"})
+	} else {
+		add("nsec3 salt", "salt", Finding{Status: Warn, Code: "salted", Detail: fmt.Sprintf("%d byte(s): %s", len(d.Salt)/2, strings.ToLower(d.Salt)),
+			Hint: "a salt only helps if it is changed often, which resigns the whole zone; use none (RFC 9276)", Remediation: recommended})
+	}
+
+	if d.OptOut {
+		add("nsec3 opt-out", "optout", Finding{Status: Warn, Code: "enabled", Detail: "set: unsigned delegations are not covered",
+			Hint: "opt-out lets an attacker add unsigned delegations undetected; RFC 9276 advises it only for very large zones of mostly unsigned delegations", Remediation: recommended})
+	} else {
+		add("nsec3 opt-out", "optout", Finding{Status: Pass, Detail: "not set"})
+	}
+
+	salt := d.Salt
+	if salt == "" {
+		salt = "-"
+	}
+	switch p := d.Param; {
+	case p == nil:
+		add("nsec3param", "param", Finding{Status: Warn, Code: "missing", Detail: "the apex has no NSEC3PARAM",
+			Hint: "authoritative servers use NSEC3PARAM to pick the NSEC3 chain; the zone may be mid-way through a change of denial method",
+			Remediation: &dnsprobe.Remediation{Action: "resign-zone", Records: param,
+				Suggested: map[string]string{"nsec3param": fmt.Sprintf("%d 0 %d %s", d.Hash, d.Iterations, salt)}}})
+	case p.Hash != d.Hash || p.Iterations != d.Iterations || !strings.EqualFold(p.Salt, d.Salt):
+		add("nsec3param", "param", Finding{Status: Warn, Code: "mismatch", Detail: fmt.Sprintf("NSEC3PARAM has %d iteration(s), salt %s; proofs use %d, salt %s", p.Iterations, p.Salt, d.Iterations, d.Salt),
+			Hint:        "NSEC3PARAM and the chain in use disagree, as while a new chain is being built; check again once the signer is done",
+			Remediation: &dnsprobe.Remediation{Action: "rerun-check", Records: param}})
+	default:
+		add("nsec3param", "param", Finding{Status: Pass, Detail: "matches the chain in use"})
+	}
+	return out, nil
+}
+
+func exposed(names []string) string {
+	if len(names) == 0 {
+		return "no names"
+	}
+	if len(names) > 5 {
+		return strings.Join(names[:5], " ") + fmt.Sprintf(" and %d more", len(names)-5)
+	}
+	return strings.Join(names, " ")
+}
@@ -0,0 +1,141 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"dnsdoc/internal/dnsprobe"
+
+	"github.com/miekg/dns"
+)
+
+// Priming sends server, a resolver or a root server, a priming query and
+// checks the reply against hints, root server names to addresses: every
+// server named, with both addresses, in a reply that fits the buffer.
+// Codes are "priming.<check>.<reason>".
+func Priming(ctx context.Context, server string, hints map[string][]string, buffer uint16, dnssec bool, opts dnsprobe.Options) ([]Finding, error) {
+	p, err := dnsprobe.Prime(ctx, server, buffer, dnssec, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Finding
+	add := func(check, code string, f Finding) {
+		f.Check = check
+		if f.Code != "" {
+			f.Code = "priming." + code + "." + f.Code
+		}
+		out = append(out, f)
+	}
+	rootNS := []string{record(".", dns.TypeNS)}
+	buffer1232 := &dnsprobe.Remediation{Action: "set-edns-buffer-size", Records: rootNS, Suggested: map[string]string{"edns-buffer-size": "1232"}}
+	updateHints := &dnsprobe.Remediation{Action: "update-root-hints", Records: rootNS, Suggested: map[string]string{"command": "dnsdoc data update"}}
+
+	reply := Finding{Status: Pass, Took: p.RTT, Detail: fmt.Sprintf("%s, %d byte(s), not authoritative: a cached copy", p.RCode, p.FullSize)}
+	switch {
+	case p.RCode != "NOERROR":
+		reply = Finding{Status: Fail, Code: "rcode", Took: p.RTT, Detail: p.RCode,
+			Hint:        "a resolver that cannot prime cannot resolve anything; check its root hints and that it reaches the root servers",
+			Remediation: &dnsprobe.Remediation{Action: "check-upstream-and-acl", Records: rootNS}}
+	case len(p.NS) == 0:
+		reply = Finding{Status: Fail, Code: "no_ns", Took: p.RTT, Detail: "NOERROR without the root NS set",
+			Hint:        "the server does not answer for the root zone; something on the path may be rewriting replies",
+			Remediation: &dnsprobe.Remediation{Action: "disable-dns-inspection", Records: rootNS}}
+	case p.Authoritative:
+		reply.Detail = fmt.Sprintf("%s, %d byte(s), authoritative", p.RCode, p.FullSize)
+	}
+	add("reply", "reply", reply)
+	if reply.Status == Fail {
+		return out, nil
+	}
+
+	var names []string
+	for n := range hints {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	var missing, extra []string
+	for _, n := range names {
+		if !slices.Contains(p.NS, n) {
+			missing = append(missing, n)
+		}
+	}
+	for _, n := range p.NS {
+		if _, ok := hints[n]; !ok {
+			extra = append(extra, n)
+		}
+	}
+	switch {
+	case len(missing) > 0:
+		add("root servers", "letters", Finding{Status: Fail, Code: "missing", Detail: fmt.Sprintf("%d of %d named; missing %s", len(names)-len(missing), len(names), strings.Join(missing, ", ")),
+			Hint: "a resolver primed with this reply spreads its load over fewer root servers and loses them all if those fail; the reply was probably cut short to fit a small buffer", Remediation: buffer1232})
+	case len(extra) > 0:
+		add("root servers", "letters", Finding{Status: Warn, Code: "unknown", Detail: "not in the root hints: " + strings.Join(extra, ", "),
+			Hint: "the root hints may be out of date (dnsdoc data update), or the server serves its own root zone", Remediation: updateHints})
+	default:
+		add("root servers", "letters", Finding{Status: Pass, Detail: fmt.Sprintf("all %d named", len(p.NS))})
+	}
+
+	var noGlue, partial, stale []string
+	for _, n := range p.NS {
+		glue := p.Glue[n]
+		var v4, v6 bool
+		for _, a := range glue {
+			ip := net.ParseIP(a)
+			v4, v6 = v4 || ip.To4() != nil, v6 || ip.To4() == nil
+			if want, ok := hints[n]; ok && !slices.Contains(want, a) {
+				stale = append(stale, n+" "+a)
+			}
+		}
+		switch {
+		case len(glue) == 0:
+			noGlue = append(noGlue, n)
+		case !v4 || !v6:
+			partial = append(partial, n)
+		}
+	}
+	glue := Finding{Status: Pass, Detail: fmt.Sprintf("IPv4 and IPv6 addresses for all %d", len(p.NS))}
+	switch {
+	case len(noGlue) == len(p.NS):
+		glue = Finding{Status: Fail, Code: "none", Detail: "no addresses in the additional section",
+			Hint: "the resolver must look up every root server's address before it can use it; the server may be minimizing responses too aggressively or the buffer is too small", Remediation: buffer1232}
+	case len(noGlue)+len(partial) > 0:
+		glue = Finding{Status: Warn, Code: "incomplete", Detail: fmt.Sprintf("%d without addresses, %d with only one family", len(noGlue), len(partial)),
+			Hint: "resolvers look up the missing addresses on their own; this is usually the reply being cut to fit the buffer", Remediation: buffer1232}
+	case len(stale) > 0:
+		glue = Finding{Status: Warn, Code: "differs", Detail: "differs from the root hints: " + strings.Join(stale, ", "),
+			Hint: "either the hints are out of date (dnsdoc data update) or the reply did not come from the real root servers", Remediation: updateHints}
+	}
+	add("glue", "glue", glue)
+
+	size := Finding{Status: Pass, Detail: fmt.Sprintf("%d byte(s) fit the %d-byte buffer", p.Size, p.Buffer)}
+	switch {
+	case p.Truncated:
+		size = Finding{Status: Warn, Code: "truncated", Detail: fmt.Sprintf("truncated at %d byte(s) for the %d-byte buffer; the full reply is %d over TCP", p.Size, p.Buffer, p.FullSize),
+			Hint:        "priming needs TCP with this buffer size; make sure TCP port 53 is reachable",
+			Remediation: &dnsprobe.Remediation{Action: "allow-dns-port", Records: rootNS, Suggested: map[string]string{"port": "53/tcp"}}}
+	case p.Size > int(p.Buffer):
+		size = Finding{Status: Fail, Code: "oversized", Detail: fmt.Sprintf("%d byte(s) for a %d-byte buffer", p.Size, p.Buffer),
+			Hint:        "the server ignores the advertised buffer size; replies this large fragment or are dropped on many paths",
+			Remediation: &dnsprobe.Remediation{Action: "fix-edns", Records: rootNS, Suggested: map[string]string{"edns-buffer-size": strconv.Itoa(int(p.Buffer))}}}
+	}
+	if p.ServerBuffer > 0 {
+		size.Detail += fmt.Sprintf(" (server advertises %d)", p.ServerBuffer)
+	}
+	add("size", "size", size)
+
+	if p.DNSSEC {
+		sig := Finding{Status: Pass, Detail: "root NS set signed"}
+		if !p.Signed {
+			sig = Finding{Status: Warn, Code: "unsigned", Detail: "DO was set but no RRSIG covers the root NS set",
+				Hint:        "the server or something on the path strips DNSSEC records, so validating clients behind it cannot check the root NS set",
+				Remediation: &dnsprobe.Remediation{Action: "disable-dns-inspection", Records: []string{record(".", dns.TypeRRSIG)}}}
+		}
+		add("signature", "signature", sig)
+	}
+	return out, nil
+}
@@ -0,0 +1,94 @@
+//go:build !lite
+
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+	"dnsdoc/internal/rdap"
+
+	"github.com/miekg/dns"
+)
+
+// The registration check needs RDAP over HTTPS, so lite builds leave it
+// out.
+func init() {
+	checks = append(checks, check{"domain registration", "registration", []string{"udp reachability"}, checkRegistration})
+}
+
+func checkRegistration(ctx context.Context, cfg Config) Finding {
+	rctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	reg, err := rdap.Lookup(rctx, cfg.Domain)
+	if errors.Is(err, rdap.ErrNotFound) {
+		return Finding{Status: Fail, Code: "unregistered", Detail: fmt.Sprintf("the registry has no record of %s", reg.Domain),
+			Hint:        "the domain is not registered (or was deleted after expiring); anyone can register it and take over its names",
+			Remediation: &dnsprobe.Remediation{Action: "register-domain", Records: []string{reg.Domain}}}
+	}
+	if err != nil {
+		return Finding{Status: Warn, Code: "rdap_unavailable", Detail: fmt.Sprintf("RDAP lookup for %s failed: %v", cfg.Domain, err),
+			Hint:        "registration data could not be fetched; check HTTPS access to the registry or pass --no-rdap",
+			Remediation: &dnsprobe.Remediation{Action: "recheck-connectivity", Records: []string{cfg.Domain}, Suggested: map[string]string{"flag": "--no-rdap"}}}
+	}
+
+	// What resolvers see now can differ from what the registry lists, so
+	// both are matched against parking services.
+	nameservers := slices.Clone(reg.Nameservers)
+	if r, _, err := exchange(ctx, cfg, query(reg.Domain, dns.TypeNS, false), false); err == nil {
+		for _, rr := range r.Answer {
+			if ns, ok := rr.(*dns.NS); ok {
+				nameservers = append(nameservers, strings.ToLower(strings.TrimSuffix(ns.Ns, ".")))
+			}
+		}
+	}
+	var parked string
+	for _, ns := range nameservers {
+		for _, p := range ParkedNameservers {
+			if ns == p || strings.HasSuffix(ns, "."+p) {
+				parked = ns
+			}
+		}
+	}
+
+	left := time.Until(reg.Expires)
+	expiry := "no expiry date published"
+	if !reg.Expires.IsZero() {
+		expiry = fmt.Sprintf("expires %s (%d days)", reg.Expires.Format("2006-01-02"), int(left.Hours()/24))
+	}
+	detail := fmt.Sprintf("%s at %s, %s", reg.Domain, orUnknown(reg.Registrar), expiry)
+	renew := &dnsprobe.Remediation{Action: "renew-domain", Records: []string{reg.Domain}, Suggested: map[string]string{"auto-renew": "on"}}
+	if reg.Registrar != "" {
+		renew.Suggested["registrar"] = reg.Registrar
+	}
+
+	switch {
+	case reg.Lapsed() || (!reg.Expires.IsZero() && left <= 0):
+		return Finding{Status: Fail, Code: "expired", Detail: detail + ", " + strings.Join(reg.Statuses, ", "),
+			Hint: "the registration has lapsed; renew it now, before the registry deletes it and resolution stops", Remediation: renew}
+	case parked != "":
+		return Finding{Status: Fail, Code: "parked", Detail: fmt.Sprintf("%s, delegated to parking name server %s", detail, parked),
+			Hint:        "the domain points at a parking service, usually after it lapsed or changed hands; restore the intended name servers at the registrar",
+			Remediation: &dnsprobe.Remediation{Action: "restore-nameservers", Records: []string{reg.Domain + " NS"}}}
+	case !reg.Expires.IsZero() && left < cfg.ExpiryWarn:
+		return Finding{Status: Warn, Code: "expiring_soon", Detail: detail,
+			Hint: "the domain expires soon; renew it and enable auto-renew, as expiry takes every name under it offline", Remediation: renew}
+	case !reg.TransferLocked():
+		return Finding{Status: Warn, Code: "unlocked", Detail: detail + ", no transfer lock",
+			Hint:        "without a transfer lock the domain can be moved away by anyone who compromises the registrar account; enable the registrar lock",
+			Remediation: &dnsprobe.Remediation{Action: "enable-registrar-lock", Records: []string{reg.Domain}, Suggested: map[string]string{"status": "clientTransferProhibited"}}}
+	}
+	return Finding{Status: Pass, Detail: detail + ", transfer locked"}
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown registrar"
+	}
+	return s
+}
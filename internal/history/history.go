@@ -0,0 +1,98 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+)
+
+// Record is one stored probe outcome. The store is an append-only JSON Lines
+// file so that separate invocations (cron, long-running recorders) can add to
+// it without coordination.
+type Record struct {
+	Time    time.Time        `json:"time"`
+	Server  string           `json:"server"`
+	Domain  string           `json:"domain"`
+	QType   string           `json:"qtype"`
+	RCode   string           `json:"rcode,omitempty"`
+	Error   string           `json:"error,omitempty"`
+	Timings dnsprobe.Timings `json:"timings"`
+}
+
+func FromResult(at time.Time, r dnsprobe.Result, err error) Record {
+	rec := Record{
+		Time:    at,
+		Server:  r.Server,
+		Domain:  r.QName,
+		QType:   r.QType,
+		RCode:   r.RCode,
+		Timings: r.Timings,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	return rec
+}
+
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dnsdoc", "history.jsonl"), nil
+}
+
+func Append(path string, recs []Record) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range recs {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load streams the store and returns the records keep accepts (all of them if
+// keep is nil). A missing file is an empty history.
+func Load(path string, keep func(Record) bool) ([]Record, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []Record
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var r Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			continue
+		}
+		if keep == nil || keep(r) {
+			out = append(out, r)
+		}
+	}
+	return out, sc.Err()
+}
@@ -0,0 +1,91 @@
+package latency
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+	"dnsdoc/internal/history"
+)
+
+// Baseline stands in for server B with past measurements of server A taken
+// from the history file: each name is compared against the per-phase median
+// of its stored probes.
+type Baseline struct {
+	byName map[string][]history.Record
+}
+
+// ErrNoBaseline is the error of a baseline outcome for a name that has no
+// stored probes at all, as opposed to stored probes that failed.
+var ErrNoBaseline = errors.New("no stored probes in the baseline window")
+
+// NewBaseline groups recs by domain; they should already be limited to the
+// server, query type and time window being compared against.
+func NewBaseline(recs []history.Record) *Baseline {
+	b := &Baseline{byName: map[string][]history.Record{}}
+	for _, r := range recs {
+		k := baselineKey(r.Domain)
+		b.byName[k] = append(b.byName[k], r)
+	}
+	return b
+}
+
+func baselineKey(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// Outcome summarizes the stored probes of name: the median of every timing
+// phase over the successful ones, and their most common rcode.
+func (b *Baseline) Outcome(name string) Outcome {
+	o := Outcome{Name: name}
+	recs := b.byName[baselineKey(name)]
+	var ok []history.Record
+	rcodes := map[string]int{}
+	for _, r := range recs {
+		if r.Error == "" {
+			ok = append(ok, r)
+			rcodes[r.RCode]++
+		}
+	}
+	switch {
+	case len(recs) == 0:
+		o.Err = fmt.Errorf("%s: %w", name, ErrNoBaseline)
+		return o
+	case len(ok) == 0:
+		o.Err = fmt.Errorf("all %d stored probes of %s failed, last with: %s", len(recs), name, recs[len(recs)-1].Error)
+		return o
+	}
+
+	o.Result = dnsprobe.Result{
+		Server: ok[0].Server,
+		QName:  name,
+		QType:  ok[0].QType,
+	}
+	for rc, n := range rcodes {
+		if n > rcodes[o.Result.RCode] || n == rcodes[o.Result.RCode] && rc < o.Result.RCode {
+			o.Result.RCode = rc
+		}
+	}
+	phase := func(f func(dnsprobe.Timings) time.Duration) time.Duration {
+		ds := make([]time.Duration, len(ok))
+		for i, r := range ok {
+			ds[i] = f(r.Timings)
+		}
+		slices.Sort(ds)
+		return ds[len(ds)/2]
+	}
+	o.Result.Timings = dnsprobe.Timings{
+		Total:     phase(func(t dnsprobe.Timings) time.Duration { return t.Total }),
+		Dial:      phase(func(t dnsprobe.Timings) time.Duration { return t.Dial }),
+		Proxy:     phase(func(t dnsprobe.Timings) time.Duration { return t.Proxy }),
+		Pack:      phase(func(t dnsprobe.Timings) time.Duration { return t.Pack }),
+		Write:     phase(func(t dnsprobe.Timings) time.Duration { return t.Write }),
+		Read:      phase(func(t dnsprobe.Timings) time.Duration { return t.Read }),
+		Unpack:    phase(func(t dnsprobe.Timings) time.Duration { return t.Unpack }),
+		RTTApprox: phase(func(t dnsprobe.Timings) time.Duration { return t.RTTApprox }),
+	}
+	return o
+}
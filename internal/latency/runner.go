@@ -0,0 +1,246 @@
+package latency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"dnsdoc/internal/dnsprobe"
+)
+
+// Target is a server to measure and the options to reach it with.
+type Target struct {
+	Label  string
+	Server string
+	Opts   dnsprobe.Options
+}
+
+// Outcome is one name's first probe. Search lists the candidates tried when
+// the runner applies a search list.
+type Outcome struct {
+	Name   string
+	Result dnsprobe.Result
+	Err    error
+	Search []dnsprobe.SearchAttempt
+}
+
+// Bench is one benchmark of a name: N serial requests, or N concurrent
+// ones; with a Duration, serial requests or rounds of N concurrent ones
+// for that long. Interrupted is set when the run was cancelled before the
+// benchmark finished; Result then covers the queries answered until then.
+type Bench struct {
+	Concurrent  bool
+	N           int
+	Duration    time.Duration
+	Interrupted bool
+	Result      dnsprobe.Benchmark
+}
+
+// Label names the benchmark the way the latency report does.
+func (b Bench) Label() string {
+	var l string
+	switch {
+	case b.Concurrent && b.Duration > 0:
+		l = fmt.Sprintf("brute (concurrent x%d for %s", b.N, b.Duration)
+	case b.Concurrent:
+		l = fmt.Sprintf("brute (concurrent x%d", b.N)
+	case b.Duration > 0:
+		l = fmt.Sprintf("bench (serial for %s", b.Duration)
+	default:
+		l = fmt.Sprintf("bench (serial x%d", b.N)
+	}
+	if b.Interrupted {
+		l += ", interrupted"
+	}
+	return l + ")"
+}
+
+// Reporter receives results as the runner produces them, name by name. In a
+// single-server run the B arguments are nil, as is B's benchmark when A's
+// was cut short, since B's would not run at all.
+type Reporter interface {
+	Probe(a, b *Outcome)
+	Stub(name string, wire, stub dnsprobe.Lookup)
+	Benchmark(a, b *Bench)
+}
+
+// Runner is the latency command's measurement flow, for other commands to
+// run without cobra. It is internal to the CLI, as dnsprobe is, and not a
+// library API. It measures Names against A, or against A and B when
+// B.Server is set, or against A's own past when Baseline is set;
+// benchmarks then run on A only.
+// Serial and Brute are the number of serial and concurrent benchmark
+// requests per name (0 skips them), and Uncached sends each of them to a
+// fresh random subdomain so they measure recursion rather than the cache;
+// Retries resends a benchmark request that timed out up to that many times,
+// which tells packet loss apart from failure; Robust keeps every benchmark
+// reply's timings for dnsprobe.Benchmark.Robust; Duration, when set, runs
+// every benchmark for that long instead, with results in Bucket-wide slices
+// of time; Stub also resolves single-server names through the OS stub;
+// Search, when set, expands unqualified names like applications do and
+// benchmarks the name the search ended on. Skipped lists the names a run
+// that ended early did not get to.
+type Runner struct {
+	A, B     Target
+	Names    []string
+	Serial   int
+	Brute    int
+	Uncached bool
+	Retries  int
+	Robust   bool
+	Duration time.Duration
+	Bucket   time.Duration
+	Stub     bool
+	Search   *dnsprobe.SearchConfig
+	Baseline *Baseline
+	Reporter Reporter
+	Skipped  []string
+}
+
+// Run measures every name in turn and returns early only when ctx ends,
+// leaving the names it did not measure in Skipped. A benchmark cut short is
+// still reported, marked Interrupted; a probe cut short is not, and its name
+// counts as skipped.
+func (r *Runner) Run(ctx context.Context) error {
+	r.Skipped = nil
+	for i, name := range r.Names {
+		measured := false
+		if ctx.Err() == nil {
+			if r.B.Server == "" && r.Baseline == nil {
+				measured = r.single(ctx, name)
+			} else {
+				measured = r.compare(ctx, name)
+			}
+		}
+		if !measured {
+			r.Skipped = r.Names[i:]
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// single measures name on A; it returns false when ctx ended before the
+// probe was answered.
+func (r *Runner) single(ctx context.Context, name string) bool {
+	o := r.probe(ctx, r.A, name)
+	if ctx.Err() != nil {
+		return false
+	}
+	r.Reporter.Probe(&o, nil)
+	if r.Stub && ctx.Err() == nil {
+		wire := dnsprobe.LookupWireDual(ctx, r.A.Server, name, r.A.Opts)
+		stub := dnsprobe.LookupStub(ctx, name, r.A.Opts.Timeout)
+		r.Reporter.Stub(name, wire, stub)
+	}
+	name = r.queried(o)
+	if r.Serial > 0 {
+		b := r.serial(ctx, r.A, name)
+		r.Reporter.Benchmark(&b, nil)
+	}
+	if r.Brute > 0 && ctx.Err() == nil {
+		b := r.brute(ctx, r.A, name)
+		r.Reporter.Benchmark(&b, nil)
+	}
+	return true
+}
+
+func (r *Runner) compare(ctx context.Context, name string) bool {
+	if r.Baseline != nil {
+		return r.compareBaseline(ctx, name)
+	}
+	oA, oB := r.probe(ctx, r.A, name), r.probe(ctx, r.B, name)
+	if ctx.Err() != nil {
+		return false
+	}
+	r.Reporter.Probe(&oA, &oB)
+	nameA, nameB := r.queried(oA), r.queried(oB)
+	if r.Serial > 0 {
+		r.benchBoth(ctx, r.serial, nameA, nameB)
+	}
+	if r.Brute > 0 && ctx.Err() == nil {
+		r.benchBoth(ctx, r.brute, nameA, nameB)
+	}
+	return true
+}
+
+// benchBoth runs bench on A and then B, unless A's was cut short.
+func (r *Runner) benchBoth(ctx context.Context, bench func(context.Context, Target, string) Bench, nameA, nameB string) {
+	bA := bench(ctx, r.A, nameA)
+	if bA.Interrupted {
+		r.Reporter.Benchmark(&bA, nil)
+		return
+	}
+	bB := bench(ctx, r.B, nameB)
+	r.Reporter.Benchmark(&bA, &bB)
+}
+
+// compareBaseline compares name's probe with its stored history. History
+// holds single probes only, so benchmarks are reported for A alone.
+func (r *Runner) compareBaseline(ctx context.Context, name string) bool {
+	oA := r.probe(ctx, r.A, name)
+	if ctx.Err() != nil {
+		return false
+	}
+	name = r.queried(oA)
+	oB := r.Baseline.Outcome(name)
+	r.Reporter.Probe(&oA, &oB)
+	if r.Serial > 0 {
+		b := r.serial(ctx, r.A, name)
+		r.Reporter.Benchmark(&b, nil)
+	}
+	if r.Brute > 0 && ctx.Err() == nil {
+		b := r.brute(ctx, r.A, name)
+		r.Reporter.Benchmark(&b, nil)
+	}
+	return true
+}
+
+func (r *Runner) serial(ctx context.Context, t Target, name string) Bench {
+	b := Bench{N: r.Serial, Duration: r.Duration}
+	if r.Duration > 0 {
+		b.Result = dnsprobe.BenchmarkSerialFor(ctx, t.Server, name, r.benchOpts(t), r.Duration, r.Bucket)
+	} else {
+		b.Result = dnsprobe.BenchmarkSerial(ctx, t.Server, name, r.benchOpts(t), r.Serial)
+	}
+	b.Interrupted = ctx.Err() != nil
+	return b
+}
+
+func (r *Runner) brute(ctx context.Context, t Target, name string) Bench {
+	b := Bench{Concurrent: true, N: r.Brute, Duration: r.Duration}
+	if r.Duration > 0 {
+		b.Result = dnsprobe.BenchmarkConcurrentFor(ctx, t.Server, name, r.benchOpts(t), r.Brute, r.Duration, r.Bucket)
+	} else {
+		b.Result = dnsprobe.BenchmarkConcurrent(ctx, t.Server, name, r.benchOpts(t), r.Brute)
+	}
+	b.Interrupted = ctx.Err() != nil
+	return b
+}
+
+func (r *Runner) benchOpts(t Target) dnsprobe.Options {
+	opts := t.Opts
+	opts.Uncached = r.Uncached
+	opts.Retries = r.Retries
+	opts.KeepTimings = r.Robust
+	return opts
+}
+
+func (r *Runner) probe(ctx context.Context, t Target, name string) Outcome {
+	o := Outcome{Name: name}
+	if r.Search == nil {
+		o.Result, o.Err = dnsprobe.Probe(ctx, t.Server, name, t.Opts)
+		return o
+	}
+	o.Result, o.Search, o.Err = dnsprobe.ProbeSearch(ctx, t.Server, name, *r.Search, t.Opts)
+	return o
+}
+
+// queried is the name follow-up benchmarks use: with a search list it is
+// the candidate the search ended on.
+func (r *Runner) queried(o Outcome) string {
+	if r.Search != nil && o.Result.QName != "" {
+		return o.Result.QName
+	}
+	return o.Name
+}
@@ -0,0 +1,161 @@
+package rdap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// BootstrapURL is the IANA registry mapping TLDs to RDAP servers (RFC 9224).
+var BootstrapURL = "https://data.iana.org/rdap/dns.json"
+
+// ErrNotFound means the registry has no such domain: it is not registered.
+var ErrNotFound = errors.New("domain not found in registry")
+
+// Registration is what the registry publishes about a domain.
+type Registration struct {
+	Domain      string
+	Registrar   string
+	Expires     time.Time // zero if not published
+	Statuses    []string  // EPP statuses in RDAP form, e.g. "client transfer prohibited"
+	Nameservers []string
+}
+
+// TransferLocked reports whether a registrar or registry transfer lock is set.
+func (r Registration) TransferLocked() bool {
+	return slices.Contains(r.Statuses, "client transfer prohibited") || slices.Contains(r.Statuses, "server transfer prohibited")
+}
+
+// Lapsed reports whether the registry already treats the domain as expired.
+func (r Registration) Lapsed() bool {
+	return slices.Contains(r.Statuses, "redemption period") || slices.Contains(r.Statuses, "pending delete")
+}
+
+// Lookup fetches the registration of the registrable domain that name belongs
+// to, finding the registry's RDAP server through the IANA bootstrap file.
+func Lookup(ctx context.Context, name string) (Registration, error) {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimSuffix(strings.ToLower(name), "."))
+	if err != nil {
+		return Registration{}, err
+	}
+	reg := Registration{Domain: domain}
+
+	base, err := serverFor(ctx, domain[strings.LastIndex(domain, ".")+1:])
+	if err != nil {
+		return reg, err
+	}
+
+	var doc struct {
+		Events []struct {
+			Action string `json:"eventAction"`
+			Date   string `json:"eventDate"`
+		} `json:"events"`
+		Status   []string `json:"status"`
+		Entities []struct {
+			Roles      []string          `json:"roles"`
+			VCardArray []json.RawMessage `json:"vcardArray"`
+		} `json:"entities"`
+		Nameservers []struct {
+			Name string `json:"ldhName"`
+		} `json:"nameservers"`
+	}
+	if err := getJSON(ctx, strings.TrimSuffix(base, "/")+"/domain/"+domain, &doc); err != nil {
+		var se *statusError
+		if errors.As(err, &se) && se.code == http.StatusNotFound {
+			return reg, ErrNotFound
+		}
+		return reg, err
+	}
+
+	for _, e := range doc.Events {
+		if e.Action == "expiration" {
+			reg.Expires, _ = time.Parse(time.RFC3339, e.Date)
+		}
+	}
+	for _, s := range doc.Status {
+		reg.Statuses = append(reg.Statuses, strings.ToLower(s))
+	}
+	for _, ns := range doc.Nameservers {
+		reg.Nameservers = append(reg.Nameservers, strings.ToLower(ns.Name))
+	}
+	for _, e := range doc.Entities {
+		if slices.Contains(e.Roles, "registrar") {
+			reg.Registrar = vcardName(e.VCardArray)
+		}
+	}
+	return reg, nil
+}
+
+// serverFor returns the RDAP base URL for tld from the bootstrap registry.
+func serverFor(ctx context.Context, tld string) (string, error) {
+	var boot struct {
+		Services [][][]string `json:"services"`
+	}
+	if err := getJSON(ctx, BootstrapURL, &boot); err != nil {
+		return "", fmt.Errorf("rdap bootstrap: %w", err)
+	}
+	for _, svc := range boot.Services {
+		if len(svc) == 2 && slices.Contains(svc[0], tld) && len(svc[1]) > 0 {
+			// Prefer https when the registry lists both.
+			for _, u := range svc[1] {
+				if strings.HasPrefix(u, "https://") {
+					return u, nil
+				}
+			}
+			return svc[1][0], nil
+		}
+	}
+	return "", fmt.Errorf("no RDAP server for .%s", tld)
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/rdap+json, application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{url: url, status: resp.Status, code: resp.StatusCode}
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+type statusError struct {
+	url    string
+	status string
+	code   int
+}
+
+func (e *statusError) Error() string { return e.url + ": " + e.status }
+
+// vcardName returns the "fn" property of a jCard (RFC 7095):
+// ["vcard", [["fn", {}, "text", "Example Registrar"], ...]].
+func vcardName(card []json.RawMessage) string {
+	if len(card) < 2 {
+		return ""
+	}
+	var props [][]any
+	if json.Unmarshal(card[1], &props) != nil {
+		return ""
+	}
+	for _, p := range props {
+		if len(p) >= 4 && p[0] == "fn" {
+			if s, ok := p[3].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}